@@ -2,11 +2,15 @@ package commands
 
 import (
 	"fmt"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"terminusai/internal/agent"
 	"terminusai/internal/common"
 	"terminusai/internal/config"
+	"terminusai/internal/policy"
 
 	"github.com/spf13/cobra"
 )
@@ -61,6 +65,48 @@ func configShow(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Max Tokens:    (use model limit)\n")
 	}
 
+	if cfg.MaxObservationBytes > 0 {
+		fmt.Printf("Max Output:    %d bytes\n", cfg.MaxObservationBytes)
+	} else {
+		fmt.Printf("Max Output:    (per-action defaults)\n")
+	}
+
+	if cfg.MaxOpenFiles > 0 {
+		fmt.Printf("Open Files:    %d\n", cfg.MaxOpenFiles)
+	}
+	if cfg.MaxFileChanges > 0 {
+		fmt.Printf("File Changes:  %d per run\n", cfg.MaxFileChanges)
+	}
+	if cfg.MaxConcurrentTasks > 0 {
+		fmt.Printf("Task Limit:    %d at once\n", cfg.MaxConcurrentTasks)
+	}
+	if cfg.AuditLog != "" {
+		fmt.Printf("Audit Log:     %s\n", cfg.AuditLog)
+	}
+
+	if len(cfg.TrustedDirs) > 0 {
+		fmt.Printf("Trusted Dirs:  %s\n", strings.Join(cfg.TrustedDirs, ", "))
+	}
+	if len(cfg.DangerousCommands) > 0 {
+		fmt.Printf("Dangerous:     %s\n", strings.Join(cfg.DangerousCommands, ", "))
+	}
+	if len(cfg.IgnoreDirs) > 0 {
+		fmt.Printf("Ignore Dirs:   %s\n", strings.Join(cfg.IgnoreDirs, ", "))
+	}
+	if len(cfg.UnignoreDirs) > 0 {
+		fmt.Printf("Unignore Dirs: %s\n", strings.Join(cfg.UnignoreDirs, ", "))
+	}
+
+	fmt.Printf("Model Switch:  %t\n", cfg.AllowModelSwitch)
+	if cfg.ActionInterval != "" {
+		fmt.Printf("Action Pause:  %s\n", cfg.ActionInterval)
+	}
+	fmt.Printf("Persist Shell: %t\n", cfg.PersistentShell)
+	fmt.Printf("Safe Shell:    %t\n", cfg.SafeShell)
+	if cfg.SafeShell && len(cfg.SafeShellAllow) > 0 {
+		fmt.Printf("  Allowed:     %s\n", strings.Join(cfg.SafeShellAllow, ", "))
+	}
+
 	// Show API key status (but not the actual keys)
 	if cfg.OpenAIAPIKey != "" {
 		fmt.Printf("OpenAI API:    configured\n")
@@ -91,14 +137,25 @@ func newConfigSetCommand() *cobra.Command {
 		Long: `Set a configuration value that will be used as default for future commands.
 
 Available keys:
-  provider        Set default LLM provider (openai|anthropic|copilot)
+  provider        Set default LLM provider (openai|anthropic|copilot|ollama)
   model          Set default model ID
   always-allow   Set always-allow mode (true|false)
+  trusted-dirs   Comma-separated directories where file changes are approved automatically
+  safe-shell     Vet shell commands and refuse dangerous shapes (true|false)
+  safe-shell-allow  Comma-separated safe-shell rules to permit anyway
+                 (pipe-to-shell, device-redirect, background)
+  allow-model-switch  Let the agent change model/temperature mid-session (true|false)
+  ignore-dirs    Comma-separated directory names to skip in searches
+  unignore-dirs  Comma-separated default skipped directories to search anyway
+                 (node_modules, .git, .venv, __pycache__, dist, build, target, coverage)
 
 Examples:
   terminusai config set provider anthropic
   terminusai config set model claude-3-sonnet-20240229
-  terminusai config set always-allow true`,
+  terminusai config set always-allow true
+  terminusai config set safe-shell true
+  terminusai config set safe-shell-allow background
+  terminusai config set unignore-dirs build,coverage`,
 		Args: cobra.ExactArgs(2),
 		RunE: configSet,
 	}
@@ -140,8 +197,8 @@ func configSet(cmd *cobra.Command, args []string) error {
 
 	switch key {
 	case "provider":
-		if value != common.ProviderOpenAI && value != common.ProviderAnthropic && value != common.ProviderCopilot {
-			return fmt.Errorf("invalid provider: %s (must be openai, anthropic, or copilot)", value)
+		if value != common.ProviderOpenAI && value != common.ProviderAnthropic && value != common.ProviderCopilot && value != common.ProviderOllama {
+			return fmt.Errorf("invalid provider: %s (must be openai, anthropic, copilot, or ollama)", value)
 		}
 		cfg.Provider = value
 	case "model":
@@ -161,6 +218,121 @@ func configSet(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("max-tokens must be 0 or positive (0 = use model limit)")
 		}
 		cfg.MaxTokensPerRequest = intValue
+	case "max-observation-bytes":
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer value for max-observation-bytes: %s (must be a number)", value)
+		}
+		if intValue < 0 {
+			return fmt.Errorf("max-observation-bytes must be 0 or positive (0 = use per-action defaults)")
+		}
+		cfg.MaxObservationBytes = intValue
+	case "max-open-files":
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer value for max-open-files: %s (must be a number)", value)
+		}
+		if intValue < 0 {
+			return fmt.Errorf("max-open-files must be 0 or positive (0 = default of 32)")
+		}
+		cfg.MaxOpenFiles = intValue
+	case "templates-dir":
+		cfg.TemplatesDir = value
+	case "observation-summary-bytes":
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer value for observation-summary-bytes: %s (must be a number)", value)
+		}
+		cfg.ObservationSummaryBytes = intValue
+	case "max-file-changes":
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer value for max-file-changes: %s (must be a number)", value)
+		}
+		if intValue < 0 {
+			return fmt.Errorf("max-file-changes must be 0 or positive (0 = unlimited)")
+		}
+		cfg.MaxFileChanges = intValue
+	case "max-concurrent-tasks":
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer value for max-concurrent-tasks: %s (must be a number)", value)
+		}
+		if intValue < 0 {
+			return fmt.Errorf("max-concurrent-tasks must be 0 or positive (0 = unlimited)")
+		}
+		cfg.MaxConcurrentTasks = intValue
+	case "audit-log":
+		cfg.AuditLog = value
+	case "audit-log-max-bytes":
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer value for audit-log-max-bytes: %s (must be a number)", value)
+		}
+		if intValue < 0 {
+			return fmt.Errorf("audit-log-max-bytes must be 0 or positive (0 = default of 10MB)")
+		}
+		cfg.AuditLogMaxBytes = intValue
+	case "safe-shell":
+		boolValue, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean value for safe-shell: %s (must be true or false)", value)
+		}
+		cfg.SafeShell = boolValue
+	case "safe-shell-allow":
+		rules := splitList(strings.ToLower(value))
+		if err := policy.ValidateShellRuleNames(rules); err != nil {
+			return err
+		}
+		cfg.SafeShellAllow = rules
+	case "allow-model-switch":
+		boolValue, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean value for allow-model-switch: %s (must be true or false)", value)
+		}
+		cfg.AllowModelSwitch = boolValue
+	case "action-interval":
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < 0 {
+			return fmt.Errorf("invalid duration for action-interval: %s (e.g. 500ms, 2s; 0 disables)", value)
+		}
+		cfg.ActionInterval = ""
+		if interval > 0 {
+			cfg.ActionInterval = interval.String()
+		}
+	case "persistent-shell":
+		boolValue, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean value for persistent-shell: %s (must be true or false)", value)
+		}
+		cfg.PersistentShell = boolValue
+	case "correction-template":
+		if value != "" {
+			if _, err := agent.ParseCorrectionTemplate(value); err != nil {
+				return fmt.Errorf("invalid correction-template: %w", err)
+			}
+		}
+		cfg.CorrectionTemplate = value
+	case "trusted-dirs":
+		var dirs []string
+		for _, dir := range splitList(value) {
+			abs, err := filepath.Abs(dir)
+			if err != nil {
+				return fmt.Errorf("invalid trusted directory %s: %w", dir, err)
+			}
+			dirs = append(dirs, abs)
+		}
+		cfg.TrustedDirs = dirs
+	case "dangerous-commands":
+		patterns := splitList(value)
+		if err := policy.ValidateDangerousCommands(patterns); err != nil {
+			return err
+		}
+		cfg.DangerousCommands = patterns
+	case "ignore-dirs":
+		cfg.IgnoreDirs = splitList(value)
+	case "unignore-dirs":
+		cfg.UnignoreDirs = splitList(value)
 	default:
 		return fmt.Errorf("unknown configuration key: %s", key)
 	}
@@ -195,6 +367,46 @@ func configGet(cmd *cobra.Command, args []string) error {
 		fmt.Println(cfg.AlwaysAllow)
 	case "max-tokens", "max-tokens-per-request":
 		fmt.Println(cfg.MaxTokensPerRequest)
+	case "max-observation-bytes":
+		fmt.Println(cfg.MaxObservationBytes)
+	case "max-open-files":
+		fmt.Println(cfg.MaxOpenFiles)
+	case "observation-summary-bytes":
+		fmt.Println(cfg.ObservationSummaryBytes)
+	case "max-file-changes":
+		fmt.Println(cfg.MaxFileChanges)
+	case "max-concurrent-tasks":
+		fmt.Println(cfg.MaxConcurrentTasks)
+	case "audit-log":
+		fmt.Println(cfg.AuditLog)
+	case "audit-log-max-bytes":
+		fmt.Println(cfg.AuditLogMaxBytes)
+	case "templates-dir":
+		fmt.Println(cfg.TemplatesDir)
+	case "safe-shell":
+		fmt.Println(cfg.SafeShell)
+	case "safe-shell-allow":
+		fmt.Println(strings.Join(cfg.SafeShellAllow, ","))
+	case "allow-model-switch":
+		fmt.Println(cfg.AllowModelSwitch)
+	case "action-interval":
+		fmt.Println(cfg.ActionInterval)
+	case "persistent-shell":
+		fmt.Println(cfg.PersistentShell)
+	case "correction-template":
+		if cfg.CorrectionTemplate != "" {
+			fmt.Println(cfg.CorrectionTemplate)
+		} else {
+			fmt.Println(agent.DefaultCorrectionTemplate)
+		}
+	case "trusted-dirs":
+		fmt.Println(strings.Join(cfg.TrustedDirs, ","))
+	case "dangerous-commands":
+		fmt.Println(strings.Join(cfg.DangerousCommands, ","))
+	case "ignore-dirs":
+		fmt.Println(strings.Join(cfg.IgnoreDirs, ","))
+	case "unignore-dirs":
+		fmt.Println(strings.Join(cfg.UnignoreDirs, ","))
 	default:
 		return fmt.Errorf("unknown configuration key: %s", key)
 	}
@@ -205,9 +417,38 @@ func configGet(cmd *cobra.Command, args []string) error {
 // configList lists all configuration keys
 func configList(cmd *cobra.Command, args []string) error {
 	fmt.Println("Available configuration keys:")
-	fmt.Println("  provider        Default LLM provider (openai|anthropic|copilot)")
+	fmt.Println("  provider        Default LLM provider (openai|anthropic|copilot|ollama)")
 	fmt.Println("  model          Default model ID")
 	fmt.Println("  always-allow   Always allow commands without prompting (true|false)")
 	fmt.Println("  max-tokens     Maximum tokens per request (0 = use model limit)")
+	fmt.Println("  max-observation-bytes  Maximum command output bytes sent back to the model (0 = per-action defaults)")
+	fmt.Println("  max-open-files  Files searches and hashing may hold open at once (0 = default of 32)")
+	fmt.Println("  observation-summary-bytes  Summarize larger observations, saving the full text (0 = default of 16000, -1 = never)")
+	fmt.Println("  max-file-changes  File-changing actions per run before asking to allow more (0 = unlimited)")
+	fmt.Println("  max-concurrent-tasks  Tasks 'terminusai serve' runs at once; others wait (0 = unlimited)")
+	fmt.Println("  audit-log      JSONL log of every executed action (empty = ~/.terminusai/audit.log, off = disabled)")
+	fmt.Println("  audit-log-max-bytes  Size at which the audit log is moved to audit.log.1 (0 = default of 10MB)")
+	fmt.Println("  safe-shell     Refuse curl|sh, device redirection and backgrounding in shell commands (true|false)")
+	fmt.Println("  safe-shell-allow  Safe-shell rules to permit anyway (pipe-to-shell,device-redirect,background)")
+	fmt.Println("  allow-model-switch  Let the agent switch model/temperature mid-session (true|false)")
+	fmt.Println("  action-interval  Minimum pause between actions, e.g. 500ms (0 = disabled)")
+	fmt.Println("  persistent-shell  Keep one shell per task so cd and exported variables persist (true|false)")
+	fmt.Println("  templates-dir  Directory of from_template scaffolds (empty = ~/.terminusai/templates)")
+	fmt.Println("  correction-template  Message sent when the model returns an invalid action ({{.Error}}, {{.ActionType}}, {{.Schema}}, {{.Raw}}; empty = built-in)")
+	fmt.Println("  trusted-dirs   Directories where file changes need no approval; shell and network actions still prompt (comma-separated)")
+	fmt.Println("  dangerous-commands  Extra regexes for commands that always need confirmation, even with always-allow (comma-separated)")
+	fmt.Println("  ignore-dirs    Extra directory names skipped by searches (comma-separated)")
+	fmt.Println("  unignore-dirs  Default skipped directories to search anyway (comma-separated)")
 	return nil
 }
+
+// splitList parses a comma-separated config value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"errors"
+
+	"terminusai/internal/agent"
+)
+
+// Process exit codes
+const (
+	ExitOK                  = 0 // Task completed
+	ExitError               = 1 // Any other error (bad flags, config, I/O)
+	ExitPolicyDenied        = 2 // An approval prompt was aborted
+	ExitProviderUnavailable = 3 // Provider kept failing with transient errors
+	ExitProviderFailed      = 4 // Provider rejected the request
+	ExitMaxIterations       = 5 // Agent stopped before finishing the task
+	ExitBudgetExceeded      = 6 // Run used up its token or cost budget
+)
+
+// ExitCode maps an error returned by a command to the process exit code
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, agent.ErrPolicyDenied):
+		return ExitPolicyDenied
+	case errors.Is(err, agent.ErrProviderUnavailable):
+		return ExitProviderUnavailable
+	case errors.Is(err, agent.ErrProviderFailed):
+		return ExitProviderFailed
+	case errors.Is(err, agent.ErrMaxIterations):
+		return ExitMaxIterations
+	case errors.Is(err, agent.ErrBudgetExceeded):
+		return ExitBudgetExceeded
+	default:
+		return ExitError
+	}
+}
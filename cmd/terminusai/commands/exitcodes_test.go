@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"terminusai/internal/agent"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"success", nil, ExitOK},
+		{"generic error", errors.New("boom"), ExitError},
+		{"policy denied", fmt.Errorf("failed to execute task: %w", agent.ErrPolicyDenied), ExitPolicyDenied},
+		{"provider unavailable", fmt.Errorf("failed to execute task: %w: %w", agent.ErrProviderUnavailable, errors.New("503")), ExitProviderUnavailable},
+		{"provider failed", fmt.Errorf("%w: %w", agent.ErrProviderFailed, errors.New("401")), ExitProviderFailed},
+		{"max iterations", agent.ErrMaxIterations, ExitMaxIterations},
+		{"budget exceeded", fmt.Errorf("failed to execute task: %w: 1200 tokens", agent.ErrBudgetExceeded), ExitBudgetExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := ExitCode(tt.err); code != tt.expected {
+				t.Errorf("Expected exit code %d, got %d", tt.expected, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"terminusai/internal/agent"
+	"terminusai/internal/policy"
+
+	"github.com/spf13/cobra"
+)
+
+// NewHistoryCommand creates the history command
+func NewHistoryCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history [session]",
+		Short: "Show or replay the actions recorded for past sessions",
+		Long: `Every action the agent executes is recorded, with its outcome and timing,
+to a JSONL file per session in ~/.terminusai/history.
+
+Without arguments the recorded sessions are listed. Pass a session name (or
+"latest", or a path to a history file) to show its actions. With --replay the
+read-only actions of the session (file reads, searches, hashes, ...) are run
+again and their outcomes compared; mutating actions are only reported.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: historyShow,
+		Example: `  terminusai history
+  terminusai history latest
+  terminusai history 20240101-120000 --replay`,
+	}
+
+	cmd.Flags().Bool("replay", false, "Re-run the read-only actions of the session")
+	cmd.Flags().String("working-dir", "", "Working directory for replayed actions")
+
+	return cmd
+}
+
+func historyShow(cmd *cobra.Command, args []string) error {
+	dir, err := agent.HistoryDir()
+	if err != nil {
+		return fmt.Errorf("failed to locate history directory: %w", err)
+	}
+
+	sessions, err := listHistorySessions(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list history: %w", err)
+	}
+
+	if len(args) == 0 {
+		if len(sessions) == 0 {
+			fmt.Println("No recorded sessions")
+			return nil
+		}
+		for _, session := range sessions {
+			fmt.Println(strings.TrimSuffix(session, ".jsonl"))
+		}
+		return nil
+	}
+
+	path := args[0]
+	switch {
+	case path == "latest":
+		if len(sessions) == 0 {
+			return fmt.Errorf("no recorded sessions")
+		}
+		path = filepath.Join(dir, sessions[len(sessions)-1])
+	case !strings.ContainsAny(path, `/\`):
+		path = filepath.Join(dir, strings.TrimSuffix(path, ".jsonl")+".jsonl")
+	}
+
+	entries, err := agent.LoadHistory(path)
+	if err != nil {
+		return fmt.Errorf("failed to load history: %w", err)
+	}
+
+	cyan.Printf("Session %s (%d actions)\n", strings.TrimSuffix(filepath.Base(path), ".jsonl"), len(entries))
+	fmt.Print(agent.FormatHistory(entries))
+
+	replay, _ := cmd.Flags().GetBool("replay")
+	if !replay {
+		return nil
+	}
+
+	workingDir, _ := cmd.Flags().GetString("working-dir")
+	policyStore, err := policy.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %w", err)
+	}
+
+	fmt.Println()
+	cyan.Printf("Replay\n")
+	replayAgent := agent.NewAgent(nil, policyStore, workingDir, false, false)
+	fmt.Print(replayAgent.ReplayHistory(entries))
+
+	return policyStore.Save()
+}
+
+// listHistorySessions returns the history file names in dir, oldest first
+func listHistorySessions(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".jsonl") {
+			sessions = append(sessions, entry.Name())
+		}
+	}
+	sort.Strings(sessions)
+	return sessions, nil
+}
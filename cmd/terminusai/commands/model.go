@@ -45,14 +45,17 @@ func NewModelListCommand() *cobra.Command {
 		Short: "List available models for GitHub Copilot",
 		Long: `List available models for GitHub Copilot.
 
-This command fetches the current list of available models from the GitHub Copilot API.
-It requires valid Copilot authentication (run 'terminusai setup' first).`,
+This command lists the available models from the GitHub Copilot API. The list is
+cached for 24 hours; use --refresh to fetch it again. It requires valid Copilot
+authentication (run 'terminusai setup' first).`,
 		RunE: listCopilotModels,
 		Example: `  terminusai model list
-  terminusai model list --json`,
+  terminusai model list --json
+  terminusai model list --refresh`,
 	}
 
 	cmd.Flags().Bool("json", false, "Output raw JSON response")
+	cmd.Flags().Bool("refresh", false, "Fetch the models list even if the cache is fresh")
 
 	return cmd
 }
@@ -72,11 +75,16 @@ func modelConfig(cmd *cobra.Command, args []string) error {
 
 func listCopilotModels(cmd *cobra.Command, args []string) error {
 	jsonOutput, _ := cmd.Flags().GetBool("json")
+	refresh, _ := cmd.Flags().GetBool("refresh")
 
 	// Create Copilot provider to access Copilot API
 	provider := providers.NewCopilotProvider("copilot")
 
-	models, err := provider.GetModels()
+	fetch := provider.GetModels
+	if refresh {
+		fetch = provider.RefreshModels
+	}
+	models, err := fetch()
 	if err != nil {
 		return fmt.Errorf("failed to fetch Copilot models: %w", err)
 	}
@@ -1,24 +1,28 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"terminusai/internal/agent"
 	"terminusai/internal/config"
 	"terminusai/internal/policy"
 	"terminusai/internal/providers"
+	"terminusai/internal/ui"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
 var (
-	cyan  = color.New(color.FgCyan)
-	green = color.New(color.FgGreen)
-	red   = color.New(color.FgRed)
-	white = color.New(color.FgWhite)
+	cyan   = color.New(color.FgCyan)
+	green  = color.New(color.FgGreen)
+	red    = color.New(color.FgRed)
+	white  = color.New(color.FgWhite)
+	yellow = color.New(color.FgYellow)
 )
 
 // NewRootCommand creates the root command for TerminusAI
@@ -31,7 +35,7 @@ func NewRootCommand() *cobra.Command {
 plan the necessary commands, and execute them with your approval.
 
 Simply describe what you want to do and TerminusAI will figure out the commands.
-It supports multiple LLM providers (OpenAI, Anthropic, Copilot) and 
+It supports multiple LLM providers (OpenAI, Anthropic, Copilot, Ollama) and 
 includes security features to ensure all commands require explicit user consent.
 
 Examples:
@@ -46,12 +50,19 @@ Examples:
 	}
 
 	// Add flags for direct task execution
-	rootCmd.Flags().String("provider", "", "LLM provider: openai|anthropic|copilot")
+	rootCmd.Flags().String("provider", "", "LLM provider: openai|anthropic|copilot|ollama")
 	rootCmd.Flags().String("model", "", "Model ID override")
 	rootCmd.Flags().String("working-dir", "", "Working directory for operations")
 	rootCmd.Flags().Bool("setup", false, "Run setup wizard before executing")
 	rootCmd.Flags().Bool("verbose", false, "Enable verbose logging")
 	rootCmd.Flags().Bool("debug", false, "Enable maximum debug logging")
+	rootCmd.Flags().Bool("no-history", false, "Don't record executed actions to ~/.terminusai/history")
+	rootCmd.Flags().StringSlice("context", nil, "Files or URLs to give the agent as reference context (comma-separated)")
+	rootCmd.Flags().Bool("json", false, "Print the task outcome (status, result, artifacts, outputs) as JSON on the last line")
+	rootCmd.Flags().String("log-file", "", "Also append everything shown on the terminal, without colors and with timestamps, to this file")
+	rootCmd.Flags().Bool("dry-run", false, "Show the commands and file changes the agent would make without running them; read-only actions still run")
+	rootCmd.Flags().Int("token-budget", 0, "Stop once the provider has reported this many tokens for the whole run (0 = unlimited)")
+	rootCmd.Flags().Float64("max-cost", 0, "Stop once the run's approximate cost in USD passes this, for models with a known price (0 = unlimited)")
 
 	// Disable completion command
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
@@ -61,6 +72,9 @@ Examples:
 		NewSetupCommand(),
 		NewModelCommand(),
 		NewConfigCommand(),
+		NewHistoryCommand(),
+		NewUndoCommand(),
+		NewServeCommand(),
 	)
 
 	return rootCmd
@@ -81,6 +95,21 @@ func handleDirectTask(cmd *cobra.Command, args []string) error {
 	setup, _ := cmd.Flags().GetBool("setup")
 	verbose, _ := cmd.Flags().GetBool("verbose")
 	debug, _ := cmd.Flags().GetBool("debug")
+	noHistory, _ := cmd.Flags().GetBool("no-history")
+	contextSources, _ := cmd.Flags().GetStringSlice("context")
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+	logFile, _ := cmd.Flags().GetString("log-file")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	tokenBudget, _ := cmd.Flags().GetInt("token-budget")
+	maxCost, _ := cmd.Flags().GetFloat64("max-cost")
+
+	if logFile != "" {
+		stopLog, err := ui.TeeOutput(logFile)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
+		defer stopLog()
+	}
 
 	// Get configuration manager
 	cm := config.GetConfigManager()
@@ -102,6 +131,9 @@ func handleDirectTask(cmd *cobra.Command, args []string) error {
 		cm.SetModelOverride(model)
 	}
 
+	cm.SetDryRun(dryRun)
+	cm.SetBudget(tokenBudget, maxCost)
+
 	// Handle setup if needed
 	if setup || cm.GetUserConfig().Provider == "" {
 		userConfig, err := config.SetupWizard(cm.GetUserConfig())
@@ -114,9 +146,7 @@ func handleDirectTask(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	providerName := cm.GetEffectiveProvider()
-
-	llmProvider, err := providers.NewProviderWithConfig(cm, providerName)
+	llmProvider, err := providers.NewFromConfig(cm)
 	if err != nil {
 		return err
 	}
@@ -133,20 +163,99 @@ func handleDirectTask(cmd *cobra.Command, args []string) error {
 	}
 
 	// Run in agent mode
-	err = agent.RunAgentTaskWithWorkingDir(task, llmProvider, policyStore, workingDir, verbose)
-	if err != nil {
-		return fmt.Errorf("failed to execute task: %w", err)
+	taskAgent := newTaskAgent(cm, llmProvider, policyStore, workingDir, verbose, !noHistory)
+	taskAgent.SetContextSources(contextSources)
+	runErr := taskAgent.RunTask(task)
+	if jsonOutput {
+		printTaskResult(taskAgent.Result(), runErr)
+	}
+
+	// Keep "always" decisions made before a failure
+	if err := policyStore.Save(); err != nil && runErr == nil {
+		return err
+	}
+	if runErr != nil {
+		return fmt.Errorf("failed to execute task: %w", runErr)
+	}
+	return nil
+}
+
+// printTaskResult writes the task outcome as one JSON line for scripts. A run
+// that ended without done is reported as a failure with the error as result.
+func printTaskResult(result *agent.TaskResult, runErr error) {
+	if result == nil {
+		result = &agent.TaskResult{Status: "failure", Result: "task ended without a result"}
+		if runErr != nil {
+			result.Result = runErr.Error()
+		}
+	}
+	data, _ := json.Marshal(result)
+	fmt.Println(string(data))
+}
+
+// newTaskAgent creates an agent configured from the user's settings
+func newTaskAgent(cm *config.ConfigManager, llmProvider providers.LLMProvider, policyStore *policy.Store, workingDir string, verbose, history bool) *agent.Agent {
+	userConfig := cm.GetUserConfig()
+	policyStore.SetTrustedDirs(userConfig.TrustedDirs)
+	if err := policyStore.SetDangerousCommands(userConfig.DangerousCommands); err != nil {
+		yellow.Printf("⚠ Ignoring %v\n", err)
+	}
+	taskAgent := agent.NewAgent(llmProvider, policyStore, workingDir, verbose, false)
+	taskAgent.SetMaxObservationBytes(userConfig.MaxObservationBytes)
+	taskAgent.SetAllowModelSwitch(userConfig.AllowModelSwitch)
+	taskAgent.SetIgnoreDirs(userConfig.IgnoreDirs, userConfig.UnignoreDirs)
+	taskAgent.SetShellRules(policy.ShellRules{
+		Enabled: userConfig.SafeShell,
+		Allow:   userConfig.SafeShellAllow,
+	})
+	if interval, err := time.ParseDuration(userConfig.ActionInterval); err == nil {
+		taskAgent.SetActionInterval(interval)
+	}
+	taskAgent.SetPersistentShell(userConfig.PersistentShell)
+	taskAgent.SetMaxOpenFiles(userConfig.MaxOpenFiles)
+	taskAgent.SetObservationSummaryBytes(userConfig.ObservationSummaryBytes)
+	taskAgent.SetMaxFileChanges(userConfig.MaxFileChanges)
+	taskAgent.SetBudget(cm.GetBudget())
+	taskAgent.SetDryRun(cm.IsDryRun())
+	taskAgent.SetTemplatesDir(userConfig.TemplatesDir)
+	if err := taskAgent.SetCorrectionTemplate(userConfig.CorrectionTemplate); err != nil {
+		yellow.Printf("⚠ Ignoring correction-template: %v\n", err)
+	}
+	if history {
+		if historyPath, err := agent.NewHistoryPath(); err == nil {
+			taskAgent.SetHistoryFile(historyPath)
+		}
+	}
+	if journalDir, err := agent.NewJournalDir(); err == nil {
+		taskAgent.SetJournalDir(journalDir)
 	}
+	if auditPath := auditLogPath(userConfig.AuditLog); auditPath != "" {
+		taskAgent.SetAuditLog(auditPath, int64(userConfig.AuditLogMaxBytes))
+	}
+	return taskAgent
+}
 
-	return policyStore.Save()
+// auditLogPath resolves the configured audit log location; "off" disables it
+func auditLogPath(configured string) string {
+	switch {
+	case strings.EqualFold(configured, "off"):
+		return ""
+	case configured != "":
+		return configured
+	}
+	path, err := agent.DefaultAuditPath()
+	if err != nil {
+		return ""
+	}
+	return path
 }
 
-// Execute runs the root command
+// Execute runs the root command and exits with a code describing the failure
 func Execute() {
 	rootCmd := NewRootCommand()
 
 	if err := rootCmd.Execute(); err != nil {
 		red.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(ExitCode(err))
 	}
 }
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"terminusai/internal/agent"
+	"terminusai/internal/config"
+	"terminusai/internal/policy"
+	"terminusai/internal/providers"
+	"terminusai/internal/server"
+
+	"github.com/spf13/cobra"
+)
+
+// NewServeCommand creates the serve command
+func NewServeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run tasks for editors and other tools over HTTP",
+		Long: `Start an HTTP server that runs tasks sent by other programs.
+
+POST /tasks with {"task": "...", "workingDir": "..."} streams the task's events
+as newline-delimited JSON. Approval prompts and questions arrive as "approval"
+and "question" events; answer them with
+POST /tasks/{task}/replies/{prompt} and {"decision": "once"} or {"answer": "..."}.`,
+		Args: cobra.NoArgs,
+		RunE: runServe,
+	}
+
+	cmd.Flags().String("addr", "127.0.0.1:8765", "Address to listen on")
+	cmd.Flags().String("token", "", "Bearer token clients must send (default $TERMINUS_AI_SERVER_TOKEN)")
+	cmd.Flags().Bool("verbose", false, "Enable verbose logging")
+
+	return cmd
+}
+
+// runServe starts the task server
+func runServe(cmd *cobra.Command, args []string) error {
+	addr, _ := cmd.Flags().GetString("addr")
+	token, _ := cmd.Flags().GetString("token")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	if token == "" {
+		token = os.Getenv("TERMINUS_AI_SERVER_TOKEN")
+	}
+
+	cm := config.GetConfigManager()
+	if err := cm.LoadUserConfig(); err != nil {
+		return fmt.Errorf("failed to load user config: %w", err)
+	}
+	cm.SetVerbose(verbose)
+	if cm.GetUserConfig().Provider == "" {
+		return fmt.Errorf("no provider configured, run 'terminusai setup' first")
+	}
+	agent.SetMaxConcurrentTasks(cm.GetUserConfig().MaxConcurrentTasks)
+
+	// Sessions share one set of rules so concurrent sessions don't overwrite
+	// each other's remembered decisions when they save
+	sharedPolicy, err := policy.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %w", err)
+	}
+
+	srv := server.New(func(req server.TaskRequest) (*server.Session, error) {
+		llmProvider, err := providers.NewFromConfig(cm)
+		if err != nil {
+			return nil, err
+		}
+		policyStore := sharedPolicy.Session()
+		return &server.Session{
+			Agent:  newTaskAgent(cm, llmProvider, policyStore, req.WorkingDir, verbose, true),
+			Policy: policyStore,
+			Close:  policyStore.Save,
+		}, nil
+	}, token)
+
+	if token == "" {
+		yellow.Printf("⚠ No --token set: any local process can run commands through this server\n")
+	}
+	green.Printf("TerminusAI server listening on http://%s\n", addr)
+	return http.ListenAndServe(addr, srv.Handler())
+}
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"terminusai/internal/agent"
+
+	"github.com/spf13/cobra"
+)
+
+// NewUndoCommand creates the undo command
+func NewUndoCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "undo [count]",
+		Short: "Revert the last file changes made by the agent",
+		Long: `Before write_file, patch_file, move_path and delete_path change anything, the
+previous state is kept in a per-session journal under ~/.terminusai/trash.
+
+undo reverts the last count (default 1) of those changes in the latest session,
+newest first. Use --session to pick an older session and --list to see what
+can be reverted.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: undoRun,
+		Example: `  terminusai undo
+  terminusai undo 3
+  terminusai undo --list
+  terminusai undo --session 20240101-120000`,
+	}
+
+	cmd.Flags().String("session", "", "Session to revert (default: latest)")
+	cmd.Flags().Bool("list", false, "List the recorded changes instead of reverting them")
+
+	return cmd
+}
+
+func undoRun(cmd *cobra.Command, args []string) error {
+	count := 1
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid count: %s (must be a positive number)", args[0])
+		}
+		count = n
+	}
+
+	trash, err := agent.TrashDir()
+	if err != nil {
+		return fmt.Errorf("failed to locate trash directory: %w", err)
+	}
+
+	session, _ := cmd.Flags().GetString("session")
+	if session == "" {
+		sessions, err := listJournalSessions(trash)
+		if err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+		if len(sessions) == 0 {
+			fmt.Println("Nothing to undo")
+			return nil
+		}
+		session = sessions[len(sessions)-1]
+	}
+	dir := filepath.Join(trash, session)
+
+	if list, _ := cmd.Flags().GetBool("list"); list {
+		entries, err := agent.LoadJournal(dir)
+		if err != nil {
+			return fmt.Errorf("failed to load journal: %w", err)
+		}
+		cyan.Printf("Session %s (%d changes)\n", session, len(entries))
+		for i := len(entries) - 1; i >= 0; i-- {
+			entry := entries[i]
+			fmt.Printf("%3d. [%s] %-12s %s\n", len(entries)-i, entry.Time.Format("15:04:05"), entry.Action, entry.Path)
+		}
+		return nil
+	}
+
+	undone, err := agent.UndoJournal(dir, count)
+	for _, line := range undone {
+		green.Printf("✓ %s\n", line)
+	}
+	if errors.Is(err, agent.ErrNothingToUndo) {
+		fmt.Println("Nothing to undo")
+		return nil
+	}
+	return err
+}
+
+// listJournalSessions returns the session directories in trash that still
+// have changes to undo, oldest first
+func listJournalSessions(trash string) ([]string, error) {
+	entries, err := os.ReadDir(trash)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		journal, err := agent.LoadJournal(filepath.Join(trash, entry.Name()))
+		if err == nil && len(journal) > 0 {
+			sessions = append(sessions, entry.Name())
+		}
+	}
+	sort.Strings(sessions)
+	return sessions, nil
+}
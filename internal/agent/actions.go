@@ -3,8 +3,11 @@ package agent
 import (
 	"encoding/json"
 	"fmt"
+	"path"
 	"regexp"
+	"runtime"
 	"strings"
+	"time"
 )
 
 // AgentAction represents an action that the agent can perform
@@ -13,19 +16,46 @@ type AgentAction struct {
 	Path     string `json:"path,omitempty"`
 	Depth    *int   `json:"depth,omitempty"`
 	MaxBytes *int   `json:"maxBytes,omitempty"`
+	Head     *int   `json:"head,omitempty"`
+	Tail     *int   `json:"tail,omitempty"`
+	FromEnd  *bool  `json:"fromEnd,omitempty"`
+	Lines    *int   `json:"lines,omitempty"`
 	Shell    string `json:"shell,omitempty"`
 	Command  string `json:"command,omitempty"`
 	CWD      string `json:"cwd,omitempty"`
 	Reason   string `json:"reason,omitempty"`
 	Result   string `json:"result,omitempty"`
+	// Done fields; result above stays the human-readable message
+	Status    string                 `json:"status,omitempty"`
+	Summary   string                 `json:"summary,omitempty"`
+	Artifacts []string               `json:"artifacts,omitempty"`
+	Outputs   map[string]interface{} `json:"outputs,omitempty"`
+	// SuccessExitCodes lists the shell exit codes that mean success (default [0])
+	SuccessExitCodes []int `json:"successExitCodes,omitempty"`
+	// OutputMode selects combined (default), separate or stdout-only shell output
+	OutputMode string `json:"output,omitempty"`
 	// Search fields
-	Pattern       string   `json:"pattern,omitempty"`
-	FileTypes     []string `json:"fileTypes,omitempty"`
-	CaseSensitive *bool    `json:"caseSensitive,omitempty"`
-	MaxResults    *int     `json:"maxResults,omitempty"`
+	Pattern        string   `json:"pattern,omitempty"`
+	FileTypes      []string `json:"fileTypes,omitempty"`
+	CaseSensitive  *bool    `json:"caseSensitive,omitempty"`
+	MaxResults     *int     `json:"maxResults,omitempty"`
+	MaxDepth       *int     `json:"maxDepth,omitempty"`
+	FollowSymlinks *bool    `json:"followSymlinks,omitempty"`
+	// RespectGitignore (default true) leaves out paths .gitignore excludes in list_files and search_files
+	RespectGitignore *bool `json:"respectGitignore,omitempty"`
+	// Detailed is shorthand for list_files format "long"
+	Detailed *bool `json:"detailed,omitempty"`
+	// Glob filters list_files by path relative to the listed directory; "**" spans directories
+	Glob string `json:"glob,omitempty"`
+	// StartLine and EndLine select a 1-based inclusive line range of read_file
+	StartLine *int `json:"startLine,omitempty"`
+	EndLine   *int `json:"endLine,omitempty"`
 	// Write file fields
-	Content string `json:"content,omitempty"`
-	Append  *bool  `json:"append,omitempty"`
+	Content string      `json:"content,omitempty"`
+	Append  *bool       `json:"append,omitempty"`
+	Backup  *bool       `json:"backup,omitempty"`
+	Record  interface{} `json:"record,omitempty"`
+	Columns []string    `json:"columns,omitempty"`
 	// Process management fields
 	ProcessID *int `json:"processId,omitempty"`
 	// Network fields
@@ -34,6 +64,13 @@ type AgentAction struct {
 	Headers map[string]string `json:"headers,omitempty"`
 	Body    string            `json:"body,omitempty"`
 	Host    string            `json:"host,omitempty"`
+	// HeadersOnly returns the status and headers without downloading the body
+	HeadersOnly *bool `json:"headersOnly,omitempty"`
+	// FollowRedirects (default true) and MaxRedirects (default 10) control http_request redirects
+	FollowRedirects *bool `json:"followRedirects,omitempty"`
+	MaxRedirects    *int  `json:"maxRedirects,omitempty"`
+	// Resume continues an interrupted download_file from the end of its partial file
+	Resume *bool `json:"resume,omitempty"`
 	// Package management fields
 	Name    string `json:"name,omitempty"`
 	Manager string `json:"manager,omitempty"`
@@ -51,6 +88,13 @@ type AgentAction struct {
 	Overwrite   *bool  `json:"overwrite,omitempty"`
 	Recursive   *bool  `json:"recursive,omitempty"`
 	Parents     *bool  `json:"parents,omitempty"`
+	SoftDelete  *bool  `json:"softDelete,omitempty"`
+	// Undo fields
+	Count *int `json:"count,omitempty"`
+	// Touch fields; RFC 3339, defaults to now
+	Timestamp string `json:"timestamp,omitempty"`
+	// Chown fields: "user", "user:group" or ":group"
+	Owner string `json:"owner,omitempty"`
 	// Patch fields
 	Patch  string `json:"patch,omitempty"`
 	Format string `json:"format,omitempty"`
@@ -60,6 +104,11 @@ type AgentAction struct {
 	Context *int   `json:"context,omitempty"`
 	// Parse fields
 	ParseType string `json:"parseType,omitempty"`
+	// Query is a JSONPath (e.g. "$.items[0].name") selecting part of a parsed document
+	Query string `json:"query,omitempty"`
+	// Delimiter (auto-detected when empty) and MaxRows (default 20) shape csv parsing
+	Delimiter string `json:"delimiter,omitempty"`
+	MaxRows   *int   `json:"maxRows,omitempty"`
 	// Enhanced user interaction fields
 	Rationale   string      `json:"rationale,omitempty"`
 	ActionName  string      `json:"action,omitempty"`
@@ -76,6 +125,8 @@ type AgentAction struct {
 	Algo      string `json:"algo,omitempty"`
 	Checksum  string `json:"checksum,omitempty"`
 	Offset    *int   `json:"offset,omitempty"`
+	Workers   *int   `json:"workers,omitempty"`
+	Focus     string `json:"focus,omitempty"`
 	// Environment fields
 	Key     string `json:"key,omitempty"`
 	Value   string `json:"value,omitempty"`
@@ -86,6 +137,35 @@ type AgentAction struct {
 	Signal string `json:"signal,omitempty"`
 	// Enhanced search/diff fields
 	Regex *bool `json:"regex,omitempty"`
+	// Find fields; ages are durations like "24h" or "7d"
+	Kind      string `json:"kind,omitempty"` // "f" for files, "d" for directories
+	MinSize   *int64 `json:"minSize,omitempty"`
+	MaxSize   *int64 `json:"maxSize,omitempty"`
+	NewerThan string `json:"newerThan,omitempty"`
+	OlderThan string `json:"olderThan,omitempty"`
+	// Snapshot fields
+	Mode     string `json:"mode,omitempty"`
+	Snapshot string `json:"snapshot,omitempty"`
+	// Test runner fields
+	Runner string `json:"runner,omitempty"`
+	// Lint fields
+	Linter string `json:"linter,omitempty"`
+	// Anchor edit fields
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+	// Template fields
+	Template string                 `json:"template,omitempty"`
+	Vars     map[string]interface{} `json:"vars,omitempty"`
+	// Wait fields
+	Condition string `json:"condition,omitempty"`
+	Port      *int   `json:"port,omitempty"`
+	Timeout   *int   `json:"timeout,omitempty"`  // Seconds; also limits shell commands (0 = no limit)
+	Interval  *int   `json:"interval,omitempty"` // Seconds
+	// Session fields
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	// Params holds every field of the action JSON; custom actions read their parameters from it
+	Params map[string]interface{} `json:"-"`
 }
 
 // SearchResult represents a search match result
@@ -120,10 +200,16 @@ func parseAgentAction(raw string) (*AgentAction, error) {
 		}
 	}
 
-	// Try each candidate
+	// Try each candidate, remembering the most specific reason one was rejected:
+	// an action that failed validation beats JSON that didn't decode
+	parseErr := &actionParseError{Reason: "no JSON object found in the response"}
+	decoded := false
 	for _, c := range candidates {
 		var obj map[string]interface{}
 		if err := json.Unmarshal([]byte(c), &obj); err != nil {
+			if !decoded && strings.HasPrefix(c, "{") && parseErr.Reason == "no JSON object found in the response" {
+				parseErr.Reason = "invalid JSON: " + err.Error()
+			}
 			continue
 		}
 
@@ -134,10 +220,19 @@ func parseAgentAction(raw string) (*AgentAction, error) {
 			// Marshal back to JSON and unmarshal to struct for type safety
 			coercedJSON, _ := json.Marshal(coerced)
 			if err := json.Unmarshal(coercedJSON, action); err != nil {
+				if !decoded {
+					parseErr = &actionParseError{Reason: "wrong field type: " + err.Error(), ActionType: action.Type}
+					decoded = true
+				}
 				continue
 			}
+			action.Params = coerced
 
 			if err := validateAction(action); err != nil {
+				if !decoded {
+					parseErr = &actionParseError{Reason: err.Error(), ActionType: action.Type}
+					decoded = true
+				}
 				continue
 			}
 
@@ -145,7 +240,7 @@ func parseAgentAction(raw string) (*AgentAction, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("could not extract a valid JSON action")
+	return nil, parseErr
 }
 
 // coerceActionType normalizes action type names and provides defaults
@@ -163,6 +258,8 @@ func coerceActionType(obj map[string]interface{}) map[string]interface{} {
 				result["type"] = "list_files"
 			case "get-file":
 				result["type"] = "read_file"
+			case "help":
+				result["type"] = "list_actions"
 			case "run-command":
 				result["type"] = "shell"
 				if _, exists := result["shell"]; !exists {
@@ -224,6 +321,27 @@ func validateAction(action *AgentAction) error {
 		} else if *action.Depth < 0 || *action.Depth > 3 {
 			return fmt.Errorf("depth must be between 0 and 3")
 		}
+		if _, err := path.Match(action.Pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", action.Pattern, err)
+		}
+		if _, err := path.Match(action.Glob, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", action.Glob, err)
+		}
+		if action.Detailed != nil && *action.Detailed {
+			switch action.Format {
+			case "", "long":
+				action.Format = "long"
+			default:
+				return fmt.Errorf("detailed can't be combined with format %q", action.Format)
+			}
+		}
+		switch action.Format {
+		case "":
+			action.Format = "names"
+		case "names", "long", "json":
+		default:
+			return fmt.Errorf("format must be names, long or json")
+		}
 	case "read_file":
 		if action.Path == "" {
 			return fmt.Errorf("path is required for read_file")
@@ -234,6 +352,50 @@ func validateAction(action *AgentAction) error {
 		} else if *action.MaxBytes < 1 || *action.MaxBytes > 200000 {
 			return fmt.Errorf("maxBytes must be between 1 and 200000")
 		}
+		if action.Head != nil && action.Tail != nil {
+			return fmt.Errorf("head and tail cannot be combined")
+		}
+		fromEnd := action.FromEnd != nil && *action.FromEnd
+		if action.StartLine != nil || action.EndLine != nil {
+			if action.Head != nil || action.Tail != nil || action.Lines != nil || fromEnd {
+				return fmt.Errorf("startLine/endLine cannot be combined with head, tail, lines or fromEnd")
+			}
+			if action.StartLine == nil {
+				start := 1
+				action.StartLine = &start
+			} else if *action.StartLine < 1 {
+				return fmt.Errorf("startLine must be at least 1")
+			}
+			if action.EndLine != nil {
+				if *action.EndLine < *action.StartLine {
+					return fmt.Errorf("endLine must not be before startLine")
+				}
+				if *action.EndLine-*action.StartLine >= maxReadLines {
+					return fmt.Errorf("a line range spans at most %d lines", maxReadLines)
+				}
+			}
+		}
+		if fromEnd && action.Head != nil {
+			return fmt.Errorf("fromEnd cannot be combined with head")
+		}
+		// lines is head, or tail with fromEnd
+		if action.Lines != nil {
+			if action.Head != nil || action.Tail != nil {
+				return fmt.Errorf("lines cannot be combined with head or tail")
+			}
+			lines := *action.Lines
+			if fromEnd {
+				action.Tail = &lines
+			} else {
+				action.Head = &lines
+			}
+			action.Lines = nil
+		}
+		for _, lines := range []*int{action.Head, action.Tail} {
+			if lines != nil && (*lines < 1 || *lines > maxReadLines) {
+				return fmt.Errorf("head/tail must be between 1 and %d", maxReadLines)
+			}
+		}
 	case "shell":
 		if action.Command == "" {
 			return fmt.Errorf("command is required for shell")
@@ -244,6 +406,22 @@ func validateAction(action *AgentAction) error {
 		if action.Shell != "powershell" && action.Shell != "bash" && action.Shell != "cmd" {
 			return fmt.Errorf("shell must be powershell, bash, or cmd")
 		}
+		if action.Timeout == nil {
+			timeout := defaultShellTimeout
+			action.Timeout = &timeout
+		} else if *action.Timeout < 0 {
+			return fmt.Errorf("timeout must be 0 (no limit) or a number of seconds")
+		}
+		for _, code := range action.SuccessExitCodes {
+			if code < 0 {
+				return fmt.Errorf("successExitCodes must not be negative")
+			}
+		}
+		switch action.OutputMode {
+		case "", "combined", "separate", "stdout":
+		default:
+			return fmt.Errorf("output must be combined, separate, or stdout")
+		}
 	case "search_files":
 		if action.Pattern == "" {
 			return fmt.Errorf("pattern is required for search_files")
@@ -259,27 +437,62 @@ func validateAction(action *AgentAction) error {
 			caseSensitive := false // Default to case-insensitive for better usability
 			action.CaseSensitive = &caseSensitive
 		}
+		if err := validateWalkFields(action); err != nil {
+			return err
+		}
 	case "write_file":
 		if action.Path == "" {
 			return fmt.Errorf("path is required for write_file")
 		}
-		if action.Content == "" {
+		if action.Format != "" {
+			if action.Format != "csv" && action.Format != "jsonl" {
+				return fmt.Errorf("format must be csv or jsonl for write_file")
+			}
+			if action.Record == nil {
+				return fmt.Errorf("record is required when format is set for write_file")
+			}
+			// Structured records are always appended
+			append := true
+			action.Append = &append
+		} else if action.Content == "" {
 			return fmt.Errorf("content is required for write_file")
 		}
 		if action.Append == nil {
 			append := false
 			action.Append = &append
 		}
+		if action.Backup == nil {
+			backup := false
+			action.Backup = &backup
+		}
 	case "done":
-		if action.Result == "" {
-			action.Result = ""
+		switch action.Status {
+		case "", "success", "failure", "partial":
+		default:
+			return fmt.Errorf("status must be success, failure, or partial")
 		}
 	case "ps":
 		// No validation needed for process list
 	case "kill":
+		// The prompt documents pid; processId is kept for older transcripts
+		if action.ProcessID == nil {
+			action.ProcessID = action.PID
+		}
 		if action.ProcessID == nil {
-			return fmt.Errorf("processId is required for kill")
+			return fmt.Errorf("pid is required for kill")
+		}
+		// 0 and negative pids signal process groups, including the agent's own
+		if *action.ProcessID <= 0 {
+			return fmt.Errorf("pid must be a positive process id for kill")
+		}
+		signal := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(action.Signal)), "SIG")
+		if signal == "" {
+			signal = "TERM"
 		}
+		if !isKillSignal(signal) {
+			return fmt.Errorf("unknown signal %q for kill (use %s)", action.Signal, strings.Join(killSignals, ", "))
+		}
+		action.Signal = signal
 	case "http_request":
 		if action.URL == "" {
 			return fmt.Errorf("url is required for http_request")
@@ -287,6 +500,17 @@ func validateAction(action *AgentAction) error {
 		if action.Method == "" {
 			action.Method = "GET"
 		}
+		action.Method = strings.ToUpper(action.Method)
+		if action.MaxRedirects != nil && *action.MaxRedirects < 0 {
+			return fmt.Errorf("maxRedirects must not be negative")
+		}
+	case "fetch_text":
+		if action.URL == "" {
+			return fmt.Errorf("url is required for fetch_text")
+		}
+		if !strings.HasPrefix(action.URL, "http://") && !strings.HasPrefix(action.URL, "https://") {
+			return fmt.Errorf("url must start with http:// or https://")
+		}
 	case "ping":
 		if action.Host == "" {
 			return fmt.Errorf("host is required for ping")
@@ -330,6 +554,10 @@ func validateAction(action *AgentAction) error {
 		if action.Path == "" {
 			return fmt.Errorf("path is required for parse_yaml")
 		}
+	case "parse_toml":
+		if action.Path == "" {
+			return fmt.Errorf("path is required for parse_toml")
+		}
 	case "ask_user":
 		if action.Question == "" {
 			return fmt.Errorf("question is required for ask_user")
@@ -363,6 +591,17 @@ func validateAction(action *AgentAction) error {
 			overwrite := false
 			action.Overwrite = &overwrite
 		}
+	case "symlink":
+		if action.Src == "" {
+			return fmt.Errorf("src is required for symlink")
+		}
+		if action.Dest == "" {
+			return fmt.Errorf("dest is required for symlink")
+		}
+		if action.Overwrite == nil {
+			overwrite := false
+			action.Overwrite = &overwrite
+		}
 	case "delete_path":
 		if action.Path == "" {
 			return fmt.Errorf("path is required for delete_path")
@@ -371,10 +610,49 @@ func validateAction(action *AgentAction) error {
 			recursive := false
 			action.Recursive = &recursive
 		}
+		if action.SoftDelete == nil {
+			softDelete := false
+			action.SoftDelete = &softDelete
+		}
+	case "undo":
+		if action.Count == nil {
+			count := 1
+			action.Count = &count
+		}
+		if *action.Count < 1 {
+			return fmt.Errorf("count must be at least 1 for undo")
+		}
 	case "stat_path":
 		if action.Path == "" {
 			return fmt.Errorf("path is required for stat_path")
 		}
+	case "realpath":
+		if action.Path == "" {
+			return fmt.Errorf("path is required for realpath")
+		}
+	case "chmod":
+		if action.Path == "" {
+			return fmt.Errorf("path is required for chmod")
+		}
+		if _, err := parseFileMode(action.Mode, 0); err != nil {
+			return fmt.Errorf("%w for chmod", err)
+		}
+	case "chown":
+		if action.Path == "" {
+			return fmt.Errorf("path is required for chown")
+		}
+		if action.Owner == "" || action.Owner == ":" {
+			return fmt.Errorf("owner is required for chown")
+		}
+	case "touch":
+		if action.Path == "" {
+			return fmt.Errorf("path is required for touch")
+		}
+		if action.Timestamp != "" {
+			if _, err := time.Parse(time.RFC3339, action.Timestamp); err != nil {
+				return fmt.Errorf("timestamp must be RFC 3339 (e.g. 2024-05-01T12:00:00Z) for touch")
+			}
+		}
 	case "make_dir":
 		if action.Path == "" {
 			return fmt.Errorf("path is required for make_dir")
@@ -390,8 +668,12 @@ func validateAction(action *AgentAction) error {
 		if action.Patch == "" {
 			return fmt.Errorf("patch is required for patch_file")
 		}
-		if action.Format == "" {
+		switch action.Format {
+		case "":
 			action.Format = "unified"
+		case "unified", "full":
+		default:
+			return fmt.Errorf("format for patch_file must be unified or full")
 		}
 	case "download_file":
 		if action.URL == "" {
@@ -407,6 +689,27 @@ func validateAction(action *AgentAction) error {
 		if action.Path == "" {
 			action.Path = "."
 		}
+		if action.CaseSensitive == nil {
+			caseSensitive := false
+			action.CaseSensitive = &caseSensitive
+		}
+		if action.MaxResults == nil {
+			maxResults := 50
+			action.MaxResults = &maxResults
+		}
+		if err := validateWalkFields(action); err != nil {
+			return err
+		}
+	case "find":
+		if action.Pattern == "" {
+			return fmt.Errorf("pattern is required for find")
+		}
+		if action.Path == "" {
+			action.Path = "."
+		}
+		if action.Kind != "" && action.Kind != "f" && action.Kind != "d" {
+			return fmt.Errorf("kind must be f or d for find")
+		}
 		if action.Regex == nil {
 			regex := false
 			action.Regex = &regex
@@ -416,8 +719,28 @@ func validateAction(action *AgentAction) error {
 			action.CaseSensitive = &caseSensitive
 		}
 		if action.MaxResults == nil {
-			maxResults := 50
+			maxResults := 100
 			action.MaxResults = &maxResults
+		} else if *action.MaxResults < 1 {
+			return fmt.Errorf("maxResults must be at least 1")
+		}
+		if (action.MinSize != nil && *action.MinSize < 0) || (action.MaxSize != nil && *action.MaxSize < 0) {
+			return fmt.Errorf("minSize and maxSize must not be negative")
+		}
+		for _, age := range []string{action.NewerThan, action.OlderThan} {
+			if age == "" {
+				continue
+			}
+			if _, err := parseAge(age); err != nil {
+				return err
+			}
+		}
+		if action.RespectGitignore == nil {
+			respectGitignore := true
+			action.RespectGitignore = &respectGitignore
+		}
+		if err := validateWalkFields(action); err != nil {
+			return err
 		}
 	case "diff":
 		if action.APath == "" {
@@ -430,8 +753,12 @@ func validateAction(action *AgentAction) error {
 			context := 3
 			action.Context = &context
 		}
-		if action.Format == "" {
+		switch action.Format {
+		case "":
 			action.Format = "unified"
+		case "unified", "side-by-side":
+		default:
+			return fmt.Errorf("format for diff must be unified or side-by-side")
 		}
 	case "parse":
 		if action.Path == "" {
@@ -440,6 +767,19 @@ func validateAction(action *AgentAction) error {
 		if action.ParseType == "" {
 			return fmt.Errorf("parseType is required for parse")
 		}
+		if strings.EqualFold(action.ParseType, "csv") {
+			if action.Delimiter != "" {
+				if _, err := parseDelimiter(action.Delimiter); err != nil {
+					return err
+				}
+			}
+			if action.MaxRows == nil {
+				maxRows := 20
+				action.MaxRows = &maxRows
+			} else if *action.MaxRows < 1 {
+				return fmt.Errorf("maxRows must be at least 1")
+			}
+		}
 	case "confirm":
 		if action.ActionName == "" {
 			return fmt.Errorf("action is required for confirm")
@@ -462,6 +802,9 @@ func validateAction(action *AgentAction) error {
 		if action.Algo == "" {
 			action.Algo = "sha256"
 		}
+		if _, err := newHasher(action.Algo); err != nil {
+			return err
+		}
 	case "checksum_verify":
 		if action.Path == "" {
 			return fmt.Errorf("path is required for checksum_verify")
@@ -472,6 +815,143 @@ func validateAction(action *AgentAction) error {
 		if action.Algo == "" {
 			action.Algo = "sha256"
 		}
+	case "hash_dir":
+		if action.Path == "" {
+			action.Path = "."
+		}
+		if action.Algo == "" {
+			action.Algo = "sha256"
+		}
+		if action.Workers == nil {
+			workers := runtime.NumCPU()
+			action.Workers = &workers
+		} else if *action.Workers < 1 || *action.Workers > 64 {
+			return fmt.Errorf("workers must be between 1 and 64")
+		}
+		if err := validateWalkFields(action); err != nil {
+			return err
+		}
+	case "format_file":
+		if action.Path == "" {
+			return fmt.Errorf("path is required for format_file")
+		}
+		if _, _, err := formatterFor(action.Path, action.Format); err != nil {
+			return err
+		}
+	case "dir_snapshot":
+		if action.Path == "" {
+			action.Path = "."
+		}
+		if action.Snapshot == "" {
+			return fmt.Errorf("snapshot is required for dir_snapshot")
+		}
+		if action.Mode == "" {
+			action.Mode = "save"
+		} else if action.Mode != "save" && action.Mode != "compare" {
+			return fmt.Errorf("mode must be save or compare")
+		}
+		if action.Algo == "" {
+			action.Algo = "sha256"
+		}
+		if action.Workers == nil {
+			workers := runtime.NumCPU()
+			action.Workers = &workers
+		} else if *action.Workers < 1 || *action.Workers > 64 {
+			return fmt.Errorf("workers must be between 1 and 64")
+		}
+		if err := validateWalkFields(action); err != nil {
+			return err
+		}
+	case "edit_file":
+		if action.Path == "" {
+			return fmt.Errorf("path is required for edit_file")
+		}
+		if action.Before == "" && action.After == "" {
+			return fmt.Errorf("before or after anchor is required for edit_file")
+		}
+	case "from_template":
+		if action.Template == "" {
+			return fmt.Errorf("template is required for from_template")
+		}
+		if action.Dest == "" {
+			return fmt.Errorf("dest is required for from_template")
+		}
+		if action.Overwrite == nil {
+			overwrite := false
+			action.Overwrite = &overwrite
+		}
+	case "wait_for":
+		switch action.Condition {
+		case "file":
+			if action.Path == "" {
+				return fmt.Errorf("path is required for wait_for file")
+			}
+		case "port":
+			if action.Port == nil || *action.Port < 1 || *action.Port > 65535 {
+				return fmt.Errorf("port between 1 and 65535 is required for wait_for port")
+			}
+			if action.Host == "" {
+				action.Host = "localhost"
+			}
+		case "command":
+			if action.Command == "" {
+				return fmt.Errorf("command is required for wait_for command")
+			}
+			if action.Shell == "" {
+				action.Shell = "powershell"
+			}
+			if action.Shell != "powershell" && action.Shell != "bash" && action.Shell != "cmd" {
+				return fmt.Errorf("shell must be powershell, bash, or cmd")
+			}
+		default:
+			return fmt.Errorf("condition must be file, port, or command")
+		}
+		if action.Timeout == nil {
+			timeout := 60
+			action.Timeout = &timeout
+		} else if *action.Timeout < 1 || *action.Timeout > maxWaitSeconds {
+			return fmt.Errorf("timeout must be between 1 and %d seconds", maxWaitSeconds)
+		}
+		if action.Interval == nil {
+			interval := 1
+			action.Interval = &interval
+		} else if *action.Interval < 1 || *action.Interval > *action.Timeout {
+			return fmt.Errorf("interval must be between 1 second and the timeout")
+		}
+	case "list_actions":
+		if action.Name != "" {
+			if _, ok := actionUsage(action.Name); !ok {
+				return fmt.Errorf("unknown action %s", action.Name)
+			}
+		}
+	case "lint_file":
+		if action.Path == "" {
+			return fmt.Errorf("path is required for lint_file")
+		}
+		action.Linter = strings.ToLower(action.Linter)
+		if _, err := findLinter(action.Linter, action.Path); err != nil {
+			return err
+		}
+	case "run_tests":
+		if action.Path == "" {
+			action.Path = "."
+		}
+		if action.Runner != "" {
+			action.Runner = strings.ToLower(action.Runner)
+			if _, err := findTestRunner(action.Runner, ""); err != nil {
+				return err
+			}
+		}
+	case "verify_checksums":
+		if action.Path == "" {
+			return fmt.Errorf("path is required for verify_checksums")
+		}
+		if action.Workers == nil {
+			workers := runtime.NumCPU()
+			action.Workers = &workers
+		} else if *action.Workers < 1 || *action.Workers > 64 {
+			return fmt.Errorf("workers must be between 1 and 64")
+		}
 	case "hexdump":
 		if action.Path == "" {
 			return fmt.Errorf("path is required for hexdump")
@@ -490,18 +970,65 @@ func validateAction(action *AgentAction) error {
 		if action.Key == "" {
 			return fmt.Errorf("key is required for env_set")
 		}
+		// The key is written into shell commands, so only plain names are allowed
+		if !envNameRe.MatchString(action.Key) {
+			return fmt.Errorf("key for env_set must be letters, digits and _, not starting with a digit")
+		}
 		if action.Value == "" {
 			return fmt.Errorf("value is required for env_set")
 		}
+		if strings.ContainsAny(action.Value, "\n\r\x00") {
+			return fmt.Errorf("value for env_set can't contain newlines or NUL characters")
+		}
 		if action.Persist == nil {
 			persist := false
 			action.Persist = &persist
 		}
 	case "whoami":
 		// No validation needed
+	case "stats":
+		// No validation needed
+	case "summarize_file":
+		if action.Path == "" {
+			return fmt.Errorf("path is required for summarize_file")
+		}
+	case "set_model":
+		if action.Model == "" {
+			return fmt.Errorf("model is required for set_model")
+		}
+	case "set_temperature":
+		if action.Temperature == nil {
+			return fmt.Errorf("temperature is required for set_temperature")
+		}
+		if *action.Temperature != -1 && (*action.Temperature < 0 || *action.Temperature > 2) {
+			return fmt.Errorf("temperature must be between 0 and 2, or -1 to reset it")
+		}
+	case "get_model":
+		// No validation needed
 	default:
-		return fmt.Errorf("unknown action type: %s", action.Type)
+		if custom, ok := lookupAction(action.Type); ok {
+			if custom.Validate != nil {
+				return custom.Validate(action.Params)
+			}
+			return nil
+		}
+		return fmt.Errorf("%w: %s", errUnknownAction, action.Type)
 	}
 
 	return nil
 }
+
+// validateWalkFields sets defaults for the directory walk options of search actions
+func validateWalkFields(action *AgentAction) error {
+	if action.MaxDepth == nil {
+		maxDepth := 20
+		action.MaxDepth = &maxDepth
+	} else if *action.MaxDepth < 1 {
+		return fmt.Errorf("maxDepth must be at least 1")
+	}
+	if action.FollowSymlinks == nil {
+		followSymlinks := false
+		action.FollowSymlinks = &followSymlinks
+	}
+	return nil
+}
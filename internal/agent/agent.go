@@ -1,27 +1,101 @@
 package agent
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+	"time"
 
+	"terminusai/internal/config"
 	"terminusai/internal/policy"
 	"terminusai/internal/providers"
+	"terminusai/internal/tokenizer"
 	"terminusai/internal/ui"
 )
 
 // Agent provides an agent with UI and interactivity
 type Agent struct {
-	provider           providers.LLMProvider
-	policyStore        *policy.Store
-	display            *ui.InteractiveDisplay
-	workingDir         string
-	verbose            bool
-	debug              bool
-	lastSuccessOutput  string // Track last successful command output
-	lastSuccessCommand string // Track last successful command for context
+	provider                providers.LLMProvider
+	policyStore             *policy.Store
+	display                 *ui.InteractiveDisplay
+	workingDir              string
+	verbose                 bool
+	debug                   bool
+	lastSuccessOutput       string          // Track last successful command output
+	lastSuccessCommand      string          // Track last successful command for context
+	maxObservationBytes     int             // Observation size limit for all handlers (0 = per-handler defaults)
+	actionCtx               context.Context // Cancelled when the user interrupts the running action
+	historyPath             string          // JSONL file recording executed actions (empty = disabled)
+	shellRules              policy.ShellRules
+	ignoreDirs              map[string]bool                       // Directory names skipped by search/grep/hash walks
+	summarizing             bool                                  // Set while summarize_file is calling the provider
+	toolsMu                 sync.Mutex                            // Guards tools
+	tools                   map[string]bool                       // Cached PATH lookups of external tools
+	contextSources          []string                              // Files/URLs attached with --context
+	env                     map[string]envVar                     // Variables set by env_set, applied to child processes
+	running                 sync.Mutex                            // Serialises RunTask calls on the same agent
+	stateMu                 sync.Mutex                            // Guards state written by handlers (see state.go)
+	asker                   func(question string) (string, error) // Answers ask_user/confirm instead of stdin
+	actionInterval          time.Duration                         // Minimum time between actions (0 = no throttling)
+	lastActionAt            time.Time
+	persistentShell         bool                     // Run shell actions in long-lived shells
+	shellSessions           map[string]*shellSession // Persistent shells by shell type
+	downloadValidators      map[string]string        // ETag or Last-Modified of interrupted downloads, by destination
+	correctionTemplate      *template.Template       // Feedback for invalid actions (nil = DefaultCorrectionTemplate)
+	openFiles               fileLimiter              // Bounds files open at once during walks
+	observationSummaryBytes int                      // Observations larger than this are summarized (0 = default, negative = never)
+	observationDir          string                   // Temp directory holding full text of summarized observations
+	savedObservations       int
+	rawObservation          string  // Untruncated output of the running action, kept when its observation was cut
+	templatesDir            string  // Where from_template finds named templates (empty = default)
+	maxFileChanges          int     // File-changing actions allowed per run before asking the user (0 = unlimited)
+	fileChangeLimit         int     // Current limit for this run, raised when the user allows more
+	fileChanges             int     // File-changing actions that succeeded this run
+	maxTokens               int     // Tokens a run may use (0 = unlimited)
+	maxCost                 float64 // Approximate USD a run may spend (0 = unlimited)
+	dryRun                  bool    // Describe system-changing actions instead of running them
+	auditPath               string  // JSONL audit log of executed actions (empty = disabled)
+	auditMaxBytes           int64   // Size at which the audit log is rotated
+	lastDecision            policy.Decision
+	journalDir              string     // Undo journal for file changes (empty = disabled)
+	journalMu               sync.Mutex // Serialises journal writes
+	journalSeq              int
+
+	// Model/temperature overrides set by set_model/set_temperature (nil = provider defaults)
+	chatOptions      *providers.ChatOptions
+	allowModelSwitch bool
+
+	// Run statistics reported by the stats action
+	startTime        time.Time
+	iteration        int
+	maxIterations    int
+	actionCounts     map[string]int
+	result           *TaskResult     // Set by the done action
+	observationBytes map[string]int  // Observation bytes produced per action type, before summarizing
+	usage            providers.Usage // Tokens the provider reported this run
+}
+
+// noProvider stands in for a missing provider: actions run, chatting fails
+type noProvider struct{}
+
+func (noProvider) Name() string         { return "none" }
+func (noProvider) DefaultModel() string { return "" }
+func (noProvider) GetTokenizer() tokenizer.Tokenizer {
+	return tokenizer.NewOpenAITokenizer()
+}
+func (noProvider) Chat(messages []providers.ChatMessage, opts *providers.ChatOptions) (string, error) {
+	return "", fmt.Errorf("no provider configured")
 }
 
-// NewAgent creates a new agent
+// NewAgent creates a new agent. With a nil provider it can still run actions,
+// as history replay does, but not talk to a model.
 func NewAgent(provider providers.LLMProvider, policyStore *policy.Store, workingDir string, verbose, debug bool) *Agent {
+	if provider == nil {
+		provider = noProvider{}
+	}
 	if workingDir == "" {
 		if wd, err := os.Getwd(); err == nil {
 			workingDir = wd
@@ -30,12 +104,130 @@ func NewAgent(provider providers.LLMProvider, policyStore *policy.Store, working
 		}
 	}
 
+	// Retry transient provider failures with backoff
+	retryConfig := providers.RetryConfig{
+		MaxRetries: maxAPIRetries,
+		BaseDelay:  retryDelay,
+		Jitter:     retryJitter,
+		OnRetry: func(attempt int, delay time.Duration, err error) {
+			if verbose {
+				fmt.Printf("  ⎿  API temporarily unavailable, retrying in %v... (%d/%d)\n", delay, attempt, maxAPIRetries)
+			}
+		},
+	}
+
 	return &Agent{
-		provider:    provider,
-		policyStore: policyStore,
-		display:     ui.NewInteractiveDisplay(verbose, debug),
-		workingDir:  workingDir,
-		verbose:     verbose,
-		debug:       debug,
+		provider:         providers.NewRetryingProvider(provider, retryConfig),
+		policyStore:      policyStore,
+		display:          ui.NewInteractiveDisplay(verbose, debug),
+		workingDir:       workingDir,
+		verbose:          verbose,
+		debug:            debug,
+		actionCounts:     make(map[string]int),
+		observationBytes: make(map[string]int),
+		ignoreDirs:       mergeIgnoreDirs(nil, nil),
+		openFiles:        newFileLimiter(defaultMaxOpenFiles),
+	}
+}
+
+// SetMaxOpenFiles caps how many files search, grep and hashing walks keep open
+// at once, whatever their worker count (0 = default of 32). Lower it where the
+// file-descriptor limit is small.
+func (a *Agent) SetMaxOpenFiles(n int) {
+	if n <= 0 {
+		n = defaultMaxOpenFiles
+	}
+	a.openFiles = newFileLimiter(n)
+}
+
+// SetIgnoreDirs adjusts the default set of directories skipped during walks:
+// add lists extra names to skip, remove lists default names to traverse again
+func (a *Agent) SetIgnoreDirs(add, remove []string) {
+	a.ignoreDirs = mergeIgnoreDirs(add, remove)
+}
+
+// SetEventSink streams an event for every action shown or updated, e.g. to a server client
+func (a *Agent) SetEventSink(sink func(ui.Event)) {
+	a.display.SetEventSink(sink)
+}
+
+// SetAsker answers ask_user/confirm questions with fn instead of reading stdin.
+// Without a terminal the agent also never waits for a key press after a task.
+func (a *Agent) SetAsker(fn func(question string) (string, error)) {
+	a.asker = fn
+}
+
+// askUser asks the user a question, from the terminal unless an asker is set
+func (a *Agent) askUser(question, prompt string) (string, error) {
+	if a.asker != nil {
+		return a.asker(question)
+	}
+	fmt.Printf("\n%s%s", question, prompt)
+	return readStdinLine(a.actionContext())
+}
+
+// SetActionInterval makes the agent wait at least interval between the start of
+// consecutive actions, smoothing bursts against rate-limited APIs or busy disks
+func (a *Agent) SetActionInterval(interval time.Duration) {
+	a.actionInterval = interval
+}
+
+// throttle sleeps until actionInterval has passed since the previous action
+func (a *Agent) throttle() {
+	if a.actionInterval <= 0 {
+		return
+	}
+	if wait := a.actionInterval - time.Since(a.lastActionAt); wait > 0 && !a.lastActionAt.IsZero() {
+		if a.verbose {
+			fmt.Printf("  ⎿  Throttling for %v\n", wait.Round(time.Millisecond))
+		}
+		time.Sleep(wait)
+	}
+	a.lastActionAt = time.Now()
+}
+
+// SetAllowModelSwitch permits the set_model/set_temperature actions
+func (a *Agent) SetAllowModelSwitch(allow bool) {
+	a.allowModelSwitch = allow
+}
+
+// SetShellRules configures safe-shell vetting of shell commands
+func (a *Agent) SetShellRules(rules policy.ShellRules) {
+	a.shellRules = rules
+}
+
+// SetMaxObservationBytes overrides the per-handler output limits used when
+// building transcript observations (0 keeps the defaults)
+func (a *Agent) SetMaxObservationBytes(limit int) {
+	a.maxObservationBytes = limit
+}
+
+// observationLimit returns how much output a handler may put into an observation.
+// An explicit maxBytes on the action wins over the configured limit, which in turn
+// wins over the handler's own default.
+func (a *Agent) observationLimit(action *AgentAction, defaultLimit int) int {
+	if action != nil && action.MaxBytes != nil && *action.MaxBytes > 0 {
+		return *action.MaxBytes
+	}
+	if a.maxObservationBytes > 0 {
+		return a.maxObservationBytes
+	}
+	return defaultLimit
+}
+
+// knownModels lists the models set_model accepts: the provider's cached models
+// list when there is one, otherwise the built-in defaults
+func (a *Agent) knownModels() []string {
+	if models := providers.CachedModelIDs(a.provider.Name()); len(models) > 0 {
+		return models
+	}
+	return config.KnownModels(a.provider.Name())
+}
+
+// absPath resolves a path from an action against the working directory
+func (a *Agent) absPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
 	}
+	return filepath.Join(a.workingDir, path)
 }
@@ -0,0 +1,872 @@
+package agent
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"terminusai/internal/policy"
+	"terminusai/internal/providers"
+	"terminusai/internal/tokenizer"
+)
+
+// stubProvider is a minimal LLMProvider returning canned responses in order
+type stubProvider struct {
+	name      string
+	responses []string
+	errs      []error
+	calls     int
+	messages  []providers.ChatMessage // Messages of the most recent call
+}
+
+func (p *stubProvider) DefaultModel() string { return "stub-model" }
+
+func (p *stubProvider) Name() string {
+	if p.name != "" {
+		return p.name
+	}
+	return "stub"
+}
+
+func (p *stubProvider) Chat(messages []providers.ChatMessage, opts *providers.ChatOptions) (string, error) {
+	i := p.calls
+	p.calls++
+	p.messages = messages
+	var err error
+	if i < len(p.errs) {
+		err = p.errs[i]
+	}
+	if err != nil {
+		return "", err
+	}
+	if i < len(p.responses) {
+		return p.responses[i], nil
+	}
+	return `{"type":"done","result":"ok"}`, nil
+}
+
+func (p *stubProvider) GetTokenizer() tokenizer.Tokenizer {
+	return tokenizer.NewOpenAITokenizer()
+}
+
+// newTestAgent creates an agent rooted in dir that auto-approves everything
+func newTestAgent(t *testing.T, dir string) *Agent {
+	t.Helper()
+	return newTestAgentWithProvider(t, dir, &stubProvider{})
+}
+
+// newTestAgentWithProvider is newTestAgent with a specific provider
+func newTestAgentWithProvider(t *testing.T, dir string, provider providers.LLMProvider) *Agent {
+	t.Helper()
+	store := &policy.Store{}
+	store.SetAlwaysAllow(true)
+	return NewAgent(provider, store, dir, false, false)
+}
+
+func TestObservationLimit(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name       string
+		configured int
+		maxBytes   *int
+		expected   int
+	}{
+		{"handler default", 0, nil, 8000},
+		{"configured limit", 32000, nil, 32000},
+		{"action override", 32000, intPtr(100), 100},
+		{"action override without config", 0, intPtr(100), 100},
+		{"non-positive action override ignored", 0, intPtr(0), 8000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Agent{}
+			a.SetMaxObservationBytes(tt.configured)
+			result := a.observationLimit(&AgentAction{MaxBytes: tt.maxBytes}, 8000)
+			if result != tt.expected {
+				t.Errorf("Expected limit %d, got %d", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestHandleStats(t *testing.T) {
+	a := newTestAgent(t, t.TempDir())
+	a.iteration = 3
+	a.maxIterations = 12
+	a.actionCounts["read_file"] = 2
+	a.actionCounts["stats"] = 1
+
+	transcript := []providers.ChatMessage{
+		{Role: "system", Content: "system prompt"},
+		{Role: "user", Content: "Task: test"},
+	}
+	if err := a.handleStats(&AgentAction{Type: "stats"}, &transcript); err != nil {
+		t.Fatalf("handleStats failed: %v", err)
+	}
+
+	observation := transcript[len(transcript)-1].Content
+	for _, expected := range []string{"Iterations: 3/12", "Remaining: 9", "read_file=2, stats=1", "Approx tokens:"} {
+		if !strings.Contains(observation, expected) {
+			t.Errorf("Expected observation to contain %q, got %q", expected, observation)
+		}
+	}
+}
+
+func TestHandleSummarizeFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "big.go"), []byte("package big\n\nfunc Large() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		nested      bool
+		expected    string
+		expectCalls int
+	}{
+		{"summarizes file", "big.go", false, "observation:summarize_file success\nPurpose: a big file", 1},
+		{"missing file", "nope.go", false, "observation:summarize_file error", 0},
+		{"nested call refused", "big.go", true, "cannot be used while a summary is being generated", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &stubProvider{responses: []string{"Purpose: a big file\n"}}
+			a := newTestAgentWithProvider(t, dir, provider)
+			a.summarizing = tt.nested
+
+			var transcript []providers.ChatMessage
+			action := &AgentAction{Type: "summarize_file", Path: tt.path, Focus: "exports"}
+			if err := a.handleSummarizeFile(action, &transcript); err != nil {
+				t.Fatalf("handleSummarizeFile failed: %v", err)
+			}
+
+			observation := transcript[len(transcript)-1].Content
+			if !strings.Contains(observation, tt.expected) {
+				t.Errorf("Expected observation to contain %q, got %q", tt.expected, observation)
+			}
+			if provider.calls != tt.expectCalls {
+				t.Errorf("Expected %d provider calls, got %d", tt.expectCalls, provider.calls)
+			}
+			if tt.expectCalls > 0 {
+				request := provider.messages[len(provider.messages)-1].Content
+				if !strings.Contains(request, "func Large()") || !strings.Contains(request, "Focus: exports") {
+					t.Errorf("Expected file content and focus in summary request, got %q", request)
+				}
+			}
+			if a.summarizing != tt.nested {
+				t.Errorf("Expected summarizing flag to be restored")
+			}
+		})
+	}
+}
+
+func TestHandleSummarizeFileTruncatesOnRuneBoundary(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("a", maxSummarizeInputBytes-1) + "é and more"
+	if err := os.WriteFile(filepath.Join(dir, "big.txt"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &stubProvider{responses: []string{"Purpose: text\n"}}
+	a := newTestAgentWithProvider(t, dir, provider)
+	var transcript []providers.ChatMessage
+	if err := a.handleSummarizeFile(&AgentAction{Type: "summarize_file", Path: "big.txt"}, &transcript); err != nil {
+		t.Fatalf("handleSummarizeFile failed: %v", err)
+	}
+
+	request := provider.messages[len(provider.messages)-1].Content
+	if !utf8.ValidString(request) || strings.Contains(request, "é") {
+		t.Errorf("Expected the cut to back off before the split rune")
+	}
+	observation := transcript[len(transcript)-1].Content
+	if expected := fmt.Sprintf("only the first %d of", maxSummarizeInputBytes-1); !strings.Contains(observation, expected) {
+		t.Errorf("Expected observation to contain %q, got %q", expected, observation)
+	}
+}
+
+func TestHandleSetModel(t *testing.T) {
+	tests := []struct {
+		name          string
+		allow         bool
+		model         string
+		expected      string
+		expectedModel string
+	}{
+		{"disabled by config", false, "gpt-4o", "disabled by the user", "stub-model"},
+		{"unknown model rejected", true, "gpt-9", "unknown model", "stub-model"},
+		{"known model accepted", true, "gpt-4o", "model changed from stub-model to gpt-4o", "gpt-4o"},
+		{"default model accepted", true, "stub-model", "set_model success", "stub-model"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAgentWithProvider(t, t.TempDir(), &stubProvider{name: "openai"})
+			a.SetAllowModelSwitch(tt.allow)
+
+			var transcript []providers.ChatMessage
+			if err := a.handleSetModel(&AgentAction{Type: "set_model", Model: tt.model}, &transcript); err != nil {
+				t.Fatalf("handleSetModel failed: %v", err)
+			}
+
+			observation := transcript[len(transcript)-1].Content
+			if !strings.Contains(observation, tt.expected) {
+				t.Errorf("Expected observation to contain %q, got %q", tt.expected, observation)
+			}
+			if model := a.sessionModel(); model != tt.expectedModel {
+				t.Errorf("Expected session model %q, got %q", tt.expectedModel, model)
+			}
+		})
+	}
+}
+
+func TestHandleSetTemperature(t *testing.T) {
+	a := newTestAgent(t, t.TempDir())
+	a.SetAllowModelSwitch(true)
+
+	tests := []struct {
+		temperature float64
+		expected    string
+	}{
+		{0.2, "Temperature: 0.20"},
+		{0, "Temperature: 0.00"},
+		{-1, "Temperature: provider default"},
+	}
+
+	var transcript []providers.ChatMessage
+	for _, tt := range tests {
+		temperature := tt.temperature
+		if err := a.handleSetTemperature(&AgentAction{Type: "set_temperature", Temperature: &temperature}, &transcript); err != nil {
+			t.Fatalf("handleSetTemperature failed: %v", err)
+		}
+		if err := a.handleGetModel(&AgentAction{Type: "get_model"}, &transcript); err != nil {
+			t.Fatalf("handleGetModel failed: %v", err)
+		}
+		if observation := transcript[len(transcript)-1].Content; !strings.Contains(observation, tt.expected) {
+			t.Errorf("Expected get_model to report %q after setting %v, got %q", tt.expected, tt.temperature, observation)
+		}
+	}
+	if a.chatOptions == nil || a.chatOptions.Temperature != nil {
+		t.Errorf("Expected the reset to clear the temperature, got %+v", a.chatOptions)
+	}
+}
+
+func TestRunTaskErrors(t *testing.T) {
+	garbage := make([]string, 12)
+	for i := range garbage {
+		garbage[i] = "not an action"
+	}
+
+	tests := []struct {
+		name     string
+		provider *stubProvider
+		expected error
+	}{
+		{"done", &stubProvider{}, nil},
+		{"provider failed", &stubProvider{errs: []error{errors.New("401 unauthorized")}}, ErrProviderFailed},
+		{"max iterations", &stubProvider{responses: garbage}, ErrMaxIterations},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAgentWithProvider(t, t.TempDir(), tt.provider)
+			err := a.RunTask("test")
+			if tt.expected == nil {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+// usageProvider is a stubProvider that reports usages[i] for its i-th reply
+type usageProvider struct {
+	stubProvider
+	usages []providers.Usage
+}
+
+func (p *usageProvider) LastUsage() providers.Usage {
+	if p.calls == 0 || p.calls > len(p.usages) {
+		return providers.Usage{}
+	}
+	return p.usages[p.calls-1]
+}
+
+func TestRunTaskSumsUsage(t *testing.T) {
+	provider := &usageProvider{
+		stubProvider: stubProvider{responses: []string{`{"type":"list_files","path":"."}`}},
+		usages: []providers.Usage{
+			{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120},
+			{PromptTokens: 150, CompletionTokens: 10, TotalTokens: 160},
+		},
+	}
+	a := newTestAgentWithProvider(t, t.TempDir(), provider)
+	if err := a.RunTask("test"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := providers.Usage{PromptTokens: 250, CompletionTokens: 30, TotalTokens: 280}
+	if usage := a.Usage(); usage != expected {
+		t.Errorf("Expected usage %+v, got %+v", expected, usage)
+	}
+	if summary := a.tokenUsage(); summary.TotalTokens != 280 || summary.CostKnown {
+		t.Errorf("Expected 280 tokens without a price for the stub model, got %+v", summary)
+	}
+}
+
+func TestRunTaskStopsOverBudget(t *testing.T) {
+	provider := &usageProvider{
+		stubProvider: stubProvider{responses: []string{`{"type":"list_files","path":"."}`, `{"type":"list_files","path":"."}`}},
+		usages: []providers.Usage{
+			{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120},
+			{PromptTokens: 150, CompletionTokens: 10, TotalTokens: 160},
+		},
+	}
+	a := newTestAgentWithProvider(t, t.TempDir(), provider)
+	a.SetBudget(100, 0)
+
+	err := a.RunTask("test")
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Expected ErrBudgetExceeded, got %v", err)
+	}
+	if provider.calls != 1 {
+		t.Errorf("Expected the run to stop after the first reply, got %d calls", provider.calls)
+	}
+	if count := a.actionCounts["list_files"]; count != 0 {
+		t.Errorf("Expected no action to run past the budget, got %d", count)
+	}
+}
+
+func TestRunTaskContextSources(t *testing.T) {
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(notes, []byte("deploy with make release"), 0644); err != nil {
+		t.Fatalf("Failed to write notes: %v", err)
+	}
+	big := filepath.Join(dir, "big.txt")
+	if err := os.WriteFile(big, []byte(strings.Repeat("x", maxContextSourceBytes+100)), 0644); err != nil {
+		t.Fatalf("Failed to write big file: %v", err)
+	}
+
+	provider := &stubProvider{}
+	a := newTestAgentWithProvider(t, dir, provider)
+	a.SetContextSources([]string{notes, filepath.Join(dir, "missing.md"), big})
+	if err := a.RunTask("deploy"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// system, notes, big (missing skipped), task
+	if len(provider.messages) != 4 {
+		t.Fatalf("Expected 4 messages, got %d", len(provider.messages))
+	}
+	if !strings.Contains(provider.messages[1].Content, "deploy with make release") {
+		t.Errorf("Expected context after the system prompt, got %q", provider.messages[1].Content)
+	}
+	if !strings.HasSuffix(provider.messages[2].Content, "(truncated)") {
+		t.Errorf("Expected large context to be truncated")
+	}
+	if !strings.HasPrefix(provider.messages[3].Content, "Task: deploy") {
+		t.Errorf("Expected the task last, got %q", provider.messages[3].Content)
+	}
+}
+
+func TestCreateArchiveSkipsUnreadableFiles(t *testing.T) {
+	for _, name := range []string{"out.zip", "out.tar", "out.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(dir, "src"), 0755); err != nil {
+				t.Fatalf("Failed to create dir: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "src", "a.txt"), []byte("hello"), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			if err := os.Symlink(filepath.Join(dir, "gone"), filepath.Join(dir, "src", "dangling")); err != nil {
+				t.Skipf("Symlinks not supported: %v", err)
+			}
+
+			dest := filepath.Join(dir, name)
+			skipped, err := createArchive([]string{"."}, dest, dir)
+			if err != nil {
+				t.Fatalf("Expected archive to be created, got %v", err)
+			}
+			if len(skipped) != 1 || !strings.HasSuffix(skipped[0].Path, "dangling") {
+				t.Errorf("Expected the dangling link to be skipped, got %+v", skipped)
+			}
+
+			extracted := filepath.Join(dir, "extracted")
+			if err := (&Agent{}).extractArchive(dest, extracted); err != nil {
+				t.Fatalf("Expected a valid archive, got %v", err)
+			}
+			if data, err := os.ReadFile(filepath.Join(extracted, "src", "a.txt")); err != nil || string(data) != "hello" {
+				t.Errorf("Expected src/a.txt to round-trip, got %q (%v)", data, err)
+			}
+			if _, err := os.Stat(filepath.Join(extracted, name)); err == nil {
+				t.Errorf("Expected the archive not to contain itself")
+			}
+		})
+	}
+}
+
+func TestCreateArchiveSingleFileGzip(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("compress me\n", 100)
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	dest := filepath.Join(dir, "notes.txt.gz")
+	if _, err := createArchive([]string{"notes.txt"}, dest, dir); err != nil {
+		t.Fatalf("Expected gzip to be created, got %v", err)
+	}
+
+	restored := filepath.Join(dir, "restored.txt")
+	if err := extractGz(dest, restored); err != nil {
+		t.Fatalf("Expected a valid gzip, got %v", err)
+	}
+	if data, err := os.ReadFile(restored); err != nil || string(data) != content {
+		t.Errorf("Expected the file to round-trip, got %d bytes (%v)", len(data), err)
+	}
+
+	multi := filepath.Join(dir, "both.gz")
+	if _, err := createArchive([]string{"notes.txt", "other.txt"}, multi, dir); err == nil || !strings.Contains(err.Error(), "exactly one file") {
+		t.Errorf("Expected an error for several files, got %v", err)
+	}
+	if _, err := os.Stat(multi); err == nil {
+		t.Errorf("Expected no gzip to be written for several files")
+	}
+}
+
+func TestExtractArchiveRefusesPathTraversal(t *testing.T) {
+	writeZip := func(path string, names ...string) {
+		f, _ := os.Create(path)
+		zw := zip.NewWriter(f)
+		for _, name := range names {
+			w, _ := zw.Create(name)
+			w.Write([]byte("payload"))
+		}
+		zw.Close()
+		f.Close()
+	}
+	writeTar := func(path string, names ...string) {
+		f, _ := os.Create(path)
+		tw := tar.NewWriter(f)
+		for _, name := range names {
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 7, Typeflag: tar.TypeReg})
+			tw.Write([]byte("payload"))
+		}
+		tw.Close()
+		f.Close()
+	}
+
+	tests := []struct {
+		name    string
+		archive string
+		write   func(path string, names ...string)
+		entries []string
+		refused bool
+	}{
+		{"zip with dot-dot entry", "evil.zip", writeZip, []string{"ok.txt", "../../evil.txt"}, true},
+		{"tar with dot-dot entry", "evil.tar", writeTar, []string{"sub/../../evil.txt"}, true},
+		{"dot-dot staying inside", "fine.zip", writeZip, []string{"sub/../inside.txt"}, false},
+		{"absolute name stays inside", "abs.tar", writeTar, []string{"/abs.txt"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			archive := filepath.Join(dir, tt.archive)
+			tt.write(archive, tt.entries...)
+			dest := filepath.Join(dir, "out", "dest")
+
+			err := (&Agent{}).extractArchive(archive, dest)
+			if tt.refused {
+				if err == nil || !strings.Contains(err.Error(), "outside") {
+					t.Errorf("Expected the archive to be refused, got %v", err)
+				}
+				if _, statErr := os.Stat(filepath.Join(dir, "evil.txt")); statErr == nil {
+					t.Errorf("Expected nothing written outside the destination")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Expected extraction to succeed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestExtractBudget(t *testing.T) {
+	full := extractBudget{entries: maxExtractEntries}
+	if err := full.addEntry(); err == nil {
+		t.Errorf("Expected an error past %d entries", maxExtractEntries)
+	}
+
+	nearlyFull := extractBudget{bytes: maxExtractBytes - 3}
+	var out bytes.Buffer
+	if err := nearlyFull.copy(&out, strings.NewReader("abc")); err != nil {
+		t.Errorf("Expected data up to the limit to be written, got %v", err)
+	}
+	if err := nearlyFull.copy(&out, strings.NewReader("d")); err == nil {
+		t.Errorf("Expected an error past %d bytes", int64(maxExtractBytes))
+	}
+}
+
+func TestEnvSetIsScopedToAgent(t *testing.T) {
+	const key = "TERMINUSAI_TEST_SCOPED_VAR"
+	a := newTestAgent(t, t.TempDir())
+	other := newTestAgent(t, t.TempDir())
+
+	var transcript []providers.ChatMessage
+	if err := a.handleEnvSet(&AgentAction{Type: "env_set", Key: key, Value: "one"}, &transcript); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if value, ok := os.LookupEnv(key); ok {
+		t.Errorf("Expected process environment untouched, got %q", value)
+	}
+	if value := a.GetEnv(key); value != "one" {
+		t.Errorf("Expected agent to see its variable, got %q", value)
+	}
+	if value := other.GetEnv(key); value != "" {
+		t.Errorf("Expected other agent not to see the variable, got %q", value)
+	}
+
+	found := false
+	for _, kv := range a.command("echo").Env {
+		if kv == key+"=one" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected child process environment to contain %s", key)
+	}
+	if env := other.command("echo").Env; env != nil {
+		t.Errorf("Expected other agent's commands to inherit the process environment")
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	a := newTestAgent(t, t.TempDir())
+
+	start := time.Now()
+	a.throttle()
+	a.throttle()
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("Expected no wait when disabled, took %v", elapsed)
+	}
+
+	a.SetActionInterval(50 * time.Millisecond)
+	a.lastActionAt = time.Time{}
+	start = time.Now()
+	a.throttle() // First action runs immediately
+	a.throttle()
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected second action to wait the interval, took %v", elapsed)
+	}
+}
+
+func TestNetworkActionsRequireApproval(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		decision policy.Decision
+		action   *AgentAction
+		expected string
+	}{
+		{"http declined", policy.DecisionSkip, &AgentAction{Type: "http_request", Method: "POST", URL: server.URL, Body: "x"}, "observation:http_request skipped by user"},
+		{"download declined", policy.DecisionNever, &AgentAction{Type: "download_file", URL: server.URL, Dest: "out.txt"}, "observation:download_file skipped by user"},
+		{"fetch_text declined", policy.DecisionSkip, &AgentAction{Type: "fetch_text", URL: server.URL}, "observation:fetch_text skipped by user"},
+		{"fetch_text approved", policy.DecisionOnce, &AgentAction{Type: "fetch_text", URL: server.URL}, "observation:fetch_text status=200"},
+		{"download approved", policy.DecisionOnce, &AgentAction{Type: "download_file", URL: server.URL, Dest: "out.txt"}, "observation:download_file success"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+			var prompted string
+			store := &policy.Store{}
+			store.SetPrompter(func(actionType, command, description string) (policy.Decision, error) {
+				prompted = command + " | " + description
+				return tt.decision, nil
+			})
+			dir := t.TempDir()
+			a := NewAgent(&stubProvider{}, store, dir, false, false)
+
+			if err := validateAction(tt.action); err != nil {
+				t.Fatalf("Expected valid action, got %v", err)
+			}
+			var transcript []providers.ChatMessage
+			if err := a.executeAction(tt.action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if obs := transcript[1].Content; !strings.HasPrefix(obs, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, obs)
+			}
+			if !strings.Contains(prompted, server.URL) {
+				t.Errorf("Expected the prompt to name the URL, got %q", prompted)
+			}
+			if tt.action.Type == "download_file" && !strings.Contains(prompted, "out.txt") {
+				t.Errorf("Expected the prompt to name the destination, got %q", prompted)
+			}
+
+			approved := tt.decision == policy.DecisionOnce
+			if approved != (requests == 1) {
+				t.Errorf("Expected %v network call, got %d requests", approved, requests)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "out.txt")); approved != (err == nil) && tt.action.Type == "download_file" {
+				t.Errorf("Expected file written only when approved, stat error %v", err)
+			}
+		})
+	}
+}
+
+func TestHandleHttpRequestHeadersOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Zeta", "last")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("the body"))
+	}))
+	defer server.Close()
+
+	headersOnly := true
+	tests := []struct {
+		name   string
+		action *AgentAction
+	}{
+		{"head", &AgentAction{Type: "http_request", Method: "head", URL: server.URL}},
+		{"get headers only", &AgentAction{Type: "http_request", URL: server.URL, HeadersOnly: &headersOnly}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAgent(t, t.TempDir())
+			if err := validateAction(tt.action); err != nil {
+				t.Fatalf("Expected valid action, got %v", err)
+			}
+			var transcript []providers.ChatMessage
+			if err := a.handleHttpRequest(tt.action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			obs := transcript[1].Content
+			if !strings.HasPrefix(obs, "observation:http_request status=200\nHTTP/1.1 200 OK\n") {
+				t.Errorf("Expected status line, got %q", obs)
+			}
+			if strings.Contains(obs, "the body") {
+				t.Errorf("Expected no body, got %q", obs)
+			}
+			if ct, zeta := strings.Index(obs, "Content-Type: text/plain"), strings.Index(obs, "X-Zeta: last"); ct < 0 || zeta < ct {
+				t.Errorf("Expected sorted headers, got %q", obs)
+			}
+		})
+	}
+}
+
+func TestHandleHttpRequestRedirects(t *testing.T) {
+	var otherAuth string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherAuth = r.Header.Get("Authorization")
+		w.Write([]byte("other host"))
+	}))
+	defer other.Close()
+
+	var finalAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/start":
+			http.Redirect(w, r, "/final", http.StatusFound)
+		case "/away":
+			http.Redirect(w, r, other.URL+"/landing", http.StatusFound)
+		case "/final":
+			finalAuth = r.Header.Get("Authorization")
+			w.Write([]byte("final page"))
+		}
+	}))
+	defer server.Close()
+
+	boolPtr := func(v bool) *bool { return &v }
+	intPtr := func(v int) *int { return &v }
+	auth := map[string]string{"Authorization": "Bearer secret"}
+
+	tests := []struct {
+		name      string
+		action    *AgentAction
+		expected  string
+		finalAuth string
+		otherAuth string
+	}{
+		{
+			name:      "follows by default",
+			action:    &AgentAction{Type: "http_request", URL: server.URL + "/start", Headers: auth},
+			expected:  "observation:http_request status=200 final_url=" + server.URL + "/final\nfinal page",
+			finalAuth: "Bearer secret",
+		},
+		{
+			name:     "does not follow when disabled",
+			action:   &AgentAction{Type: "http_request", URL: server.URL + "/start", FollowRedirects: boolPtr(false)},
+			expected: "observation:http_request status=302 location=/final\n",
+		},
+		{
+			name:     "stops at the redirect limit",
+			action:   &AgentAction{Type: "http_request", URL: server.URL + "/start", MaxRedirects: intPtr(0)},
+			expected: "observation:http_request error\n",
+		},
+		{
+			name:     "drops authorization on another host",
+			action:   &AgentAction{Type: "http_request", URL: server.URL + "/away", Headers: auth},
+			expected: "observation:http_request status=200 final_url=" + other.URL + "/landing\nother host",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finalAuth, otherAuth = "", ""
+			a := newTestAgent(t, t.TempDir())
+			if err := validateAction(tt.action); err != nil {
+				t.Fatalf("Expected valid action, got %v", err)
+			}
+			var transcript []providers.ChatMessage
+			if err := a.handleHttpRequest(tt.action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if obs := transcript[1].Content; !strings.HasPrefix(obs, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, obs)
+			}
+			if finalAuth != tt.finalAuth {
+				t.Errorf("Expected Authorization %q on the same host, got %q", tt.finalAuth, finalAuth)
+			}
+			if otherAuth != tt.otherAuth {
+				t.Errorf("Expected Authorization %q on the other host, got %q", tt.otherAuth, otherAuth)
+			}
+		})
+	}
+}
+
+func TestHandleCopyPathPreservesSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "src", "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "src", "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Symlink("a.txt", filepath.Join(dir, "src", "link")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	// A link back to its own parent used to be copied forever
+	if err := os.Symlink("..", filepath.Join(dir, "src", "sub", "loop")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	a := newTestAgent(t, dir)
+	overwrite := false
+	action := &AgentAction{Type: "copy_path", Src: "src", Dest: "dest", Overwrite: &overwrite}
+	var transcript []providers.ChatMessage
+	if err := a.handleCopyPath(action, &transcript); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := "observation:copy_path success\nCopy completed successfully (2 symlinks preserved)"
+	if obs := transcript[1].Content; obs != expected {
+		t.Errorf("Expected %q, got %q", expected, obs)
+	}
+	for link, target := range map[string]string{"link": "a.txt", filepath.Join("sub", "loop"): ".."} {
+		path := filepath.Join(dir, "dest", link)
+		info, err := os.Lstat(path)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("Expected %s to be a symlink, got %v (%v)", link, info, err)
+			continue
+		}
+		if got, _ := os.Readlink(path); got != target {
+			t.Errorf("Expected %s to point at %q, got %q", link, target, got)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "dest", "link")); err != nil || string(data) != "hello" {
+		t.Errorf("Expected the copied link to resolve, got %q (%v)", data, err)
+	}
+}
+
+func TestExtractArchiveCompressedTarballs(t *testing.T) {
+	for _, fixture := range []string{"sample.tar.bz2", "sample.tar.xz"} {
+		t.Run(fixture, func(t *testing.T) {
+			dest := t.TempDir()
+			if err := (&Agent{}).extractArchive(filepath.Join("testdata", fixture), dest); err != nil {
+				t.Fatalf("Expected extraction to succeed, got %v", err)
+			}
+			data, err := os.ReadFile(filepath.Join(dest, "docs", "hello.txt"))
+			if err != nil || string(data) != "hello from a compressed tarball\n" {
+				t.Errorf("Expected docs/hello.txt, got %q (%v)", data, err)
+			}
+		})
+	}
+}
+
+func TestExtractTarXzCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a := &Agent{actionCtx: ctx}
+
+	dest := t.TempDir()
+	if err := a.extractArchive(filepath.Join("testdata", "sample.tar.xz"), dest); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled action to stop extracting, got %v", err)
+	}
+}
+
+func TestArchiveFormat(t *testing.T) {
+	tests := map[string]string{
+		"a.zip":          ".zip",
+		"a.tar":          ".tar",
+		"a.tar.gz":       ".tar.gz",
+		"a.TGZ":          ".tar.gz",
+		"a.gz":           ".gz",
+		"a.tar.bz2":      ".tar.bz2",
+		"a.tbz2":         ".tar.bz2",
+		"a.tar.xz":       ".tar.xz",
+		"a.txz":          ".tar.xz",
+		"release.bz2":    "",
+		"notes.txt":      "",
+		"v1.2.tar.gz":    ".tar.gz",
+		"archive.7z":     "",
+		"backup.tar.gz2": "",
+	}
+	for name, expected := range tests {
+		if got := archiveFormat(name); got != expected {
+			t.Errorf("archiveFormat(%q) = %q, want %q", name, got, expected)
+		}
+	}
+
+	err := (&Agent{}).extractArchive("archive.7z", t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "supported: .zip") {
+		t.Errorf("Expected the error to list supported formats, got %v", err)
+	}
+}
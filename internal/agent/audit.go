@@ -0,0 +1,183 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"terminusai/internal/common"
+	"terminusai/internal/policy"
+	"terminusai/internal/providers"
+)
+
+// DefaultAuditMaxBytes is the size at which the audit log is rotated
+const DefaultAuditMaxBytes = 10 * 1024 * 1024
+
+// redactedValue replaces secrets in audit entries
+const redactedValue = "[REDACTED]"
+
+// AuditEntry is one executed action as recorded in the audit log
+type AuditEntry struct {
+	Time     time.Time    `json:"time"`
+	Action   string       `json:"action"`
+	Args     *AgentAction `json:"args"`
+	Decision string       `json:"decision,omitempty"` // Empty when the action needed no approval
+	Status   string       `json:"status"`
+	ExitCode *int         `json:"exitCode,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// secretPatterns match secrets in command strings; the first group is kept
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(authorization:\s*(?:bearer|basic|token)\s+)([^\s'"]+)`),
+	regexp.MustCompile(`(?i)((?:password|passwd|token|secret|api[_-]?key|access[_-]?key)[\w-]*\s*[=:]\s*)("[^"]*"|'[^']*'|\S+)`),
+	regexp.MustCompile(`(?i)(--?(?:password|passwd|token|secret|api[_-]?key|access[_-]?key)[\w-]*\s+)("[^"]*"|'[^']*'|\S+)`),
+	regexp.MustCompile(`(://[^/\s:@]+:)([^/\s@]+)(@)`),
+	regexp.MustCompile(`()\b(sk-[A-Za-z0-9_-]{16,}|gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,}|AKIA[0-9A-Z]{16}|xox[abpr]-[A-Za-z0-9-]{10,})`),
+}
+
+// secretHeaders are request headers whose values are always redacted
+var secretHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"x-api-key":           true,
+}
+
+// exitCodeRegex finds the exit code at the start of an observation
+var exitCodeRegex = regexp.MustCompile(`^observation:\S+ exit=(-?\d+)`)
+
+// DefaultAuditPath returns the audit log location used when none is configured
+func DefaultAuditPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, common.ConfigDirName, "audit.log"), nil
+}
+
+// SetAuditLog enables the append-only audit log at path. Once the file reaches
+// maxBytes it is moved to path.1 and a new one is started (0 = default of 10MB).
+func (a *Agent) SetAuditLog(path string, maxBytes int64) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultAuditMaxBytes
+	}
+	a.auditPath = path
+	a.auditMaxBytes = maxBytes
+	if path != "" && a.policyStore != nil {
+		a.policyStore.SetDecisionHook(func(command string, decision policy.Decision) {
+			a.setLastDecision(decision)
+		})
+	}
+}
+
+// recordAudit appends an entry for an executed action. Like history, failures
+// to write are reported but never interrupt the task.
+func (a *Agent) recordAudit(action *AgentAction, transcript []providers.ChatMessage, before int, runErr error) {
+	if a.auditPath == "" {
+		return
+	}
+
+	entry := AuditEntry{
+		Time:     time.Now(),
+		Action:   action.Type,
+		Args:     redactAction(action),
+		Decision: string(a.takeLastDecision()),
+	}
+	var observation string
+	entry.Status, observation = observationStatus(action.Type, transcript, before)
+	if match := exitCodeRegex.FindStringSubmatch(observation); match != nil {
+		if code, err := strconv.Atoi(match[1]); err == nil {
+			entry.ExitCode = &code
+		}
+	}
+	if runErr != nil {
+		entry.Status = "error"
+		entry.Error = runErr.Error()
+	}
+
+	if err := appendAuditEntry(a.auditPath, a.auditMaxBytes, entry); err != nil && a.verbose {
+		fmt.Printf("  ⎿  Failed to write audit log: %v\n", err)
+	}
+}
+
+// appendAuditEntry writes entry as a JSON line, rotating the file first when it
+// has grown past maxBytes
+func appendAuditEntry(path string, maxBytes int64, entry AuditEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(path); err == nil && maxBytes > 0 && info.Size() >= maxBytes {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// redactAction returns a copy of action fit for the audit log: secrets in
+// commands, URLs, headers and secret-looking variables are masked, and file
+// contents are replaced by their size
+func redactAction(action *AgentAction) *AgentAction {
+	redacted := *action
+	redacted.Command = redactSecrets(action.Command)
+	redacted.URL = redactSecrets(action.URL)
+	redacted.Body = redactSecrets(action.Body)
+	if action.Content != "" {
+		redacted.Content = fmt.Sprintf("[%d bytes]", len(action.Content))
+	}
+	if action.Patch != "" {
+		redacted.Patch = fmt.Sprintf("[%d bytes]", len(action.Patch))
+	}
+	if action.Value != "" && secretName(action.Key) {
+		redacted.Value = redactedValue
+	}
+	if action.Headers != nil {
+		redacted.Headers = make(map[string]string, len(action.Headers))
+		for name, value := range action.Headers {
+			if secretHeaders[strings.ToLower(name)] || secretName(name) {
+				value = redactedValue
+			}
+			redacted.Headers[name] = value
+		}
+	}
+	return &redacted
+}
+
+// redactSecrets masks passwords, tokens and keys in a command string
+func redactSecrets(s string) string {
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+redactedValue+"${3}")
+	}
+	return s
+}
+
+// secretName reports whether a variable or header name suggests a secret value
+func secretName(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range []string{"password", "passwd", "secret", "token", "api_key", "apikey", "api-key", "access_key", "private_key"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"fmt"
+
+	"terminusai/internal/providers"
+)
+
+// SetBudget stops runs once the provider has reported more than maxTokens
+// tokens, or more than maxCost USD at the model's list price (0 = unlimited).
+// The cost budget only applies to models with a known price.
+func (a *Agent) SetBudget(maxTokens int, maxCost float64) {
+	a.maxTokens = maxTokens
+	a.maxCost = maxCost
+}
+
+// budgetExceeded describes how the run's usage went over budget, or returns ""
+// while it is within it
+func (a *Agent) budgetExceeded() string {
+	usage := a.Usage()
+	if a.maxTokens > 0 && usage.TotalTokens > a.maxTokens {
+		return fmt.Sprintf("%d tokens used, budget is %d", usage.TotalTokens, a.maxTokens)
+	}
+	if a.maxCost > 0 {
+		if cost, known := providers.EstimateCost(a.provider.Name(), a.sessionModel(), usage); known && cost > a.maxCost {
+			return fmt.Sprintf("about $%.4f spent, budget is $%.4f", cost, a.maxCost)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// actionSpec describes an action type, read from its tool lines in the system
+// prompt (including registered custom actions), so the prompt stays the single
+// source of truth for what the model may call
+type actionSpec struct {
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+	Fields      []fieldSpec `json:"fields,omitempty"`
+}
+
+// fieldSpec is one parameter of an action
+type fieldSpec struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // As written in the prompt, e.g. string, number, "csv"|"jsonl"
+	Required bool   `json:"required"`
+}
+
+// toolLineRe matches "- name { fields } -> description"
+var toolLineRe = regexp.MustCompile(`^- ([a-z_][a-z0-9_]*) \{(.*)\}\s*->\s*(.*)$`)
+
+// actionCatalog lists the available actions in prompt order. Actions with
+// several tool lines (e.g. write_file) are merged: a field is required only
+// when every form requires it.
+func actionCatalog() []actionSpec {
+	var specs []actionSpec
+	index := make(map[string]int)
+	for _, line := range strings.Split(systemPrompt(), "\n") {
+		m := toolLineRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		spec := actionSpec{Type: m[1], Description: strings.TrimSpace(m[3]), Fields: parseFieldSpecs(m[2])}
+
+		i, seen := index[spec.Type]
+		if !seen {
+			index[spec.Type] = len(specs)
+			specs = append(specs, spec)
+			continue
+		}
+		specs[i] = mergeActionSpecs(specs[i], spec)
+	}
+	return specs
+}
+
+// parseFieldSpecs splits "path: string, depth?: 0-3" into fields, ignoring
+// commas nested in brackets or braces
+func parseFieldSpecs(fields string) []fieldSpec {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range fields {
+		switch r {
+		case '[', '{', '(':
+			depth++
+		case ']', '}', ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, fields[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, fields[start:])
+
+	var specs []fieldSpec
+	for _, part := range parts {
+		name, typ, ok := strings.Cut(part, ":")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		optional := strings.HasSuffix(name, "?")
+		specs = append(specs, fieldSpec{
+			Name:     strings.TrimSuffix(name, "?"),
+			Type:     strings.TrimSpace(typ),
+			Required: !optional,
+		})
+	}
+	return specs
+}
+
+// mergeActionSpecs combines two tool lines for the same action
+func mergeActionSpecs(a, b actionSpec) actionSpec {
+	inB := make(map[string]fieldSpec)
+	for _, f := range b.Fields {
+		inB[f.Name] = f
+	}
+
+	merged := actionSpec{Type: a.Type, Description: a.Description + "; or: " + b.Description}
+	seen := make(map[string]bool)
+	for _, f := range a.Fields {
+		other, ok := inB[f.Name]
+		f.Required = f.Required && ok && other.Required
+		if ok && other.Type != f.Type {
+			f.Type += "|" + other.Type
+		}
+		merged.Fields = append(merged.Fields, f)
+		seen[f.Name] = true
+	}
+	for _, f := range b.Fields {
+		if !seen[f.Name] {
+			f.Required = false
+			merged.Fields = append(merged.Fields, f)
+		}
+	}
+	return merged
+}
+
+// signature renders an action compactly, e.g. "read_file(path, maxBytes?, head?)"
+func (s actionSpec) signature() string {
+	names := make([]string, len(s.Fields))
+	for i, f := range s.Fields {
+		names[i] = f.Name
+		if !f.Required {
+			names[i] += "?"
+		}
+	}
+	return fmt.Sprintf("%s(%s)", s.Type, strings.Join(names, ", "))
+}
+
+// ToolSchema describes an action as a function-calling tool
+type ToolSchema struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"` // JSON Schema of the action's fields
+}
+
+// ToolSchemas returns every available action (built in and registered) as a
+// function-calling tool definition
+func ToolSchemas() []ToolSchema {
+	var tools []ToolSchema
+	for _, spec := range actionCatalog() {
+		properties := make(map[string]interface{})
+		required := []string{}
+		for _, f := range spec.Fields {
+			properties[f.Name] = jsonSchemaType(f.Type)
+			if f.Required {
+				required = append(required, f.Name)
+			}
+		}
+		tools = append(tools, ToolSchema{
+			Name:        spec.Type,
+			Description: spec.Description,
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": properties,
+				"required":   required,
+			},
+		})
+	}
+	return tools
+}
+
+var (
+	enumTypeRe  = regexp.MustCompile(`^"[^"]*"(\|"[^"]*")*$`)
+	rangeTypeRe = regexp.MustCompile(`^\d+(-\d+|(\|\d+)+)$`)
+)
+
+// jsonSchemaType maps a prompt field type to a JSON Schema
+func jsonSchemaType(typ string) map[string]interface{} {
+	switch {
+	case enumTypeRe.MatchString(typ):
+		var values []string
+		for _, v := range strings.Split(typ, "|") {
+			values = append(values, strings.Trim(v, `"`))
+		}
+		return map[string]interface{}{"type": "string", "enum": values}
+	case strings.HasPrefix(typ, "[") || strings.HasSuffix(typ, "[]") || typ == "array":
+		items := map[string]interface{}{"type": "string"}
+		if strings.HasPrefix(typ, "number") {
+			items = map[string]interface{}{"type": "number"}
+		}
+		return map[string]interface{}{"type": "array", "items": items}
+	case typ == "number" || rangeTypeRe.MatchString(typ):
+		return map[string]interface{}{"type": "number"}
+	case typ == "boolean":
+		return map[string]interface{}{"type": "boolean"}
+	case typ == "object":
+		return map[string]interface{}{"type": "object"}
+	case strings.Contains(typ, "|"):
+		// Mixed alternatives such as object|array
+		return map[string]interface{}{}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestActionCatalog(t *testing.T) {
+	specs := make(map[string]actionSpec)
+	for _, spec := range actionCatalog() {
+		specs[spec.Type] = spec
+	}
+
+	t.Run("every listed action is implemented", func(t *testing.T) {
+		for name := range specs {
+			if err := validateAction(&AgentAction{Type: name}); errors.Is(err, errUnknownAction) {
+				t.Errorf("Expected %s to be a known action", name)
+			}
+		}
+	})
+
+	tests := []struct {
+		name      string
+		signature string
+	}{
+		{"read_file", "read_file(path, maxBytes?, head?, tail?, fromEnd?, lines?, startLine?, endLine?)"},
+		{"list_files", "list_files(path, depth?, pattern?, glob?, format?, detailed?, respectGitignore?)"},
+		{"stats", "stats()"},
+		{"write_file", "write_file(path, content?, append?, backup?, reason?, format?, record?, columns?)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, ok := specs[tt.name]
+			if !ok {
+				t.Fatalf("Expected %s in catalog", tt.name)
+			}
+			if got := spec.signature(); got != tt.signature {
+				t.Errorf("Expected %q, got %q", tt.signature, got)
+			}
+		})
+	}
+}
+
+func TestActionCatalogIncludesCustomActions(t *testing.T) {
+	if err := RegisterAction(CustomAction{
+		Type:  "test_lint",
+		Usage: `test_lint { target: string, fix?: boolean } -> run the linter`,
+		Run:   func(ActionRequest) (string, error) { return "", nil },
+	}); err != nil {
+		t.Fatalf("Expected registration to succeed, got %v", err)
+	}
+	t.Cleanup(func() { unregisterAction("test_lint") })
+
+	for _, tool := range ToolSchemas() {
+		if tool.Name != "test_lint" {
+			continue
+		}
+		expected := map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"target": map[string]interface{}{"type": "string"},
+				"fix":    map[string]interface{}{"type": "boolean"},
+			},
+			"required": []string{"target"},
+		}
+		if !reflect.DeepEqual(tool.Parameters, expected) {
+			t.Errorf("Expected %v, got %v", expected, tool.Parameters)
+		}
+		return
+	}
+	t.Errorf("Expected test_lint in tool schemas")
+}
+
+func TestJSONSchemaType(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected map[string]interface{}
+	}{
+		{"string", map[string]interface{}{"type": "string"}},
+		{"number", map[string]interface{}{"type": "number"}},
+		{"0-3", map[string]interface{}{"type": "number"}},
+		{"4|5", map[string]interface{}{"type": "number"}},
+		{`"unified"|"json"`, map[string]interface{}{"type": "string", "enum": []string{"unified", "json"}}},
+		{"number[]", map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "number"}}},
+		{`["go","js","py"]`, map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}},
+		{"object|array", map[string]interface{}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := jsonSchemaType(tt.input); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestHandleListActions(t *testing.T) {
+	a := newTestAgent(t, t.TempDir())
+
+	run := func(raw string) string {
+		action, err := parseAgentAction(raw)
+		if err != nil {
+			t.Fatalf("Expected valid action, got %v", err)
+		}
+		var transcript []providers.ChatMessage
+		if err := a.executeAction(action, &transcript); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return transcript[1].Content
+	}
+
+	if obs := run(`{"type":"help"}`); !strings.Contains(obs, "\nread_file(path, maxBytes?, head?, tail?, fromEnd?, lines?, startLine?, endLine?)\n") {
+		t.Errorf("Expected compact listing, got %q", obs)
+	}
+	obs := run(`{"type":"list_actions","name":"diff"}`)
+	if !strings.Contains(obs, "diff: compare files") || !strings.Contains(obs, "  context: number (optional)") {
+		t.Errorf("Expected diff details, got %q", obs)
+	}
+	if _, err := parseAgentAction(`{"type":"list_actions","name":"nope"}`); err == nil {
+		t.Errorf("Expected unknown action name to be rejected")
+	}
+}
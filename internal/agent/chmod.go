@@ -0,0 +1,225 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"terminusai/internal/policy"
+	"terminusai/internal/providers"
+)
+
+// permissionBits maps a symbolic permission letter to its bits for the owner;
+// shifting right by 3 or 6 gives the group and other bits
+var permissionBits = map[rune]os.FileMode{'r': 0400, 'w': 0200, 'x': 0100}
+
+// parseFileMode applies a chmod mode to current: either octal ("755",
+// "0644", "4755") or symbolic clauses like "+x", "u+rwx,go-w" or "a=r"
+func parseFileMode(spec string, current os.FileMode) (os.FileMode, error) {
+	if spec == "" {
+		return 0, fmt.Errorf("mode is required")
+	}
+	if spec[0] >= '0' && spec[0] <= '9' {
+		return parseOctalMode(spec, current)
+	}
+
+	mode := current
+	for _, clause := range strings.Split(spec, ",") {
+		opIndex := strings.IndexAny(clause, "+-=")
+		if opIndex < 0 {
+			return 0, fmt.Errorf("invalid mode %q: clause %q has no +, - or =", spec, clause)
+		}
+		who, op, perms := clause[:opIndex], clause[opIndex], clause[opIndex+1:]
+
+		var shifts []uint
+		if who == "" {
+			who = "a"
+		}
+		for _, c := range who {
+			switch c {
+			case 'u':
+				shifts = append(shifts, 0)
+			case 'g':
+				shifts = append(shifts, 3)
+			case 'o':
+				shifts = append(shifts, 6)
+			case 'a':
+				shifts = append(shifts, 0, 3, 6)
+			default:
+				return 0, fmt.Errorf("invalid mode %q: unknown class %q", spec, c)
+			}
+		}
+
+		var bits, mask os.FileMode
+		for _, c := range perms {
+			bit, ok := permissionBits[c]
+			if !ok {
+				return 0, fmt.Errorf("invalid mode %q: unknown permission %q", spec, c)
+			}
+			for _, shift := range shifts {
+				bits |= bit >> shift
+			}
+		}
+		for _, shift := range shifts {
+			mask |= 0700 >> shift
+		}
+
+		switch op {
+		case '+':
+			mode |= bits
+		case '-':
+			mode &^= bits
+		case '=':
+			mode = mode&^mask | bits
+		}
+	}
+	return mode, nil
+}
+
+// parseOctalMode replaces the permission bits of current with an octal mode;
+// the leading digit of a four-digit mode sets setuid, setgid and sticky
+func parseOctalMode(spec string, current os.FileMode) (os.FileMode, error) {
+	value, err := strconv.ParseUint(spec, 8, 32)
+	if err != nil || value > 07777 {
+		return 0, fmt.Errorf("invalid mode %q: octal modes go from 0000 to 7777", spec)
+	}
+	mode := current&^(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky) | os.FileMode(value)&os.ModePerm
+	if value&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if value&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if value&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode, nil
+}
+
+// lookupOwner resolves "user", "user:group" or ":group" (names or numeric ids)
+// to ids; -1 leaves that id unchanged
+func lookupOwner(owner string) (int, int, error) {
+	userName, groupName, _ := strings.Cut(owner, ":")
+	uid, gid := -1, -1
+	if userName != "" {
+		id, err := strconv.Atoi(userName)
+		if err != nil {
+			u, err := user.Lookup(userName)
+			if err != nil {
+				return 0, 0, err
+			}
+			id, _ = strconv.Atoi(u.Uid)
+		}
+		uid = id
+	}
+	if groupName != "" {
+		id, err := strconv.Atoi(groupName)
+		if err != nil {
+			g, err := user.LookupGroup(groupName)
+			if err != nil {
+				return 0, 0, err
+			}
+			id, _ = strconv.Atoi(g.Gid)
+		}
+		gid = id
+	}
+	return uid, gid, nil
+}
+
+// handleChmod changes the permissions of a path
+func (a *Agent) handleChmod(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	command := fmt.Sprintf("chmod %s %s", action.Mode, action.Path)
+	return a.changePermissions(action, transcript, "Change mode", command, func(fullPath string) (string, error) {
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			return "", err
+		}
+		mode, err := parseFileMode(action.Mode, info.Mode())
+		if err != nil {
+			return "", err
+		}
+		if err := os.Chmod(fullPath, mode); err != nil {
+			return "", err
+		}
+		summary := fmt.Sprintf("Mode of %s is now %04o (%s)", action.Path, mode.Perm(), mode)
+		if runtime.GOOS == "windows" {
+			summary += "\nNote: Windows only honours the write bit (read-only attribute)"
+		}
+		return summary, nil
+	})
+}
+
+// handleChown changes the owner and/or group of a path. Windows has no
+// Unix-style ownership, so there it only reports that chown is unsupported.
+func (a *Agent) handleChown(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	if runtime.GOOS == "windows" {
+		actionUI := a.display.ShowAction("Change owner", action.Path, false)
+		a.display.UpdateAction(actionUI, "failed", []string{"Not supported on Windows"})
+		actionJSON, _ := json.Marshal(action)
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:chown error\nchown is not supported on Windows; use icacls through shell to change file ownership or ACLs"},
+		)
+		return nil
+	}
+
+	command := fmt.Sprintf("chown %s %s", action.Owner, action.Path)
+	return a.changePermissions(action, transcript, "Change owner", command, func(fullPath string) (string, error) {
+		uid, gid, err := lookupOwner(action.Owner)
+		if err != nil {
+			return "", err
+		}
+		if err := os.Lchown(fullPath, uid, gid); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Owner of %s is now %s", action.Path, action.Owner), nil
+	})
+}
+
+// changePermissions approves and runs a chmod/chown change, recording the
+// observation
+func (a *Agent) changePermissions(action *AgentAction, transcript *[]providers.ChatMessage, title, command string, change func(fullPath string) (string, error)) error {
+	fullPath := a.absPath(action.Path)
+	actionUI := a.display.ShowAction(title, command, true)
+
+	reason := action.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("Run %s", command)
+	}
+	decision, err := a.policyStore.Approve(action.Type, command, reason, fullPath)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		return err
+	}
+
+	actionJSON, _ := json.Marshal(action)
+	if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+		a.display.UpdateAction(actionUI, "skipped", []string{"User declined"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:%s skipped by user", action.Type)},
+		)
+		return nil
+	}
+
+	summary, err := change(fullPath)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:%s error\n%s", action.Type, err.Error())},
+		)
+		return nil
+	}
+
+	a.display.UpdateAction(actionUI, "completed", []string{summary})
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:%s success\n%s", action.Type, summary)},
+	)
+	return nil
+}
@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseFileMode(t *testing.T) {
+	tests := []struct {
+		spec     string
+		current  os.FileMode
+		expected os.FileMode
+	}{
+		{"755", 0644, 0755},
+		{"0600", 0644, 0600},
+		{"4755", 0644, 0755 | os.ModeSetuid},
+		{"+x", 0644, 0755},
+		{"u+x", 0644, 0744},
+		{"go-w", 0666, 0644},
+		{"a=r", 0755, 0444},
+		{"u=rwx,g=rx,o=", 0600, 0750},
+		{"o+w", os.ModeDir | 0755, os.ModeDir | 0757},
+	}
+	for _, tt := range tests {
+		got, err := parseFileMode(tt.spec, tt.current)
+		if err != nil {
+			t.Errorf("parseFileMode(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("parseFileMode(%q, %v): expected %v, got %v", tt.spec, tt.current, tt.expected, got)
+		}
+	}
+
+	for _, spec := range []string{"", "9", "17777", "x", "u+z", "k+x"} {
+		if _, err := parseFileMode(spec, 0644); err == nil {
+			t.Errorf("Expected parseFileMode(%q) to fail", spec)
+		}
+	}
+}
+
+func TestChmodExecutable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no executable bit")
+	}
+
+	for _, mode := range []string{"0755", "+x"} {
+		t.Run(mode, func(t *testing.T) {
+			dir := t.TempDir()
+			script := filepath.Join(dir, "run.sh")
+			if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0644); err != nil {
+				t.Fatalf("Failed to create script: %v", err)
+			}
+			a := newTestAgent(t, dir)
+
+			observation := runActions(t, a, `{"type":"chmod","path":"run.sh","mode":"`+mode+`"}`)
+			if !strings.HasPrefix(observation, "observation:chmod success\nMode of run.sh is now 0755") {
+				t.Errorf("Expected success, got %q", observation)
+			}
+			info, err := os.Stat(script)
+			if err != nil {
+				t.Fatalf("Failed to stat script: %v", err)
+			}
+			if info.Mode().Perm() != 0755 {
+				t.Errorf("Expected mode 0755, got %04o", info.Mode().Perm())
+			}
+		})
+	}
+}
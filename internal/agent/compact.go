@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"terminusai/internal/providers"
+)
+
+// historySummaryPrefix starts the system message standing in for summarized
+// transcript messages
+const historySummaryPrefix = "Summary of earlier steps:\n"
+
+// compactTranscript keeps the transcript short once it holds more than
+// compactTranscriptAt messages past the first pinned ones: everything between
+// those and the last keepRecentMessages is replaced by a provider-written
+// summary. If summarizing fails the older messages are just dropped.
+func (a *Agent) compactTranscript(transcript []providers.ChatMessage, pinned int) []providers.ChatMessage {
+	if len(transcript) <= pinned+compactTranscriptAt {
+		return transcript
+	}
+
+	older := transcript[pinned : len(transcript)-keepRecentMessages]
+	compacted := append([]providers.ChatMessage{}, transcript[:pinned]...)
+	summary, err := a.summarizeHistory(older)
+	if err == nil {
+		compacted = append(compacted, providers.ChatMessage{Role: "system", Content: historySummaryPrefix + summary})
+	} else if a.verbose || a.debug {
+		fmt.Printf("  ⎿  Dropping %d earlier messages, summarizing them failed: %v\n", len(older), err)
+	}
+	return append(compacted, transcript[len(transcript)-keepRecentMessages:]...)
+}
+
+// summarizeHistory asks the provider to condense messages into a short note
+func (a *Agent) summarizeHistory(messages []providers.ChatMessage) (string, error) {
+	var request strings.Builder
+	for _, msg := range messages {
+		content := msg.Content
+		if strings.HasPrefix(content, historySummaryPrefix) {
+			content = "PREVIOUS SUMMARY: " + strings.TrimPrefix(content, historySummaryPrefix)
+		} else {
+			content = strings.ToUpper(msg.Role) + ": " + truncateString(content, maxHistoryMessageBytes)
+		}
+		request.WriteString(content)
+		request.WriteString("\n\n")
+	}
+
+	summary, err := a.provider.Chat([]providers.ChatMessage{
+		{Role: "system", Content: historySummaryPrompt},
+		{Role: "user", Content: request.String()},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	a.addUsage(providers.LastUsage(a.provider))
+
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return summary, nil
+}
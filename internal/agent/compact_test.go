@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestCompactTranscript(t *testing.T) {
+	pinned := 2
+	transcript := []providers.ChatMessage{
+		{Role: "system", Content: "system prompt"},
+		{Role: "user", Content: "Task: test"},
+	}
+	for i := 0; i < 8; i++ {
+		transcript = append(transcript,
+			providers.ChatMessage{Role: "assistant", Content: fmt.Sprintf(`{"type":"read_file","path":"f%d"}`, i)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:read_file f%d", i)},
+		)
+	}
+
+	t.Run("short transcript is kept", func(t *testing.T) {
+		a := newTestAgentWithProvider(t, t.TempDir(), &stubProvider{})
+		short := transcript[:pinned+compactTranscriptAt]
+		if got := a.compactTranscript(short, pinned); len(got) != len(short) {
+			t.Errorf("Expected %d messages, got %d", len(short), len(got))
+		}
+	})
+
+	t.Run("older messages are summarized", func(t *testing.T) {
+		provider := &stubProvider{responses: []string{"Read f0 to f4."}}
+		a := newTestAgentWithProvider(t, t.TempDir(), provider)
+
+		got := a.compactTranscript(transcript, pinned)
+		if len(got) != pinned+1+keepRecentMessages {
+			t.Fatalf("Expected %d messages, got %d", pinned+1+keepRecentMessages, len(got))
+		}
+		if summary := got[pinned]; summary.Role != "system" || summary.Content != historySummaryPrefix+"Read f0 to f4." {
+			t.Errorf("Expected the summary marker message after the pinned ones, got %+v", summary)
+		}
+		if got[len(got)-1] != transcript[len(transcript)-1] {
+			t.Errorf("Expected the most recent message to be kept")
+		}
+		request := provider.messages[1].Content
+		if !strings.Contains(request, `ASSISTANT: {"type":"read_file","path":"f0"}`) || strings.Contains(request, "f7") {
+			t.Errorf("Expected only the older messages to be summarized, got %q", request)
+		}
+
+		// A later compaction folds the previous summary in
+		longer := append(got, transcript[pinned:pinned+8]...)
+		a.compactTranscript(longer, pinned)
+		if request := provider.messages[1].Content; !strings.HasPrefix(request, "PREVIOUS SUMMARY: Read f0 to f4.") {
+			t.Errorf("Expected the previous summary to be passed on, got %q", request)
+		}
+	})
+
+	t.Run("failed summary falls back to truncation", func(t *testing.T) {
+		a := newTestAgentWithProvider(t, t.TempDir(), &stubProvider{errs: []error{errors.New("401 unauthorized")}})
+
+		got := a.compactTranscript(transcript, pinned)
+		if len(got) != pinned+keepRecentMessages {
+			t.Fatalf("Expected %d messages, got %d", pinned+keepRecentMessages, len(got))
+		}
+		for _, msg := range got {
+			if strings.HasPrefix(msg.Content, historySummaryPrefix) {
+				t.Errorf("Expected no summary message, got %q", msg.Content)
+			}
+		}
+	})
+}
@@ -5,8 +5,56 @@ import "time"
 const (
 	// maxAPIRetries defines the maximum number of retries for API errors
 	maxAPIRetries = 3
-	// retryDelay is the base delay between retries (exponential backoff)
-	retryDelay = 2 * time.Second
+	// retryDelay is the base delay between retries (retry n waits retryDelay*n)
+	retryDelay = 500 * time.Millisecond
+	// retryJitter adds up to this fraction of the delay at random to each retry
+	retryJitter = 0.5
 	// maxFileSize is the maximum file size to process during searches (16MB)
 	maxFileSize = 16 * 1024 * 1024
+	// maxDiffPreviewLines limits the diff shown before approving a file change
+	maxDiffPreviewLines = 40
+	// maxDeletionPreview limits the files listed before approving a glob delete
+	maxDeletionPreview = 20
+	// maxFetchTextBytes caps how much of a page fetch_text downloads
+	maxFetchTextBytes = 5 * 1024 * 1024
+	// maxExtractBytes and maxExtractEntries bound what extracting one archive may write
+	maxExtractBytes   = 2 << 30
+	maxExtractEntries = 50000
+	// maxTableRows limits the rows of ps/stat tables shown in the UI
+	maxTableRows = 15
+	// maxJSONItems and maxJSONLines limit parsed documents shown in the UI;
+	// the model still gets the full text up to the observation limit
+	maxJSONItems = 20
+	maxJSONLines = 60
+	// maxSummarizeInputBytes caps how much of a file is sent to the provider by summarize_file
+	maxSummarizeInputBytes = 100 * 1024
+	// maxReadLines caps the head/tail line count of read_file
+	maxReadLines = 1000
+	// searchFileTimeout bounds how long search_files/grep spend matching one file
+	searchFileTimeout = 2 * time.Second
+	// defaultObservationSummaryBytes is the observation size above which it is
+	// summarized in the transcript and the full text saved to disk
+	defaultObservationSummaryBytes = 16000
+	// maxWaitSeconds caps the timeout of wait_for
+	maxWaitSeconds = 600
+	// defaultMaxOpenFiles caps files held open at once by search/grep/hash walks
+	defaultMaxOpenFiles = 32
+	// defaultShellTimeout is how long a shell command may run unless the action sets timeout
+	defaultShellTimeout = 120
+	// shellKillWait is how long to wait for a killed command's output pipes to close
+	shellKillWait = 2 * time.Second
+	// compactTranscriptAt is how many messages past the pinned ones the
+	// transcript may hold before older ones are summarized; the last
+	// keepRecentMessages stay verbatim
+	compactTranscriptAt = 12
+	keepRecentMessages  = 6
+	// maxHistoryMessageBytes caps each message sent to be summarized
+	maxHistoryMessageBytes = 2000
+	// maxJournalBackupBytes is the largest prior state copied into the undo
+	// journal; bigger files and trees are changed without a backup
+	maxJournalBackupBytes = 64 * 1024 * 1024
+	// maxJournalSessions and maxJournalAge bound the undo journals kept in the
+	// trash; older ones are removed when a new session starts
+	maxJournalSessions = 20
+	maxJournalAge      = 7 * 24 * time.Hour
 )
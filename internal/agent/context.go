@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"terminusai/internal/providers"
+	"terminusai/internal/ui"
+)
+
+const (
+	// maxContextSourceBytes caps each file or URL attached with --context
+	maxContextSourceBytes = 16 * 1024
+	// maxContextTotalBytes caps all attached context together
+	maxContextTotalBytes = 64 * 1024
+)
+
+// SetContextSources attaches files or http(s) URLs whose contents are added to
+// the conversation after the system prompt when a task starts
+func (a *Agent) SetContextSources(sources []string) {
+	a.contextSources = sources
+}
+
+// contextMessages loads the attached context sources as user messages. Sources
+// that can't be read are reported and skipped rather than failing the task.
+func (a *Agent) contextMessages() []providers.ChatMessage {
+	var messages []providers.ChatMessage
+	remaining := maxContextTotalBytes
+	for _, source := range a.contextSources {
+		if remaining <= 0 {
+			ui.Warning.Printf("● Skipping context %s\n", source)
+			ui.Muted.Printf("  ⎿  Context limit of %d bytes reached\n", maxContextTotalBytes)
+			continue
+		}
+
+		content, err := loadContextSource(source)
+		if err != nil {
+			ui.Warning.Printf("● Skipping context %s\n", source)
+			ui.Muted.Printf("  ⎿  %v\n", err)
+			continue
+		}
+
+		limit := min(maxContextSourceBytes, remaining)
+		if len(content) > limit {
+			content = truncateString(content, limit) + "\n... (truncated)"
+		}
+		remaining -= len(content)
+
+		if a.verbose {
+			fmt.Printf("  ⎿  Attached context %s (%d bytes)\n", source, len(content))
+		}
+		messages = append(messages, providers.ChatMessage{
+			Role:    "user",
+			Content: fmt.Sprintf("Reference context from %s:\n%s", source, content),
+		})
+	}
+	return messages
+}
+
+// loadContextSource reads a context file, or fetches it when it is an http(s) URL
+func loadContextSource(source string) (string, error) {
+	var data []byte
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(source)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return "", fmt.Errorf("HTTP %s", resp.Status)
+		}
+		// Read one byte past the cap so truncation is still detected
+		data, err = io.ReadAll(io.LimitReader(resp.Body, maxContextSourceBytes+1))
+		if err != nil {
+			return "", err
+		}
+	} else {
+		var err error
+		data, err = os.ReadFile(source)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return "", fmt.Errorf("file not found")
+			}
+			return "", err
+		}
+	}
+
+	if !utf8.Valid(data) || strings.ContainsRune(string(data), 0) {
+		return "", fmt.Errorf("not a text file")
+	}
+	return string(data), nil
+}
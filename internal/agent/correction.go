@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// actionParseError explains why a model response is not a valid action
+type actionParseError struct {
+	Reason     string // Most specific failure, e.g. "command is required for shell"
+	ActionType string // Type the response asked for, when it got that far
+}
+
+func (e *actionParseError) Error() string {
+	return "could not extract a valid JSON action: " + e.Reason
+}
+
+// DefaultCorrectionTemplate is the message sent back to the model when its
+// response is not a valid action. Templates can use:
+//
+//	{{.Error}}      why the response was rejected
+//	{{.ActionType}} the action type it asked for, or the nearest known type
+//	{{.Schema}}     the tool line for that type from the system prompt (may be empty)
+//	{{.Raw}}        the start of the raw response
+const DefaultCorrectionTemplate = `Invalid action: {{.Error}}.
+{{if .Schema}}Expected format for {{.ActionType}}: {{.Schema}}
+{{end}}Your response started with: {{.Raw}}
+Reply with exactly one JSON object such as {"type": "...", ...} and no other text.`
+
+// correctionData is passed to the correction template
+type correctionData struct {
+	Error      string
+	ActionType string
+	Schema     string
+	Raw        string
+}
+
+// ParseCorrectionTemplate checks a correction template, including that it
+// only refers to known fields
+func ParseCorrectionTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("correction").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, correctionData{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// SetCorrectionTemplate replaces DefaultCorrectionTemplate; empty restores the default
+func (a *Agent) SetCorrectionTemplate(text string) error {
+	if text == "" {
+		a.correctionTemplate = nil
+		return nil
+	}
+	tmpl, err := ParseCorrectionTemplate(text)
+	if err != nil {
+		return fmt.Errorf("invalid correction template: %w", err)
+	}
+	a.correctionTemplate = tmpl
+	return nil
+}
+
+var defaultCorrectionTemplate = template.Must(ParseCorrectionTemplate(DefaultCorrectionTemplate))
+
+// correctionMessage builds the corrective feedback for a response that failed to parse
+func (a *Agent) correctionMessage(raw string, err error) string {
+	data := correctionData{Error: err.Error(), Raw: truncateString(strings.TrimSpace(raw), 200)}
+	if parseErr, ok := err.(*actionParseError); ok {
+		data.Error = parseErr.Reason
+		data.ActionType = parseErr.ActionType
+		if data.ActionType == "" {
+			data.Error = `missing or unrecognised "type" field (` + parseErr.Reason + ")"
+		} else if _, known := actionUsage(data.ActionType); !known {
+			data.ActionType = nearestActionType(data.ActionType)
+		}
+		data.Schema, _ = actionUsage(data.ActionType)
+	}
+
+	tmpl := a.correctionTemplate
+	if tmpl == nil {
+		tmpl = defaultCorrectionTemplate
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		// Validated when set, so this only happens for templates that fail on real data
+		defaultCorrectionTemplate.Execute(&out, data)
+	}
+	return out.String()
+}
+
+// actionUsage returns the system prompt lines describing an action type
+func actionUsage(actionType string) (string, bool) {
+	if actionType == "" {
+		return "", false
+	}
+	var lines []string
+	for _, line := range strings.Split(systemPrompt(), "\n") {
+		if strings.HasPrefix(line, "- "+actionType+" {") {
+			lines = append(lines, strings.TrimSpace(strings.TrimPrefix(line, "- ")))
+		}
+	}
+	return strings.Join(lines, "\n"), len(lines) > 0
+}
+
+// nearestActionType returns the known action type closest to a misspelt one,
+// or "" when nothing is close
+func nearestActionType(actionType string) string {
+	actionType = strings.ToLower(strings.ReplaceAll(actionType, "-", "_"))
+	best, bestDistance := "", len(actionType)/3+2
+	for _, line := range strings.Split(systemPrompt(), "\n") {
+		name, _, ok := strings.Cut(strings.TrimPrefix(line, "- "), " {")
+		if !ok || !strings.HasPrefix(line, "- ") || strings.Contains(name, " ") {
+			continue
+		}
+		if d := editDistance(actionType, name); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(min(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCorrectionMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		contains []string
+	}{
+		{"invalid json", `{"type": "read_file", "path": }`, []string{"invalid JSON", "Your response started with: {\"type\""}},
+		{"no json", `I will now read the file.`, []string{"no JSON object found"}},
+		{"missing field", `{"type": "shell"}`, []string{"command is required for shell", "Expected format for shell: shell { shell:"}},
+		{"misspelt type", `{"type": "read_fiel", "path": "a.txt"}`, []string{"unknown action type: read_fiel", "Expected format for read_file: read_file { path: string"}},
+		{"wrong field type", `{"type": "list_files", "depth": "two"}`, []string{"wrong field type", "Expected format for list_files"}},
+		{"missing type", `{"foo": "bar"}`, []string{`missing or unrecognised "type" field`}},
+	}
+
+	a := newTestAgent(t, t.TempDir())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseAgentAction(tt.raw)
+			if err == nil {
+				t.Fatalf("Expected %q to be rejected", tt.raw)
+			}
+			msg := a.correctionMessage(tt.raw, err)
+			for _, want := range tt.contains {
+				if !strings.Contains(msg, want) {
+					t.Errorf("Expected message to contain %q, got %q", want, msg)
+				}
+			}
+		})
+	}
+}
+
+func TestSetCorrectionTemplate(t *testing.T) {
+	a := newTestAgent(t, t.TempDir())
+	if err := a.SetCorrectionTemplate("{{.Missing}}"); err == nil {
+		t.Errorf("Expected unknown field to be rejected")
+	}
+	if err := a.SetCorrectionTemplate("{{.Error"); err == nil {
+		t.Errorf("Expected syntax error to be rejected")
+	}
+
+	if err := a.SetCorrectionTemplate("Fix it ({{.ActionType}}): {{.Error}}"); err != nil {
+		t.Fatalf("Expected template to be accepted, got %v", err)
+	}
+	_, err := parseAgentAction(`{"type": "shell"}`)
+	if msg := a.correctionMessage(`{"type": "shell"}`, err); msg != "Fix it (shell): command is required for shell" {
+		t.Errorf("Expected custom message, got %q", msg)
+	}
+
+	if err := a.SetCorrectionTemplate(""); err != nil {
+		t.Fatalf("Expected reset to succeed, got %v", err)
+	}
+	if msg := a.correctionMessage(`{"type": "shell"}`, err); !strings.HasPrefix(msg, "Invalid action:") {
+		t.Errorf("Expected default template after reset, got %q", msg)
+	}
+}
+
+func TestNearestActionType(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"read_fiel", "read_file"},
+		{"write-file", "write_file"},
+		{"listfiles", "list_files"},
+		{"launch_rocket", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := nearestActionType(tt.input); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
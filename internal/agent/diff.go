@@ -0,0 +1,218 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// diffOpKind identifies whether a line is kept, inserted or deleted
+type diffOpKind int
+
+const (
+	diffEqual diffOpKind = iota
+	diffInsert
+	diffDelete
+)
+
+// diffOp is a single line of a line-based diff
+type diffOp struct {
+	Kind  diffOpKind
+	Line  string
+	ALine int // 1-based line number in the old text (0 for insertions)
+	BLine int // 1-based line number in the new text (0 for deletions)
+}
+
+// splitLines splits text into lines, ignoring the empty element after a trailing newline
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a minimal line diff between a and b using Myers' algorithm
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+search:
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover the edit script
+	var reversed []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, diffOp{Kind: diffEqual, Line: a[x-1], ALine: x, BLine: y})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, diffOp{Kind: diffInsert, Line: b[y-1], BLine: y})
+				y--
+			} else {
+				reversed = append(reversed, diffOp{Kind: diffDelete, Line: a[x-1], ALine: x})
+				x--
+			}
+		}
+	}
+
+	ops := make([]diffOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+// unifiedDiff renders a unified diff between two texts with the given number of
+// context lines. It returns an empty string when the texts are identical.
+func unifiedDiff(aName, bName, aText, bText string, context int) string {
+	if context < 0 {
+		context = 0
+	}
+
+	ops := diffLines(splitLines(aText), splitLines(bText))
+
+	var changes []int
+	for i, op := range ops {
+		if op.Kind != diffEqual {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+
+	// Group changes whose context windows overlap into hunks
+	for i := 0; i < len(changes); {
+		start := changes[i] - context
+		if start < 0 {
+			start = 0
+		}
+		end := changes[i] + context
+		j := i + 1
+		for j < len(changes) && changes[j]-context <= end+1 {
+			end = changes[j] + context
+			j++
+		}
+		if end >= len(ops) {
+			end = len(ops) - 1
+		}
+
+		writeHunk(&sb, ops[start:end+1], ops[:start])
+		i = j
+	}
+
+	return sb.String()
+}
+
+// writeHunk writes a single @@ hunk; preceding is used to find the starting line numbers
+func writeHunk(sb *strings.Builder, hunk, preceding []diffOp) {
+	aStart, bStart := 0, 0
+	for _, op := range preceding {
+		if op.Kind != diffInsert {
+			aStart = op.ALine
+		}
+		if op.Kind != diffDelete {
+			bStart = op.BLine
+		}
+	}
+
+	aCount, bCount := 0, 0
+	for _, op := range hunk {
+		if op.Kind != diffInsert {
+			aCount++
+		}
+		if op.Kind != diffDelete {
+			bCount++
+		}
+	}
+
+	// Empty ranges point at the line before the change, as in GNU diff
+	if aCount > 0 {
+		aStart++
+	}
+	if bCount > 0 {
+		bStart++
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+	for _, op := range hunk {
+		switch op.Kind {
+		case diffEqual:
+			sb.WriteString(" " + op.Line + "\n")
+		case diffInsert:
+			sb.WriteString("+" + op.Line + "\n")
+		case diffDelete:
+			sb.WriteString("-" + op.Line + "\n")
+		}
+	}
+}
+
+// previewFileChange shows the diff between the current content of fullPath and
+// newContent so the user approves the actual change. Nothing is shown for new
+// files or when every action is auto-approved.
+func (a *Agent) previewFileChange(fullPath, displayPath, newContent string) {
+	if a.policyStore.IsAlwaysAllow() {
+		return
+	}
+
+	current, err := os.ReadFile(fullPath)
+	if err != nil {
+		return
+	}
+
+	diff := unifiedDiff(displayPath, displayPath, string(current), newContent, 3)
+	if diff == "" {
+		a.display.ShowDiff("(no changes)", 0)
+		return
+	}
+	a.display.ShowDiff(diff, maxDiffPreviewLines)
+}
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		context  int
+		expected string
+	}{
+		{
+			name:     "identical",
+			a:        "one\ntwo\n",
+			b:        "one\ntwo\n",
+			context:  3,
+			expected: "",
+		},
+		{
+			name:     "insert at top",
+			a:        "one\ntwo\nthree\n",
+			b:        "zero\none\ntwo\nthree\n",
+			context:  1,
+			expected: "--- a\n+++ b\n@@ -1,1 +1,2 @@\n+zero\n one\n",
+		},
+		{
+			name:     "change in middle",
+			a:        "one\ntwo\nthree\n",
+			b:        "one\n2\nthree\n",
+			context:  1,
+			expected: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n",
+		},
+		{
+			name:     "delete last line",
+			a:        "one\ntwo\n",
+			b:        "one\n",
+			context:  0,
+			expected: "--- a\n+++ b\n@@ -2,1 +1,0 @@\n-two\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := unifiedDiff("a", "b", tt.a, tt.b, tt.context)
+			if result != tt.expected {
+				t.Errorf("Expected diff %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestHandleDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		format   string
+		context  int
+		expected string
+	}{
+		{"insertion", "one\ntwo\nthree\n", "zero\none\ntwo\nthree\n", "", 1, "observation:diff success\n--- a.txt\n+++ b.txt\n@@ -1,1 +1,2 @@\n+zero\n one\n"},
+		{"deletion", "one\ntwo\nthree\n", "one\nthree\n", "unified", 3, "observation:diff success\n--- a.txt\n+++ b.txt\n@@ -1,3 +1,2 @@\n one\n-two\n three\n"},
+		{"identical", "one\ntwo\n", "one\ntwo\n", "", 3, "observation:diff success\nFiles are identical"},
+		{"side by side insertion", "one\ntwo\n", "zero\none\ntwo\n", "side-by-side", 3, "observation:diff success\n+1: zero"},
+		{"side by side change", "one\ntwo\nthree\n", "one\n2\nthree\n", "side-by-side", 3, "observation:diff success\n-2: two\n+2: 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, "a.txt"), []byte(tt.a), 0644)
+			os.WriteFile(filepath.Join(dir, "b.txt"), []byte(tt.b), 0644)
+
+			a := newTestAgent(t, dir)
+			context := tt.context
+			action := &AgentAction{Type: "diff", APath: "a.txt", BPath: "b.txt", Format: tt.format, Context: &context}
+			if err := validateAction(action); err != nil {
+				t.Fatalf("Expected valid action, got %v", err)
+			}
+			var transcript []providers.ChatMessage
+			if err := a.handleDiff(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if obs := transcript[1].Content; obs != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, obs)
+			}
+		})
+	}
+
+	if err := validateAction(&AgentAction{Type: "diff", APath: "a", BPath: "b", Format: "json"}); err == nil || !strings.Contains(err.Error(), "unified or side-by-side") {
+		t.Errorf("Expected unknown format to be rejected, got %v", err)
+	}
+}
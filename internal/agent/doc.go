@@ -0,0 +1,31 @@
+// Package agent runs tasks by asking an LLM provider for one action at a time,
+// executing it with the user's approval and feeding the observation back.
+//
+// # Custom actions
+//
+// Programs embedding the agent can add their own action types with
+// RegisterAction; see CustomAction for the handler signature and what the
+// model is told about the result.
+//
+// # Concurrency
+//
+// Each Agent keeps its own state (transcript, working directory, model and
+// temperature overrides, and the environment set by env_set, which is applied
+// to the commands it runs instead of the process environment), so separate
+// Agent values may run tasks concurrently. RunTask calls on the same Agent are
+// serialised, and SetMaxConcurrentTasks caps how many run at once across all
+// agents in the process.
+//
+// Some state is still shared by the whole process and must be set up once by
+// an embedding program rather than per agent:
+//
+//   - policy.Store prompts on stdin unless given a prompter; give each agent
+//     its own store, or a Session of one shared store so remembered rules
+//     are saved together.
+//   - Output goes to stdout and colors are toggled globally through
+//     ui.EnableColors/ui.DisableColors (fatih/color's NoColor).
+//   - config.GetConfigManager is a process-wide singleton; build providers for
+//     concurrent agents from separate config.NewConfigManager values with
+//     providers.NewProviderWithConfig.
+//   - Ctrl+C is delivered to every agent that is executing an action.
+package agent
@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"terminusai/internal/ui"
+)
+
+// errDownloadTooLarge reports a download that went past its maxBytes cap
+type errDownloadTooLarge struct {
+	limit int64
+}
+
+func (e errDownloadTooLarge) Error() string {
+	return fmt.Sprintf("download exceeds maxBytes (%d bytes)", e.limit)
+}
+
+// downloadProgress counts the bytes written through it and redraws a progress
+// bar at most every 100ms when the total size is known
+type downloadProgress struct {
+	bar      *ui.ProgressBar
+	written  int64
+	lastDraw time.Time
+}
+
+func (p *downloadProgress) Write(b []byte) (int, error) {
+	p.written += int64(len(b))
+	if p.bar != nil && time.Since(p.lastDraw) >= 100*time.Millisecond {
+		p.bar.Update(int(p.written))
+		p.lastDraw = time.Now()
+	}
+	return len(b), nil
+}
+
+// requestDownload sends the GET for download_file with the action's headers,
+// asking for the bytes from offset on when it is positive. The range is sent
+// with If-Range: validator, so a server whose file changed sends all of it.
+func (a *Agent) requestDownload(client *http.Client, action *AgentAction, offset int64, validator string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(a.actionContext(), http.MethodGet, action.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range action.Headers {
+		req.Header.Set(key, value)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+	}
+	return client.Do(req)
+}
+
+// downloadValidator returns the validator a response can be resumed with: a
+// strong ETag, or else its Last-Modified date ("" when it has neither)
+func downloadValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// contentRangeStart returns the first byte of a "bytes start-end/size"
+// Content-Range, or -1 when it can't be parsed
+func contentRangeStart(contentRange string) int64 {
+	spec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return -1
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return -1
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return start
+}
+
+// setDownloadValidator remembers the validator of the partial file at path,
+// or forgets it when validator is empty
+func (a *Agent) setDownloadValidator(path, validator string) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	if validator == "" {
+		delete(a.downloadValidators, path)
+		return
+	}
+	if a.downloadValidators == nil {
+		a.downloadValidators = make(map[string]string)
+	}
+	a.downloadValidators[path] = validator
+}
+
+// savedDownloadValidator returns the validator remembered for the partial file at path
+func (a *Agent) savedDownloadValidator(path string) string {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	return a.downloadValidators[path]
+}
+
+// copyDownload copies body to dst, which already holds start bytes of the
+// file, drawing progress when the remaining size total is known. With
+// limit > 0 it stops with errDownloadTooLarge as soon as the file would grow
+// past limit bytes. It returns the number of bytes copied.
+func copyDownload(dst io.Writer, body io.Reader, total, limit, start int64) (int64, error) {
+	progress := &downloadProgress{written: start}
+	if total > 0 {
+		progress.bar = ui.NewProgressBar(int(start+total), "  ⎿  Downloading")
+	}
+
+	src := body
+	if limit > 0 {
+		src = io.LimitReader(body, max(limit-start, 0)+1)
+	}
+	n, err := io.Copy(io.MultiWriter(dst, progress), src)
+	if err != nil {
+		return n, err
+	}
+	if limit > 0 && start+n > limit {
+		return n, errDownloadTooLarge{limit: limit}
+	}
+	return n, nil
+}
@@ -0,0 +1,185 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"terminusai/internal/providers"
+)
+
+func TestHandleDownloadFileMaxBytes(t *testing.T) {
+	payload := strings.Repeat("x", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/chunked" {
+			// No Content-Length, so the cap is only hit while copying
+			w.Write([]byte(payload[:500]))
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	intPtr := func(v int) *int { return &v }
+	auth := map[string]string{"Authorization": "Bearer token"}
+
+	tests := []struct {
+		name     string
+		path     string
+		headers  map[string]string
+		maxBytes *int
+		expected string
+		size     int // Expected file size, -1 when no file may remain
+	}{
+		{"within the cap", "/", auth, intPtr(1000), "observation:download_file success\nDownloaded 1000 bytes", 1000},
+		{"no cap", "/chunked", auth, nil, "observation:download_file success\nDownloaded 1500 bytes", 1500},
+		{"declared size over the cap", "/", auth, intPtr(100), "observation:download_file error\ndownload exceeds maxBytes (100 bytes)", -1},
+		{"streamed size over the cap", "/chunked", auth, intPtr(700), "observation:download_file error\ndownload exceeds maxBytes (700 bytes)", -1},
+		{"missing headers", "/", nil, nil, "observation:download_file error\nHTTP 401", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			a := newTestAgent(t, dir)
+			action := &AgentAction{Type: "download_file", URL: server.URL + tt.path, Dest: "out.bin", Headers: tt.headers, MaxBytes: tt.maxBytes}
+			var transcript []providers.ChatMessage
+			if err := a.handleDownloadFile(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if obs := transcript[1].Content; !strings.HasPrefix(obs, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, obs)
+			}
+			info, err := os.Stat(filepath.Join(dir, "out.bin"))
+			if tt.size < 0 {
+				if err == nil {
+					t.Errorf("Expected no file to remain, found %d bytes", info.Size())
+				}
+				return
+			}
+			if err != nil || info.Size() != int64(tt.size) {
+				t.Errorf("Expected a %d byte file, got %v (%v)", tt.size, info, err)
+			}
+		})
+	}
+}
+
+func TestHandleDownloadFileResume(t *testing.T) {
+	payload := "0123456789abcdefghij"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/norange":
+			w.Write([]byte(payload))
+			return
+		case "/wrongrange":
+			// Answers any range with the file from its start
+			if r.Header.Get("Range") != "" {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(payload)-1, len(payload)))
+				w.WriteHeader(http.StatusPartialContent)
+			}
+			w.Write([]byte(payload))
+			return
+		}
+		// ServeContent honours Range and If-Range with 206 and 416 replies
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "payload.txt", time.Time{}, strings.NewReader(payload))
+	}))
+	defer server.Close()
+
+	boolPtr := func(v bool) *bool { return &v }
+
+	tests := []struct {
+		name      string
+		path      string
+		existing  string
+		validator string // Saved from the interrupted download
+		resume    *bool
+		expected  string
+	}{
+		{"no existing file", "/", "", "", nil, "Downloaded 20 bytes to out.txt\nStarted fresh"},
+		{"resumes a partial file", "/", "0123456", `"v2"`, boolPtr(true), "Downloaded 13 bytes to out.txt\nResumed from byte 7 (file is now 20 bytes)"},
+		{"existing file replaced without resume", "/", "0123456", `"v2"`, nil, "Downloaded 20 bytes to out.txt\nStarted fresh"},
+		{"no interrupted download to resume", "/", "0123456", "", boolPtr(true), "Downloaded 20 bytes to out.txt\nStarted fresh (no interrupted download of this file to resume)"},
+		{"remote file changed", "/", "0123456", `"v1"`, boolPtr(true), "Downloaded 20 bytes to out.txt\nStarted fresh"},
+		{"server ignores the range", "/norange", "0123456", `"v2"`, boolPtr(true), "Downloaded 20 bytes to out.txt\nStarted fresh"},
+		{"server sends another range", "/wrongrange", "0123456", `"v2"`, boolPtr(true), "Downloaded 20 bytes to out.txt\nStarted fresh"},
+		{"partial file longer than the remote", "/", payload + "extra", `"v2"`, boolPtr(true), "Downloaded 20 bytes to out.txt\nStarted fresh"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			dest := filepath.Join(dir, "out.txt")
+			if tt.existing != "" {
+				if err := os.WriteFile(dest, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			a := newTestAgent(t, dir)
+			a.setDownloadValidator(dest, tt.validator)
+			action := &AgentAction{Type: "download_file", URL: server.URL + tt.path, Dest: "out.txt", Resume: tt.resume}
+			var transcript []providers.ChatMessage
+			if err := a.handleDownloadFile(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if expected := "observation:download_file success\n" + tt.expected; transcript[1].Content != expected {
+				t.Errorf("Expected %q, got %q", expected, transcript[1].Content)
+			}
+			if content, _ := os.ReadFile(dest); string(content) != payload {
+				t.Errorf("Expected the full payload, got %q", content)
+			}
+		})
+	}
+}
+
+func TestHandleDownloadFileResumesInterrupted(t *testing.T) {
+	payload := "0123456789abcdefghij"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("Range") == "" {
+			// Promise the whole file but drop the connection partway
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			w.Write([]byte(payload[:7]))
+			return
+		}
+		http.ServeContent(w, r, "payload.txt", time.Time{}, strings.NewReader(payload))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	a := newTestAgent(t, dir)
+	resume := true
+	action := &AgentAction{Type: "download_file", URL: server.URL, Dest: "out.txt", Resume: &resume}
+
+	var transcript []providers.ChatMessage
+	if err := a.handleDownloadFile(action, &transcript); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if obs := transcript[1].Content; !strings.Contains(obs, "Partial file kept at out.txt (7 bytes); download_file again with resume: true") {
+		t.Fatalf("Expected the partial file to be kept for resuming, got %q", obs)
+	}
+
+	transcript = nil
+	if err := a.handleDownloadFile(action, &transcript); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if expected := "observation:download_file success\nDownloaded 13 bytes to out.txt\nResumed from byte 7 (file is now 20 bytes)"; transcript[1].Content != expected {
+		t.Errorf("Expected %q, got %q", expected, transcript[1].Content)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "out.txt")); string(content) != payload {
+		t.Errorf("Expected the full payload, got %q", content)
+	}
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"terminusai/internal/providers"
+)
+
+// systemChangingActions run commands or change state outside the agent:
+// together with fileMutatingActions they are only described in dry-run mode
+var systemChangingActions = map[string]bool{
+	"shell": true, "git": true, "kill": true, "install_package": true, "run_tests": true, "lint_file": true,
+}
+
+// SetDryRun makes the agent describe actions that would change the system
+// instead of running them; read-only actions still run
+func (a *Agent) SetDryRun(enabled bool) {
+	a.dryRun = enabled
+}
+
+// changesSystem reports whether action would change files, processes,
+// packages, remote state or the user's environment. Custom actions run
+// commands, so they count as changes.
+func changesSystem(action *AgentAction) bool {
+	switch {
+	case fileMutatingActions[action.Type], systemChangingActions[action.Type]:
+		return true
+	case action.Type == "http_request":
+		return action.Method != "GET" && action.Method != "HEAD"
+	case action.Type == "env_set":
+		return action.Persist != nil && *action.Persist
+	case action.Type == "wait_for":
+		return action.Condition == "command"
+	case action.Type == "hash_dir":
+		return action.Dest != ""
+	}
+	_, custom := lookupAction(action.Type)
+	return custom
+}
+
+// handleDryRun records what action would do without running it
+func (a *Agent) handleDryRun(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	description := dryRunDescription(action)
+	actionUI := a.display.ShowAction("Dry run", description, false)
+	a.display.UpdateAction(actionUI, "skipped", []string{"Not executed (dry-run)"})
+
+	actionJSON, _ := json.Marshal(action)
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:%s skipped (dry-run)\nWould %s. Nothing was changed; continue as if it had succeeded.", action.Type, description)},
+	)
+	return nil
+}
+
+// dryRunDescription says in a few words what action would do
+func dryRunDescription(action *AgentAction) string {
+	switch action.Type {
+	case "shell", "git":
+		return fmt.Sprintf("run %s: %s", action.Type, action.Command)
+	case "wait_for":
+		return fmt.Sprintf("run %s until it succeeds", action.Command)
+	case "hash_dir":
+		return fmt.Sprintf("write checksums of %s to %s", action.Path, action.Dest)
+	case "kill":
+		if action.ProcessID != nil {
+			return fmt.Sprintf("kill process %d", *action.ProcessID)
+		}
+		return "kill a process"
+	case "install_package":
+		return fmt.Sprintf("install %s with %s", action.Name, action.Manager)
+	case "http_request":
+		return fmt.Sprintf("send %s %s", action.Method, action.URL)
+	case "download_file":
+		return fmt.Sprintf("download %s to %s", action.URL, action.Dest)
+	case "copy_path", "move_path":
+		return fmt.Sprintf("%s %s to %s", action.Type, action.Src, action.Dest)
+	case "symlink":
+		return fmt.Sprintf("link %s to %s", action.Dest, action.Src)
+	case "write_file":
+		return fmt.Sprintf("write %d bytes to %s", len(action.Content), action.Path)
+	case "env_set":
+		return fmt.Sprintf("persist %s in the user environment", action.Key)
+	case "undo":
+		return fmt.Sprintf("revert the last %d file change(s)", *action.Count)
+	}
+	if action.Path != "" {
+		return fmt.Sprintf("%s %s", action.Type, action.Path)
+	}
+	return action.Type
+}
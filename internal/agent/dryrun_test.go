@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "existing.txt"), []byte("keep me"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		action   string
+		expected string // Observation prefix
+	}{
+		{"write_file", `{"type":"write_file","path":"new.txt","content":"hello"}`, "observation:write_file skipped (dry-run)\nWould write 5 bytes to new.txt."},
+		{"delete_path", `{"type":"delete_path","path":"existing.txt"}`, "observation:delete_path skipped (dry-run)\nWould delete_path existing.txt."},
+		{"shell", `{"type":"shell","shell":"bash","command":"touch made.txt"}`, "observation:shell skipped (dry-run)\nWould run shell: touch made.txt."},
+		{"post request", `{"type":"http_request","url":"http://127.0.0.1:1/x","method":"POST"}`, "observation:http_request skipped (dry-run)\nWould send POST http://127.0.0.1:1/x."},
+		{"wait_for command", `{"type":"wait_for","condition":"command","shell":"bash","command":"touch waited.txt","timeout":5}`, "observation:wait_for skipped (dry-run)\nWould run touch waited.txt until it succeeds."},
+		{"hash_dir with dest", `{"type":"hash_dir","path":".","dest":"SHA256SUMS"}`, "observation:hash_dir skipped (dry-run)\nWould write checksums of . to SHA256SUMS."},
+		{"lint_file", `{"type":"lint_file","path":"main.go"}`, "observation:lint_file skipped (dry-run)\nWould lint_file main.go."},
+		{"wait_for file runs", `{"type":"wait_for","condition":"file","path":"existing.txt","timeout":5}`, "observation:wait_for success"},
+		{"read-only action runs", `{"type":"read_file","path":"existing.txt"}`, "observation:read_file existing.txt\nkeep me"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAgent(t, dir)
+			a.SetDryRun(true)
+			action, err := parseAgentAction(tt.action)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			var transcript []providers.ChatMessage
+			if err := a.executeAction(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if obs := transcript[len(transcript)-1].Content; !strings.HasPrefix(obs, tt.expected) {
+				t.Errorf("Expected observation starting %q, got %q", tt.expected, obs)
+			}
+		})
+	}
+
+	for _, name := range []string{"new.txt", "made.txt", "waited.txt", "SHA256SUMS"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be absent after a dry run, got %v", name, err)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "existing.txt")); err != nil || string(data) != "keep me" {
+		t.Errorf("Expected existing.txt untouched, got %q (%v)", data, err)
+	}
+}
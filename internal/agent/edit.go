@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// anchorEdit is the result of applying an edit_file action to a file's text
+type anchorEdit struct {
+	Text      string // The new file text
+	StartLine int    // First line of the edited region in the new text
+	Removed   int    // Lines removed
+	Added     int    // Lines inserted
+}
+
+// applyAnchorEdit edits text around unique anchors:
+//   - before and after: content replaces everything between them (anchors kept)
+//   - before only: content is inserted right after before
+//   - after only: content is inserted right before after
+//
+// before must occur exactly once in text; after exactly once in the text that
+// follows before (or in the whole text when there is no before). When the file
+// uses CRLF line endings, anchors and content written with LF are converted.
+func applyAnchorEdit(text, before, after, content string) (anchorEdit, error) {
+	if before == "" && after == "" {
+		return anchorEdit{}, fmt.Errorf("before or after anchor is required")
+	}
+	if strings.Contains(text, "\r\n") {
+		before, after, content = toCRLF(before), toCRLF(after), toCRLF(content)
+	}
+
+	start, end := 0, len(text)
+	if before != "" {
+		i, err := findUniqueAnchor(text, before, "before")
+		if err != nil {
+			return anchorEdit{}, err
+		}
+		start = i + len(before)
+		end = start
+	}
+	if after != "" {
+		i, err := findUniqueAnchor(text[start:], after, "after")
+		if err != nil {
+			return anchorEdit{}, err
+		}
+		end = start + i
+		if before == "" {
+			start = end
+		}
+	}
+
+	return anchorEdit{
+		Text:      text[:start] + content + text[end:],
+		StartLine: strings.Count(text[:start], "\n") + 1,
+		Removed:   strings.Count(text[start:end], "\n"),
+		Added:     strings.Count(content, "\n"),
+	}, nil
+}
+
+// findUniqueAnchor returns the offset of anchor in text, failing unless it
+// occurs exactly once
+func findUniqueAnchor(text, anchor, name string) (int, error) {
+	switch n := strings.Count(text, anchor); n {
+	case 0:
+		return 0, fmt.Errorf("%s anchor not found: %q", name, truncateString(anchor, 80))
+	case 1:
+		return strings.Index(text, anchor), nil
+	default:
+		return 0, fmt.Errorf("%s anchor matches %d places; include more surrounding text to make it unique: %q", name, n, truncateString(anchor, 80))
+	}
+}
+
+// toCRLF converts LF line endings to CRLF, leaving existing CRLF alone
+func toCRLF(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
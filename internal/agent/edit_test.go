@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestApplyAnchorEdit(t *testing.T) {
+	text := "func a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 1\n}\n"
+
+	tests := []struct {
+		name      string
+		text      string
+		before    string
+		after     string
+		content   string
+		expected  string
+		expectErr string
+	}{
+		{"replace between anchors", text, "func b() {\n", "}\n", "\treturn 2\n", "func a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 2\n}\n", ""},
+		{"insert after", text, "func a() {\n", "", "\t// first\n", "func a() {\n\t// first\n\treturn 1\n}\n\nfunc b() {\n\treturn 1\n}\n", ""},
+		{"insert before", "a\nc\n", "", "c\n", "b\n", "a\nb\nc\n", ""},
+		{"after searched past before only", text, "func a() {\n", "\treturn 1\n}\n\nfunc b", "\t// body\n", "func a() {\n\t// body\n\treturn 1\n}\n\nfunc b() {\n\treturn 1\n}\n", ""},
+		{"crlf file with lf anchors", "a\r\nb\r\nc\r\n", "a\n", "c\n", "x\ny\n", "a\r\nx\r\ny\r\nc\r\n", ""},
+		{"missing anchor", text, "func c() {", "", "", "", "before anchor not found"},
+		{"ambiguous anchor", text, "\treturn 1\n", "", "", "", "before anchor matches 2 places"},
+		{"after before before", text, "func b() {", "func a() {", "", "", "after anchor not found"},
+		{"no anchors", text, "", "", "x", "", "anchor is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := applyAnchorEdit(tt.text, tt.before, tt.after, tt.content)
+			if tt.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result.Text != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result.Text)
+			}
+		})
+	}
+}
+
+func TestHandleEditFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	a := newTestAgent(t, dir)
+
+	run := func(raw string) string {
+		action, err := parseAgentAction(raw)
+		if err != nil {
+			t.Fatalf("Expected valid action, got %v", err)
+		}
+		var transcript []providers.ChatMessage
+		if err := a.executeAction(action, &transcript); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return transcript[1].Content
+	}
+
+	obs := run(`{"type":"edit_file","path":"main.go","before":"func main() {\n","after":"}\n","content":"\tprintln(\"hello\")\n"}`)
+	if !strings.HasPrefix(obs, "observation:edit_file success\nmain.go edited at line 4 (-1/+1 lines)") || !strings.Contains(obs, "+\tprintln(\"hello\")") {
+		t.Errorf("Expected success with diff, got %q", obs)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "println(\"hello\")") {
+		t.Errorf("Expected file to be edited, got %q", data)
+	}
+
+	if obs := run(`{"type":"edit_file","path":"main.go","before":"func missing()","content":"x"}`); !strings.HasPrefix(obs, "observation:edit_file error\nbefore anchor not found") {
+		t.Errorf("Expected anchor error, got %q", obs)
+	}
+	if _, err := parseAgentAction(`{"type":"edit_file","path":"main.go","content":"x"}`); err == nil {
+		t.Errorf("Expected missing anchors to be rejected")
+	}
+}
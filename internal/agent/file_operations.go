@@ -1,47 +1,352 @@
 package agent
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
-// listDir recursively lists directory contents
-func listDir(path string, depth int, lines *[]string, basePath string) error {
+// tailChunkSize is how much readTailLines reads per step from the end of a file
+const tailChunkSize = 8192
+
+// listEntry is one entry found by listEntries
+type listEntry struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"` // Relative to the listed directory, with forward slashes
+	Size    int64     `json:"size"`
+	IsDir   bool      `json:"isDir"`
+	ModTime time.Time `json:"mtime"`
+	mode    os.FileMode
+	level   int  // Nesting below the listed directory, for indentation
+	failed  bool // Marks a directory that could not be read
+}
+
+// listDir recursively lists directory contents. A non-empty pattern keeps only
+// files matching it (see matchListPattern); directories are still traversed up
+// to depth and shown when something below them matches.
+func listDir(path string, depth int, lines *[]string, basePath, pattern string) error {
+	var entries []listEntry
+	if err := listEntries(path, depth, &entries, basePath, pattern, nil); err != nil {
+		return err
+	}
+	*lines = append(*lines, formatListNames(entries)...)
+	return nil
+}
+
+// listEntries walks a directory like listDir, keeping each entry's metadata.
+// Paths excluded by ignore, the .gitignore rules in effect for path, are left
+// out (nil lists everything).
+func listEntries(path string, depth int, entries *[]listEntry, basePath, pattern string, ignore *gitignore) error {
 	if depth < 0 {
 		return nil
 	}
 
-	entries, err := os.ReadDir(path)
+	dirEntries, err := os.ReadDir(path)
 	if err != nil {
 		return err
 	}
 
-	for _, entry := range entries {
+	for _, entry := range dirEntries {
 		fullPath := filepath.Join(path, entry.Name())
-		
+		if ignore.ignored(fullPath, entry.IsDir()) {
+			continue
+		}
+
 		// Calculate relative path from base
 		relPath, err := filepath.Rel(basePath, fullPath)
 		if err != nil {
 			relPath = fullPath
 		}
 
-		// Add indentation based on depth level
-		indent := strings.Repeat("  ", len(strings.Split(relPath, string(filepath.Separator)))-1)
-		
+		item := listEntry{
+			Name:  entry.Name(),
+			Path:  filepath.ToSlash(relPath),
+			IsDir: entry.IsDir(),
+			level: len(strings.Split(relPath, string(filepath.Separator))) - 1,
+		}
+		if info, err := entry.Info(); err == nil {
+			item.Size = info.Size()
+			item.ModTime = info.ModTime()
+			item.mode = info.Mode()
+		}
+
 		if entry.IsDir() {
-			*lines = append(*lines, indent+entry.Name()+"/")
+			var children []listEntry
 			// Recursively list subdirectories if depth allows
 			if depth > 0 {
-				if err := listDir(fullPath, depth-1, lines, basePath); err != nil {
+				if err := listEntries(fullPath, depth-1, &children, basePath, pattern, ignore.enter(fullPath)); err != nil {
 					// Continue on error, just note it
-					*lines = append(*lines, indent+"  (error reading directory)")
+					children = append(children, listEntry{level: item.level + 1, failed: true})
 				}
 			}
-		} else {
-			*lines = append(*lines, indent+entry.Name())
+			if pattern == "" || len(children) > 0 || matchListPattern(pattern, relPath) {
+				*entries = append(*entries, item)
+				*entries = append(*entries, children...)
+			}
+		} else if pattern == "" || matchListPattern(pattern, relPath) {
+			*entries = append(*entries, item)
 		}
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// formatListNames renders entries as an indented tree of names
+func formatListNames(entries []listEntry) []string {
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		indent := strings.Repeat("  ", e.level)
+		switch {
+		case e.failed:
+			lines = append(lines, indent+"(error reading directory)")
+		case e.IsDir:
+			lines = append(lines, indent+e.Name+"/")
+		default:
+			lines = append(lines, indent+e.Name)
+		}
+	}
+	return lines
+}
+
+// formatListLong renders entries like ls -l: mode, size, modification time, name
+func formatListLong(entries []listEntry) []string {
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		indent := strings.Repeat("  ", e.level)
+		if e.failed {
+			lines = append(lines, fmt.Sprintf("%-10s %10s %16s  %s(error reading directory)", "?", "-", "-", indent))
+			continue
+		}
+		name := e.Name
+		if e.IsDir {
+			name += "/"
+		}
+		lines = append(lines, fmt.Sprintf("%-10s %10d %16s  %s%s", e.mode, e.Size, e.ModTime.Format("2006-01-02 15:04"), indent, name))
+	}
+	return lines
+}
+
+// matchListPattern matches a glob against an entry's name, or against its path
+// relative to the listed directory when the pattern contains a slash
+// (e.g. "cmd/*/main.go"), where a "**" segment spans directories (e.g. "**/*.md")
+func matchListPattern(pattern, relPath string) bool {
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, filepath.Base(relPath))
+		return matched
+	}
+	return matchGlobPath(pattern, filepath.ToSlash(relPath))
+}
+
+// filterListGlob keeps the entries whose path relative to the listed directory
+// matches glob, which may use "**" to span directories (e.g. "**/*.md"), plus
+// the directories leading to them. It also returns how many entries matched
+// and how many were scanned.
+func filterListGlob(entries []listEntry, glob string) ([]listEntry, int, int) {
+	keep := make([]bool, len(entries))
+	parents := make(map[string]bool)
+	matched, scanned := 0, 0
+	for i, e := range entries {
+		if e.failed {
+			continue
+		}
+		scanned++
+		if matchGlobPath(glob, e.Path) {
+			keep[i] = true
+			matched++
+			for dir := path.Dir(e.Path); dir != "."; dir = path.Dir(dir) {
+				parents[dir] = true
+			}
+		}
+	}
+
+	kept := make([]listEntry, 0, matched+len(parents))
+	for i, e := range entries {
+		if keep[i] || (e.IsDir && parents[e.Path]) {
+			kept = append(kept, e)
+		}
+	}
+	return kept, matched, scanned
+}
+
+// readHeadLines returns the first n lines of a file without reading the rest
+func readHeadLines(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	reader := bufio.NewReader(f)
+	for len(lines) < n {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			lines = append(lines, strings.TrimRight(line, "\r\n"))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+// readLineRange returns lines start to end (1-based, inclusive) of a file,
+// clamped to its length, and how many lines the file has
+func readLineRange(path string, start, end int) ([]string, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var lines []string
+	total := 0
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			total++
+			if total >= start && total <= end {
+				lines = append(lines, strings.TrimRight(line, "\r\n"))
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return lines, total, nil
+}
+
+// readTailLines returns the last n lines of a file and the 1-based line number
+// of the first one. It seeks backwards in chunks so only the tail is held in
+// memory; the line number is found by counting newlines in the skipped prefix.
+func readTailLines(path string, n int) ([]string, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pos := info.Size()
+	var tail []byte
+	for pos > 0 {
+		size := int64(tailChunkSize)
+		if pos < size {
+			size = pos
+		}
+		pos -= size
+		chunk := make([]byte, size)
+		if _, err := f.ReadAt(chunk, pos); err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		tail = append(chunk, tail...)
+
+		// A trailing newline ends the last line rather than starting an empty one
+		if bytes.Count(bytes.TrimSuffix(tail, []byte("\n")), []byte("\n")) >= n {
+			break
+		}
+	}
+
+	text := strings.TrimSuffix(string(tail), "\n")
+	if text == "" && pos == 0 {
+		return nil, 0, nil
+	}
+	lines := strings.Split(text, "\n")
+	if pos > 0 {
+		// The first line may be cut by the chunk boundary
+		lines = lines[1:]
+	}
+	skipped := 0
+	if len(lines) > n {
+		skipped = len(lines) - n
+		lines = lines[skipped:]
+	}
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+
+	// Line number of the first line in tail, plus the lines dropped above
+	first := 1 + skipped
+	if pos > 0 {
+		prefix, err := countLines(io.NewSectionReader(f, 0, pos))
+		if err != nil {
+			return nil, 0, err
+		}
+		// The partial line at the boundary started in the prefix
+		first += prefix + 1
+	}
+	return lines, first, nil
+}
+
+// readTailBytes returns up to the last n bytes of a file and the file's size,
+// reading only the tail. A UTF-8 sequence cut by the start is dropped.
+func readTailBytes(path string, n int) ([]byte, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size := info.Size()
+	start := size - int64(n)
+	if start < 0 {
+		start = 0
+	}
+	data := make([]byte, size-start)
+	if _, err := f.ReadAt(data, start); err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	skip := 0
+	for start > 0 && skip < len(data) && skip < utf8.UTFMax-1 && !utf8.RuneStart(data[skip]) {
+		skip++
+	}
+	return data[skip:], size, nil
+}
+
+// countLines counts newlines in r without holding it in memory
+func countLines(r io.Reader) (int, error) {
+	buf := make([]byte, 32*1024)
+	count := 0
+	for {
+		n, err := r.Read(buf)
+		count += bytes.Count(buf[:n], []byte("\n"))
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+}
+
+// numberLines prefixes each line with its 1-based line number, starting at first
+func numberLines(lines []string, first int) string {
+	var sb strings.Builder
+	for i, line := range lines {
+		sb.WriteString(fmt.Sprintf("%6d  %s\n", first+i, line))
+	}
+	return sb.String()
+}
@@ -0,0 +1,555 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"terminusai/internal/providers"
+)
+
+func TestReadHeadTailLines(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	// Enough lines to span several tail chunks
+	var big strings.Builder
+	for i := 1; i <= 3000; i++ {
+		big.WriteString(fmt.Sprintf("line %d\n", i))
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		n             int
+		expectedHead  []string
+		expectedTail  []string
+		expectedFirst int
+	}{
+		{"short file", write("short.txt", "a\nb\nc\n"), 2, []string{"a", "b"}, []string{"b", "c"}, 2},
+		{"no trailing newline", write("nonl.txt", "a\r\nb\r\nc"), 5, []string{"a", "b", "c"}, []string{"a", "b", "c"}, 1},
+		{"empty file", write("empty.txt", ""), 3, nil, nil, 0},
+		{"large file", write("big.log", big.String()), 3, []string{"line 1", "line 2", "line 3"}, []string{"line 2998", "line 2999", "line 3000"}, 2998},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head, err := readHeadLines(tt.path, tt.n)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if strings.Join(head, "|") != strings.Join(tt.expectedHead, "|") {
+				t.Errorf("Expected head %q, got %q", tt.expectedHead, head)
+			}
+
+			tail, first, err := readTailLines(tt.path, tt.n)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if strings.Join(tail, "|") != strings.Join(tt.expectedTail, "|") {
+				t.Errorf("Expected tail %q, got %q", tt.expectedTail, tail)
+			}
+			if len(tail) > 0 && first != tt.expectedFirst {
+				t.Errorf("Expected first line number %d, got %d", tt.expectedFirst, first)
+			}
+		})
+	}
+}
+
+func TestReadFileFromEnd(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.log"), []byte("one\ntwo\nthree\nfour\nfive\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		action   string
+		expected string
+	}{
+		{"last lines", `{"type":"read_file","path":"app.log","lines":3,"fromEnd":true}`, "observation:read_file app.log (last 3 lines)\n     3  three\n     4  four\n     5  five\n"},
+		{"first lines", `{"type":"read_file","path":"app.log","lines":2}`, "observation:read_file app.log (first 2 lines)\n     1  one\n     2  two\n"},
+		{"last bytes", `{"type":"read_file","path":"app.log","maxBytes":10,"fromEnd":true}`, "observation:read_file app.log (last 10 of 24 bytes)\nfour\nfive\n"},
+		{"whole file when smaller", `{"type":"read_file","path":"app.log","maxBytes":100,"fromEnd":true}`, "observation:read_file app.log (last 24 of 24 bytes)\none\ntwo\nthree\nfour\nfive\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, err := parseAgentAction(tt.action)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			var transcript []providers.ChatMessage
+			if err := newTestAgent(t, dir).handleReadFile(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got := transcript[1].Content; got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	for _, invalid := range []string{
+		`{"type":"read_file","path":"app.log","lines":3,"tail":3}`,
+		`{"type":"read_file","path":"app.log","head":3,"fromEnd":true}`,
+		`{"type":"read_file","path":"app.log","lines":0}`,
+	} {
+		if _, err := parseAgentAction(invalid); err == nil {
+			t.Errorf("Expected %s to be rejected", invalid)
+		}
+	}
+}
+
+func TestReadFileLineRange(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("one\ntwo\nthree\nfour\nfive\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		action   string
+		expected string
+	}{
+		{"middle range", `{"type":"read_file","path":"main.go","startLine":2,"endLine":4}`, "observation:read_file main.go (lines 2-4 of 5)\n     2  two\n     3  three\n     4  four\n"},
+		{"end clamped", `{"type":"read_file","path":"main.go","startLine":4,"endLine":50}`, "observation:read_file main.go (lines 4-5 of 5)\n     4  four\n     5  five\n"},
+		{"start only", `{"type":"read_file","path":"main.go","startLine":5}`, "observation:read_file main.go (lines 5-5 of 5)\n     5  five\n"},
+		{"past the end", `{"type":"read_file","path":"main.go","startLine":9,"endLine":12}`, "observation:read_file main.go (no lines from 9, file has 5 lines)\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, err := parseAgentAction(tt.action)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			var transcript []providers.ChatMessage
+			if err := newTestAgent(t, dir).handleReadFile(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got := transcript[1].Content; got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	for _, invalid := range []string{
+		`{"type":"read_file","path":"main.go","startLine":4,"endLine":2}`,
+		`{"type":"read_file","path":"main.go","startLine":0}`,
+		`{"type":"read_file","path":"main.go","startLine":1,"tail":3}`,
+	} {
+		if _, err := parseAgentAction(invalid); err == nil {
+			t.Errorf("Expected %s to be rejected", invalid)
+		}
+	}
+}
+
+func TestReadTailBytesSkipsSplitRune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "utf8.txt")
+	if err := os.WriteFile(path, []byte("añb"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	// "ñ" is two bytes, so the last two bytes cut it in half
+	data, size, err := readTailBytes(path, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(data) != "b" || size != 4 {
+		t.Errorf("Expected \"b\" of 4 bytes, got %q of %d", data, size)
+	}
+}
+
+func TestListFormats(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	var entries []listEntry
+	if err := listEntries(dir, 1, &entries, dir, "", nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	long := formatListLong(entries)
+	if len(long) != 2 || !strings.HasSuffix(long[0], " sub/") || !strings.HasSuffix(long[1], "   a.txt") || !strings.Contains(long[1], " 5 ") {
+		t.Errorf("Expected long listing of sub/ and a.txt with size, got %q", long)
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var decoded []map[string]interface{}
+	json.Unmarshal(data, &decoded)
+	if len(decoded) != 2 || decoded[1]["path"] != "sub/a.txt" || decoded[1]["size"] != float64(5) || decoded[0]["isDir"] != true || decoded[1]["mtime"] == nil {
+		t.Errorf("Expected JSON entries with path, size, isDir and mtime, got %s", data)
+	}
+
+	tests := []struct {
+		format   string
+		expected string
+		valid    bool
+	}{
+		{"", "names", true},
+		{"long", "long", true},
+		{"json", "json", true},
+		{"xml", "", false},
+	}
+	for _, tt := range tests {
+		t.Run("format "+tt.format, func(t *testing.T) {
+			action, err := parseAgentAction(`{"type":"list_files","path":".","format":"` + tt.format + `"}`)
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("Expected format %q to be rejected", tt.format)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if action.Format != tt.expected {
+				t.Errorf("Expected format %q, got %q", tt.expected, action.Format)
+			}
+		})
+	}
+}
+
+func TestListFilesDetailed(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello world"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	action, err := parseAgentAction(`{"type":"list_files","path":".","detailed":true}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if action.Format != "long" {
+		t.Errorf("Expected detailed to select format long, got %q", action.Format)
+	}
+
+	var transcript []providers.ChatMessage
+	if err := newTestAgent(t, dir).handleListFiles(action, &transcript); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if listed := transcript[1].Content; !strings.Contains(listed, " 11 ") || !strings.HasSuffix(listed, "  notes.txt") {
+		t.Errorf("Expected notes.txt listed with its 11 byte size, got %q", listed)
+	}
+
+	if _, err := parseAgentAction(`{"type":"list_files","path":".","detailed":true,"format":"json"}`); err == nil {
+		t.Error("Expected detailed with format json to be rejected")
+	}
+}
+
+func TestNumberLines(t *testing.T) {
+	expected := "    41  foo\n    42  bar\n"
+	if got := numberLines([]string{"foo", "bar"}, 41); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestListDirPattern(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"main.go", "README.md", "cmd/app/main.go", "cmd/app/flags.go", "docs/guide.md", "internal/x/deep.go"} {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", f, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		depth    int
+		expected []string
+	}{
+		{"no pattern", "", 0, []string{"README.md", "cmd/", "docs/", "internal/", "main.go"}},
+		{"directories without matches within depth are hidden", "*.go", 1, []string{"main.go"}},
+		{"name glob deeper", "*.go", 2, []string{"cmd/", "  app/", "    flags.go", "    main.go", "internal/", "  x/", "    deep.go", "main.go"}},
+		{"depth still applies", "*.go", 0, []string{"main.go"}},
+		{"path glob", "cmd/*/main.go", 2, []string{"cmd/", "  app/", "    main.go"}},
+		{"matching directory", "docs", 0, []string{"docs/"}},
+		{"no matches", "*.rs", 3, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			if err := listDir(dir, tt.depth, &lines, dir, tt.pattern); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if strings.Join(lines, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("Expected %q, got %q", tt.expected, lines)
+			}
+		})
+	}
+
+	if _, err := parseAgentAction(`{"type":"list_files","path":".","pattern":"[a-"}`); err == nil {
+		t.Errorf("Expected malformed pattern to be rejected")
+	}
+}
+
+func TestListFilesGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"main.go", "README.md", "cmd/app/main.go", "docs/guide.md", "docs/api/ref.md"} {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", f, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		glob     string
+		depth    int
+		expected string
+	}{
+		{"top level only", "*.go", 1, "glob \"*.go\" matched 1 of 7 entries scanned\nmain.go"},
+		{"recursive", "**/*.md", 3, "glob \"**/*.md\" matched 3 of 9 entries scanned\nREADME.md\ndocs/\n  api/\n    ref.md\n  guide.md"},
+		{"depth still applies", "**/*.md", 1, "glob \"**/*.md\" matched 2 of 7 entries scanned\nREADME.md\ndocs/\n  guide.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			depth := tt.depth
+			var transcript []providers.ChatMessage
+			if err := newTestAgent(t, dir).handleListFiles(&AgentAction{Type: "list_files", Path: ".", Depth: &depth, Glob: tt.glob}, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if listed := strings.TrimPrefix(transcript[1].Content, "observation:list_files\n"); listed != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, listed)
+			}
+		})
+	}
+
+	if _, err := parseAgentAction(`{"type":"list_files","path":".","glob":"[a-"}`); err == nil {
+		t.Errorf("Expected malformed glob to be rejected")
+	}
+}
+
+func TestListFilesRecursivePattern(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"main.go", "README.md", "cmd/app/main.go", "docs/guide.md", "docs/api/ref.md"} {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", f, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		expected string
+	}{
+		{"below a directory", "docs/**/*.md", "docs/\n  api/\n    ref.md\n  guide.md"},
+		{"any depth between", "cmd/**/main.go", "cmd/\n  app/\n    main.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			depth := 3
+			var transcript []providers.ChatMessage
+			if err := newTestAgent(t, dir).handleListFiles(&AgentAction{Type: "list_files", Path: ".", Depth: &depth, Pattern: tt.pattern}, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if listed := strings.TrimPrefix(transcript[1].Content, "observation:list_files\n"); listed != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, listed)
+			}
+		})
+	}
+}
+
+func TestWriteFileBackup(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "app.conf")
+	original := []byte("listen = 8080\r\nmode = prod\n")
+	if err := os.WriteFile(target, original, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	a := newTestAgent(t, dir)
+
+	observation := runActions(t, a, `{"type":"write_file","path":"app.conf","content":"listen = 9090\n","backup":true}`)
+	if !strings.HasPrefix(observation, "observation:write_file success") {
+		t.Fatalf("Expected success, got %q", observation)
+	}
+
+	backups, _ := filepath.Glob(target + ".bak.*")
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %v", backups)
+	}
+	if !strings.Contains(observation, backups[0]) {
+		t.Errorf("Expected observation to report %s, got %q", backups[0], observation)
+	}
+	if got := readString(t, backups[0]); got != string(original) {
+		t.Errorf("Expected backup %q, got %q", original, got)
+	}
+	if got := readString(t, target); got != "listen = 9090\n" {
+		t.Errorf("Expected new content, got %q", got)
+	}
+
+	// Appends and new files leave no backup behind
+	runActions(t, a,
+		`{"type":"write_file","path":"app.conf","content":"mode = dev\n","append":true,"backup":true}`,
+		`{"type":"write_file","path":"new.conf","content":"x","backup":true}`,
+	)
+	if backups, _ := filepath.Glob(filepath.Join(dir, "*.bak.*")); len(backups) != 1 {
+		t.Errorf("Expected only the overwrite backup, got %v", backups)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "settings.json")
+	if err := os.WriteFile(target, []byte(`{"theme":"dark"}`), 0600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	a := newTestAgent(t, dir)
+
+	// A write that dies halfway must leave the original untouched
+	realWrite := writeTempData
+	writeTempData = func(tmp *os.File, data []byte) error {
+		tmp.Write(data[:len(data)/2])
+		return fmt.Errorf("disk full")
+	}
+	observation := runActions(t, a, `{"type":"write_file","path":"settings.json","content":"{\"theme\":\"light\",\"font\":\"mono\"}"}`)
+	writeTempData = realWrite
+	if !strings.HasPrefix(observation, "observation:write_file error") || !strings.Contains(observation, "disk full") {
+		t.Errorf("Expected write error, got %q", observation)
+	}
+	if got := readString(t, target); got != `{"theme":"dark"}` {
+		t.Errorf("Expected original content after failed write, got %q", got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected the temporary file to be cleaned up, got %d entries", len(entries))
+	}
+
+	observation = runActions(t, a, `{"type":"write_file","path":"settings.json","content":"{}"}`)
+	if !strings.HasPrefix(observation, "observation:write_file success") {
+		t.Fatalf("Expected success, got %q", observation)
+	}
+	if got := readString(t, target); got != "{}" {
+		t.Errorf("Expected new content, got %q", got)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 to be kept, got %o", info.Mode().Perm())
+	}
+}
+
+func TestWriteFileAtomicThroughSymlink(t *testing.T) {
+	dir := t.TempDir()
+	skipWithoutSymlinks(t, dir)
+	real := filepath.Join(dir, "dotfiles", "bashrc")
+	if err := os.MkdirAll(filepath.Dir(real), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(real, []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	link := filepath.Join(dir, ".bashrc")
+	if err := os.Symlink(filepath.Join("dotfiles", "bashrc"), link); err != nil {
+		t.Fatalf("Failed to create link: %v", err)
+	}
+	a := newTestAgent(t, dir)
+
+	observation := runActions(t, a, `{"type":"write_file","path":".bashrc","content":"new"}`)
+	if !strings.HasPrefix(observation, "observation:write_file success") {
+		t.Fatalf("Expected success, got %q", observation)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected .bashrc to still be a symlink, got %v (%v)", info, err)
+	}
+	if got := readString(t, real); got != "new" {
+		t.Errorf("Expected the link target to be written, got %q", got)
+	}
+
+	// A dangling link creates its target
+	if err := os.Symlink("missing.txt", filepath.Join(dir, "dangling")); err != nil {
+		t.Fatalf("Failed to create link: %v", err)
+	}
+	runActions(t, a, `{"type":"write_file","path":"dangling","content":"created"}`)
+	if got := readString(t, filepath.Join(dir, "missing.txt")); got != "created" {
+		t.Errorf("Expected the dangling link's target to be created, got %q", got)
+	}
+}
+
+func TestTouchCreatesFile(t *testing.T) {
+	dir := t.TempDir()
+	a := newTestAgent(t, dir)
+
+	observation := runActions(t, a, `{"type":"touch","path":"logs/app.log"}`)
+	if observation != "observation:touch success\nCreated logs/app.log" {
+		t.Errorf("Expected the file to be created, got %q", observation)
+	}
+	info, err := os.Stat(filepath.Join(dir, "logs", "app.log"))
+	if err != nil {
+		t.Fatalf("Expected the file to exist: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("Expected an empty file, got %d bytes", info.Size())
+	}
+}
+
+func TestTouchUpdatesMtime(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "stamp")
+	if err := os.WriteFile(target, []byte("keep"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(target, old, old); err != nil {
+		t.Fatalf("Failed to age file: %v", err)
+	}
+	a := newTestAgent(t, dir)
+
+	runActions(t, a, `{"type":"touch","path":"stamp"}`)
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if time.Since(info.ModTime()) > time.Minute {
+		t.Errorf("Expected mtime to be bumped to now, got %v", info.ModTime())
+	}
+	if got := readString(t, target); got != "keep" {
+		t.Errorf("Expected content to be kept, got %q", got)
+	}
+
+	observation := runActions(t, a, `{"type":"touch","path":"stamp","timestamp":"2020-01-02T03:04:05Z"}`)
+	if !strings.HasPrefix(observation, "observation:touch success") {
+		t.Fatalf("Expected success, got %q", observation)
+	}
+	info, _ = os.Stat(target)
+	if want := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC); !info.ModTime().Equal(want) {
+		t.Errorf("Expected mtime %v, got %v", want, info.ModTime())
+	}
+
+	if _, err := parseAgentAction(`{"type":"touch","path":"stamp","timestamp":"yesterday"}`); err == nil {
+		t.Errorf("Expected an invalid timestamp to be rejected")
+	}
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"terminusai/internal/providers"
+	"terminusai/internal/ui"
+)
+
+// fileMutatingActions create, modify or remove files; they count towards the
+// per-run file change limit. Shell commands are not counted: their effects
+// can't be known in advance and they are approved one by one.
+var fileMutatingActions = map[string]bool{
+	"write_file": true, "edit_file": true, "patch_file": true, "format_file": true,
+	"copy_path": true, "move_path": true, "delete_path": true, "make_dir": true,
+	"extract": true, "compress": true, "download_file": true, "from_template": true,
+	"dir_snapshot": true, "touch": true, "chmod": true, "chown": true, "symlink": true,
+	"undo": true,
+}
+
+// mutatesFiles reports whether action counts towards the file change limit;
+// hash_dir only writes a file when it is given a dest
+func mutatesFiles(action *AgentAction) bool {
+	if action.Type == "hash_dir" {
+		return action.Dest != ""
+	}
+	return fileMutatingActions[action.Type]
+}
+
+// SetMaxFileChanges caps how many file-changing actions may succeed in one run
+// (0 = unlimited). Once reached, the user is asked before each further change.
+func (a *Agent) SetMaxFileChanges(n int) {
+	a.maxFileChanges = n
+}
+
+// fileChangeAllowed reports whether action may run under the file change limit.
+// At the limit the user can raise it by another maxFileChanges for this run.
+func (a *Agent) fileChangeAllowed(action *AgentAction) bool {
+	if !mutatesFiles(action) || a.maxFileChanges <= 0 {
+		return true
+	}
+	a.stateMu.Lock()
+	changes, limit := a.fileChanges, a.fileChangeLimit
+	a.stateMu.Unlock()
+	if changes < limit {
+		return true
+	}
+
+	ui.Warning.Printf("⚠ File change limit reached: %d changes made this run (limit %d)\n", changes, limit)
+	answer, err := a.askUser(fmt.Sprintf("Allow %d more file changes for this run?", a.maxFileChanges), " [y/N]: ")
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return false
+	}
+	a.stateMu.Lock()
+	a.fileChangeLimit += a.maxFileChanges
+	a.stateMu.Unlock()
+	return true
+}
+
+// countFileChange records a successful file-changing action, judged by the
+// observation its handler appended after index before
+func (a *Agent) countFileChange(action *AgentAction, transcript []providers.ChatMessage, before int) {
+	if !mutatesFiles(action) {
+		return
+	}
+	for _, msg := range transcript[min(before, len(transcript)):] {
+		if msg.Role == "user" && strings.HasPrefix(msg.Content, "observation:"+action.Type+" success") {
+			a.stateMu.Lock()
+			a.fileChanges++
+			a.stateMu.Unlock()
+			return
+		}
+	}
+}
+
+// fileLimitObservation tells the model why a file change was refused
+func (a *Agent) fileLimitObservation(action *AgentAction) string {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	return fmt.Sprintf("observation:%s refused\nFile change limit reached: this run already made %d file changes and the user did not allow more. Continue with read-only actions, or finish with done and list the changes that are still needed.", action.Type, a.fileChanges)
+}
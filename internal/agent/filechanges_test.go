@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestFileChangeLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit         int
+		answer        string
+		expectedAsked bool
+		expectAllowed bool
+	}{
+		{"unlimited", 0, "", false, true},
+		{"under the limit", 3, "", false, true},
+		{"at the limit, user declines", 2, "n", true, false},
+		{"at the limit, user allows more", 2, "yes", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAgent(t, t.TempDir())
+			a.SetMaxFileChanges(tt.limit)
+			a.fileChangeLimit = tt.limit
+			asked := false
+			a.SetAsker(func(question string) (string, error) {
+				asked = true
+				return tt.answer, nil
+			})
+
+			// Two successful writes, one failed write and a read
+			write := &AgentAction{Type: "write_file"}
+			for _, obs := range []string{"observation:write_file success\nok", "observation:write_file error\nfailed", "observation:write_file success\nok"} {
+				transcript := []providers.ChatMessage{{Role: "assistant", Content: "{}"}, {Role: "user", Content: obs}}
+				a.countFileChange(write, transcript, 0)
+			}
+			a.countFileChange(&AgentAction{Type: "read_file"}, []providers.ChatMessage{{Role: "user", Content: "observation:read_file success"}}, 0)
+			if a.fileChanges != 2 {
+				t.Fatalf("Expected 2 counted file changes, got %d", a.fileChanges)
+			}
+
+			for _, action := range []*AgentAction{{Type: "read_file"}, {Type: "hash_dir", Path: "."}} {
+				if !a.fileChangeAllowed(action) {
+					t.Errorf("Expected read-only %s to always be allowed", action.Type)
+				}
+			}
+			for _, action := range []*AgentAction{{Type: "undo"}, {Type: "hash_dir", Path: ".", Dest: "sums.txt"}} {
+				if !mutatesFiles(action) {
+					t.Errorf("Expected %s to count as a file change", action.Type)
+				}
+			}
+			if allowed := a.fileChangeAllowed(&AgentAction{Type: "delete_path"}); allowed != tt.expectAllowed {
+				t.Errorf("Expected allowed=%v, got %v", tt.expectAllowed, allowed)
+			}
+			if asked != tt.expectedAsked {
+				t.Errorf("Expected asked=%v, got %v", tt.expectedAsked, asked)
+			}
+			if tt.answer == "yes" && a.fileChangeLimit != 2*tt.limit {
+				t.Errorf("Expected limit raised to %d, got %d", 2*tt.limit, a.fileChangeLimit)
+			}
+			if !tt.expectAllowed && !strings.Contains(a.fileLimitObservation(&AgentAction{Type: "delete_path"}), "observation:delete_path refused") {
+				t.Errorf("Expected a refusal observation")
+			}
+		})
+	}
+}
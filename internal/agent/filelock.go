@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"terminusai/internal/common"
+)
+
+// ErrFileLocked is returned when another process holds the lock on a path
+var ErrFileLocked = errors.New("file is locked by another process")
+
+// fileLockTimeout is how long lockFiles waits for a lock held elsewhere
+var fileLockTimeout = 2 * time.Second
+
+// fileLockRetry is the interval between lock attempts
+const fileLockRetry = 50 * time.Millisecond
+
+// lockDir holds the lock files. Locking the targets themselves would not work:
+// they may not exist yet, and atomic writes replace them. The directory is per
+// user, as another user couldn't create lock files in a shared one.
+func lockDir() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, common.ConfigDirName, "locks")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("terminusai-locks-%d", os.Getuid()))
+}
+
+// lockFileName maps a path to its lock file
+func lockFileName(path string) string {
+	key := filepath.Clean(path)
+	if abs, err := filepath.Abs(key); err == nil {
+		key = abs
+	}
+	if runtime.GOOS == "windows" {
+		key = strings.ToLower(key)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(lockDir(), hex.EncodeToString(sum[:12])+".lock")
+}
+
+// lockPath takes the advisory lock on path, so other TerminusAI sessions can't
+// change it at the same time. It waits up to timeout for a lock held elsewhere
+// and then fails with ErrFileLocked.
+func lockPath(path string, timeout time.Duration) (*os.File, error) {
+	if err := os.MkdirAll(lockDir(), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(lockFileName(path), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("locking %s: %w", path, err)
+		}
+		if locked {
+			return file, nil
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("%w: %s", ErrFileLocked, path)
+		}
+		time.Sleep(fileLockRetry)
+	}
+}
+
+// unlockPath releases a lock taken by lockPath
+func unlockPath(file *os.File) {
+	unlockFile(file)
+	file.Close()
+}
+
+// lockFiles locks every path and returns a function releasing them all. Paths
+// are locked in sorted order so two sessions can't deadlock on a pair.
+func lockFiles(paths ...string) (func(), error) {
+	sorted := append([]string(nil), paths...)
+	sort.Strings(sorted)
+
+	var held []*os.File
+	unlock := func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			unlockPath(held[i])
+		}
+	}
+	for i, path := range sorted {
+		if i > 0 && path == sorted[i-1] {
+			continue
+		}
+		file, err := lockPath(path, fileLockTimeout)
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+		held = append(held, file)
+	}
+	return unlock, nil
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockPathTimesOut(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "shared.txt")
+
+	held, err := lockPath(target, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to take the first lock: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := lockPath(target, 100*time.Millisecond); !errors.Is(err, ErrFileLocked) {
+		t.Fatalf("Expected ErrFileLocked, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected the second attempt to wait for the timeout, returned after %v", elapsed)
+	}
+
+	// Actions on the locked path report the contention
+	a := newTestAgent(t, dir)
+	realTimeout := fileLockTimeout
+	fileLockTimeout = 100 * time.Millisecond
+	defer func() { fileLockTimeout = realTimeout }()
+	observation := runActions(t, a, `{"type":"write_file","path":"shared.txt","content":"mine"}`)
+	if !strings.HasPrefix(observation, "observation:write_file error") || !strings.Contains(observation, "file is locked by another process") {
+		t.Errorf("Expected a locked-file error, got %q", observation)
+	}
+	os.WriteFile(target, []byte("theirs\n"), 0644)
+	observation = runActions(t, a, `{"type":"patch_file","path":"shared.txt","patch":"mine\n","format":"full"}`)
+	if !strings.HasPrefix(observation, "observation:patch_file error") || !strings.Contains(observation, "file is locked by another process") {
+		t.Errorf("Expected patch_file to wait for the lock before reading, got %q", observation)
+	}
+	if content := readString(t, target); content != "theirs\n" {
+		t.Errorf("Expected the locked file unchanged, got %q", content)
+	}
+
+	unlockPath(held)
+	second, err := lockPath(target, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected the lock to be free after unlocking, got %v", err)
+	}
+	unlockPath(second)
+
+	if home, err := os.UserHomeDir(); err == nil && !strings.HasPrefix(lockFileName(target), home) {
+		t.Errorf("Expected lock files in the user's home, got %s", lockFileName(target))
+	}
+}
@@ -0,0 +1,206 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"terminusai/internal/providers"
+)
+
+// errFindLimit stops the walk once find has enough results
+var errFindLimit = fmt.Errorf("max results reached")
+
+// findFilter decides which walked entries find reports
+type findFilter struct {
+	glob      string         // Basename glob, lowercased when matching case-insensitively
+	regex     *regexp.Regexp // Basename regex, used instead of glob when set
+	fold      bool           // Case-insensitive glob matching
+	kind      string         // "f", "d" or "" for both
+	minSize   int64          // Bytes (0 = no limit)
+	maxSize   int64          // Bytes (0 = no limit)
+	newerThan time.Time      // Zero = no limit
+	olderThan time.Time      // Zero = no limit
+}
+
+// newFindFilter builds the filter for a validated find action
+func newFindFilter(action *AgentAction, now time.Time) (*findFilter, error) {
+	filter := &findFilter{kind: action.Kind, fold: !*action.CaseSensitive}
+	if *action.Regex {
+		pattern := action.Pattern
+		if filter.fold {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex pattern: %w", err)
+		}
+		filter.regex = re
+	} else {
+		filter.glob = action.Pattern
+		if filter.fold {
+			filter.glob = strings.ToLower(filter.glob)
+		}
+		if _, err := filepath.Match(filter.glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern: %w", err)
+		}
+	}
+	if action.MinSize != nil {
+		filter.minSize = *action.MinSize
+	}
+	if action.MaxSize != nil {
+		filter.maxSize = *action.MaxSize
+	}
+	if action.NewerThan != "" {
+		age, _ := parseAge(action.NewerThan)
+		filter.newerThan = now.Add(-age)
+	}
+	if action.OlderThan != "" {
+		age, _ := parseAge(action.OlderThan)
+		filter.olderThan = now.Add(-age)
+	}
+	return filter, nil
+}
+
+// matches reports whether an entry passes every filter
+func (f *findFilter) matches(info os.FileInfo) bool {
+	switch f.kind {
+	case "f":
+		if info.IsDir() {
+			return false
+		}
+	case "d":
+		if !info.IsDir() {
+			return false
+		}
+	}
+
+	name := info.Name()
+	if f.regex != nil {
+		if !f.regex.MatchString(name) {
+			return false
+		}
+	} else {
+		if f.fold {
+			name = strings.ToLower(name)
+		}
+		if ok, _ := filepath.Match(f.glob, name); !ok {
+			return false
+		}
+	}
+
+	// Sizes only mean something for files
+	if !info.IsDir() {
+		if f.minSize > 0 && info.Size() < f.minSize {
+			return false
+		}
+		if f.maxSize > 0 && info.Size() > f.maxSize {
+			return false
+		}
+	}
+	if !f.newerThan.IsZero() && info.ModTime().Before(f.newerThan) {
+		return false
+	}
+	if !f.olderThan.IsZero() && info.ModTime().After(f.olderThan) {
+		return false
+	}
+	return true
+}
+
+// parseAge parses a find age: a Go duration ("90m", "24h") or a number of days
+// or weeks ("7d", "2w")
+func parseAge(s string) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit == 0 {
+		age, err := time.ParseDuration(s)
+		if err != nil || age < 0 {
+			return 0, fmt.Errorf("invalid age %q (use e.g. \"30m\", \"24h\" or \"7d\")", s)
+		}
+		return age, nil
+	}
+	n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. \"30m\", \"24h\" or \"7d\")", s)
+	}
+	return time.Duration(n * float64(unit)), nil
+}
+
+// findEntries walks root and returns the paths matching filter, relative to
+// base, with a trailing slash on directories
+func findEntries(root, base string, filter *findFilter, maxResults int, opts walkOptions) ([]string, error) {
+	var results []string
+	err := walkTree(root, opts, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil // Skip entries we can't access
+		}
+		if path == root || !filter.matches(info) {
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(base, path)
+		relPath = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			relPath += "/"
+		}
+		results = append(results, relPath)
+		if len(results) >= maxResults {
+			return errFindLimit
+		}
+		return nil
+	})
+	if err == errFindLimit {
+		err = nil
+	}
+	return results, err
+}
+
+// handleFind lists files and directories whose name matches a glob or regex
+func (a *Agent) handleFind(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	actionUI := a.display.ShowAction("Find", fmt.Sprintf("'%s' in %s", action.Pattern, action.Path), false)
+	actionJSON, _ := json.Marshal(action)
+
+	filter, err := newFindFilter(action, time.Now())
+	var results []string
+	if err == nil {
+		opts := a.walkOptionsFor(action)
+		opts.RespectGitignore = *action.RespectGitignore
+		results, err = findEntries(a.absPath(action.Path), a.workingDir, filter, *action.MaxResults, opts)
+	}
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:find error\n%s", err.Error())},
+		)
+		return nil
+	}
+
+	resultText := strings.Join(results, "\n")
+	switch {
+	case len(results) == 0:
+		resultText = "No matches found"
+	case len(results) >= *action.MaxResults:
+		resultText += fmt.Sprintf("\n... (stopped at %d results; narrow the pattern or raise maxResults)", *action.MaxResults)
+	}
+
+	a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Found %d matches", len(results))})
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:find success\n%s", a.limitObservation(action, resultText, 4000))},
+	)
+	return nil
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// findTree creates a small project for find tests
+func findTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, file := range []string{"main.go", "README.md", "cmd/app/app.go", "cmd/app/app_test.go", "internal/util.GO", "node_modules/pkg/index.go"} {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("package x\n"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestFindGoFiles(t *testing.T) {
+	a := newTestAgent(t, findTree(t))
+
+	observation := runActions(t, a, `{"type":"find","pattern":"*.go"}`)
+	expected := "observation:find success\ncmd/app/app.go\ncmd/app/app_test.go\ninternal/util.GO\nmain.go"
+	if observation != expected {
+		t.Errorf("Expected %q, got %q", expected, observation)
+	}
+
+	// Case-sensitive globs and regexes
+	observation = runActions(t, a, `{"type":"find","pattern":"*.go","caseSensitive":true,"kind":"f"}`)
+	if strings.Contains(observation, "util.GO") || !strings.Contains(observation, "main.go") {
+		t.Errorf("Expected a case-sensitive match, got %q", observation)
+	}
+	observation = runActions(t, a, `{"type":"find","pattern":"_test\\.go$","regex":true,"path":"cmd"}`)
+	if observation != "observation:find success\ncmd/app/app_test.go" {
+		t.Errorf("Expected only the test file, got %q", observation)
+	}
+}
+
+func TestFindDirectoriesOnly(t *testing.T) {
+	a := newTestAgent(t, findTree(t))
+
+	observation := runActions(t, a, `{"type":"find","pattern":"*","kind":"d"}`)
+	expected := "observation:find success\ncmd/\ncmd/app/\ninternal/\nnode_modules/"
+	if observation != expected {
+		t.Errorf("Expected %q, got %q", expected, observation)
+	}
+}
+
+func TestFindFilters(t *testing.T) {
+	dir := findTree(t)
+	if err := os.WriteFile(filepath.Join(dir, "big.go"), make([]byte, 4096), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	a := newTestAgent(t, dir)
+
+	observation := runActions(t, a, `{"type":"find","pattern":"*.go","minSize":1024}`)
+	if observation != "observation:find success\nbig.go" {
+		t.Errorf("Expected only the large file, got %q", observation)
+	}
+	observation = runActions(t, a, `{"type":"find","pattern":"*.md","olderThan":"1d"}`)
+	if observation != "observation:find success\nNo matches found" {
+		t.Errorf("Expected no old files, got %q", observation)
+	}
+	observation = runActions(t, a, `{"type":"find","pattern":"*.md","newerThan":"1h"}`)
+	if observation != "observation:find success\nREADME.md" {
+		t.Errorf("Expected the fresh README, got %q", observation)
+	}
+
+	if _, err := parseAgentAction(`{"type":"find","pattern":"*","newerThan":"yesterday"}`); err == nil {
+		t.Errorf("Expected an invalid age to be rejected")
+	}
+}
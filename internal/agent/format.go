@@ -0,0 +1,200 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// fileFormatter reformats the contents of a file
+type fileFormatter func(data []byte) ([]byte, error)
+
+// formatters maps format names to formatters; add an entry (and to
+// formatExtensions) to support another file type
+var formatters = map[string]fileFormatter{
+	"json": formatJSON,
+	"yaml": formatYAML,
+	"go":   format.Source,
+}
+
+// formatExtensions maps file extensions to format names
+var formatExtensions = map[string]string{
+	".json": "json",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".go":   "go",
+}
+
+// formatterFor picks the formatter from an explicit format name or the file extension
+func formatterFor(path, name string) (string, fileFormatter, error) {
+	if name == "" {
+		name = formatExtensions[strings.ToLower(filepath.Ext(path))]
+	}
+	formatter, ok := formatters[strings.ToLower(name)]
+	if !ok {
+		return "", nil, fmt.Errorf("don't know how to format %s (supported: json, yaml, go)", filepath.Base(path))
+	}
+	return strings.ToLower(name), formatter, nil
+}
+
+// formatContent formats data, keeping CRLF line endings when the input used them
+func formatContent(data []byte, formatter fileFormatter) ([]byte, error) {
+	crlf := bytes.Contains(data, []byte("\r\n"))
+	formatted, err := formatter(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")))
+	if err != nil {
+		return nil, err
+	}
+	if crlf {
+		formatted = bytes.ReplaceAll(formatted, []byte("\n"), []byte("\r\n"))
+	}
+	return formatted, nil
+}
+
+// formatJSON indents JSON with two spaces, keeping key order
+func formatJSON(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	if err := json.Indent(&out, bytes.TrimSpace(data), "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// formatYAML re-marshals YAML, keeping key order. Re-marshalling drops
+// comments and document markers, so files with comments or several documents
+// are refused; a leading --- is put back.
+func formatYAML(data []byte) ([]byte, error) {
+	leadingMarker := false
+	seenContent := false
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if yamlHasComment(line) {
+			return nil, fmt.Errorf("line %d has a comment that reformatting would remove", i+1)
+		}
+		if strings.HasPrefix(trimmed, "---") || trimmed == "..." {
+			if seenContent || trimmed != "---" {
+				return nil, fmt.Errorf("multi-document YAML is not supported")
+			}
+			leadingMarker = true
+		}
+		if trimmed != "" {
+			seenContent = true
+		}
+	}
+
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	out, err := yaml.Marshal(doc)
+	if err != nil || !leadingMarker {
+		return out, err
+	}
+	return append([]byte("---\n"), out...), nil
+}
+
+// yamlHasComment reports whether a line holds a # comment: a # at the start of
+// the line or after whitespace, outside quotes
+func yamlHasComment(line string) bool {
+	var quote rune
+	prev := ' '
+	for _, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '#' && (prev == ' ' || prev == '\t'):
+			return true
+		}
+		prev = c
+	}
+	return false
+}
+
+// writeTempData fills the temporary file of writeFileAtomic, replaceable in
+// tests to simulate a write that dies halfway
+var writeTempData = func(tmp *os.File, data []byte) error {
+	_, err := tmp.Write(data)
+	return err
+}
+
+// writeFileAtomic replaces path with data via a temporary file in the same
+// directory, so readers never see a half-written file. The file mode is kept.
+// A symlink is written through: its target is replaced, not the link itself.
+func writeFileAtomic(path string, data []byte) error {
+	path = resolveWriteTarget(path)
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if err := writeTempData(tmp, data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// resolveWriteTarget follows symlinks to the file a write should replace. A
+// dangling link resolves to the missing target, which the write then creates.
+func resolveWriteTarget(path string) string {
+	for hops := 0; hops < maxSymlinkHops; hops++ {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			return resolved
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return path
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = target
+	}
+	return path
+}
+
+// jsonCompatible converts the map[interface{}]interface{} values produced by
+// yaml.Unmarshal into map[string]interface{} so they can be encoded as JSON
+func jsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = jsonCompatible(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonCompatible(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestFormatContent(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		input     string
+		expected  string
+		expectErr bool
+	}{
+		{"json keeps key order", "a.json", `{"b":1,"a":[1,2]}`, "{\n  \"b\": 1,\n  \"a\": [\n    1,\n    2\n  ]\n}\n", false},
+		{"json keeps crlf", "a.json", "{\"a\":1}\r\n", "{\r\n  \"a\": 1\r\n}\r\n", false},
+		{"invalid json", "a.json", `{"a":`, "", true},
+		{"yaml", "a.yml", "b:   1\na:\n    - x\n", "b: 1\na:\n- x\n", false},
+		{"yaml with comments refused", "a.yaml", "# settings\na: 1\n", "", true},
+		{"yaml with trailing comment refused", "a.yaml", "a: 1 # port\n", "", true},
+		{"yaml hash in a value kept", "a.yaml", "url: 'http://x/#top'\ncolor: a#b\n", "url: http://x/#top\ncolor: a#b\n", false},
+		{"yaml leading marker kept", "a.yaml", "---\na:   1\n", "---\na: 1\n", false},
+		{"multi-document yaml refused", "a.yaml", "a: 1\n---\nb: 2\n", "", true},
+		{"go", "main.go", "package main\nfunc main(){}\n", "package main\n\nfunc main() {}\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, formatter, err := formatterFor(tt.path, "")
+			if err != nil {
+				t.Fatalf("Expected a formatter for %s, got %v", tt.path, err)
+			}
+			got, err := formatContent([]byte(tt.input), formatter)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFormatterForUnknownType(t *testing.T) {
+	if _, _, err := formatterFor("notes.txt", ""); err == nil {
+		t.Errorf("Expected unsupported extension to be rejected")
+	}
+	if name, _, err := formatterFor("config", "JSON"); err != nil || name != "json" {
+		t.Errorf("Expected explicit format to be used, got %q (%v)", name, err)
+	}
+}
+
+func TestHandleFormatFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	if err := os.WriteFile(path, []byte(`{"a":1}`), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	a := newTestAgent(t, dir)
+
+	run := func() string {
+		var transcript []providers.ChatMessage
+		if err := a.handleFormatFile(&AgentAction{Type: "format_file", Path: "data.json"}, &transcript); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return transcript[1].Content
+	}
+
+	if obs := run(); !strings.Contains(obs, "(changed") {
+		t.Errorf("Expected file to be reported as changed, got %q", obs)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "{\n  \"a\": 1\n}\n" {
+		t.Errorf("Expected formatted file, got %q", data)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 && filepath.Separator == '/' {
+		t.Errorf("Expected file mode to be kept, got %v", info.Mode().Perm())
+	}
+	if obs := run(); !strings.Contains(obs, "unchanged") {
+		t.Errorf("Expected second run to report unchanged, got %q", obs)
+	}
+}
+
+func TestHandleParseYamlOutputsJSON(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("server:\n  port: 8080\n  hosts: [a, b]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config.yaml: %v", err)
+	}
+
+	a := newTestAgent(t, dir)
+	var transcript []providers.ChatMessage
+	if err := a.handleParseYaml(&AgentAction{Type: "parse_yaml", Path: "config.yaml"}, &transcript); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := "observation:parse_yaml success\n{\n  \"server\": {\n    \"hosts\": [\n      \"a\",\n      \"b\"\n    ],\n    \"port\": 8080\n  }\n}"
+	if got := transcript[1].Content; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitignoreRule is one pattern line of a .gitignore file
+type gitignoreRule struct {
+	dir      string // Directory holding the .gitignore; the pattern is relative to it
+	pattern  string
+	negate   bool // "!pattern" re-includes a path
+	dirOnly  bool // "pattern/" only matches directories
+	anchored bool // A slash before the end ties the pattern to dir instead of any level
+}
+
+// gitignore is the ordered set of .gitignore rules in effect for a directory.
+// Later rules win, as in git, so nested files override their parents.
+type gitignore struct {
+	rules []gitignoreRule
+}
+
+// loadGitignore collects the .gitignore files that apply to paths below root:
+// those of its ancestors up to the repository root (the nearest directory
+// holding .git) and root's own. Outside a repository only root's file counts.
+func loadGitignore(root string) *gitignore {
+	dirs := []string{root}
+	if _, err := os.Stat(filepath.Join(root, ".git")); err != nil {
+		for dir := filepath.Dir(root); ; dir = filepath.Dir(dir) {
+			dirs = append(dirs, dir)
+			if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+				break
+			}
+			if filepath.Dir(dir) == dir {
+				dirs = dirs[:1] // Not in a repository
+				break
+			}
+		}
+	}
+
+	g := &gitignore{}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		g = g.enter(dirs[i])
+	}
+	return g
+}
+
+// enter returns the rules in effect inside dir: g plus dir's own .gitignore.
+// g itself is left unchanged so sibling directories don't see dir's rules. A
+// nil g (gitignore filtering off) stays nil.
+func (g *gitignore) enter(dir string) *gitignore {
+	if g == nil {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return g
+	}
+	defer f.Close()
+
+	rules := append([]gitignoreRule(nil), g.rules...)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := gitignoreRule{dir: dir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, "\\") // Escaped leading ! or #
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return &gitignore{rules: rules}
+}
+
+// ignored reports whether the path (absolute, like the rule directories) is excluded
+func (g *gitignore) ignored(p string, isDir bool) bool {
+	if g == nil {
+		return false
+	}
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(rule.dir, p)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		var matched bool
+		if rule.anchored {
+			matched = matchGlobPath(rule.pattern, rel)
+		} else {
+			matched, _ = path.Match(rule.pattern, path.Base(rel))
+		}
+		if matched {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchGlobPath matches a slash-separated path against a pattern whose
+// segments are path.Match globs, where a "**" segment spans any number of
+// directories
+func matchGlobPath(pattern, name string) bool {
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlobSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
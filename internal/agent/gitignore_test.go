@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestGitignoreIgnored(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(rel, content string) {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(".gitignore", "# build output\nout/\n*.log\n!keep.log\n/root-only.txt\ndocs/**/*.tmp\n")
+	writeFile("sub/.gitignore", "local.txt\n")
+
+	root := loadGitignore(dir)
+	sub := root.enter(filepath.Join(dir, "sub"))
+
+	tests := []struct {
+		name     string
+		g        *gitignore
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"directory rule", root, "out", true, true},
+		{"directory rule skips files of that name", root, "out", false, false},
+		{"glob at any level", root, "a/b/debug.log", false, true},
+		{"negation", root, "keep.log", false, false},
+		{"anchored at its .gitignore", root, "root-only.txt", false, true},
+		{"anchored rule not below", root, "sub/root-only.txt", false, false},
+		{"double star", root, "docs/x/y/page.tmp", false, true},
+		{"double star matches zero directories", root, "docs/page.tmp", false, true},
+		{"unmatched", root, "main.go", false, false},
+		{"nested rule", sub, "sub/local.txt", false, true},
+		{"nested rule stays in its directory", root, "local.txt", false, false},
+		{"parent rules apply in nested directory", sub, "sub/trace.log", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.g.ignored(filepath.Join(dir, tt.path), tt.isDir); got != tt.expected {
+				t.Errorf("ignored(%q) = %v, want %v", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGitignoreFiltersListAndSearch(t *testing.T) {
+	dir := t.TempDir()
+	for rel, content := range map[string]string{
+		".gitignore":         "generated/\n",
+		"main.go":            "needle in source",
+		"generated/types.go": "needle in generated code",
+	} {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	off := false
+	for _, respect := range []*bool{nil, &off} {
+		expectGenerated := respect != nil
+		a := newTestAgent(t, dir)
+		depth := 2
+
+		var transcript []providers.ChatMessage
+		if err := a.handleListFiles(&AgentAction{Type: "list_files", Path: ".", Depth: &depth, RespectGitignore: respect}, &transcript); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if listed := transcript[1].Content; strings.Contains(listed, "generated/") != expectGenerated {
+			t.Errorf("respectGitignore=%v: expected generated listed=%v, got %q", respect != nil, expectGenerated, listed)
+		}
+
+		transcript = nil
+		if err := a.handleSearchFiles(&AgentAction{Type: "search_files", Pattern: "needle", RespectGitignore: respect}, &transcript); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		found := transcript[1].Content
+		if !strings.Contains(found, "main.go") || strings.Contains(found, "types.go") != expectGenerated {
+			t.Errorf("respectGitignore=%v: unexpected search result %q", respect != nil, found)
+		}
+	}
+}
@@ -3,9 +3,12 @@ package agent
 import (
 	"archive/tar"
 	"archive/zip"
-	"bufio"
+	"bytes"
+	"compress/bzip2"
 	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,14 +17,17 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
-	"strconv"
+	"sort"
 	"strings"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"terminusai/internal/policy"
 	"terminusai/internal/providers"
 	"terminusai/internal/ui"
 
+	"github.com/ulikunitz/xz"
 	"gopkg.in/yaml.v2"
 )
 
@@ -50,10 +56,23 @@ func (a *Agent) handleListFiles(action *AgentAction, transcript *[]providers.Cha
 		base = abs
 	}
 
-	var lines []string
-	if err := listDir(base, depth, &lines, base); err != nil {
-		lines = append(lines, fmt.Sprintf("(error listing %s: %s)", base, err.Error()))
-		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+	var ignore *gitignore
+	if action.RespectGitignore == nil || *action.RespectGitignore {
+		ignore = loadGitignore(base)
+	}
+
+	var entries []listEntry
+	listErr := listEntries(base, depth, &entries, base, action.Pattern, ignore)
+	var globNote string
+	if action.Glob != "" {
+		var matched, scanned int
+		entries, matched, scanned = filterListGlob(entries, action.Glob)
+		globNote = fmt.Sprintf("glob %q matched %d of %d entries scanned\n", action.Glob, matched, scanned)
+	}
+	lines := formatListNames(entries)
+	if listErr != nil {
+		lines = append(lines, fmt.Sprintf("(error listing %s: %s)", base, listErr.Error()))
+		a.display.UpdateAction(actionUI, "failed", []string{listErr.Error()})
 	} else {
 		// Update with actual count
 		actionUI.Summary = ui.FormatItemCount(len(lines), "items")
@@ -61,16 +80,33 @@ func (a *Agent) handleListFiles(action *AgentAction, transcript *[]providers.Cha
 	}
 
 	// Limit output for LLM
-	if len(lines) > 500 {
-		lines = lines[:500]
+	if len(entries) > 500 {
+		entries = entries[:500]
+	}
+	var out string
+	switch {
+	case listErr != nil:
+		out = strings.Join(lines, "\n")
+	case action.Format == "long":
+		out = strings.Join(formatListLong(entries), "\n")
+	case action.Format == "json":
+		files := make([]listEntry, 0, len(entries))
+		for _, e := range entries {
+			if !e.failed {
+				files = append(files, e)
+			}
+		}
+		data, _ := json.Marshal(files)
+		out = string(data)
+	default:
+		out = strings.Join(formatListNames(entries), "\n")
 	}
-	out := strings.Join(lines, "\n")
 
 	// Add to transcript
 	actionJSON, _ := json.Marshal(action)
 	*transcript = append(*transcript,
 		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:list_files\n%s", out)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:list_files\n%s%s", globNote, out)},
 	)
 
 	return nil
@@ -81,7 +117,7 @@ func (a *Agent) handleCopyPath(action *AgentAction, transcript *[]providers.Chat
 	actionUI := a.display.ShowAction("Copy path", fmt.Sprintf("Copying %s to %s", action.Src, action.Dest), true)
 
 	reason := fmt.Sprintf("Copy %s to %s", action.Src, action.Dest)
-	decision, err := a.policyStore.Approve(fmt.Sprintf("copy %s %s", action.Src, action.Dest), reason)
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("copy %s %s", action.Src, action.Dest), reason, a.absPath(action.Dest))
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		return err
@@ -106,7 +142,7 @@ func (a *Agent) handleCopyPath(action *AgentAction, transcript *[]providers.Chat
 		destPath = filepath.Join(a.workingDir, action.Dest)
 	}
 
-	err = copyPathHelper(srcPath, destPath, *action.Overwrite)
+	symlinks, err := copyPathHelper(srcPath, destPath, *action.Overwrite)
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		*transcript = append(*transcript,
@@ -114,10 +150,14 @@ func (a *Agent) handleCopyPath(action *AgentAction, transcript *[]providers.Chat
 			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:copy_path error\n%s", err.Error())},
 		)
 	} else {
-		a.display.UpdateAction(actionUI, "completed", []string{"Copy completed successfully"})
+		summary := "Copy completed successfully"
+		if symlinks > 0 {
+			summary += fmt.Sprintf(" (%d symlinks preserved)", symlinks)
+		}
+		a.display.UpdateAction(actionUI, "completed", []string{summary})
 		*transcript = append(*transcript,
 			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-			providers.ChatMessage{Role: "user", Content: "observation:copy_path success\nCopy completed successfully"},
+			providers.ChatMessage{Role: "user", Content: "observation:copy_path success\n" + summary},
 		)
 	}
 	return nil
@@ -128,7 +168,7 @@ func (a *Agent) handleMovePath(action *AgentAction, transcript *[]providers.Chat
 	actionUI := a.display.ShowAction("Move path", fmt.Sprintf("Moving %s to %s", action.Src, action.Dest), true)
 
 	reason := fmt.Sprintf("Move %s to %s", action.Src, action.Dest)
-	decision, err := a.policyStore.Approve(fmt.Sprintf("move %s %s", action.Src, action.Dest), reason)
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("move %s %s", action.Src, action.Dest), reason, a.absPath(action.Src), a.absPath(action.Dest))
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		return err
@@ -153,6 +193,17 @@ func (a *Agent) handleMovePath(action *AgentAction, transcript *[]providers.Chat
 		destPath = filepath.Join(a.workingDir, action.Dest)
 	}
 
+	unlock, err := lockFiles(srcPath, destPath)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:move_path error\n%s", err.Error())},
+		)
+		return nil
+	}
+	defer unlock()
+
 	if !*action.Overwrite {
 		if _, err := os.Stat(destPath); err == nil {
 			err = fmt.Errorf("destination already exists and overwrite is false")
@@ -165,7 +216,11 @@ func (a *Agent) handleMovePath(action *AgentAction, transcript *[]providers.Chat
 		}
 	}
 
-	err = os.Rename(srcPath, destPath)
+	journal, err := a.journalPrepare(action.Type, destPath)
+	method := ""
+	if err == nil {
+		method, err = movePath(srcPath, destPath, *action.Overwrite)
+	}
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		*transcript = append(*transcript,
@@ -173,12 +228,85 @@ func (a *Agent) handleMovePath(action *AgentAction, transcript *[]providers.Chat
 			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:move_path error\n%s", err.Error())},
 		)
 	} else {
-		a.display.UpdateAction(actionUI, "completed", []string{"Move completed successfully"})
+		if journal != nil {
+			journal.Src = srcPath
+			a.journalCommit(journal)
+		}
+		summary := "Move completed successfully (rename)"
+		if method == "copy" {
+			summary = "Move completed successfully (copied across filesystems, then removed the source)"
+		}
+		a.display.UpdateAction(actionUI, "completed", []string{summary})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:move_path success\n" + summary},
+		)
+	}
+	return nil
+}
+
+// errPrivilegeNotHeld is ERROR_PRIVILEGE_NOT_HELD, which Windows returns for
+// symlinks created without Developer Mode or administrator rights
+const errPrivilegeNotHeld = syscall.Errno(1314)
+
+// handleSymlink creates a symbolic link at dest pointing to src. Like ln -s,
+// a relative src is stored as-is and resolved from the link's directory.
+func (a *Agent) handleSymlink(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	actionUI := a.display.ShowAction("Create symlink", fmt.Sprintf("%s -> %s", action.Dest, action.Src), true)
+
+	reason := action.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("Link %s to %s", action.Dest, action.Src)
+	}
+	destPath := a.absPath(action.Dest)
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("symlink %s %s", action.Src, action.Dest), reason, destPath)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		return err
+	}
+
+	actionJSON, _ := json.Marshal(action)
+	if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+		a.display.UpdateAction(actionUI, "skipped", []string{"User declined"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:symlink skipped by user"},
+		)
+		return nil
+	}
+
+	if _, err = os.Lstat(destPath); err == nil {
+		if *action.Overwrite {
+			// Only a link, file or empty directory is replaced
+			err = os.Remove(destPath)
+		} else {
+			err = fmt.Errorf("destination already exists and overwrite is false")
+		}
+	} else {
+		err = os.MkdirAll(filepath.Dir(destPath), 0755)
+	}
+	if err == nil {
+		err = os.Symlink(action.Src, destPath)
+		if errors.Is(err, errPrivilegeNotHeld) {
+			err = fmt.Errorf("%w\nCreating symlinks on Windows needs Developer Mode (Settings > Privacy & security > For developers) or an elevated prompt", err)
+		}
+	}
+
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		*transcript = append(*transcript,
 			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-			providers.ChatMessage{Role: "user", Content: "observation:move_path success\nMove completed successfully"},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:symlink error\n%s", err.Error())},
 		)
+		return nil
 	}
+
+	summary := fmt.Sprintf("Created symlink %s -> %s", action.Dest, action.Src)
+	a.display.UpdateAction(actionUI, "completed", []string{summary})
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: "observation:symlink success\n" + summary},
+	)
 	return nil
 }
 
@@ -187,7 +315,7 @@ func (a *Agent) handleDeletePath(action *AgentAction, transcript *[]providers.Ch
 	actionUI := a.display.ShowAction("Delete path", fmt.Sprintf("Deleting %s", action.Path), true)
 
 	reason := fmt.Sprintf("Delete %s", action.Path)
-	decision, err := a.policyStore.Approve(fmt.Sprintf("delete %s", action.Path), reason)
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("delete %s", action.Path), reason, a.absPath(action.Path))
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		return err
@@ -208,11 +336,27 @@ func (a *Agent) handleDeletePath(action *AgentAction, transcript *[]providers.Ch
 		targetPath = filepath.Join(a.workingDir, action.Path)
 	}
 
-	var deleteErr error
-	if *action.Recursive {
-		deleteErr = os.RemoveAll(targetPath)
-	} else {
-		deleteErr = os.Remove(targetPath)
+	// softDelete moves the path to the trash; otherwise it is backed up for
+	// undo first, then removed
+	unlock, deleteErr := lockFiles(targetPath)
+	if deleteErr == nil {
+		defer unlock()
+		if *action.SoftDelete {
+			deleteErr = a.trashPath(targetPath, *action.Recursive)
+		} else {
+			journal, err := a.journalPrepare(action.Type, targetPath)
+			switch {
+			case err != nil:
+				deleteErr = err
+			case *action.Recursive:
+				deleteErr = os.RemoveAll(targetPath)
+			default:
+				deleteErr = os.Remove(targetPath)
+			}
+			if deleteErr == nil {
+				a.journalCommit(journal)
+			}
+		}
 	}
 
 	if deleteErr != nil {
@@ -258,6 +402,7 @@ func (a *Agent) handleStatPath(action *AgentAction, transcript *[]providers.Chat
 		result.WriteString(fmt.Sprintf("IsDir: %v\n", info.IsDir()))
 
 		a.display.UpdateAction(actionUI, "completed", []string{"File stat retrieved"})
+		a.display.ShowTable(ui.ParseKeyValues(result.String()), 0)
 		*transcript = append(*transcript,
 			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
 			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:stat_path\n%s", result.String())},
@@ -266,6 +411,69 @@ func (a *Agent) handleStatPath(action *AgentAction, transcript *[]providers.Chat
 	return nil
 }
 
+// maxSymlinkHops bounds how many links realpath follows when listing a chain
+const maxSymlinkHops = 40
+
+// handleRealpath resolves a path to its canonical absolute form and reports
+// whether it is a symlink, where it points and the links followed on the way
+func (a *Agent) handleRealpath(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	actionUI := a.display.ShowAction("Resolve path", action.Path, false)
+	actionJSON, _ := json.Marshal(action)
+
+	targetPath := a.absPath(action.Path)
+	info, err := os.Lstat(targetPath)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:realpath error\n%s", err.Error())},
+		)
+		return nil
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Path: %s\n", targetPath))
+	if canonical, err := filepath.EvalSymlinks(targetPath); err != nil {
+		result.WriteString(fmt.Sprintf("Canonical: unresolved (%s)\n", err.Error()))
+	} else {
+		abs, _ := filepath.Abs(canonical)
+		result.WriteString(fmt.Sprintf("Canonical: %s\n", abs))
+	}
+
+	isLink := info.Mode()&os.ModeSymlink != 0
+	result.WriteString(fmt.Sprintf("Symlink: %v\n", isLink))
+	if isLink {
+		target, _ := os.Readlink(targetPath)
+		result.WriteString(fmt.Sprintf("Target: %s\n", target))
+
+		// Follow the chain hop by hop, so link-to-link setups are visible
+		chain := []string{targetPath}
+		current := targetPath
+		for len(chain) <= maxSymlinkHops {
+			next, err := os.Readlink(current)
+			if err != nil {
+				break
+			}
+			if !filepath.IsAbs(next) {
+				next = filepath.Join(filepath.Dir(current), next)
+			}
+			chain = append(chain, next)
+			current = next
+		}
+		if len(chain) > 2 {
+			result.WriteString(fmt.Sprintf("Chain: %s\n", strings.Join(chain, " -> ")))
+		}
+	}
+
+	a.display.UpdateAction(actionUI, "completed", []string{"Path resolved"})
+	a.display.ShowTable(ui.ParseKeyValues(result.String()), 0)
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:realpath\n%s", result.String())},
+	)
+	return nil
+}
+
 // handleReadFile handles read_file
 func (a *Agent) handleReadFile(action *AgentAction, transcript *[]providers.ChatMessage) error {
 	maxBytes := 4000
@@ -287,6 +495,13 @@ func (a *Agent) handleReadFile(action *AgentAction, transcript *[]providers.Chat
 		file = abs
 	}
 
+	if action.Head != nil || action.Tail != nil || action.StartLine != nil {
+		return a.readFileLines(action, file, maxBytes, actionUI, transcript)
+	}
+	if action.FromEnd != nil && *action.FromEnd {
+		return a.readFileTail(action, file, maxBytes, actionUI, transcript)
+	}
+
 	var content string
 	data, err := os.ReadFile(file)
 	if err != nil {
@@ -309,6 +524,9 @@ func (a *Agent) handleReadFile(action *AgentAction, transcript *[]providers.Chat
 		a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("File size: %d bytes", len(content))})
 	}
 
+	if len(content) > maxBytes {
+		a.keepRawObservation(content)
+	}
 	head := truncateString(content, maxBytes)
 
 	// Add to transcript
@@ -321,6 +539,113 @@ func (a *Agent) handleReadFile(action *AgentAction, transcript *[]providers.Chat
 	return nil
 }
 
+// readFileLines serves read_file with head/tail or a startLine/endLine range:
+// only the requested lines are read and they are returned numbered. Output
+// over maxBytes drops the lines furthest from the requested end.
+func (a *Agent) readFileLines(action *AgentAction, file string, maxBytes int, actionUI *ui.InteractiveAction, transcript *[]providers.ChatMessage) error {
+	var lines []string
+	first := 1
+	total := -1
+	var err error
+	which := "first"
+	switch {
+	case action.StartLine != nil:
+		first = *action.StartLine
+		end := first + maxReadLines - 1
+		if action.EndLine != nil {
+			end = *action.EndLine
+		}
+		lines, total, err = readLineRange(file, first, end)
+	case action.Tail != nil:
+		which = "last"
+		lines, first, err = readTailLines(file, *action.Tail)
+	default:
+		lines, err = readHeadLines(file, *action.Head)
+	}
+
+	actionJSON, _ := json.Marshal(action)
+	if err != nil {
+		if os.IsNotExist(err) {
+			actionUI.Summary = "File not found, skipping"
+			a.display.UpdateAction(actionUI, "skipped", []string{"File does not exist"})
+			*transcript = append(*transcript,
+				providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+				providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:read_file %s not found", action.Path)},
+			)
+			return nil
+		}
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:read_file error: %v", err)},
+		)
+		return nil
+	}
+
+	output := numberLines(lines, first)
+	for len(output) > maxBytes && len(lines) > 1 {
+		if action.Tail != nil {
+			lines = lines[1:]
+			first++
+		} else {
+			lines = lines[:len(lines)-1]
+		}
+		output = numberLines(lines, first)
+	}
+	output = truncateString(output, maxBytes)
+
+	shown := fmt.Sprintf("%s %d lines", which, len(lines))
+	switch {
+	case total < 0:
+	case len(lines) == 0:
+		shown = fmt.Sprintf("no lines from %d, file has %d lines", first, total)
+	default:
+		shown = fmt.Sprintf("lines %d-%d of %d", first, first+len(lines)-1, total)
+	}
+
+	actionUI.Summary = ui.FormatItemCount(len(lines), "lines read")
+	a.display.UpdateAction(actionUI, "completed", []string{"Showing " + shown})
+
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:read_file %s (%s)\n%s", action.Path, shown, output)},
+	)
+	return nil
+}
+
+// readFileTail serves read_file with fromEnd: the last maxBytes bytes are read
+// by seeking from the end, so large logs aren't loaded whole
+func (a *Agent) readFileTail(action *AgentAction, file string, maxBytes int, actionUI *ui.InteractiveAction, transcript *[]providers.ChatMessage) error {
+	actionJSON, _ := json.Marshal(action)
+	data, size, err := readTailBytes(file, maxBytes)
+	if err != nil {
+		if os.IsNotExist(err) {
+			actionUI.Summary = "File not found, skipping"
+			a.display.UpdateAction(actionUI, "skipped", []string{"File does not exist"})
+			*transcript = append(*transcript,
+				providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+				providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:read_file %s not found", action.Path)},
+			)
+			return nil
+		}
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:read_file error: %v", err)},
+		)
+		return nil
+	}
+
+	actionUI.Summary = ui.FormatItemCount(len(data), "bytes read")
+	a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("File size: %d bytes, showing the last %d", size, len(data))})
+
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:read_file %s (last %d of %d bytes)\n%s", action.Path, len(data), size, data)},
+	)
+	return nil
+}
+
 // handleShell handles shell commands
 func (a *Agent) handleShell(action *AgentAction, transcript *[]providers.ChatMessage) error {
 	cwd := action.CWD
@@ -336,11 +661,27 @@ func (a *Agent) handleShell(action *AgentAction, transcript *[]providers.ChatMes
 	// Show the command action
 	actionUI := a.display.ShowShellCommand(action.Shell, action.Command, reason)
 
+	// Refuse dangerous command shapes before asking for approval in safe-shell mode
+	if verdict := policy.VetCommand(action.Shell, action.Command, a.shellRules); !verdict.Allowed {
+		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Blocked by safe-shell (%s): %s", verdict.Rule, verdict.Reason)})
+		actionJSON, _ := json.Marshal(action)
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:shell blocked by safe-shell mode (%s): %s. Rewrite the command without this construct.", verdict.Rule, verdict.Reason)},
+		)
+		return nil
+	}
+
+	// Show what a glob passed to rm/del would remove before asking
+	if targets := policy.DeletionTargets(action.Shell, action.Command, cwd); len(targets) > 0 {
+		a.display.ShowDeletionPreview(targets, maxDeletionPreview)
+	}
+
 	// Update status to show we're waiting for approval
 	actionUI.Summary = "Waiting for approval..."
 
 	// Get approval
-	decision, err := a.policyStore.Approve(action.Command, reason)
+	decision, err := a.policyStore.Approve(action.Type, action.Command, reason)
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Approval failed: %s", err.Error())})
 		return fmt.Errorf("failed to get approval: %w", err)
@@ -358,40 +699,72 @@ func (a *Agent) handleShell(action *AgentAction, transcript *[]providers.ChatMes
 	}
 
 	// Execute the command
-	var shell string
-	var args []string
-
-	switch action.Shell {
-	case "powershell":
-		shell = "powershell.exe"
-		args = []string{"-Command", action.Command}
-	case "cmd":
-		shell = "cmd"
-		args = []string{"/c", action.Command}
-	case "bash":
-		shell = "bash"
-		args = []string{"-c", action.Command}
-	default:
-		shell = "powershell.exe"
-		args = []string{"-Command", action.Command}
-	}
-
-	cmd := exec.Command(shell, args...)
-	if action.CWD != "" {
-		cmd.Dir = action.CWD
+	shell, args := shellInvocation(action.Shell, action.Command)
+
+	// Commands that hang (ping -t, an interactive prompt) are killed after the timeout
+	timeout := time.Duration(defaultShellTimeout) * time.Second
+	if action.Timeout != nil {
+		timeout = time.Duration(*action.Timeout) * time.Second
+	}
+	ctx := a.actionContext()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// stdout holds everything unless the action asked for the streams apart
+	separate := action.OutputMode == "separate" || action.OutputMode == "stdout"
+	var stdout, stderr string
+	if a.persistentShell && action.CWD == "" {
+		// Keep cd/export effects for the next command
+		stdout, stderr, err = a.runInShellSession(strings.TrimSuffix(shell, ".exe"), action.Command, separate, timeout)
 	} else {
-		cmd.Dir = a.workingDir
+		cmd := a.commandContext(ctx, shell, args...)
+		killTreeOnCancel(cmd)
+		cmd.WaitDelay = shellKillWait
+		if action.CWD != "" {
+			cmd.Dir = action.CWD
+		} else {
+			cmd.Dir = a.workingDir
+		}
+		if separate {
+			var stdoutBuf, stderrBuf bytes.Buffer
+			cmd.Stdout = &stdoutBuf
+			cmd.Stderr = &stderrBuf
+			err = cmd.Run()
+			stdout, stderr = stdoutBuf.String(), stderrBuf.String()
+		} else {
+			var output []byte
+			output, err = cmd.CombinedOutput()
+			stdout = string(output)
+		}
 	}
 
-	output, err := cmd.CombinedOutput()
-	outputStr := truncateString(string(output), 8000)
+	if errors.Is(err, errShellTimeout) || (err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded)) {
+		outputStr := a.shellObservation(action, stdout, stderr, false)
+		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Timed out after %v", timeout)})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:shell timeout\nCommand did not finish within %v and was killed. Run long or interactive commands with a larger timeout or non-interactive flags.\n%s", timeout, outputStr)},
+		)
+		return nil
+	}
 
+	exitCode := 0
 	if err != nil {
-		exitCode := -1
+		exitCode = -1
 		if exitError, ok := err.(*exec.ExitError); ok {
 			exitCode = exitError.ExitCode()
+		} else if sessionError, ok := err.(*shellExitError); ok {
+			exitCode = sessionError.code
 		}
+	}
+
+	success := isSuccessExitCode(exitCode, action.SuccessExitCodes)
+	outputStr := a.shellObservation(action, stdout, stderr, success)
 
+	if !success {
 		// Show failure
 		a.display.UpdateAction(actionUI, "failed", []string{
 			fmt.Sprintf("Exit code: %d", exitCode),
@@ -412,6 +785,9 @@ func (a *Agent) handleShell(action *AgentAction, transcript *[]providers.ChatMes
 	} else {
 		// Show success
 		summary := "Command completed successfully"
+		if exitCode != 0 {
+			summary = fmt.Sprintf("Command completed (exit %d, accepted as success)", exitCode)
+		}
 		if outputStr != "" {
 			lines := strings.Split(strings.TrimSpace(outputStr), "\n")
 			if len(lines) == 1 && len(lines[0]) < 60 {
@@ -423,8 +799,7 @@ func (a *Agent) handleShell(action *AgentAction, transcript *[]providers.ChatMes
 
 		// Capture last successful command output for potential display in done summary
 		if outputStr != "" {
-			a.lastSuccessOutput = outputStr
-			a.lastSuccessCommand = action.Command
+			a.setLastSuccess(action.Command, outputStr)
 		}
 
 		actionUI.Summary = ui.TruncateForSummary(summary, 60)
@@ -432,13 +807,62 @@ func (a *Agent) handleShell(action *AgentAction, transcript *[]providers.ChatMes
 
 		*transcript = append(*transcript,
 			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:shell exit=0\n%s", outputStr)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:shell exit=%d\n%s", exitCode, outputStr)},
 		)
 	}
 
 	return nil
 }
 
+// shellObservation lays out a command's output within the action's observation
+// limit, keeping the untruncated output when it had to be cut
+func (a *Agent) shellObservation(action *AgentAction, stdout, stderr string, success bool) string {
+	limit := a.observationLimit(action, 8000)
+	if len(stdout) > limit || len(stderr) > limit/4 {
+		raw := stdout
+		if stderr != "" {
+			raw = fmt.Sprintf("stdout:\n%s\nstderr:\n%s", stdout, stderr)
+		}
+		a.keepRawObservation(raw)
+	}
+	return shellObservationOutput(action.OutputMode, stdout, stderr, success, limit)
+}
+
+// shellObservationOutput lays out a command's output for the observation. In
+// "separate" mode both streams are labelled; "stdout" drops stderr unless the
+// command failed. stderr gets a quarter of the limit, as it is usually diagnostics.
+func shellObservationOutput(mode, stdout, stderr string, success bool, limit int) string {
+	switch {
+	case mode != "separate" && mode != "stdout":
+		return truncateString(stdout, limit)
+	case mode == "stdout" && success:
+		out := truncateString(stdout, limit)
+		if stderr != "" {
+			out += fmt.Sprintf("\n(stderr: %d bytes not shown)", len(stderr))
+		}
+		return out
+	default:
+		return fmt.Sprintf("stdout:\n%s\nstderr:\n%s", strings.TrimRight(truncateString(stdout, limit), "\n"), truncateString(stderr, limit/4))
+	}
+}
+
+// isSuccessExitCode reports whether a shell exit code counts as success; without
+// an explicit list only 0 does. -1 (the command could not be run) never does.
+func isSuccessExitCode(code int, successCodes []int) bool {
+	if code < 0 {
+		return false
+	}
+	if len(successCodes) == 0 {
+		return code == 0
+	}
+	for _, c := range successCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
 // handleSearchFiles handles search_files
 func (a *Agent) handleSearchFiles(action *AgentAction, transcript *[]providers.ChatMessage) error {
 	pattern := action.Pattern
@@ -467,7 +891,9 @@ func (a *Agent) handleSearchFiles(action *AgentAction, transcript *[]providers.C
 	actionUI := a.display.ShowSearchFiles(pattern, searchPath, 0) // Will update count later
 
 	// Perform the search
-	results, err := a.performFileSearch(pattern, searchPath, fileTypes, caseSensitive, maxResults)
+	opts := a.walkOptionsFor(action)
+	opts.RespectGitignore = action.RespectGitignore == nil || *action.RespectGitignore
+	results, skipped, err := a.performFileSearch(pattern, searchPath, fileTypes, caseSensitive, maxResults, opts)
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 
@@ -510,8 +936,10 @@ func (a *Agent) handleSearchFiles(action *AgentAction, transcript *[]providers.C
 
 	outputStr := output.String()
 	if len(outputStr) > 8000 {
+		a.keepRawObservation(outputStr)
 		outputStr = outputStr[:8000] + "\n... (truncated)"
 	}
+	outputStr += skippedFilesNote(skipped)
 
 	// Add to transcript
 	actionJSON, _ := json.Marshal(action)
@@ -557,7 +985,12 @@ func (a *Agent) handleWriteFile(action *AgentAction, transcript *[]providers.Cha
 		}
 	}
 
-	decision, err := a.policyStore.Approve(fmt.Sprintf("write_file %s", action.Path), reason)
+	// Let the user review what an overwrite actually changes
+	if !*action.Append {
+		a.previewFileChange(filePath, action.Path, action.Content)
+	}
+
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("write_file %s", action.Path), reason, filePath)
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Failed to get approval: %s", err.Error())})
 		return fmt.Errorf("failed to get approval: %w", err)
@@ -585,8 +1018,49 @@ func (a *Agent) handleWriteFile(action *AgentAction, transcript *[]providers.Cha
 		return nil
 	}
 
+	// Another session may be changing the same file
+	unlock, err := lockFiles(filePath)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:write_file error\n%s", err.Error())},
+		)
+		return nil
+	}
+	defer unlock()
+
+	// Keep the current content so the write can be undone
+	journal, err := a.journalPrepare(action.Type, filePath)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:write_file error\n%s", err.Error())},
+		)
+		return nil
+	}
+
+	// Keep a copy next to the file when asked to; appends don't need one
+	var backupPath string
+	if *action.Backup && !*action.Append {
+		backupPath, err = backupFile(filePath)
+		if err != nil {
+			errorMsg := fmt.Sprintf("Failed to back up %s: %s", action.Path, err.Error())
+			a.display.UpdateAction(actionUI, "failed", []string{errorMsg})
+			*transcript = append(*transcript,
+				providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+				providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:write_file error\n%s", errorMsg)},
+			)
+			return nil
+		}
+	}
+
 	var writeErr error
-	if *action.Append {
+	if action.Format != "" {
+		// Append a structured record
+		writeErr = appendRecord(filePath, action.Format, action.Record, action.Columns)
+	} else if *action.Append {
 		// Append to file
 		file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 		if err != nil {
@@ -596,8 +1070,8 @@ func (a *Agent) handleWriteFile(action *AgentAction, transcript *[]providers.Cha
 			_, writeErr = file.WriteString(action.Content)
 		}
 	} else {
-		// Write (overwrite) file
-		writeErr = os.WriteFile(filePath, []byte(action.Content), 0644)
+		// Write (overwrite) file without ever leaving it half-written
+		writeErr = writeFileAtomic(filePath, []byte(action.Content))
 	}
 
 	if writeErr != nil {
@@ -612,7 +1086,14 @@ func (a *Agent) handleWriteFile(action *AgentAction, transcript *[]providers.Cha
 		if *action.Append {
 			operation = "appended"
 		}
+		a.journalCommit(journal)
 		successMsg := fmt.Sprintf("Content %s to %s", operation, action.Path)
+		if action.Format != "" {
+			successMsg = fmt.Sprintf("%s record appended to %s", strings.ToUpper(action.Format), action.Path)
+		}
+		if backupPath != "" {
+			successMsg += fmt.Sprintf("\nBackup of the previous content saved to %s", backupPath)
+		}
 		a.display.UpdateAction(actionUI, "completed", []string{successMsg})
 		*transcript = append(*transcript,
 			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
@@ -623,19 +1104,52 @@ func (a *Agent) handleWriteFile(action *AgentAction, transcript *[]providers.Cha
 	return nil
 }
 
+// backupFile copies an existing file to <path>.bak.<timestamp> and returns the
+// copy's path, or "" when there is no file to back up
+func backupFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+
+	stamp := time.Now().Format("20060102-150405")
+	backupPath := fmt.Sprintf("%s.bak.%s", path, stamp)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(backupPath); os.IsNotExist(err) {
+			break
+		}
+		backupPath = fmt.Sprintf("%s.bak.%s-%d", path, stamp, i)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(backupPath, content, info.Mode().Perm()); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
 // handlePs handles ps command to list processes
 func (a *Agent) handlePs(action *AgentAction, transcript *[]providers.ChatMessage) error {
 	actionUI := a.display.ShowAction("List processes", "Getting running processes", false)
 
 	var cmd *exec.Cmd
 	if runtime.GOOS == "windows" {
-		cmd = exec.Command("powershell", "-Command", "Get-Process | Select-Object Id, ProcessName, CPU, WorkingSet | Format-Table -AutoSize")
+		cmd = a.command("powershell", "-Command", "Get-Process | Select-Object Id, ProcessName, CPU, WorkingSet | Format-Table -AutoSize")
 	} else {
-		cmd = exec.Command("ps", "aux")
+		cmd = a.command("ps", "aux")
 	}
 
 	output, err := cmd.CombinedOutput()
-	outputStr := truncateString(string(output), 8000)
+	outputStr := a.limitObservation(action, string(output), 8000)
 
 	actionJSON, _ := json.Marshal(action)
 
@@ -649,6 +1163,8 @@ func (a *Agent) handlePs(action *AgentAction, transcript *[]providers.ChatMessag
 		lines := strings.Split(strings.TrimSpace(outputStr), "\n")
 		actionUI.Summary = ui.FormatItemCount(len(lines), "processes")
 		a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Listed %d processes", len(lines))})
+		// The table is for the user only; the model still gets the raw output
+		a.display.ShowTable(ui.ParseColumns(string(output)), maxTableRows)
 		*transcript = append(*transcript,
 			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
 			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:ps\n%s", outputStr)},
@@ -663,8 +1179,13 @@ func (a *Agent) handleKill(action *AgentAction, transcript *[]providers.ChatMess
 	pid := *action.ProcessID
 	actionUI := a.display.ShowAction("Kill process", fmt.Sprintf("Terminating process %d", pid), true)
 
+	signal := action.Signal
+	if signal == "" {
+		signal = "TERM"
+	}
+
 	reason := fmt.Sprintf("Terminate process %d", pid)
-	decision, err := a.policyStore.Approve(fmt.Sprintf("kill %d", pid), reason)
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("kill -%s %d", signal, pid), reason)
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		return err
@@ -681,27 +1202,17 @@ func (a *Agent) handleKill(action *AgentAction, transcript *[]providers.ChatMess
 		return nil
 	}
 
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("taskkill", "/PID", strconv.Itoa(pid), "/F")
-	} else {
-		cmd = exec.Command("kill", "-9", strconv.Itoa(pid))
-	}
-
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
-
-	if err != nil {
-		a.display.UpdateAction(actionUI, "failed", []string{outputStr})
+	if err := a.signalProcess(pid, signal); err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		*transcript = append(*transcript,
 			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:kill error\n%s", outputStr)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:kill error\n%s", err.Error())},
 		)
 	} else {
-		a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Process %d terminated", pid)})
+		a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Sent SIG%s to process %d", signal, pid)})
 		*transcript = append(*transcript,
 			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:kill success\nProcess %d terminated", pid)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:kill success\nSent SIG%s to process %d", signal, pid)},
 		)
 	}
 
@@ -712,14 +1223,33 @@ func (a *Agent) handleKill(action *AgentAction, transcript *[]providers.ChatMess
 func (a *Agent) handleHttpRequest(action *AgentAction, transcript *[]providers.ChatMessage) error {
 	actionUI := a.display.ShowAction("HTTP request", fmt.Sprintf("%s %s", action.Method, action.URL), false)
 
-	client := &http.Client{Timeout: 30 * time.Second}
-
-	var reqBody io.Reader
-	if action.Body != "" {
-		reqBody = strings.NewReader(action.Body)
+	reason := action.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("Send %s request to %s", action.Method, action.URL)
+	}
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("http_request %s %s", action.Method, action.URL), reason)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Failed to get approval: %s", err.Error())})
+		return fmt.Errorf("failed to get approval: %w", err)
+	}
+	if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+		a.display.UpdateAction(actionUI, "skipped", []string{"Skipped by user"})
+		actionJSON, _ := json.Marshal(action)
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:http_request skipped by user"},
+		)
+		return nil
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second, CheckRedirect: redirectPolicy(action)}
+
+	var reqBody io.Reader
+	if action.Body != "" {
+		reqBody = strings.NewReader(action.Body)
 	}
 
-	req, err := http.NewRequest(action.Method, action.URL, reqBody)
+	req, err := http.NewRequestWithContext(a.actionContext(), action.Method, action.URL, reqBody)
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		actionJSON, _ := json.Marshal(action)
@@ -746,6 +1276,21 @@ func (a *Agent) handleHttpRequest(action *AgentAction, transcript *[]providers.C
 		return nil
 	}
 	defer resp.Body.Close()
+	redirected := redirectNote(resp, req.URL)
+
+	// HEAD responses have no body; headersOnly skips downloading it
+	if action.Method == http.MethodHead || (action.HeadersOnly != nil && *action.HeadersOnly) {
+		headers := formatResponseHeaders(resp)
+		actionUI.Summary = fmt.Sprintf("Status: %d", resp.StatusCode)
+		a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Status: %s", resp.Status)})
+
+		actionJSON, _ := json.Marshal(action)
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:http_request status=%d%s\n%s", resp.StatusCode, redirected, a.limitObservation(action, headers, 4000))},
+		)
+		return nil
+	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -758,32 +1303,50 @@ func (a *Agent) handleHttpRequest(action *AgentAction, transcript *[]providers.C
 		return nil
 	}
 
-	responseStr := truncateString(string(body), 4000)
+	responseStr := a.limitObservation(action, string(body), 4000)
 	actionUI.Summary = fmt.Sprintf("Status: %d", resp.StatusCode)
 	a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Status: %d %s", resp.StatusCode, resp.Status)})
 
 	actionJSON, _ := json.Marshal(action)
 	*transcript = append(*transcript,
 		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:http_request status=%d\n%s", resp.StatusCode, responseStr)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:http_request status=%d%s\n%s", resp.StatusCode, redirected, responseStr)},
 	)
 
 	return nil
 }
 
+// formatResponseHeaders renders the status line and headers as sorted "Key: value" lines
+func formatResponseHeaders(resp *http.Response) string {
+	keys := make([]string, 0, len(resp.Header))
+	for key := range resp.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s %s\n", resp.Proto, resp.Status))
+	for _, key := range keys {
+		for _, value := range resp.Header[key] {
+			sb.WriteString(fmt.Sprintf("%s: %s\n", key, value))
+		}
+	}
+	return sb.String()
+}
+
 // handlePing handles ping command
 func (a *Agent) handlePing(action *AgentAction, transcript *[]providers.ChatMessage) error {
 	actionUI := a.display.ShowAction("Ping", fmt.Sprintf("Pinging %s", action.Host), false)
 
 	var cmd *exec.Cmd
 	if runtime.GOOS == "windows" {
-		cmd = exec.Command("ping", "-n", "4", action.Host)
+		cmd = a.command("ping", "-n", "4", action.Host)
 	} else {
-		cmd = exec.Command("ping", "-c", "4", action.Host)
+		cmd = a.command("ping", "-c", "4", action.Host)
 	}
 
 	output, err := cmd.CombinedOutput()
-	outputStr := truncateString(string(output), 2000)
+	outputStr := a.limitObservation(action, string(output), 2000)
 
 	actionJSON, _ := json.Marshal(action)
 
@@ -810,13 +1373,13 @@ func (a *Agent) handleTraceroute(action *AgentAction, transcript *[]providers.Ch
 
 	var cmd *exec.Cmd
 	if runtime.GOOS == "windows" {
-		cmd = exec.Command("tracert", action.Host)
+		cmd = a.command("tracert", action.Host)
 	} else {
-		cmd = exec.Command("traceroute", action.Host)
+		cmd = a.command("traceroute", action.Host)
 	}
 
 	output, err := cmd.CombinedOutput()
-	outputStr := truncateString(string(output), 4000)
+	outputStr := a.limitObservation(action, string(output), 4000)
 
 	actionJSON, _ := json.Marshal(action)
 
@@ -848,7 +1411,7 @@ func (a *Agent) handleGetSystemInfo(action *AgentAction, transcript *[]providers
 	output.WriteString(fmt.Sprintf("CPU Cores: %d\n", runtime.NumCPU()))
 
 	if runtime.GOOS == "windows" {
-		if cmd := exec.Command("powershell", "-Command", "Get-ComputerInfo | Select-Object TotalPhysicalMemory, CsProcessors, WindowsVersion"); cmd != nil {
+		if cmd := a.command("powershell", "-Command", "Get-ComputerInfo | Select-Object TotalPhysicalMemory, CsProcessors, WindowsVersion"); cmd != nil {
 			if out, err := cmd.CombinedOutput(); err == nil {
 				output.WriteString("\nDetailed Info:\n")
 				output.WriteString(string(out))
@@ -856,14 +1419,14 @@ func (a *Agent) handleGetSystemInfo(action *AgentAction, transcript *[]providers
 		}
 	} else {
 		// Memory info
-		if cmd := exec.Command("free", "-h"); cmd != nil {
+		if cmd := a.command("free", "-h"); cmd != nil {
 			if out, err := cmd.CombinedOutput(); err == nil {
 				output.WriteString("\nMemory:\n")
 				output.WriteString(string(out))
 			}
 		}
 		// Disk info
-		if cmd := exec.Command("df", "-h"); cmd != nil {
+		if cmd := a.command("df", "-h"); cmd != nil {
 			if out, err := cmd.CombinedOutput(); err == nil {
 				output.WriteString("\nDisk Usage:\n")
 				output.WriteString(string(out))
@@ -888,7 +1451,7 @@ func (a *Agent) handleInstallPackage(action *AgentAction, transcript *[]provider
 	actionUI := a.display.ShowAction("Install package", fmt.Sprintf("Installing %s via %s", action.Name, action.Manager), true)
 
 	reason := fmt.Sprintf("Install package %s using %s", action.Name, action.Manager)
-	decision, err := a.policyStore.Approve(fmt.Sprintf("install_package %s %s", action.Manager, action.Name), reason)
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("install_package %s %s", action.Manager, action.Name), reason)
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		return err
@@ -908,17 +1471,17 @@ func (a *Agent) handleInstallPackage(action *AgentAction, transcript *[]provider
 	var cmd *exec.Cmd
 	switch action.Manager {
 	case "npm":
-		cmd = exec.Command("npm", "install", action.Name)
+		cmd = a.command("npm", "install", action.Name)
 	case "pip":
-		cmd = exec.Command("pip", "install", action.Name)
+		cmd = a.command("pip", "install", action.Name)
 	case "apt":
-		cmd = exec.Command("sudo", "apt", "install", "-y", action.Name)
+		cmd = a.command("sudo", "apt", "install", "-y", action.Name)
 	case "yum":
-		cmd = exec.Command("sudo", "yum", "install", "-y", action.Name)
+		cmd = a.command("sudo", "yum", "install", "-y", action.Name)
 	case "brew":
-		cmd = exec.Command("brew", "install", action.Name)
+		cmd = a.command("brew", "install", action.Name)
 	case "choco":
-		cmd = exec.Command("choco", "install", action.Name, "-y")
+		cmd = a.command("choco", "install", action.Name, "-y")
 	default:
 		errorMsg := fmt.Sprintf("Unsupported package manager: %s", action.Manager)
 		a.display.UpdateAction(actionUI, "failed", []string{errorMsg})
@@ -930,7 +1493,7 @@ func (a *Agent) handleInstallPackage(action *AgentAction, transcript *[]provider
 	}
 
 	output, err := cmd.CombinedOutput()
-	outputStr := truncateString(string(output), 4000)
+	outputStr := a.limitObservation(action, string(output), 4000)
 
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{outputStr})
@@ -954,7 +1517,7 @@ func (a *Agent) handleGit(action *AgentAction, transcript *[]providers.ChatMessa
 	actionUI := a.display.ShowAction("Git command", action.Command, true)
 
 	reason := fmt.Sprintf("Execute git command: %s", action.Command)
-	decision, err := a.policyStore.Approve(fmt.Sprintf("git %s", action.Command), reason)
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("git %s", action.Command), reason)
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		return err
@@ -972,11 +1535,11 @@ func (a *Agent) handleGit(action *AgentAction, transcript *[]providers.ChatMessa
 	}
 
 	args := strings.Fields(action.Command)
-	cmd := exec.Command("git", args...)
+	cmd := a.command("git", args...)
 	cmd.Dir = a.workingDir
 
 	output, err := cmd.CombinedOutput()
-	outputStr := truncateString(string(output), 4000)
+	outputStr := a.limitObservation(action, string(output), 4000)
 
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{outputStr})
@@ -1000,7 +1563,7 @@ func (a *Agent) handleExtract(action *AgentAction, transcript *[]providers.ChatM
 	actionUI := a.display.ShowAction("Extract archive", fmt.Sprintf("Extracting %s to %s", action.ArchivePath, action.Dest), true)
 
 	reason := fmt.Sprintf("Extract archive %s to %s", action.ArchivePath, action.Dest)
-	decision, err := a.policyStore.Approve(fmt.Sprintf("extract %s", action.ArchivePath), reason)
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("extract %s", action.ArchivePath), reason, a.absPath(action.Dest))
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		return err
@@ -1027,7 +1590,7 @@ func (a *Agent) handleExtract(action *AgentAction, transcript *[]providers.ChatM
 		destPath = filepath.Join(a.workingDir, action.Dest)
 	}
 
-	err = extractArchive(archivePath, destPath)
+	err = a.extractArchive(archivePath, destPath)
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		*transcript = append(*transcript,
@@ -1050,7 +1613,7 @@ func (a *Agent) handleCompress(action *AgentAction, transcript *[]providers.Chat
 	actionUI := a.display.ShowAction("Create archive", fmt.Sprintf("Creating %s", action.Dest), true)
 
 	reason := fmt.Sprintf("Create archive %s", action.Dest)
-	decision, err := a.policyStore.Approve(fmt.Sprintf("compress %s", action.Dest), reason)
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("compress %s", action.Dest), reason, a.absPath(action.Dest))
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		return err
@@ -1072,12 +1635,22 @@ func (a *Agent) handleCompress(action *AgentAction, transcript *[]providers.Chat
 		destPath = filepath.Join(a.workingDir, action.Dest)
 	}
 
-	err = createArchive(action.Files, destPath, a.workingDir)
+	skipped, err := createArchive(action.Files, destPath, a.workingDir)
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		*transcript = append(*transcript,
 			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:compress error\n%s", err.Error())},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:compress error\n%s\nNo archive was written", err.Error())},
+		)
+	} else if len(skipped) > 0 {
+		var details []string
+		for _, skip := range skipped {
+			details = append(details, fmt.Sprintf("%s: %v", skip.Path, skip.Err))
+		}
+		a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Archive created, %d files skipped", len(skipped))})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: truncateString(fmt.Sprintf("observation:compress success\nArchive created; %d files could not be read and were skipped:\n%s", len(skipped), strings.Join(details, "\n")), a.observationLimit(action, 4000))},
 		)
 	} else {
 		a.display.UpdateAction(actionUI, "completed", []string{"Archive created successfully"})
@@ -1122,10 +1695,23 @@ func (a *Agent) handleParseJson(action *AgentAction, transcript *[]providers.Cha
 		return nil
 	}
 
+	if action.Query != "" {
+		if jsonData, err = queryJSONPath(jsonData, action.Query); err != nil {
+			a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+			actionJSON, _ := json.Marshal(action)
+			*transcript = append(*transcript,
+				providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+				providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:parse_json error\n%s", err.Error())},
+			)
+			return nil
+		}
+	}
+
 	prettyJSON, _ := json.MarshalIndent(jsonData, "", "  ")
-	result := truncateString(string(prettyJSON), 4000)
+	result := a.limitObservation(action, string(prettyJSON), 4000)
 
 	a.display.UpdateAction(actionUI, "completed", []string{"JSON parsed successfully"})
+	a.display.ShowJSON(jsonData, maxJSONItems, maxJSONLines)
 	actionJSON, _ := json.Marshal(action)
 	*transcript = append(*transcript,
 		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
@@ -1168,10 +1754,23 @@ func (a *Agent) handleParseYaml(action *AgentAction, transcript *[]providers.Cha
 	}
 
 	// Convert to JSON for easier reading
+	yamlData = jsonCompatible(yamlData)
+	if action.Query != "" {
+		if yamlData, err = queryJSONPath(yamlData, action.Query); err != nil {
+			a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+			actionJSON, _ := json.Marshal(action)
+			*transcript = append(*transcript,
+				providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+				providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:parse_yaml error\n%s", err.Error())},
+			)
+			return nil
+		}
+	}
 	jsonData, _ := json.MarshalIndent(yamlData, "", "  ")
-	result := truncateString(string(jsonData), 4000)
+	result := a.limitObservation(action, string(jsonData), 4000)
 
 	a.display.UpdateAction(actionUI, "completed", []string{"YAML parsed successfully"})
+	a.display.ShowJSON(yamlData, maxJSONItems, maxJSONLines)
 	actionJSON, _ := json.Marshal(action)
 	*transcript = append(*transcript,
 		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
@@ -1181,15 +1780,56 @@ func (a *Agent) handleParseYaml(action *AgentAction, transcript *[]providers.Cha
 	return nil
 }
 
+// handleParseToml handles TOML parsing and validation
+func (a *Agent) handleParseToml(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	actionUI := a.display.ShowAction("Parse TOML", fmt.Sprintf("Parsing %s", action.Path), false)
+	actionJSON, _ := json.Marshal(action)
+
+	data, err := os.ReadFile(a.absPath(action.Path))
+	var tomlData interface{}
+	if err == nil {
+		if tomlData, err = parseTOML(data); err != nil {
+			err = fmt.Errorf("Invalid TOML: %w", err)
+		}
+	}
+	if err == nil && action.Query != "" {
+		tomlData, err = queryJSONPath(tomlData, action.Query)
+	}
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:parse_toml error\n%s", err.Error())},
+		)
+		return nil
+	}
+
+	// Convert to JSON for easier reading
+	jsonData, err := json.MarshalIndent(tomlData, "", "  ")
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:parse_toml error\n%s", err.Error())},
+		)
+		return nil
+	}
+	result := a.limitObservation(action, string(jsonData), 4000)
+
+	a.display.UpdateAction(actionUI, "completed", []string{"TOML parsed successfully"})
+	a.display.ShowJSON(tomlData, maxJSONItems, maxJSONLines)
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:parse_toml success\n%s", result)},
+	)
+	return nil
+}
+
 // handleAskUser handles interactive user prompts
 func (a *Agent) handleAskUser(action *AgentAction, transcript *[]providers.ChatMessage) error {
 	actionUI := a.display.ShowAction("Ask user", action.Question, false)
 
-	fmt.Printf("\n%s\n", action.Question)
-	fmt.Print("Your response: ")
-
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
+	response, err := a.askUser(action.Question, "\nYour response: ")
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		return err
@@ -1231,29 +1871,57 @@ func (a *Agent) handleLog(action *AgentAction, transcript *[]providers.ChatMessa
 }
 
 // Helper function to extract archives
-func extractArchive(archivePath, destPath string) error {
+func (a *Agent) extractArchive(archivePath, destPath string) error {
 	if err := os.MkdirAll(destPath, 0755); err != nil {
 		return err
 	}
 
-	ext := strings.ToLower(filepath.Ext(archivePath))
-	switch ext {
+	switch archiveFormat(archivePath) {
 	case ".zip":
 		return extractZip(archivePath, destPath)
+	case ".tar.gz":
+		return extractTarGz(archivePath, destPath)
 	case ".gz":
-		if strings.HasSuffix(strings.ToLower(archivePath), ".tar.gz") {
-			return extractTarGz(archivePath, destPath)
-		}
 		return extractGz(archivePath, destPath)
+	case ".tar.bz2":
+		return extractTarBz2(archivePath, destPath)
+	case ".tar.xz":
+		return a.extractTarXz(archivePath, destPath)
 	case ".tar":
 		return extractTar(archivePath, destPath)
 	default:
-		return fmt.Errorf("unsupported archive format: %s", ext)
+		return fmt.Errorf("unsupported archive format: %s (supported: .zip, .tar, .tar.gz/.tgz, .tar.bz2/.tbz2, .tar.xz/.txz, .gz)", filepath.Base(archivePath))
+	}
+}
+
+// archiveSuffixes maps archive file suffixes to their format, longest first so
+// that e.g. "x.tar.bz2" is read as a bzip2 tarball rather than by its last extension
+var archiveSuffixes = []struct{ suffix, format string }{
+	{".tar.gz", ".tar.gz"},
+	{".tar.bz2", ".tar.bz2"},
+	{".tar.xz", ".tar.xz"},
+	{".tbz2", ".tar.bz2"},
+	{".tgz", ".tar.gz"},
+	{".txz", ".tar.xz"},
+	{".tar", ".tar"},
+	{".zip", ".zip"},
+	{".gz", ".gz"},
+}
+
+// archiveFormat returns the format of an archive named path, judged by its full
+// suffix, or "" when it isn't one extractArchive knows
+func archiveFormat(path string) string {
+	name := strings.ToLower(path)
+	for _, s := range archiveSuffixes {
+		if strings.HasSuffix(name, s.suffix) {
+			return s.format
+		}
 	}
+	return ""
 }
 
 // Helper function to create archives
-func createArchive(files []string, destPath, workingDir string) error {
+func createArchive(files []string, destPath, workingDir string) ([]archiveSkip, error) {
 	ext := strings.ToLower(filepath.Ext(destPath))
 	switch ext {
 	case ".zip":
@@ -1262,12 +1930,69 @@ func createArchive(files []string, destPath, workingDir string) error {
 		if strings.HasSuffix(strings.ToLower(destPath), ".tar.gz") {
 			return createTarGz(files, destPath, workingDir)
 		}
-		return fmt.Errorf("single file gzip compression not supported")
+		return nil, createGz(files, destPath, workingDir)
 	case ".tar":
 		return createTar(files, destPath, workingDir)
 	default:
-		return fmt.Errorf("unsupported archive format: %s", ext)
+		return nil, fmt.Errorf("unsupported archive format: %s", ext)
+	}
+}
+
+// extractBudget bounds what one extraction may write, so a small archive
+// can't expand into millions of files or fill the disk (zip bombs)
+type extractBudget struct {
+	entries int
+	bytes   int64
+}
+
+// addEntry counts one archive entry against maxExtractEntries
+func (b *extractBudget) addEntry() error {
+	b.entries++
+	if b.entries > maxExtractEntries {
+		return fmt.Errorf("archive has more than %d entries; refusing to extract", maxExtractEntries)
+	}
+	return nil
+}
+
+// copy writes src to dst, failing once the total extracted size passes maxExtractBytes
+func (b *extractBudget) copy(dst io.Writer, src io.Reader) error {
+	remaining := maxExtractBytes - b.bytes
+	n, err := io.Copy(dst, io.LimitReader(src, remaining+1))
+	b.bytes += n
+	if err != nil {
+		return err
+	}
+	if n > remaining {
+		return fmt.Errorf("archive expands to more than %d bytes; refusing to extract", int64(maxExtractBytes))
+	}
+	return nil
+}
+
+// extractTarget returns where an archive entry goes under dest, refusing names
+// that would land outside it (Zip Slip: "../../etc/passwd")
+func extractTarget(dest, name string) (string, error) {
+	dest = filepath.Clean(dest)
+	target := filepath.Clean(filepath.Join(dest, name))
+	if target != dest && !strings.HasPrefix(target, dest+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q would be extracted outside %s", name, dest)
+	}
+	return target, nil
+}
+
+// writeExtractedFile creates path (and its parent directories) from r
+func writeExtractedFile(path string, mode os.FileMode, r io.Reader, budget *extractBudget) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if err := budget.copy(file, r); err != nil {
+		file.Close()
+		return err
 	}
+	return file.Close()
 }
 
 // ZIP extraction
@@ -1278,8 +2003,15 @@ func extractZip(src, dest string) error {
 	}
 	defer r.Close()
 
+	var budget extractBudget
 	for _, f := range r.File {
-		path := filepath.Join(dest, f.Name)
+		if err := budget.addEntry(); err != nil {
+			return err
+		}
+		path, err := extractTarget(dest, f.Name)
+		if err != nil {
+			return err
+		}
 		if f.FileInfo().IsDir() {
 			os.MkdirAll(path, f.FileInfo().Mode())
 			continue
@@ -1289,15 +2021,8 @@ func extractZip(src, dest string) error {
 		if err != nil {
 			return err
 		}
-		defer fileReader.Close()
-
-		targetFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.FileInfo().Mode())
-		if err != nil {
-			return err
-		}
-		defer targetFile.Close()
-
-		_, err = io.Copy(targetFile, fileReader)
+		err = writeExtractedFile(path, f.FileInfo().Mode(), fileReader, &budget)
+		fileReader.Close()
 		if err != nil {
 			return err
 		}
@@ -1319,36 +2044,49 @@ func extractTarGz(src, dest string) error {
 	}
 	defer gzr.Close()
 
-	tr := tar.NewReader(gzr)
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
+	return extractTarStream(tar.NewReader(gzr), dest)
+}
 
-		path := filepath.Join(dest, header.Name)
-		info := header.FileInfo()
-		if info.IsDir() {
-			if err = os.MkdirAll(path, info.Mode()); err != nil {
-				return err
-			}
-			continue
-		}
+// TAR.BZ2 extraction
+func extractTarBz2(src, dest string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
-		file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		_, err = io.Copy(file, tr)
-		if err != nil {
-			return err
-		}
+	return extractTarStream(tar.NewReader(bzip2.NewReader(file)), dest)
+}
+
+// TAR.XZ extraction. The standard library has no xz decoder, so the stream is
+// decompressed by the xz command, which is stopped when the action is cancelled.
+func (a *Agent) extractTarXz(src, dest string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
 	}
-	return nil
+	defer file.Close()
+
+	xzr, err := xz.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("xz: %w", err)
+	}
+
+	// Decompressing a large archive takes a while; stop when the action is cancelled
+	return extractTarStream(tar.NewReader(&contextReader{ctx: a.actionContext(), r: xzr}), dest)
+}
+
+// contextReader reads from r until ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
 }
 
 // TAR extraction
@@ -1359,7 +2097,12 @@ func extractTar(src, dest string) error {
 	}
 	defer file.Close()
 
-	tr := tar.NewReader(file)
+	return extractTarStream(tar.NewReader(file), dest)
+}
+
+// extractTarStream writes the entries of a tar stream under dest
+func extractTarStream(tr *tar.Reader, dest string) error {
+	var budget extractBudget
 	for {
 		header, err := tr.Next()
 		if err == io.EOF {
@@ -1368,8 +2111,14 @@ func extractTar(src, dest string) error {
 		if err != nil {
 			return err
 		}
+		if err := budget.addEntry(); err != nil {
+			return err
+		}
 
-		path := filepath.Join(dest, header.Name)
+		path, err := extractTarget(dest, header.Name)
+		if err != nil {
+			return err
+		}
 		info := header.FileInfo()
 		if info.IsDir() {
 			if err = os.MkdirAll(path, info.Mode()); err != nil {
@@ -1378,13 +2127,7 @@ func extractTar(src, dest string) error {
 			continue
 		}
 
-		file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		_, err = io.Copy(file, tr)
-		if err != nil {
+		if err := writeExtractedFile(path, info.Mode(), tr, &budget); err != nil {
 			return err
 		}
 	}
@@ -1411,21 +2154,22 @@ func extractGz(src, dest string) error {
 	}
 	defer outFile.Close()
 
-	_, err = io.Copy(outFile, gzr)
-	return err
+	var budget extractBudget
+	return budget.copy(outFile, gzr)
 }
 
-// ZIP creation
-func createZip(files []string, destPath, workingDir string) error {
-	file, err := os.Create(destPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	w := zip.NewWriter(file)
-	defer w.Close()
+// archiveSkip is a file left out of an archive because it couldn't be read
+type archiveSkip struct {
+	Path string
+	Err  error
+}
 
+// walkArchiveFiles walks files (relative to workingDir) and calls add for every
+// entry with its archive name. Files are opened before add is called so
+// unreadable or vanished ones are skipped and reported instead of aborting;
+// errors returned by add are fatal. destPath itself is never archived.
+func walkArchiveFiles(files []string, destPath, workingDir string, add func(name string, fi os.FileInfo, r io.Reader) error) ([]archiveSkip, error) {
+	var skipped []archiveSkip
 	for _, filename := range files {
 		path := filename
 		if !filepath.IsAbs(path) {
@@ -1434,155 +2178,177 @@ func createZip(files []string, destPath, workingDir string) error {
 
 		err := filepath.Walk(path, func(file string, fi os.FileInfo, err error) error {
 			if err != nil {
-				return err
+				skipped = append(skipped, archiveSkip{Path: file, Err: err})
+				if fi != nil && fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if file == destPath {
+				return nil
 			}
 
 			relPath, err := filepath.Rel(workingDir, file)
 			if err != nil {
 				return err
 			}
+			name := filepath.ToSlash(relPath)
 
 			if fi.IsDir() {
+				return add(name, fi, nil)
+			}
+			if !fi.Mode().IsRegular() {
+				skipped = append(skipped, archiveSkip{Path: file, Err: fmt.Errorf("not a regular file")})
 				return nil
 			}
 
-			data, err := os.ReadFile(file)
+			data, err := os.Open(file)
 			if err != nil {
-				return err
+				skipped = append(skipped, archiveSkip{Path: file, Err: err})
+				return nil
 			}
+			defer data.Close()
 
-			f, err := w.Create(relPath)
-			if err != nil {
-				return err
+			// Use the size at open time so the header matches what is copied
+			if current, err := data.Stat(); err == nil {
+				fi = current
 			}
-
-			_, err = f.Write(data)
-			return err
+			return add(name, fi, data)
 		})
 		if err != nil {
-			return err
+			return skipped, err
 		}
 	}
-	return nil
+	return skipped, nil
 }
 
-// TAR.GZ creation
-func createTarGz(files []string, destPath, workingDir string) error {
-	file, err := os.Create(destPath)
+// finishArchive closes the archive's writers and removes the partial file when
+// creation failed, so a truncated archive is never left behind
+func finishArchive(destPath string, err error, closers ...io.Closer) error {
+	for _, c := range closers {
+		if closeErr := c.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
 	if err != nil {
-		return err
+		os.Remove(destPath)
 	}
-	defer file.Close()
-
-	gw := gzip.NewWriter(file)
-	defer gw.Close()
-
-	tw := tar.NewWriter(gw)
-	defer tw.Close()
-
-	for _, filename := range files {
-		path := filename
-		if !filepath.IsAbs(path) {
-			path = filepath.Join(workingDir, filename)
-		}
-
-		err := filepath.Walk(path, func(file string, fi os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+	return err
+}
 
-			relPath, err := filepath.Rel(workingDir, file)
-			if err != nil {
-				return err
-			}
+// ZIP creation
+func createZip(files []string, destPath, workingDir string) ([]archiveSkip, error) {
+	file, err := os.Create(destPath)
+	if err != nil {
+		return nil, err
+	}
 
-			header, err := tar.FileInfoHeader(fi, "")
-			if err != nil {
-				return err
-			}
-			header.Name = relPath
+	w := zip.NewWriter(file)
 
-			if err := tw.WriteHeader(header); err != nil {
-				return err
+	skipped, err := walkArchiveFiles(files, destPath, workingDir, func(name string, fi os.FileInfo, r io.Reader) error {
+		if fi.IsDir() {
+			// Directory entries let extractors recreate the tree
+			if name == "." {
+				return nil
 			}
+			_, err := w.Create(name + "/")
+			return err
+		}
 
-			if !fi.IsDir() {
-				data, err := os.Open(file)
-				if err != nil {
-					return err
-				}
-				defer data.Close()
-
-				_, err = io.Copy(tw, data)
-				if err != nil {
-					return err
-				}
-			}
-			return nil
-		})
+		f, err := w.Create(name)
 		if err != nil {
 			return err
 		}
-	}
-	return nil
+
+		_, err = io.Copy(f, r)
+		return err
+	})
+	return skipped, finishArchive(destPath, err, w, file)
 }
 
-// TAR creation
-func createTar(files []string, destPath, workingDir string) error {
+// TAR.GZ creation
+func createTarGz(files []string, destPath, workingDir string) ([]archiveSkip, error) {
 	file, err := os.Create(destPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer file.Close()
 
-	tw := tar.NewWriter(file)
-	defer tw.Close()
+	gw := gzip.NewWriter(file)
+	tw := tar.NewWriter(gw)
 
-	for _, filename := range files {
-		path := filename
-		if !filepath.IsAbs(path) {
-			path = filepath.Join(workingDir, filename)
-		}
+	skipped, err := walkArchiveFiles(files, destPath, workingDir, tarEntryWriter(tw))
+	return skipped, finishArchive(destPath, err, tw, gw, file)
+}
 
-		err := filepath.Walk(path, func(file string, fi os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+// GZ creation (single file)
+func createGz(files []string, destPath, workingDir string) error {
+	if len(files) != 1 {
+		return fmt.Errorf("a plain .gz holds exactly one file, got %d; use .tar.gz or .zip for several", len(files))
+	}
+	src := files[0]
+	if !filepath.IsAbs(src) {
+		src = filepath.Join(workingDir, src)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory; use .tar.gz or .zip to archive directories", files[0])
+	}
 
-			relPath, err := filepath.Rel(workingDir, file)
-			if err != nil {
-				return err
-			}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
 
-			header, err := tar.FileInfoHeader(fi, "")
-			if err != nil {
-				return err
-			}
-			header.Name = relPath
+	file, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
 
-			if err := tw.WriteHeader(header); err != nil {
-				return err
-			}
+	gw := gzip.NewWriter(file)
+	gw.Name = info.Name()
+	gw.ModTime = info.ModTime()
 
-			if !fi.IsDir() {
-				data, err := os.Open(file)
-				if err != nil {
-					return err
-				}
-				defer data.Close()
+	_, err = io.Copy(gw, in)
+	return finishArchive(destPath, err, gw, file)
+}
 
-				_, err = io.Copy(tw, data)
-				if err != nil {
-					return err
-				}
-			}
-			return nil
-		})
+// TAR creation
+func createTar(files []string, destPath, workingDir string) ([]archiveSkip, error) {
+	file, err := os.Create(destPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tw := tar.NewWriter(file)
+
+	skipped, err := walkArchiveFiles(files, destPath, workingDir, tarEntryWriter(tw))
+	return skipped, finishArchive(destPath, err, tw, file)
+}
+
+// tarEntryWriter returns a walkArchiveFiles callback adding entries to tw
+func tarEntryWriter(tw *tar.Writer) func(name string, fi os.FileInfo, r io.Reader) error {
+	return func(name string, fi os.FileInfo, r io.Reader) error {
+		header, err := tar.FileInfoHeader(fi, "")
 		if err != nil {
 			return err
 		}
+		header.Name = name
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !fi.IsDir() {
+			if _, err := io.CopyN(tw, r, header.Size); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		return nil
 	}
-	return nil
 }
 
 // handleMakeDir handles directory creation
@@ -1634,6 +2400,73 @@ func (a *Agent) handleMakeDir(action *AgentAction, transcript *[]providers.ChatM
 	return nil
 }
 
+// handleTouch creates an empty file, with its parent directories, or sets the
+// access and modification times of an existing one
+func (a *Agent) handleTouch(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	fullPath := a.absPath(action.Path)
+	actionUI := a.display.ShowAction("Touch", action.Path, true)
+
+	reason := action.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("Create or update the timestamp of %s", action.Path)
+	}
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("touch %s", action.Path), reason, fullPath)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		return err
+	}
+
+	actionJSON, _ := json.Marshal(action)
+	if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+		a.display.UpdateAction(actionUI, "skipped", []string{"User declined"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:touch skipped by user"},
+		)
+		return nil
+	}
+
+	stamp := time.Now()
+	if action.Timestamp != "" {
+		stamp, _ = time.Parse(time.RFC3339, action.Timestamp)
+	}
+
+	summary := fmt.Sprintf("Updated the timestamps of %s to %s", action.Path, stamp.Format(time.RFC3339))
+	_, err = os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		summary = fmt.Sprintf("Created %s", action.Path)
+		err = os.MkdirAll(filepath.Dir(fullPath), 0755)
+		if err == nil {
+			var file *os.File
+			if file, err = os.OpenFile(fullPath, os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+				err = file.Close()
+			}
+		}
+		if err == nil && action.Timestamp != "" {
+			summary += fmt.Sprintf(" with timestamp %s", stamp.Format(time.RFC3339))
+		}
+	}
+	if err == nil {
+		err = os.Chtimes(fullPath, stamp, stamp)
+	}
+
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:touch error\n%s", err.Error())},
+		)
+		return nil
+	}
+
+	a.display.UpdateAction(actionUI, "completed", []string{summary})
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: "observation:touch success\n" + summary},
+	)
+	return nil
+}
+
 // handlePatchFile handles applying patches to files
 func (a *Agent) handlePatchFile(action *AgentAction, transcript *[]providers.ChatMessage) error {
 	path := action.Path
@@ -1650,11 +2483,25 @@ func (a *Agent) handlePatchFile(action *AgentAction, transcript *[]providers.Cha
 		return nil
 	}
 
-	actionUI := a.display.ShowAction("Patch file", path, false)
+	actionUI := a.display.ShowAction("Patch file", path, true)
 
-	// Read original file
+	// Hold the lock from reading to writing, so a change made by another
+	// session in between isn't silently overwritten
 	fullPath := filepath.Join(a.workingDir, path)
-	_, err := os.ReadFile(fullPath)
+	unlock, err := lockFiles(fullPath)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		actionJSON, _ := json.Marshal(action)
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:patch_file error\n%s", err.Error())},
+		)
+		return nil
+	}
+	defer unlock()
+
+	// Read original file
+	original, err := os.ReadFile(fullPath)
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		actionJSON, _ := json.Marshal(action)
@@ -1665,9 +2512,54 @@ func (a *Agent) handlePatchFile(action *AgentAction, transcript *[]providers.Cha
 		return nil
 	}
 
-	// Simple patch application - replace content
-	// This is a basic implementation; more sophisticated patch parsing could be added
-	err = os.WriteFile(fullPath, []byte(patch), 0644)
+	// "full" replaces the whole file; otherwise apply the unified diff hunks
+	newContent := patch
+	summary := "File replaced"
+	if action.Format != "full" {
+		hunks, err := parseUnifiedPatch(patch)
+		if err == nil {
+			newContent, err = applyUnifiedPatch(string(original), hunks)
+		}
+		if err != nil {
+			a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+			actionJSON, _ := json.Marshal(action)
+			*transcript = append(*transcript,
+				providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+				providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:patch_file error\n%s\nThe file was not changed. Read the current content and regenerate the patch.", err.Error())},
+			)
+			return nil
+		}
+		summary = fmt.Sprintf("Applied %d hunk(s)", len(hunks))
+	}
+
+	// Show the resulting change before asking for approval
+	a.previewFileChange(fullPath, path, newContent)
+
+	reason := action.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("Patch file %s", path)
+	}
+
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("patch_file %s", path), reason, fullPath)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Failed to get approval: %s", err.Error())})
+		return fmt.Errorf("failed to get approval: %w", err)
+	}
+
+	if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+		a.display.UpdateAction(actionUI, "skipped", []string{"Skipped by user"})
+		actionJSON, _ := json.Marshal(action)
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:patch_file skipped by user"},
+		)
+		return nil
+	}
+
+	journal, err := a.journalPrepare(action.Type, fullPath)
+	if err == nil {
+		err = os.WriteFile(fullPath, []byte(newContent), 0644)
+	}
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		actionJSON, _ := json.Marshal(action)
@@ -1677,12 +2569,13 @@ func (a *Agent) handlePatchFile(action *AgentAction, transcript *[]providers.Cha
 		)
 		return nil
 	}
+	a.journalCommit(journal)
 
-	a.display.UpdateAction(actionUI, "completed", []string{"File patched successfully"})
+	a.display.UpdateAction(actionUI, "completed", []string{summary})
 	actionJSON, _ := json.Marshal(action)
 	*transcript = append(*transcript,
 		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-		providers.ChatMessage{Role: "user", Content: "observation:patch_file success\nFile patched"},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:patch_file success\nFile patched\n%s", summary)},
 	)
 
 	return nil
@@ -1691,7 +2584,11 @@ func (a *Agent) handlePatchFile(action *AgentAction, transcript *[]providers.Cha
 // handleDownloadFile handles downloading files from URLs
 func (a *Agent) handleDownloadFile(action *AgentAction, transcript *[]providers.ChatMessage) error {
 	url := action.URL
-	path := action.Path
+	// dest is the documented field; path is accepted for older transcripts
+	path := action.Dest
+	if path == "" {
+		path = action.Path
+	}
 
 	if url == "" || path == "" {
 		actionUI := a.display.ShowAction("Download file", "Missing URL or path", false)
@@ -1706,9 +2603,51 @@ func (a *Agent) handleDownloadFile(action *AgentAction, transcript *[]providers.
 
 	actionUI := a.display.ShowAction("Download file", fmt.Sprintf("%s -> %s", url, path), false)
 
+	reason := action.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("Download %s to %s", url, path)
+	}
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("download_file %s %s", url, path), reason)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Failed to get approval: %s", err.Error())})
+		return fmt.Errorf("failed to get approval: %w", err)
+	}
+	if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+		a.display.UpdateAction(actionUI, "skipped", []string{"Skipped by user"})
+		actionJSON, _ := json.Marshal(action)
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:download_file skipped by user"},
+		)
+		return nil
+	}
+
+	fullPath := filepath.Join(a.workingDir, path)
+
+	// resume continues the partial file an interrupted download left, which is
+	// only safe with the validator it was fetched with to check it still matches
+	var offset int64
+	var validator, note string
+	if action.Resume != nil && *action.Resume {
+		if info, err := os.Stat(fullPath); err == nil && info.Mode().IsRegular() {
+			if validator = a.savedDownloadValidator(fullPath); validator != "" {
+				offset = info.Size()
+			} else {
+				note = " (no interrupted download of this file to resume)"
+			}
+		}
+	}
+
 	// Create HTTP client with timeout
 	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Get(url)
+	resp, err := a.requestDownload(client, action, offset, validator)
+	if err == nil && offset > 0 && (resp.StatusCode == http.StatusRequestedRangeNotSatisfiable ||
+		(resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp.Header.Get("Content-Range")) != offset)) {
+		// The partial file doesn't fit the remote one, or the server sent some
+		// other range; fetch it whole
+		resp.Body.Close()
+		resp, err = a.requestDownload(client, action, 0, "")
+	}
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		actionJSON, _ := json.Marshal(action)
@@ -1720,7 +2659,15 @@ func (a *Agent) handleDownloadFile(action *AgentAction, transcript *[]providers.
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	// 206 continues the partial file; a 200 means the server ignored the range
+	// or the file changed since
+	resumed := offset > 0 && resp.StatusCode == http.StatusPartialContent
+	if !resumed {
+		offset = 0
+		validator = downloadValidator(resp)
+	}
+
+	if resp.StatusCode != http.StatusOK && !resumed {
 		errMsg := fmt.Sprintf("HTTP %d: %s", resp.StatusCode, resp.Status)
 		a.display.UpdateAction(actionUI, "failed", []string{errMsg})
 		actionJSON, _ := json.Marshal(action)
@@ -1731,8 +2678,21 @@ func (a *Agent) handleDownloadFile(action *AgentAction, transcript *[]providers.
 		return nil
 	}
 
-	// Create destination file
-	fullPath := filepath.Join(a.workingDir, path)
+	// maxBytes caps the download; a declared size over the cap fails up front
+	var limit int64
+	if action.MaxBytes != nil && *action.MaxBytes > 0 {
+		limit = int64(*action.MaxBytes)
+	}
+	if limit > 0 && resp.ContentLength >= 0 && offset+resp.ContentLength > limit {
+		errMsg := errDownloadTooLarge{limit: limit}.Error()
+		a.display.UpdateAction(actionUI, "failed", []string{errMsg})
+		actionJSON, _ := json.Marshal(action)
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:download_file error\n%s: server reports %d bytes", errMsg, offset+resp.ContentLength)},
+		)
+		return nil
+	}
 
 	// Create directory if needed
 	dir := filepath.Dir(fullPath)
@@ -1746,7 +2706,12 @@ func (a *Agent) handleDownloadFile(action *AgentAction, transcript *[]providers.
 		return nil
 	}
 
-	outFile, err := os.Create(fullPath)
+	var outFile *os.File
+	if resumed {
+		outFile, err = os.OpenFile(fullPath, os.O_WRONLY|os.O_APPEND, 0)
+	} else {
+		outFile, err = os.Create(fullPath)
+	}
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		actionJSON, _ := json.Marshal(action)
@@ -1758,27 +2723,47 @@ func (a *Agent) handleDownloadFile(action *AgentAction, transcript *[]providers.
 	}
 	defer outFile.Close()
 
-	// Copy content
-	_, err = io.Copy(outFile, resp.Body)
+	// Copy content. A file over the cap is removed; any other partial file is
+	// kept so that running download_file again resumes it.
+	written, err := copyDownload(outFile, resp.Body, resp.ContentLength, limit, offset)
 	if err != nil {
+		outFile.Close()
+		errMsg := err.Error()
+		var tooLarge errDownloadTooLarge
+		if errors.As(err, &tooLarge) {
+			os.Remove(fullPath)
+			a.setDownloadValidator(fullPath, "")
+		} else if validator != "" {
+			a.setDownloadValidator(fullPath, validator)
+			errMsg += fmt.Sprintf("\nPartial file kept at %s (%d bytes); download_file again with resume: true to continue it", path, offset+written)
+		} else {
+			a.setDownloadValidator(fullPath, "")
+			errMsg += fmt.Sprintf("\nPartial file kept at %s (%d bytes); the server sent no ETag or Last-Modified, so it can't be resumed", path, offset+written)
+		}
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		actionJSON, _ := json.Marshal(action)
 		*transcript = append(*transcript,
 			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:download_file error\n%s", err.Error())},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:download_file error\n%s", errMsg)},
 		)
 		return nil
 	}
 
+	a.setDownloadValidator(fullPath, "")
+
 	// Get file size for feedback
 	stat, _ := outFile.Stat()
 	size := stat.Size()
 
-	a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Downloaded %d bytes", size)})
+	origin := "Started fresh" + note
+	if resumed {
+		origin = fmt.Sprintf("Resumed from byte %d (file is now %d bytes)", offset, size)
+	}
+	a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Downloaded %d bytes", written), origin})
 	actionJSON, _ := json.Marshal(action)
 	*transcript = append(*transcript,
 		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:download_file success\nDownloaded %d bytes to %s", size, path)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:download_file success\nDownloaded %d bytes to %s\n%s", written, path, origin)},
 	)
 
 	return nil
@@ -1805,15 +2790,7 @@ func (a *Agent) handleGrep(action *AgentAction, transcript *[]providers.ChatMess
 
 	actionUI := a.display.ShowAction("Grep", fmt.Sprintf("'%s' in %s", pattern, path), false)
 
-	// Compile regex
-	var regex *regexp.Regexp
-	var err error
-	if action.CaseSensitive != nil && *action.CaseSensitive {
-		regex, err = regexp.Compile(pattern)
-	} else {
-		regex, err = regexp.Compile("(?i)" + pattern)
-	}
-
+	regex, err := compileGrepPattern(pattern, action.Regex, action.CaseSensitive != nil && *action.CaseSensitive)
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{"Invalid regex pattern"})
 		actionJSON, _ := json.Marshal(action)
@@ -1825,14 +2802,15 @@ func (a *Agent) handleGrep(action *AgentAction, transcript *[]providers.ChatMess
 	}
 
 	// Search files
-	var results []string
+	var results, skipped []string
 	maxResults := 100
 	if action.MaxResults != nil {
 		maxResults = *action.MaxResults
 	}
 
 	fullPath := filepath.Join(a.workingDir, path)
-	err = filepath.Walk(fullPath, func(filePath string, info os.FileInfo, err error) error {
+	opts := a.walkOptionsFor(action)
+	err = walkTree(fullPath, opts, func(filePath string, info os.FileInfo, err error) error {
 		if err != nil || info.IsDir() {
 			return nil
 		}
@@ -1842,21 +2820,21 @@ func (a *Agent) handleGrep(action *AgentAction, transcript *[]providers.ChatMess
 			return nil
 		}
 
-		content, err := os.ReadFile(filePath)
+		content, err := opts.OpenFiles.readFile(filePath)
 		if err != nil {
 			return nil
 		}
 
-		lines := strings.Split(string(content), "\n")
-		for lineNum, line := range lines {
-			if regex.MatchString(line) {
-				relPath, _ := filepath.Rel(a.workingDir, filePath)
-				results = append(results, fmt.Sprintf("%s:%d:%s", relPath, lineNum+1, strings.TrimSpace(line)))
-
-				if len(results) >= maxResults {
-					return fmt.Errorf("max results reached")
-				}
-			}
+		relPath, _ := filepath.Rel(a.workingDir, filePath)
+		matches, timedOut := matchFileLines(regex, content, maxResults-len(results), searchFileTimeout)
+		if timedOut {
+			skipped = append(skipped, relPath)
+		}
+		for _, m := range matches {
+			results = append(results, fmt.Sprintf("%s:%d:%s", relPath, m.num, strings.TrimSpace(m.line)))
+		}
+		if len(results) >= maxResults {
+			return fmt.Errorf("max results reached")
 		}
 		return nil
 	})
@@ -1875,12 +2853,13 @@ func (a *Agent) handleGrep(action *AgentAction, transcript *[]providers.ChatMess
 	if len(results) == 0 {
 		resultText = "No matches found"
 	}
+	resultText += skippedFilesNote(skipped)
 
 	a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Found %d matches", len(results))})
 	actionJSON, _ := json.Marshal(action)
 	*transcript = append(*transcript,
 		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:grep success\n%s", truncateString(resultText, 4000))},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:grep success\n%s", a.limitObservation(action, resultText, 4000))},
 	)
 
 	return nil
@@ -1930,54 +2909,51 @@ func (a *Agent) handleDiff(action *AgentAction, transcript *[]providers.ChatMess
 		return nil
 	}
 
-	// Simple line-by-line diff
-	lines1 := strings.Split(string(content1), "\n")
-	lines2 := strings.Split(string(content2), "\n")
-
-	var diffResult []string
-	maxLen := len(lines1)
-	if len(lines2) > maxLen {
-		maxLen = len(lines2)
+	contextLines := 3
+	if action.Context != nil {
+		contextLines = *action.Context
 	}
 
-	for i := 0; i < maxLen; i++ {
-		var line1, line2 string
-		if i < len(lines1) {
-			line1 = lines1[i]
-		}
-		if i < len(lines2) {
-			line2 = lines2[i]
-		}
-
-		if line1 != line2 {
-			if i < len(lines1) {
-				diffResult = append(diffResult, fmt.Sprintf("-%d: %s", i+1, line1))
+	var result string
+	changes := 0
+	if action.Format == "side-by-side" {
+		// One line per changed line, numbered in its own file
+		var diffResult []string
+		for _, op := range diffLines(splitLines(string(content1)), splitLines(string(content2))) {
+			switch op.Kind {
+			case diffDelete:
+				diffResult = append(diffResult, fmt.Sprintf("-%d: %s", op.ALine, op.Line))
+			case diffInsert:
+				diffResult = append(diffResult, fmt.Sprintf("+%d: %s", op.BLine, op.Line))
 			}
-			if i < len(lines2) {
-				diffResult = append(diffResult, fmt.Sprintf("+%d: %s", i+1, line2))
+		}
+		changes = len(diffResult)
+		result = strings.Join(diffResult, "\n")
+	} else {
+		result = unifiedDiff(file1, file2, string(content1), string(content2), contextLines)
+		for _, line := range strings.Split(result, "\n") {
+			if (strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++ ")) || (strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "--- ")) {
+				changes++
 			}
 		}
 	}
-
-	var result string
-	if len(diffResult) == 0 {
+	if changes == 0 {
 		result = "Files are identical"
-	} else {
-		result = strings.Join(diffResult, "\n")
 	}
 
-	a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Found %d differences", len(diffResult))})
+	a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Found %d differences", changes)})
 	actionJSON, _ := json.Marshal(action)
 	*transcript = append(*transcript,
 		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:diff success\n%s", truncateString(result, 4000))},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:diff success\n%s", a.limitObservation(action, result, 4000))},
 	)
 
 	return nil
 }
 
-// performFileSearch performs the actual file search with regex
-func (a *Agent) performFileSearch(pattern, searchPath string, fileTypes []string, caseSensitive bool, maxResults int) ([]SearchResult, error) {
+// performFileSearch performs the actual file search with regex. It also returns
+// the files that took too long to match (see matchFileLines).
+func (a *Agent) performFileSearch(pattern, searchPath string, fileTypes []string, caseSensitive bool, maxResults int, opts walkOptions) ([]SearchResult, []string, error) {
 	// Compile regex pattern
 	var regex *regexp.Regexp
 	var err error
@@ -1989,7 +2965,7 @@ func (a *Agent) performFileSearch(pattern, searchPath string, fileTypes []string
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+		return nil, nil, fmt.Errorf("invalid regex pattern: %w", err)
 	}
 
 	// Create file type map for quick lookup
@@ -2004,21 +2980,16 @@ func (a *Agent) performFileSearch(pattern, searchPath string, fileTypes []string
 	}
 
 	var results []SearchResult
+	var skipped []string
 	base := filepath.Join(a.workingDir, searchPath)
 
-	err = filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+	err = walkTree(base, opts, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // Skip files we can't access
 		}
 
 		if info.IsDir() {
-			// Skip common heavy directories
-			dirName := filepath.Base(path)
-			if dirName == "node_modules" || dirName == ".git" || dirName == ".venv" ||
-				dirName == "__pycache__" {
-				return filepath.SkipDir
-			}
-			return nil
+			return nil // Ignored directories are skipped by walkTree
 		}
 
 		// Check file extension
@@ -2033,54 +3004,78 @@ func (a *Agent) performFileSearch(pattern, searchPath string, fileTypes []string
 		}
 
 		// Read and search file
-		content, err := os.ReadFile(path)
+		content, err := opts.OpenFiles.readFile(path)
 		if err != nil {
 			return nil // Skip files we can't read
 		}
 
-		lines := strings.Split(string(content), "\n")
-		for lineNum, line := range lines {
-			if regex.MatchString(line) {
-				relPath, _ := filepath.Rel(base, path)
-				results = append(results, SearchResult{
-					File:    relPath,
-					LineNum: lineNum + 1,
-					Line:    line,
-				})
-
-				if len(results) >= maxResults {
-					return fmt.Errorf("max results reached")
-				}
-			}
+		relPath, _ := filepath.Rel(base, path)
+		matches, timedOut := matchFileLines(regex, content, maxResults-len(results), searchFileTimeout)
+		if timedOut {
+			skipped = append(skipped, relPath)
+		}
+		for _, m := range matches {
+			results = append(results, SearchResult{
+				File:    relPath,
+				LineNum: m.num,
+				Line:    m.line,
+			})
+		}
+		if len(results) >= maxResults {
+			return fmt.Errorf("max results reached")
 		}
 
 		return nil
 	})
 
 	if err != nil && err.Error() != "max results reached" {
-		return results, err
+		return results, skipped, err
 	}
 
-	return results, nil
+	return results, skipped, nil
 }
 
-// copyPathHelper is a helper function to copy files and directories
-func copyPathHelper(src, dest string, overwrite bool) error {
-	srcInfo, err := os.Stat(src)
+// copyPathHelper is a helper function to copy files and directories. Symlinks
+// are recreated rather than followed; it returns how many were preserved.
+func copyPathHelper(src, dest string, overwrite bool) (int, error) {
+	srcInfo, err := os.Lstat(src)
 	if err != nil {
-		return fmt.Errorf("source path does not exist: %w", err)
+		return 0, fmt.Errorf("source path does not exist: %w", err)
 	}
 
 	if !overwrite {
-		if _, err := os.Stat(dest); err == nil {
-			return fmt.Errorf("destination already exists and overwrite is false")
+		if _, err := os.Lstat(dest); err == nil {
+			return 0, fmt.Errorf("destination already exists and overwrite is false")
 		}
 	}
 
-	if srcInfo.IsDir() {
-		return copyDir(src, dest)
+	switch {
+	case srcInfo.Mode()&os.ModeSymlink != 0:
+		return 1, copySymlink(src, dest)
+	case srcInfo.IsDir():
+		symlinks := 0
+		err := copyDir(src, dest, nil, &symlinks)
+		return symlinks, err
+	}
+	return 0, copyFile(src, dest)
+}
+
+// copySymlink recreates the symlink src at dest with the same target
+func copySymlink(src, dest string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
 	}
-	return copyFile(src, dest)
+	// Replace whatever is at dest rather than writing through an old link
+	if info, err := os.Lstat(dest); err == nil && !info.IsDir() {
+		if err := os.Remove(dest); err != nil {
+			return err
+		}
+	}
+	return os.Symlink(target, dest)
 }
 
 // copyFile copies a single file
@@ -2116,12 +3111,20 @@ func copyFile(src, dest string) error {
 	return os.Chmod(dest, srcInfo.Mode())
 }
 
-// copyDir recursively copies a directory
-func copyDir(src, dest string) error {
+// copyDir recursively copies a directory. ancestors holds the directories
+// above src in the copy so a directory that contains itself is refused
+// instead of copied forever; symlinks counts the links recreated.
+func copyDir(src, dest string, ancestors []os.FileInfo, symlinks *int) error {
 	srcInfo, err := os.Stat(src)
 	if err != nil {
 		return err
 	}
+	for _, ancestor := range ancestors {
+		if os.SameFile(ancestor, srcInfo) {
+			return fmt.Errorf("directory cycle at %s", src)
+		}
+	}
+	ancestors = append(ancestors, srcInfo)
 
 	err = os.MkdirAll(dest, srcInfo.Mode())
 	if err != nil {
@@ -2137,9 +3140,13 @@ func copyDir(src, dest string) error {
 		srcPath := filepath.Join(src, entry.Name())
 		destPath := filepath.Join(dest, entry.Name())
 
-		if entry.IsDir() {
-			err = copyDir(srcPath, destPath)
-		} else {
+		switch {
+		case entry.Type()&os.ModeSymlink != 0:
+			err = copySymlink(srcPath, destPath)
+			*symlinks++
+		case entry.IsDir():
+			err = copyDir(srcPath, destPath, ancestors, symlinks)
+		default:
 			err = copyFile(srcPath, destPath)
 		}
 		if err != nil {
@@ -2182,6 +3189,9 @@ func (a *Agent) handleParse(action *AgentAction, transcript *[]providers.ChatMes
 	}
 
 	var result string
+	var parsed interface{}
+	var table *ui.Table
+	valid := false
 	switch strings.ToLower(parseType) {
 	case "json":
 		var jsonData interface{}
@@ -2189,8 +3199,7 @@ func (a *Agent) handleParse(action *AgentAction, transcript *[]providers.ChatMes
 		if err != nil {
 			result = fmt.Sprintf("Invalid JSON: %s", err.Error())
 		} else {
-			prettyJSON, _ := json.MarshalIndent(jsonData, "", "  ")
-			result = string(prettyJSON)
+			parsed, valid = jsonData, true
 		}
 	case "yaml":
 		var yamlData interface{}
@@ -2198,35 +3207,86 @@ func (a *Agent) handleParse(action *AgentAction, transcript *[]providers.ChatMes
 		if err != nil {
 			result = fmt.Sprintf("Invalid YAML: %s", err.Error())
 		} else {
-			jsonData, _ := json.MarshalIndent(yamlData, "", "  ")
-			result = string(jsonData)
+			parsed, valid = jsonCompatible(yamlData), true
+		}
+	case "toml":
+		tomlData, err := parseTOML(content)
+		if err != nil {
+			result = fmt.Sprintf("Invalid TOML: %s", err.Error())
+		} else {
+			parsed, valid = tomlData, true
+		}
+	case "csv":
+		var delim rune
+		if action.Delimiter != "" {
+			delim, _ = parseDelimiter(action.Delimiter)
+		}
+		maxRows := 20
+		if action.MaxRows != nil {
+			maxRows = *action.MaxRows
+		}
+		preview, err := previewCSV(content, delim, maxRows)
+		if err != nil {
+			result = fmt.Sprintf("Invalid CSV: %s", err.Error())
+		} else {
+			result = preview.String()
+			table = preview.table()
 		}
 	default:
 		result = fmt.Sprintf("Unsupported parse type: %s", parseType)
 	}
 
-	a.display.UpdateAction(actionUI, "completed", []string{"Parse completed"})
-	actionJSON, _ := json.Marshal(action)
-	*transcript = append(*transcript,
-		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:parse success\n%s", truncateString(result, 4000))},
-	)
-
-	return nil
-}
-
-// handleConfirm handles user confirmation prompts
-func (a *Agent) handleConfirm(action *AgentAction, transcript *[]providers.ChatMessage) error {
-	question := action.Question
-	if question == "" {
-		question = "Do you want to proceed?"
+	if valid && action.Query != "" {
+		if parsed, err = queryJSONPath(parsed, action.Query); err != nil {
+			a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+			actionJSON, _ := json.Marshal(action)
+			*transcript = append(*transcript,
+				providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+				providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:parse error\n%s", err.Error())},
+			)
+			return nil
+		}
+	}
+	if valid {
+		prettyJSON, err := json.MarshalIndent(parsed, "", "  ")
+		if err != nil {
+			a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+			actionJSON, _ := json.Marshal(action)
+			*transcript = append(*transcript,
+				providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+				providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:parse error\n%s", err.Error())},
+			)
+			return nil
+		}
+		result = string(prettyJSON)
+	}
+
+	a.display.UpdateAction(actionUI, "completed", []string{"Parse completed"})
+	if parsed != nil {
+		a.display.ShowJSON(parsed, maxJSONItems, maxJSONLines)
+	}
+	if table != nil {
+		a.display.ShowTable(table, maxTableRows)
+	}
+	actionJSON, _ := json.Marshal(action)
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:parse success\n%s", a.limitObservation(action, result, 4000))},
+	)
+
+	return nil
+}
+
+// handleConfirm handles user confirmation prompts
+func (a *Agent) handleConfirm(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	question := action.Question
+	if question == "" {
+		question = "Do you want to proceed?"
 	}
 
 	actionUI := a.display.ShowAction("Confirm", question, false)
 
-	fmt.Printf("\n%s (y/N): ", question)
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
+	response, err := a.askUser(question, " (y/N): ")
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		return err
@@ -2332,8 +3392,11 @@ func (a *Agent) handleHashFile(action *AgentAction, transcript *[]providers.Chat
 
 	actionUI := a.display.ShowAction("Hash", fmt.Sprintf("%s (%s)", path, algo), false)
 
-	fullPath := filepath.Join(a.workingDir, path)
-	content, err := os.ReadFile(fullPath)
+	fullPath := path
+	if !filepath.IsAbs(fullPath) {
+		fullPath = filepath.Join(a.workingDir, path)
+	}
+	hash, err := hashFile(fullPath, algo)
 	if err != nil {
 		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
 		actionJSON, _ := json.Marshal(action)
@@ -2344,21 +3407,6 @@ func (a *Agent) handleHashFile(action *AgentAction, transcript *[]providers.Chat
 		return nil
 	}
 
-	var hash string
-	switch strings.ToLower(algo) {
-	case "md5":
-		h := fmt.Sprintf("%x", content) // Simple placeholder
-		hash = h[:32]                   // Truncate to MD5 length for demo
-	case "sha1":
-		h := fmt.Sprintf("%x", content)
-		hash = h[:40] // Truncate to SHA1 length for demo
-	case "sha256":
-		h := fmt.Sprintf("%x", content)
-		hash = h[:64] // Truncate to SHA256 length for demo
-	default:
-		hash = "unsupported algorithm"
-	}
-
 	a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("%s: %s", algo, hash)})
 	actionJSON, _ := json.Marshal(action)
 	*transcript = append(*transcript,
@@ -2389,23 +3437,164 @@ func (a *Agent) handleChecksumVerify(action *AgentAction, transcript *[]provider
 		return nil
 	}
 
+	// A prefix such as "sha512:" names the algorithm of the expected digest
+	prefixAlgo, digest := splitChecksum(expected)
+	if prefixAlgo != "" {
+		algo = prefixAlgo
+	}
+	expected = digest
+
 	actionUI := a.display.ShowAction("Verify", fmt.Sprintf("%s (%s)", path, algo), false)
+	actionJSON, _ := json.Marshal(action)
 
-	// For now, just simulate verification
-	verified := true // This would be actual verification in real implementation
+	fullPath := path
+	if !filepath.IsAbs(fullPath) {
+		fullPath = filepath.Join(a.workingDir, path)
+	}
+	actual, err := hashFile(fullPath, algo)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:checksum_verify error\n%s", err.Error())},
+		)
+		return nil
+	}
 
-	if verified {
-		a.display.UpdateAction(actionUI, "completed", []string{"Checksum verified"})
-	} else {
+	if !strings.EqualFold(actual, expected) {
 		a.display.UpdateAction(actionUI, "failed", []string{"Checksum mismatch"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:checksum_verify failed\nChecksum mismatch for %s (%s)\nExpected: %s\nActual:   %s", path, algo, expected, actual)},
+		)
+		return nil
+	}
+
+	a.display.UpdateAction(actionUI, "completed", []string{"Checksum verified"})
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:checksum_verify success\nChecksum verified for %s (%s: %s)", path, algo, actual)},
+	)
+
+	return nil
+}
+
+// handleHashDir hashes every file under a directory with a worker pool
+func (a *Agent) handleHashDir(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	root := action.Path
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(a.workingDir, root)
+	}
+
+	actionUI := a.display.ShowAction("Hash directory", fmt.Sprintf("%s (%s, %d workers)", action.Path, action.Algo, *action.Workers), action.Dest != "")
+	actionJSON, _ := json.Marshal(action)
+
+	digests, failures, err := hashTree(root, action.Algo, *action.Workers, a.walkOptionsFor(action))
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:hash_dir error\n%s", err.Error())},
+		)
+		return nil
+	}
+
+	checksums := formatChecksums(digests)
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Hashed %d files with %s\n", len(digests), action.Algo))
+	if len(failures) > 0 {
+		result.WriteString(fmt.Sprintf("Failed to hash %d files:\n", len(failures)))
+		for path, failure := range failures {
+			result.WriteString(fmt.Sprintf("  %s: %s\n", path, failure.Error()))
+		}
+	}
+
+	if action.Dest != "" {
+		destPath := action.Dest
+		if !filepath.IsAbs(destPath) {
+			destPath = filepath.Join(a.workingDir, destPath)
+		}
+
+		decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("hash_dir write %s", action.Dest), fmt.Sprintf("Write checksums file %s", action.Dest), destPath)
+		if err != nil {
+			a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Failed to get approval: %s", err.Error())})
+			return fmt.Errorf("failed to get approval: %w", err)
+		}
+
+		if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+			result.WriteString("Checksums file not written (skipped by user)\n")
+		} else if err := os.WriteFile(destPath, []byte(checksums), 0644); err != nil {
+			result.WriteString(fmt.Sprintf("Failed to write checksums file: %s\n", err.Error()))
+		} else {
+			result.WriteString(fmt.Sprintf("Checksums written to %s\n", action.Dest))
+		}
+	}
+
+	result.WriteString("\n")
+	result.WriteString(checksums)
+
+	a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Hashed %d files", len(digests))})
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:hash_dir success\n%s", a.limitObservation(action, result.String(), 8000))},
+	)
+
+	return nil
+}
+
+// handleVerifyChecksums verifies the files listed in a SHA256SUMS-style file
+func (a *Agent) handleVerifyChecksums(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	sumsPath := action.Path
+	if !filepath.IsAbs(sumsPath) {
+		sumsPath = filepath.Join(a.workingDir, sumsPath)
 	}
 
+	actionUI := a.display.ShowAction("Verify checksums", action.Path, false)
 	actionJSON, _ := json.Marshal(action)
+
+	content, err := os.ReadFile(sumsPath)
+	var entries []checksumEntry
+	if err == nil {
+		entries, err = parseChecksums(string(content), action.Algo)
+	}
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:verify_checksums error\n%s", err.Error())},
+		)
+		return nil
+	}
+
+	results := verifyChecksums(entries, filepath.Dir(sumsPath), *action.Workers, a.openFiles)
+
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+
+	report := struct {
+		Passed  int              `json:"passed"`
+		Failed  int              `json:"failed"`
+		Missing int              `json:"missing"`
+		Errors  int              `json:"errors"`
+		Results []checksumResult `json:"results"`
+	}{counts["passed"], counts["failed"], counts["missing"], counts["error"], results}
+	reportJSON, _ := json.MarshalIndent(report, "", "  ")
+
+	status := "success"
+	summary := fmt.Sprintf("%d passed, %d failed, %d missing", report.Passed, report.Failed, report.Missing)
+	if report.Failed > 0 || report.Missing > 0 || report.Errors > 0 {
+		status = "failed"
+		a.display.UpdateAction(actionUI, "failed", []string{summary})
+	} else {
+		a.display.UpdateAction(actionUI, "completed", []string{summary})
+	}
+
 	*transcript = append(*transcript,
 		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:checksum_verify %s\nChecksum %s",
-			map[bool]string{true: "success", false: "failed"}[verified],
-			map[bool]string{true: "verified", false: "mismatch"}[verified])},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:verify_checksums %s\n%s", status, a.limitObservation(action, string(reportJSON), 8000))},
 	)
 
 	return nil
@@ -2526,7 +3715,7 @@ func (a *Agent) handleEnvGet(action *AgentAction, transcript *[]providers.ChatMe
 
 	actionUI := a.display.ShowAction("Env Get", key, false)
 
-	value := os.Getenv(key)
+	value := a.GetEnv(key)
 	if value == "" {
 		a.display.UpdateAction(actionUI, "completed", []string{"Variable not set"})
 	} else {
@@ -2560,22 +3749,62 @@ func (a *Agent) handleEnvSet(action *AgentAction, transcript *[]providers.ChatMe
 
 	actionUI := a.display.ShowAction("Env Set", fmt.Sprintf("%s=%s", key, value), false)
 
-	err := os.Setenv(key, value)
-	if err != nil {
-		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+	if !envNameRe.MatchString(key) {
+		a.display.UpdateAction(actionUI, "failed", []string{"Invalid variable name"})
 		actionJSON, _ := json.Marshal(action)
 		*transcript = append(*transcript,
 			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:env_set error\n%s", err.Error())},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:env_set error\ninvalid variable name %q", key)},
 		)
 		return nil
 	}
 
-	a.display.UpdateAction(actionUI, "completed", []string{"Environment variable set"})
+	// Persisting changes the user's environment beyond this run, so it needs approval
+	scope := "session only"
+	if action.Persist != nil && *action.Persist {
+		reason := action.Reason
+		if reason == "" {
+			reason = fmt.Sprintf("Persist environment variable %s", key)
+		}
+		decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("env_set --persist %s", key), reason)
+		if err != nil {
+			a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Failed to get approval: %s", err.Error())})
+			return fmt.Errorf("failed to get approval: %w", err)
+		}
+		if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+			a.display.UpdateAction(actionUI, "skipped", []string{"Skipped by user"})
+			actionJSON, _ := json.Marshal(action)
+			*transcript = append(*transcript,
+				providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+				providers.ChatMessage{Role: "user", Content: "observation:env_set skipped by user"},
+			)
+			return nil
+		}
+
+		where, err := a.persistEnv(key, value)
+		if err != nil {
+			a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+			actionJSON, _ := json.Marshal(action)
+			*transcript = append(*transcript,
+				providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+				providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:env_set error\nFailed to persist %s: %s", key, err.Error())},
+			)
+			return nil
+		}
+		scope = "persisted to " + where
+	}
+
+	// Scoped to this agent: later commands see it, the process environment doesn't change
+	a.SetEnv(key, value)
+	for _, s := range a.shellSessions {
+		s.setEnv(key, value)
+	}
+
+	a.display.UpdateAction(actionUI, "completed", []string{"Environment variable set (" + scope + ")"})
 	actionJSON, _ := json.Marshal(action)
 	*transcript = append(*transcript,
 		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
-		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:env_set success\n%s=%s", key, value)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:env_set success\n%s=%s\nScope: %s", key, value, scope)},
 	)
 
 	return nil
@@ -2587,9 +3816,9 @@ func (a *Agent) handleWhoami(action *AgentAction, transcript *[]providers.ChatMe
 
 	var username string
 	if runtime.GOOS == "windows" {
-		username = os.Getenv("USERNAME")
+		username = a.GetEnv("USERNAME")
 	} else {
-		username = os.Getenv("USER")
+		username = a.GetEnv("USER")
 	}
 
 	if username == "" {
@@ -2605,3 +3834,935 @@ func (a *Agent) handleWhoami(action *AgentAction, transcript *[]providers.ChatMe
 
 	return nil
 }
+
+// handleStats reports the current conversation state so long tasks can self-regulate
+func (a *Agent) handleStats(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	actionUI := a.display.ShowAction("Stats", "Get conversation statistics", false)
+
+	actionCounts, observationBytes := a.actionStats()
+
+	var types []string
+	for actionType := range actionCounts {
+		types = append(types, actionType)
+	}
+	sort.Strings(types)
+
+	var counts []string
+	for _, actionType := range types {
+		counts = append(counts, fmt.Sprintf("%s=%d", actionType, actionCounts[actionType]))
+	}
+
+	var sizes []string
+	for _, actionType := range types {
+		if n := observationBytes[actionType]; n > 0 {
+			sizes = append(sizes, fmt.Sprintf("%s=%d", actionType, n))
+		}
+	}
+
+	elapsed := time.Duration(0)
+	if !a.startTime.IsZero() {
+		elapsed = time.Since(a.startTime).Round(time.Second)
+	}
+
+	var info strings.Builder
+	info.WriteString(fmt.Sprintf("Iterations: %d/%d\n", a.iteration, a.maxIterations))
+	info.WriteString(fmt.Sprintf("Remaining: %d\n", a.maxIterations-a.iteration))
+	info.WriteString(fmt.Sprintf("Transcript messages: %d\n", len(*transcript)))
+	info.WriteString(fmt.Sprintf("Approx tokens: %d\n", providers.EstimateTokensForMessages(a.provider, *transcript)))
+	info.WriteString(fmt.Sprintf("Elapsed: %s\n", elapsed))
+	info.WriteString(fmt.Sprintf("Actions: %s", strings.Join(counts, ", ")))
+	if len(sizes) > 0 {
+		info.WriteString(fmt.Sprintf("\nObservation bytes: %s", strings.Join(sizes, ", ")))
+	}
+
+	a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Iteration %d/%d", a.iteration, a.maxIterations)})
+	actionJSON, _ := json.Marshal(action)
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:stats success\n%s", info.String())},
+	)
+
+	return nil
+}
+
+// handleSummarizeFile asks the provider for a concise summary of a file so the
+// transcript gets the gist instead of the raw content
+func (a *Agent) handleSummarizeFile(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	actionUI := a.display.ShowAction("Summarize file", action.Path, false)
+	actionJSON, _ := json.Marshal(action)
+
+	// The summary is a plain chat call, but never let it re-enter summarize_file
+	if a.summarizing {
+		a.display.UpdateAction(actionUI, "failed", []string{"summarize_file cannot be nested"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:summarize_file error\nsummarize_file cannot be used while a summary is being generated"},
+		)
+		return nil
+	}
+
+	filePath := action.Path
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(a.workingDir, filePath)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:summarize_file error\n%s", err.Error())},
+		)
+		return nil
+	}
+
+	content := string(data)
+	note := ""
+	if len(content) > maxSummarizeInputBytes {
+		cut := maxSummarizeInputBytes
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		content = content[:cut]
+		note = fmt.Sprintf(" (only the first %d of %d bytes were summarized)", cut, len(data))
+	}
+
+	request := fmt.Sprintf("File: %s\n", action.Path)
+	if action.Focus != "" {
+		request += fmt.Sprintf("Focus: %s\n", action.Focus)
+	}
+	request += fmt.Sprintf("\n%s", content)
+
+	a.summarizing = true
+	summary, err := a.provider.Chat([]providers.ChatMessage{
+		{Role: "system", Content: summarizePrompt},
+		{Role: "user", Content: request},
+	}, nil)
+	a.summarizing = false
+	if err == nil {
+		a.addUsage(providers.LastUsage(a.provider))
+	}
+
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:summarize_file error\nfailed to summarize: %s", err.Error())},
+		)
+		return nil
+	}
+
+	actionUI.Summary = fmt.Sprintf("Summarized %d bytes", len(data))
+	a.display.UpdateAction(actionUI, "completed", []string{actionUI.Summary})
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:summarize_file success%s\n%s", note, a.limitObservation(action, strings.TrimSpace(summary), 4000))},
+	)
+
+	return nil
+}
+
+// sessionModel returns the model used for the next provider call
+func (a *Agent) sessionModel() string {
+	if a.chatOptions != nil && a.chatOptions.Model != "" {
+		return a.chatOptions.Model
+	}
+	return a.provider.DefaultModel()
+}
+
+// modelSwitchDisabled records that the user hasn't allowed model/temperature changes
+func (a *Agent) modelSwitchDisabled(action *AgentAction, actionUI *ui.InteractiveAction, transcript *[]providers.ChatMessage) {
+	a.display.UpdateAction(actionUI, "skipped", []string{"Model switching is disabled"})
+	actionJSON, _ := json.Marshal(action)
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:%s error\nchanging the model or temperature is disabled by the user; continue with %s", action.Type, a.sessionModel())},
+	)
+}
+
+// handleSetModel switches the model used for the rest of the session
+func (a *Agent) handleSetModel(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	actionUI := a.display.ShowAction("Set model", action.Model, false)
+	if !a.allowModelSwitch {
+		a.modelSwitchDisabled(action, actionUI, transcript)
+		return nil
+	}
+
+	actionJSON, _ := json.Marshal(action)
+
+	known := a.knownModels()
+	valid := len(known) == 0 || action.Model == a.provider.DefaultModel()
+	for _, model := range known {
+		if model == action.Model {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Unknown model for %s", a.provider.Name())})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:set_model error\nunknown model %q for %s; known models: %s", action.Model, a.provider.Name(), strings.Join(known, ", "))},
+		)
+		return nil
+	}
+
+	previous := a.sessionModel()
+	if a.chatOptions == nil {
+		a.chatOptions = &providers.ChatOptions{}
+	}
+	a.chatOptions.Model = action.Model
+
+	actionUI.Summary = fmt.Sprintf("%s -> %s", previous, action.Model)
+	a.display.UpdateAction(actionUI, "completed", []string{actionUI.Summary})
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:set_model success\nmodel changed from %s to %s", previous, action.Model)},
+	)
+
+	return nil
+}
+
+// handleSetTemperature changes the sampling temperature for the rest of the session
+func (a *Agent) handleSetTemperature(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	actionUI := a.display.ShowAction("Set temperature", fmt.Sprintf("%.2f", *action.Temperature), false)
+	if !a.allowModelSwitch {
+		a.modelSwitchDisabled(action, actionUI, transcript)
+		return nil
+	}
+	if !providers.SupportsTemperature(a.provider.Name(), a.sessionModel()) {
+		a.display.UpdateAction(actionUI, "skipped", []string{"Model does not accept a temperature"})
+		actionJSON, _ := json.Marshal(action)
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:set_temperature error\n%s does not accept a temperature; it always uses its default", a.sessionModel())},
+		)
+		return nil
+	}
+
+	if a.chatOptions == nil {
+		a.chatOptions = &providers.ChatOptions{}
+	}
+
+	result := fmt.Sprintf("temperature set to %.2f", *action.Temperature)
+	if *action.Temperature < 0 {
+		a.chatOptions.Temperature = nil
+		result = "temperature reset to the provider default"
+	} else {
+		temperature := *action.Temperature
+		a.chatOptions.Temperature = &temperature
+	}
+
+	a.display.UpdateAction(actionUI, "completed", []string{result})
+	actionJSON, _ := json.Marshal(action)
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:set_temperature success\n%s", result)},
+	)
+
+	return nil
+}
+
+// handleGetModel reports the model and temperature used for the next responses
+func (a *Agent) handleGetModel(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	actionUI := a.display.ShowAction("Get model", "Get current model settings", false)
+
+	temperature := "provider default"
+	if !providers.SupportsTemperature(a.provider.Name(), a.sessionModel()) {
+		temperature = "not supported by this model"
+	} else if a.chatOptions != nil && a.chatOptions.Temperature != nil {
+		temperature = fmt.Sprintf("%.2f", *a.chatOptions.Temperature)
+	}
+
+	var info strings.Builder
+	info.WriteString(fmt.Sprintf("Provider: %s\n", a.provider.Name()))
+	info.WriteString(fmt.Sprintf("Model: %s\n", a.sessionModel()))
+	info.WriteString(fmt.Sprintf("Temperature: %s\n", temperature))
+	if caps, ok := providers.CachedModelCapabilities(a.provider.Name(), a.sessionModel()); ok {
+		info.WriteString(fmt.Sprintf("Context tokens: %d\n", providers.ModelContextTokens(a.provider, a.sessionModel())))
+		info.WriteString(fmt.Sprintf("Tool calls: %t\n", caps.Supports.ToolCalls))
+	}
+	info.WriteString(fmt.Sprintf("Known models: %s\n", strings.Join(a.knownModels(), ", ")))
+	info.WriteString(fmt.Sprintf("Switching allowed: %t", a.allowModelSwitch))
+
+	a.display.UpdateAction(actionUI, "completed", []string{a.sessionModel()})
+	actionJSON, _ := json.Marshal(action)
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:get_model success\n%s", info.String())},
+	)
+
+	return nil
+}
+
+// handleDirSnapshot saves a path->digest snapshot of a directory or compares the
+// directory against a saved snapshot
+func (a *Agent) handleDirSnapshot(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	root := action.Path
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(a.workingDir, root)
+	}
+	snapshotPath := action.Snapshot
+	if !filepath.IsAbs(snapshotPath) {
+		snapshotPath = filepath.Join(a.workingDir, snapshotPath)
+	}
+
+	title := "Snapshot directory"
+	if action.Mode == "compare" {
+		title = "Compare directory with snapshot"
+	}
+	actionUI := a.display.ShowAction(title, fmt.Sprintf("%s (%s)", action.Path, action.Snapshot), false)
+	actionJSON, _ := json.Marshal(action)
+
+	fail := func(message string) error {
+		a.display.UpdateAction(actionUI, "failed", []string{message})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:dir_snapshot error\n%s", message)},
+		)
+		return nil
+	}
+
+	var saved dirSnapshot
+	algo := action.Algo
+	if action.Mode == "compare" {
+		data, err := os.ReadFile(snapshotPath)
+		if err != nil {
+			return fail(fmt.Sprintf("cannot read snapshot: %v", err))
+		}
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fail(fmt.Sprintf("invalid snapshot file: %v", err))
+		}
+		// Digests are only comparable with the snapshot's algorithm
+		algo = saved.Algo
+	}
+
+	digests, failures, err := hashTree(root, algo, *action.Workers, a.walkOptionsFor(action))
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	// The snapshot file never counts as part of the tree
+	if rel, err := filepath.Rel(root, snapshotPath); err == nil {
+		delete(digests, filepath.ToSlash(rel))
+		delete(failures, filepath.ToSlash(rel))
+	}
+
+	if action.Mode == "compare" {
+		changes := diffSnapshot(saved.Files, digests, failures)
+		changesJSON, _ := json.MarshalIndent(changes, "", "  ")
+		summary := fmt.Sprintf("%d added, %d removed, %d modified, %d unchanged since %s",
+			len(changes.Added), len(changes.Removed), len(changes.Modified), changes.Unchanged, saved.CreatedAt.Format(time.RFC3339))
+
+		a.display.UpdateAction(actionUI, "completed", []string{summary})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:dir_snapshot success\n%s\n%s", summary, a.limitObservation(action, string(changesJSON), 8000))},
+		)
+		return nil
+	}
+
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("dir_snapshot write %s", action.Snapshot), fmt.Sprintf("Write snapshot of %s", action.Path), snapshotPath)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Failed to get approval: %s", err.Error())})
+		return fmt.Errorf("failed to get approval: %w", err)
+	}
+	if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+		a.display.UpdateAction(actionUI, "skipped", []string{"User declined"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:dir_snapshot skipped by user"},
+		)
+		return nil
+	}
+
+	snapshot := dirSnapshot{Root: root, Algo: algo, CreatedAt: time.Now().UTC(), Files: digests}
+	data, _ := json.MarshalIndent(snapshot, "", "  ")
+	if err := os.WriteFile(snapshotPath, data, 0644); err != nil {
+		return fail(fmt.Sprintf("cannot write snapshot: %v", err))
+	}
+
+	result := fmt.Sprintf("Snapshot of %d files (%s) written to %s", len(digests), algo, action.Snapshot)
+	if len(failures) > 0 {
+		result += fmt.Sprintf("\n%d files could not be hashed and are not in the snapshot", len(failures))
+	}
+	a.display.UpdateAction(actionUI, "completed", []string{result})
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:dir_snapshot success\n%s", result)},
+	)
+	return nil
+}
+
+// handleFormatFile reformats a JSON, YAML or Go file in place
+func (a *Agent) handleFormatFile(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	path := action.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(a.workingDir, path)
+	}
+
+	actionUI := a.display.ShowAction("Format file", action.Path, false)
+	actionJSON, _ := json.Marshal(action)
+
+	fail := func(message string) error {
+		a.display.UpdateAction(actionUI, "failed", []string{message})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:format_file error\n%s", message)},
+		)
+		return nil
+	}
+
+	name, formatter, err := formatterFor(path, action.Format)
+	if err != nil {
+		return fail(err.Error())
+	}
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return fail(err.Error())
+	}
+	formatted, err := formatContent(original, formatter)
+	if err != nil {
+		return fail(fmt.Sprintf("invalid %s: %v", name, err))
+	}
+
+	if bytes.Equal(original, formatted) {
+		a.display.UpdateAction(actionUI, "completed", []string{"Already formatted"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:format_file success\n%s is already formatted (unchanged)", action.Path)},
+		)
+		return nil
+	}
+
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("format_file %s", action.Path), fmt.Sprintf("Reformat %s as %s", action.Path, name), path)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Failed to get approval: %s", err.Error())})
+		return fmt.Errorf("failed to get approval: %w", err)
+	}
+	if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+		a.display.UpdateAction(actionUI, "skipped", []string{"User declined"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:format_file skipped by user"},
+		)
+		return nil
+	}
+
+	if err := writeFileAtomic(path, formatted); err != nil {
+		return fail(err.Error())
+	}
+
+	result := fmt.Sprintf("%s reformatted as %s (changed, %d -> %d bytes)", action.Path, name, len(original), len(formatted))
+	a.display.UpdateAction(actionUI, "completed", []string{result})
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:format_file success\n%s", result)},
+	)
+	return nil
+}
+
+// handleRunTests runs the project's test suite and reports a parsed summary
+func (a *Agent) handleRunTests(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	dir := action.Path
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(a.workingDir, dir)
+	}
+
+	actionUI := a.display.ShowAction("Run tests", action.Path, false)
+	actionJSON, _ := json.Marshal(action)
+
+	fail := func(message string) error {
+		a.display.UpdateAction(actionUI, "failed", []string{message})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:run_tests error\n%s", message)},
+		)
+		return nil
+	}
+
+	runner, err := findTestRunner(action.Runner, dir)
+	if err != nil {
+		return fail(err.Error())
+	}
+	args := runner.command(action.Pattern)
+	command := strings.Join(args, " ")
+
+	decision, err := a.policyStore.Approve(action.Type, command, fmt.Sprintf("Run %s tests in %s", runner.name, action.Path))
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Failed to get approval: %s", err.Error())})
+		return fmt.Errorf("failed to get approval: %w", err)
+	}
+	if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+		a.display.UpdateAction(actionUI, "skipped", []string{"User declined"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:run_tests skipped by user"},
+		)
+		return nil
+	}
+
+	cmd := a.command(args[0], args[1:]...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	exitCode := 0
+	if err != nil {
+		exitError, ok := err.(*exec.ExitError)
+		if !ok {
+			return fail(fmt.Sprintf("failed to run %s: %v", command, err))
+		}
+		exitCode = exitError.ExitCode()
+	}
+
+	summary, details := summarizeTests(runner, string(output), exitCode)
+	summary.Command = command
+	summaryJSON, _ := json.MarshalIndent(summary, "", "  ")
+
+	result := fmt.Sprintf("%s: %d passed, %d failed, %d skipped", summary.Status, summary.Passed, summary.Failed, summary.Skipped)
+	if !summary.Parsed {
+		result = fmt.Sprintf("%s (exit %d, output not parsed)", summary.Status, exitCode)
+	}
+	status := "completed"
+	if summary.Status != "passed" {
+		status = "failed"
+	}
+	a.display.UpdateAction(actionUI, status, []string{result})
+
+	observation := string(summaryJSON)
+	if summary.Status != "passed" || !summary.Parsed {
+		// The end of the output holds the failures and the runner's own summary
+		if limit := a.observationLimit(action, 6000); len(details) > limit {
+			a.keepRawObservation(details)
+			details = "..." + details[len(details)-limit:]
+		}
+		observation += "\n\nOutput:\n" + details
+	}
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:run_tests success\n%s", observation)},
+	)
+	return nil
+}
+
+// handleListActions describes the available actions: one compact signature per
+// action, or the field types and description of a single action
+func (a *Agent) handleListActions(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	actionUI := a.display.ShowAction("List actions", action.Name, false)
+	actionJSON, _ := json.Marshal(action)
+
+	var sb strings.Builder
+	count := 0
+	for _, spec := range actionCatalog() {
+		if action.Name != "" && spec.Type != action.Name {
+			continue
+		}
+		count++
+		if action.Name == "" {
+			sb.WriteString(spec.signature() + "\n")
+			continue
+		}
+		sb.WriteString(spec.Type + ": " + spec.Description + "\n")
+		for _, f := range spec.Fields {
+			required := "optional"
+			if f.Required {
+				required = "required"
+			}
+			sb.WriteString(fmt.Sprintf("  %s: %s (%s)\n", f.Name, f.Type, required))
+		}
+	}
+
+	summary := fmt.Sprintf("%d actions", count)
+	if action.Name != "" {
+		summary = action.Name
+	}
+	a.display.UpdateAction(actionUI, "completed", []string{summary})
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:list_actions success\n%s", strings.TrimRight(sb.String(), "\n"))},
+	)
+	return nil
+}
+
+// handleEditFile replaces or inserts text at unique anchors and writes the file atomically
+func (a *Agent) handleEditFile(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	path := action.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(a.workingDir, path)
+	}
+
+	actionUI := a.display.ShowAction("Edit file", fmt.Sprintf("File: %s", action.Path), true)
+	actionJSON, _ := json.Marshal(action)
+
+	fail := func(message string) error {
+		a.display.UpdateAction(actionUI, "failed", []string{message})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:edit_file error\n%s", message)},
+		)
+		return nil
+	}
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return fail(err.Error())
+	}
+	result, err := applyAnchorEdit(string(original), action.Before, action.After, action.Content)
+	if err != nil {
+		return fail(err.Error())
+	}
+	if result.Text == string(original) {
+		a.display.UpdateAction(actionUI, "completed", []string{"No changes"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:edit_file success\n%s unchanged (content already in place)", action.Path)},
+		)
+		return nil
+	}
+
+	reason := action.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("Edit file %s", action.Path)
+	}
+	a.previewFileChange(path, action.Path, result.Text)
+
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("edit_file %s", action.Path), reason, path)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Failed to get approval: %s", err.Error())})
+		return fmt.Errorf("failed to get approval: %w", err)
+	}
+	if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+		a.display.UpdateAction(actionUI, "skipped", []string{"Skipped by user"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:edit_file skipped by user"},
+		)
+		return nil
+	}
+
+	if err := writeFileAtomic(path, []byte(result.Text)); err != nil {
+		return fail(err.Error())
+	}
+
+	summary := fmt.Sprintf("%s edited at line %d (-%d/+%d lines)", action.Path, result.StartLine, result.Removed, result.Added)
+	diff := unifiedDiff(action.Path, action.Path, string(original), result.Text, 2)
+	a.display.UpdateAction(actionUI, "completed", []string{summary})
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:edit_file success\n%s\n%s", summary, a.limitObservation(action, diff, 3000))},
+	)
+	return nil
+}
+
+func (a *Agent) handleFromTemplate(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	dest := action.Dest
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(a.workingDir, dest)
+	}
+
+	actionUI := a.display.ShowAction("From template", fmt.Sprintf("Template: %s -> %s", action.Template, action.Dest), true)
+	actionJSON, _ := json.Marshal(action)
+
+	fail := func(message string) error {
+		a.display.UpdateAction(actionUI, "failed", []string{message})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:from_template error\n%s", message)},
+		)
+		return nil
+	}
+
+	templatePath, err := a.resolveTemplate(action.Template)
+	if err != nil {
+		return fail(err.Error())
+	}
+	text, err := os.ReadFile(templatePath)
+	if err != nil {
+		return fail(err.Error())
+	}
+	rendered, err := renderTemplate(string(text), action.Vars)
+	if err != nil {
+		return fail(err.Error())
+	}
+	if _, err := os.Stat(dest); err == nil && (action.Overwrite == nil || !*action.Overwrite) {
+		return fail(fmt.Sprintf("%s already exists; set overwrite to replace it", action.Dest))
+	}
+
+	reason := action.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("Create %s from template %s", action.Dest, action.Template)
+	}
+	a.previewFileChange(dest, action.Dest, rendered)
+
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("from_template %s %s", action.Template, action.Dest), reason, dest)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Failed to get approval: %s", err.Error())})
+		return fmt.Errorf("failed to get approval: %w", err)
+	}
+	if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+		a.display.UpdateAction(actionUI, "skipped", []string{"Skipped by user"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:from_template skipped by user"},
+		)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fail(err.Error())
+	}
+	if err := writeFileAtomic(dest, []byte(rendered)); err != nil {
+		return fail(err.Error())
+	}
+
+	summary := fmt.Sprintf("Created %s from %s (%d bytes, %d lines)", action.Dest, templatePath, len(rendered), strings.Count(rendered, "\n"))
+	a.display.UpdateAction(actionUI, "completed", []string{summary})
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:from_template success\n%s", summary)},
+	)
+	return nil
+}
+
+// handleWaitFor polls a condition until it holds or the timeout passes
+func (a *Agent) handleWaitFor(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	check, description := a.waitCheck(action)
+	actionUI := a.display.ShowAction("Wait for", description, action.Condition == "command")
+	actionJSON, _ := json.Marshal(action)
+
+	if action.Condition == "command" {
+		// The polled command is a shell command like any other
+		if verdict := policy.VetCommand(action.Shell, action.Command, a.shellRules); !verdict.Allowed {
+			a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Blocked by safe-shell (%s): %s", verdict.Rule, verdict.Reason)})
+			*transcript = append(*transcript,
+				providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+				providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:wait_for blocked by safe-shell mode (%s): %s. Rewrite the command without this construct.", verdict.Rule, verdict.Reason)},
+			)
+			return nil
+		}
+
+		reason := action.Reason
+		if reason == "" {
+			reason = fmt.Sprintf("Poll until %s", description)
+		}
+		decision, err := a.policyStore.Approve(action.Type, action.Command, reason)
+		if err != nil {
+			a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Failed to get approval: %s", err.Error())})
+			return fmt.Errorf("failed to get approval: %w", err)
+		}
+		if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+			a.display.UpdateAction(actionUI, "skipped", []string{"Skipped by user"})
+			*transcript = append(*transcript,
+				providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+				providers.ChatMessage{Role: "user", Content: "observation:wait_for skipped by user"},
+			)
+			return nil
+		}
+	}
+
+	started := time.Now()
+	timeout := time.Duration(*action.Timeout) * time.Second
+	attempts, err := pollUntil(a.actionContext(), timeout, time.Duration(*action.Interval)*time.Second, check)
+	elapsed := time.Since(started).Round(100 * time.Millisecond)
+
+	var result string
+	switch {
+	case err == nil:
+		result = fmt.Sprintf("observation:wait_for success\n%s after %s (%d checks)", description, elapsed, attempts)
+		a.display.UpdateAction(actionUI, "completed", []string{fmt.Sprintf("Ready after %s", elapsed)})
+	case errors.Is(err, context.DeadlineExceeded):
+		result = fmt.Sprintf("observation:wait_for timeout\nstill waiting for %s after %s (%d checks)", description, timeout, attempts)
+		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Timed out after %s", timeout)})
+	default:
+		result = fmt.Sprintf("observation:wait_for error\n%v", err)
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+	}
+
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: result},
+	)
+	return nil
+}
+
+// handleFetchText downloads a URL and returns its readable text; HTML pages are
+// reduced to their main content, other content types are returned as-is
+func (a *Agent) handleFetchText(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	actionUI := a.display.ShowAction("Fetch text", action.URL, false)
+	actionJSON, _ := json.Marshal(action)
+
+	fail := func(message string) error {
+		a.display.UpdateAction(actionUI, "failed", []string{message})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:fetch_text error\n%s", message)},
+		)
+		return nil
+	}
+
+	reason := action.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("Fetch %s as text", action.URL)
+	}
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("fetch_text %s", action.URL), reason)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Failed to get approval: %s", err.Error())})
+		return fmt.Errorf("failed to get approval: %w", err)
+	}
+	if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+		a.display.UpdateAction(actionUI, "skipped", []string{"Skipped by user"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:fetch_text skipped by user"},
+		)
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(a.actionContext(), http.MethodGet, action.URL, nil)
+	if err != nil {
+		return fail(err.Error())
+	}
+	for key, value := range action.Headers {
+		req.Header.Set(key, value)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fail(err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchTextBytes))
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+
+	var text string
+	header := fmt.Sprintf("URL: %s\nContent-Type: %s\n", resp.Request.URL, contentType)
+	switch {
+	case isHTMLContentType(contentType):
+		title, content := extractReadableText(string(body))
+		if title != "" {
+			header = fmt.Sprintf("Title: %s\n", title) + header
+		}
+		text = content
+	case !utf8.Valid(body) || bytes.IndexByte(body, 0) >= 0:
+		return fail(fmt.Sprintf("%s is binary content (%s, %d bytes); use download_file to save it", action.URL, contentType, len(body)))
+	default:
+		text = string(body)
+	}
+
+	summary := fmt.Sprintf("Status: %d, %d characters of text", resp.StatusCode, len(text))
+	actionUI.Summary = summary
+	status := "completed"
+	if resp.StatusCode >= 400 {
+		status = "failed"
+	}
+	a.display.UpdateAction(actionUI, status, []string{summary})
+
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:fetch_text status=%d\n%s\n%s", resp.StatusCode, header, a.limitObservation(action, text, 8000))},
+	)
+	return nil
+}
+
+// handleLintFile checks a source file with the linter for its type and reports
+// the issues found as structured JSON
+func (a *Agent) handleLintFile(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	path := action.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(a.workingDir, path)
+	}
+
+	actionUI := a.display.ShowAction("Lint file", action.Path, false)
+	actionJSON, _ := json.Marshal(action)
+
+	fail := func(message string) error {
+		a.display.UpdateAction(actionUI, "failed", []string{message})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:lint_file error\n%s", message)},
+		)
+		return nil
+	}
+
+	linter, err := findLinter(action.Linter, path)
+	if err != nil {
+		return fail(err.Error())
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fail(err.Error())
+	}
+	dir := filepath.Dir(path)
+	args := linter.command(filepath.Base(path))
+	command := strings.Join(args, " ")
+
+	decision, err := a.policyStore.Approve(action.Type, command, fmt.Sprintf("Lint %s with %s", action.Path, linter.name))
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{fmt.Sprintf("Failed to get approval: %s", err.Error())})
+		return fmt.Errorf("failed to get approval: %w", err)
+	}
+	if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+		a.display.UpdateAction(actionUI, "skipped", []string{"User declined"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:lint_file skipped by user"},
+		)
+		return nil
+	}
+
+	cmd := a.command(args[0], args[1:]...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	exitCode := 0
+	if err != nil {
+		exitError, ok := err.(*exec.ExitError)
+		if !ok {
+			return fail(fmt.Sprintf("failed to run %s: %v", command, err))
+		}
+		exitCode = exitError.ExitCode()
+	}
+
+	summary, details := summarizeLint(linter, string(output), exitCode)
+	summary.Command = command
+	// Report issue paths relative to the working directory, like the action's path
+	for i := range summary.Issues {
+		file := summary.Issues[i].File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		if rel, err := filepath.Rel(a.workingDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+		summary.Issues[i].File = file
+	}
+	summaryJSON, _ := json.MarshalIndent(summary, "", "  ")
+
+	result := "clean"
+	status := "completed"
+	switch {
+	case !summary.Parsed:
+		result = fmt.Sprintf("exit %d, output not parsed", exitCode)
+		status = "failed"
+	case len(summary.Issues) > 0:
+		result = fmt.Sprintf("%d issues", len(summary.Issues))
+		status = "failed"
+	}
+	a.display.UpdateAction(actionUI, status, []string{result})
+
+	observation := a.limitObservation(action, string(summaryJSON), 8000)
+	if details != "" {
+		observation += "\n\nOutput:\n" + a.limitObservation(action, details, 6000)
+	}
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:lint_file success\n%s", observation)},
+	)
+	return nil
+}
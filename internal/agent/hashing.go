@@ -0,0 +1,322 @@
+package agent
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// newHasher returns a hash implementation for the given algorithm name
+func newHasher(algo string) (hash.Hash, error) {
+	switch strings.ToLower(algo) {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256", "":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm: %s (use md5, sha1, sha256 or sha512)", algo)
+	}
+}
+
+// hashFile streams a file through the requested hash and returns the hex digest
+func hashFile(path, algo string) (string, error) {
+	h, err := newHasher(algo)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashJob is a single file queued for hashing
+type hashJob struct {
+	path string
+	rel  string
+}
+
+// hashTree hashes every regular file under root with a bounded pool of workers.
+// It returns digests keyed by slash-separated relative path, plus per-file errors.
+func hashTree(root, algo string, workers int, opts walkOptions) (map[string]string, map[string]error, error) {
+	if _, err := newHasher(algo); err != nil {
+		return nil, nil, err
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	var jobs []hashJob
+	err := walkTree(root, opts, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip entries we can't access
+		}
+		if info.IsDir() || !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			rel = path
+		}
+		jobs = append(jobs, hashJob{path: path, rel: filepath.ToSlash(rel)})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	digests := make(map[string]string, len(jobs))
+	failures := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	queue := make(chan hashJob)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				digest, err := opts.OpenFiles.hashFile(job.path, algo)
+				mu.Lock()
+				if err != nil {
+					failures[job.rel] = err
+				} else {
+					digests[job.rel] = digest
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	return digests, failures, nil
+}
+
+// formatChecksums renders digests in the "<digest>  <path>" format used by sha256sum
+func formatChecksums(digests map[string]string) string {
+	paths := make([]string, 0, len(digests))
+	for path := range digests {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var sb strings.Builder
+	for _, path := range paths {
+		sb.WriteString(fmt.Sprintf("%s  %s\n", digests[path], path))
+	}
+	return sb.String()
+}
+
+// checksumEntry is one line of a sha256sum-style checksums file
+type checksumEntry struct {
+	Digest string
+	Path   string
+	Algo   string
+}
+
+// checksumResult is the verification outcome for a single listed file
+type checksumResult struct {
+	File     string `json:"file"`
+	Status   string `json:"status"` // "passed", "failed", "missing" or "error"
+	Expected string `json:"expected"`
+	Actual   string `json:"actual,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// splitChecksum separates an optional algorithm prefix ("sha256:<digest>")
+// from a checksum; algo is empty when there is none
+func splitChecksum(checksum string) (algo, digest string) {
+	checksum = strings.TrimSpace(checksum)
+	if prefix, rest, ok := strings.Cut(checksum, ":"); ok && prefix != "" {
+		if _, err := newHasher(prefix); err == nil {
+			return strings.ToLower(prefix), strings.TrimSpace(rest)
+		}
+	}
+	return "", checksum
+}
+
+// algoForDigest guesses the hash algorithm from a hex digest length
+func algoForDigest(digest string) string {
+	switch len(digest) {
+	case 32:
+		return "md5"
+	case 40:
+		return "sha1"
+	case 128:
+		return "sha512"
+	default:
+		return "sha256"
+	}
+}
+
+// parseChecksums parses GNU ("<digest>  <path>", "<digest> *<path>") and BSD
+// ("SHA256 (<path>) = <digest>") checksum lines. algo overrides detection.
+func parseChecksums(content, algo string) ([]checksumEntry, error) {
+	var entries []checksumEntry
+	for lineNum, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var entry checksumEntry
+		if open := strings.Index(line, " ("); open > 0 && strings.Contains(line, ") = ") {
+			closeIdx := strings.LastIndex(line, ") = ")
+			entry = checksumEntry{
+				Algo:   strings.ToLower(line[:open]),
+				Path:   line[open+2 : closeIdx],
+				Digest: strings.TrimSpace(line[closeIdx+4:]),
+			}
+		} else {
+			fields := strings.SplitN(line, " ", 2)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: expected \"<digest>  <path>\"", lineNum+1)
+			}
+			entry = checksumEntry{
+				Digest: fields[0],
+				Path:   strings.TrimPrefix(strings.TrimLeft(fields[1], " "), "*"),
+			}
+		}
+
+		if _, err := hex.DecodeString(entry.Digest); err != nil || entry.Path == "" {
+			return nil, fmt.Errorf("line %d: malformed checksum entry", lineNum+1)
+		}
+		entry.Digest = strings.ToLower(entry.Digest)
+		if algo != "" {
+			entry.Algo = algo
+		} else if entry.Algo == "" {
+			entry.Algo = algoForDigest(entry.Digest)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// verifyChecksums checks each entry against files relative to baseDir, hashing
+// with workers goroutines but holding at most openFiles' capacity open at once
+func verifyChecksums(entries []checksumEntry, baseDir string, workers int, openFiles fileLimiter) []checksumResult {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]checksumResult, len(entries))
+	var wg sync.WaitGroup
+	queue := make(chan int)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range queue {
+				entry := entries[idx]
+				result := checksumResult{File: entry.Path, Expected: entry.Digest}
+
+				path := filepath.FromSlash(entry.Path)
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(baseDir, path)
+				}
+
+				actual, err := openFiles.hashFile(path, entry.Algo)
+				switch {
+				case os.IsNotExist(err):
+					result.Status = "missing"
+				case err != nil:
+					result.Status = "error"
+					result.Error = err.Error()
+				case actual == entry.Digest:
+					result.Status = "passed"
+					result.Actual = actual
+				default:
+					result.Status = "failed"
+					result.Actual = actual
+				}
+				results[idx] = result
+			}
+		}()
+	}
+
+	for i := range entries {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	return results
+}
+
+// dirSnapshot is the file written by dir_snapshot: a digest for every file under Root
+type dirSnapshot struct {
+	Root      string            `json:"root"`
+	Algo      string            `json:"algo"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Files     map[string]string `json:"files"` // Slash-separated relative path -> digest
+}
+
+// snapshotChanges is the change set between a snapshot and the current tree
+type snapshotChanges struct {
+	Added      []string `json:"added"`
+	Removed    []string `json:"removed"`
+	Modified   []string `json:"modified"`
+	Unreadable []string `json:"unreadable,omitempty"`
+	Unchanged  int      `json:"unchanged"`
+}
+
+// diffSnapshot compares saved digests against the current ones. Files that
+// couldn't be hashed now are reported as unreadable rather than removed.
+func diffSnapshot(saved, current map[string]string, failures map[string]error) snapshotChanges {
+	changes := snapshotChanges{Added: []string{}, Removed: []string{}, Modified: []string{}}
+	for path, digest := range current {
+		old, ok := saved[path]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, path)
+		case old != digest:
+			changes.Modified = append(changes.Modified, path)
+		default:
+			changes.Unchanged++
+		}
+	}
+	for path := range saved {
+		if _, ok := current[path]; ok {
+			continue
+		}
+		if _, failed := failures[path]; failed {
+			changes.Unreadable = append(changes.Unreadable, path)
+		} else {
+			changes.Removed = append(changes.Removed, path)
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Unreadable)
+	return changes
+}
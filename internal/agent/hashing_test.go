@@ -0,0 +1,259 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestHashTree(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"a.txt":                   "abc",
+		"sub/b.txt":               "",
+		"node_modules/skip/c.txt": "ignored",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	digests, failures, err := hashTree(root, "sha256", 4, walkOptions{IgnoreDirs: defaultIgnoreDirs, OpenFiles: newFileLimiter(1)})
+	if err != nil {
+		t.Fatalf("hashTree failed: %v", err)
+	}
+	if len(failures) != 0 {
+		t.Errorf("Expected no failures, got %v", failures)
+	}
+
+	expected := map[string]string{
+		"a.txt":     "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"sub/b.txt": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	}
+	if len(digests) != len(expected) {
+		t.Fatalf("Expected %d digests, got %d: %v", len(expected), len(digests), digests)
+	}
+	for path, digest := range expected {
+		if digests[path] != digest {
+			t.Errorf("Expected %s digest %s, got %s", path, digest, digests[path])
+		}
+	}
+
+	sums := formatChecksums(digests)
+	if !strings.HasPrefix(sums, expected["a.txt"]+"  a.txt\n") {
+		t.Errorf("Expected sha256sum-style output, got %q", sums)
+	}
+}
+
+func TestHashTreeUnsupportedAlgorithm(t *testing.T) {
+	if _, _, err := hashTree(t.TempDir(), "crc32", 1, walkOptions{}); err == nil {
+		t.Errorf("Expected error for unsupported algorithm")
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	content := "# comment\n" +
+		"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  a.txt\n" +
+		"900150983CD24FB0D6963F7D28E17F72 *bin/b.exe\n" +
+		"SHA1 (c.txt) = a9993e364706816aba3e25717850c26c9cd0d89d\n"
+
+	entries, err := parseChecksums(content, "")
+	if err != nil {
+		t.Fatalf("parseChecksums failed: %v", err)
+	}
+
+	expected := []checksumEntry{
+		{Digest: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", Path: "a.txt", Algo: "sha256"},
+		{Digest: "900150983cd24fb0d6963f7d28e17f72", Path: "bin/b.exe", Algo: "md5"},
+		{Digest: "a9993e364706816aba3e25717850c26c9cd0d89d", Path: "c.txt", Algo: "sha1"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(entries))
+	}
+	for i, entry := range entries {
+		if entry != expected[i] {
+			t.Errorf("Expected entry %d to be %+v, got %+v", i, expected[i], entry)
+		}
+	}
+
+	if _, err := parseChecksums("not-hex  file\n", ""); err == nil {
+		t.Errorf("Expected error for malformed line")
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "good.txt"), []byte("abc"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bad.txt"), []byte("tampered"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	abc := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	entries := []checksumEntry{
+		{Digest: abc, Path: "good.txt", Algo: "sha256"},
+		{Digest: abc, Path: "bad.txt", Algo: "sha256"},
+		{Digest: abc, Path: "gone.txt", Algo: "sha256"},
+	}
+
+	results := verifyChecksums(entries, dir, 2, newFileLimiter(1))
+	expected := []string{"passed", "failed", "missing"}
+	for i, result := range results {
+		if result.Status != expected[i] {
+			t.Errorf("Expected %s to be %s, got %s", result.File, expected[i], result.Status)
+		}
+	}
+	if results[1].Actual == "" || results[1].Actual == abc {
+		t.Errorf("Expected actual digest for mismatching file, got %q", results[1].Actual)
+	}
+}
+
+func TestDiffSnapshot(t *testing.T) {
+	saved := map[string]string{"a.txt": "1", "b.txt": "2", "c.txt": "3", "locked.txt": "4"}
+	current := map[string]string{"a.txt": "1", "b.txt": "changed", "d.txt": "5"}
+	failures := map[string]error{"locked.txt": os.ErrPermission}
+
+	changes := diffSnapshot(saved, current, failures)
+	if strings.Join(changes.Added, ",") != "d.txt" {
+		t.Errorf("Expected d.txt added, got %v", changes.Added)
+	}
+	if strings.Join(changes.Removed, ",") != "c.txt" {
+		t.Errorf("Expected c.txt removed, got %v", changes.Removed)
+	}
+	if strings.Join(changes.Modified, ",") != "b.txt" {
+		t.Errorf("Expected b.txt modified, got %v", changes.Modified)
+	}
+	if strings.Join(changes.Unreadable, ",") != "locked.txt" {
+		t.Errorf("Expected locked.txt unreadable, got %v", changes.Unreadable)
+	}
+	if changes.Unchanged != 1 {
+		t.Errorf("Expected 1 unchanged file, got %d", changes.Unchanged)
+	}
+}
+
+func TestHandleDirSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "keep.txt"), []byte("same"), 0644)
+	os.WriteFile(filepath.Join(dir, "edit.txt"), []byte("before"), 0644)
+	a := newTestAgent(t, dir)
+
+	run := func(mode string) string {
+		t.Helper()
+		action := &AgentAction{Type: "dir_snapshot", Snapshot: "snap.json", Mode: mode}
+		if err := validateAction(action); err != nil {
+			t.Fatalf("Expected valid action, got %v", err)
+		}
+		var transcript []providers.ChatMessage
+		if err := a.handleDirSnapshot(action, &transcript); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return transcript[len(transcript)-1].Content
+	}
+
+	if obs := run("save"); !strings.Contains(obs, "Snapshot of 2 files") {
+		t.Fatalf("Expected snapshot of 2 files, got %q", obs)
+	}
+
+	os.WriteFile(filepath.Join(dir, "edit.txt"), []byte("after"), 0644)
+	os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0644)
+
+	obs := run("compare")
+	if !strings.Contains(obs, "1 added, 0 removed, 1 modified, 1 unchanged") {
+		t.Errorf("Expected change summary, got %q", obs)
+	}
+	if !strings.Contains(obs, `"new.txt"`) || !strings.Contains(obs, `"edit.txt"`) {
+		t.Errorf("Expected change set to list new.txt and edit.txt, got %q", obs)
+	}
+}
+
+func TestHandleHashFile(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "empty.txt"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "abc.txt"), []byte("abc"), 0644)
+
+	tests := []struct {
+		path     string
+		algo     string
+		expected string
+	}{
+		{"empty.txt", "md5", "d41d8cd98f00b204e9800998ecf8427e"},
+		{"empty.txt", "sha1", "da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+		{"empty.txt", "sha256", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"empty.txt", "sha512", "cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e"},
+		{"abc.txt", "md5", "900150983cd24fb0d6963f7d28e17f72"},
+		{"abc.txt", "SHA1", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{"abc.txt", "", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"abc.txt", "sha512", "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.algo, func(t *testing.T) {
+			a := newTestAgent(t, dir)
+			action := &AgentAction{Type: "hash_file", Path: tt.path, Algo: tt.algo}
+			if err := validateAction(action); err != nil {
+				t.Fatalf("Expected valid action, got %v", err)
+			}
+			var transcript []providers.ChatMessage
+			if err := a.handleHashFile(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if obs := transcript[1].Content; !strings.HasSuffix(obs, ": "+tt.expected) || !strings.HasPrefix(obs, "observation:hash_file success") {
+				t.Errorf("Expected digest %s, got %q", tt.expected, obs)
+			}
+		})
+	}
+
+	if err := validateAction(&AgentAction{Type: "hash_file", Path: "abc.txt", Algo: "crc32"}); err == nil || !strings.Contains(err.Error(), "unsupported hash algorithm") {
+		t.Errorf("Expected unsupported algorithm error, got %v", err)
+	}
+}
+
+func TestHandleChecksumVerify(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "abc.txt"), []byte("abc"), 0644)
+	const abcSHA256 = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+
+	tests := []struct {
+		name     string
+		path     string
+		checksum string
+		algo     string
+		expected []string
+	}{
+		{"match", "abc.txt", abcSHA256, "", []string{"observation:checksum_verify success", abcSHA256}},
+		{"match ignores case", "abc.txt", strings.ToUpper(abcSHA256), "sha256", []string{"observation:checksum_verify success"}},
+		{"prefix selects algorithm", "abc.txt", "md5:900150983cd24fb0d6963f7d28e17f72", "", []string{"observation:checksum_verify success", "(md5: "}},
+		{"sha256 prefix", "abc.txt", "SHA256:" + abcSHA256, "", []string{"observation:checksum_verify success"}},
+		{"mismatch", "abc.txt", strings.Repeat("0", 64), "", []string{"observation:checksum_verify failed", "Expected: " + strings.Repeat("0", 64), "Actual:   " + abcSHA256}},
+		{"missing file", "missing.txt", abcSHA256, "", []string{"observation:checksum_verify error"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAgent(t, dir)
+			action := &AgentAction{Type: "checksum_verify", Path: tt.path, Checksum: tt.checksum, Algo: tt.algo}
+			if err := validateAction(action); err != nil {
+				t.Fatalf("Expected valid action, got %v", err)
+			}
+			var transcript []providers.ChatMessage
+			if err := a.handleChecksumVerify(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			obs := transcript[1].Content
+			for _, want := range tt.expected {
+				if !strings.Contains(obs, want) {
+					t.Errorf("Expected %q in %q", want, obs)
+				}
+			}
+		})
+	}
+}
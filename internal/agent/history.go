@@ -0,0 +1,278 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"terminusai/internal/common"
+	"terminusai/internal/providers"
+)
+
+// historyObservationLimit caps the observation text stored per history entry
+const historyObservationLimit = 2000
+
+// HistoryEntry is one executed action as recorded in a session history file
+type HistoryEntry struct {
+	Iteration   int          `json:"iteration"`
+	Action      *AgentAction `json:"action"`
+	Status      string       `json:"status"` // "success", "failed", "skipped", "interrupted", ...
+	Observation string       `json:"observation,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	StartedAt   time.Time    `json:"startedAt"`
+	DurationMs  int64        `json:"durationMs"`
+}
+
+// readOnlyActions are the action types that are safe to re-run during replay.
+// hash_dir is only read-only without dest (see replayable).
+var readOnlyActions = map[string]bool{
+	"list_files":       true,
+	"read_file":        true,
+	"search_files":     true,
+	"grep":             true,
+	"find":             true,
+	"stat_path":        true,
+	"realpath":         true,
+	"hash_file":        true,
+	"hash_dir":         true,
+	"verify_checksums": true,
+	"hexdump":          true,
+	"diff":             true,
+	"ps":               true,
+	"get_system_info":  true,
+	"whoami":           true,
+	"time_now":         true,
+	"parse_json":       true,
+	"parse_yaml":       true,
+	"parse_toml":       true,
+}
+
+// HistoryDir returns the directory where session history files are stored
+func HistoryDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, common.ConfigDirName, "history"), nil
+}
+
+// NewHistoryPath returns a fresh history file path for a session starting now
+func NewHistoryPath() (string, error) {
+	dir, err := HistoryDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, time.Now().Format("20060102-150405")+".jsonl"), nil
+}
+
+// SetHistoryFile enables recording of every executed action to a JSONL file
+func (a *Agent) SetHistoryFile(path string) {
+	a.historyPath = path
+}
+
+// recordHistory appends an entry for an executed action. Recording failures are
+// reported but never interrupt the task.
+func (a *Agent) recordHistory(action *AgentAction, transcript []providers.ChatMessage, before int, started time.Time, runErr error) {
+	if a.historyPath == "" {
+		return
+	}
+
+	// History files outlive the session, so secrets are masked as in the audit log
+	entry := HistoryEntry{
+		Iteration:  a.iteration,
+		Action:     redactAction(action),
+		StartedAt:  started,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	var observation string
+	entry.Status, observation = observationStatus(action.Type, transcript, before)
+	entry.Observation = redactSecrets(observation)
+	if runErr != nil {
+		entry.Status = "error"
+		entry.Error = redactSecrets(runErr.Error())
+	}
+
+	if err := appendHistoryEntry(a.historyPath, entry); err != nil && a.verbose {
+		fmt.Printf("  ⎿  Failed to record action history: %v\n", err)
+	}
+}
+
+// observationStatus extracts the status word and text of the observation a
+// handler appended to the transcript after index before
+func observationStatus(actionType string, transcript []providers.ChatMessage, before int) (string, string) {
+	if before > len(transcript) {
+		before = len(transcript)
+	}
+	for i := len(transcript) - 1; i >= before; i-- {
+		msg := transcript[i]
+		if msg.Role != "user" {
+			continue
+		}
+
+		observation := truncateString(msg.Content, historyObservationLimit)
+		rest := strings.TrimPrefix(msg.Content, "observation:"+actionType)
+		if rest == msg.Content {
+			return "unknown", observation
+		}
+
+		return statusWord(rest, msg.Content), observation
+	}
+	return "unknown", ""
+}
+
+// statusWord classifies an observation from the text following "observation:<type>"
+func statusWord(rest, content string) string {
+	if strings.Contains(content, "interrupted by user") {
+		return "interrupted"
+	}
+
+	firstLine := strings.SplitN(rest, "\n", 2)[0]
+	fields := strings.Fields(firstLine)
+	if len(fields) == 0 {
+		return "success"
+	}
+
+	switch word := strings.TrimSuffix(fields[0], ":"); {
+	case word == "error" || word == "skipped" || word == "failed" || word == "blocked":
+		return word
+	case strings.HasPrefix(word, "exit="):
+		if word == "exit=0" {
+			return "success"
+		}
+		return "failed"
+	case strings.HasSuffix(firstLine, " not found"):
+		return "error"
+	default:
+		return "success"
+	}
+}
+
+// appendHistoryEntry writes a single entry as a JSON line
+func appendHistoryEntry(path string, entry HistoryEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// LoadHistory reads the entries of a session history file
+func LoadHistory(path string) ([]HistoryEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// FormatHistory renders history entries as a human-readable listing
+func FormatHistory(entries []HistoryEntry) string {
+	var sb strings.Builder
+	for i, entry := range entries {
+		actionType := "?"
+		summary := ""
+		if entry.Action != nil {
+			actionType = entry.Action.Type
+			summary = historySummary(entry.Action)
+		}
+		sb.WriteString(fmt.Sprintf("%3d. [%s] %-16s %-11s %6dms  %s\n",
+			i+1, entry.StartedAt.Format("15:04:05"), actionType, entry.Status, entry.DurationMs, summary))
+		if entry.Error != "" {
+			sb.WriteString(fmt.Sprintf("     error: %s\n", entry.Error))
+		}
+	}
+	return sb.String()
+}
+
+// historySummary picks the most descriptive field of an action for display
+func historySummary(action *AgentAction) string {
+	for _, field := range []string{action.Command, action.Path, action.Pattern, action.URL, action.Reason} {
+		if field != "" {
+			return truncateString(field, 80)
+		}
+	}
+	return ""
+}
+
+// replayable reports whether action only reads, so replay may run it
+func replayable(action *AgentAction) bool {
+	if action.Type == "hash_dir" && action.Dest != "" {
+		return false
+	}
+	return readOnlyActions[action.Type]
+}
+
+// ReplayHistory re-runs the read-only actions of a recorded session and reports
+// how each outcome compares with the recording. Mutating actions are never
+// executed; they are listed as skipped.
+func (a *Agent) ReplayHistory(entries []HistoryEntry) string {
+	var sb strings.Builder
+	for i, entry := range entries {
+		if entry.Action == nil {
+			continue
+		}
+		action := entry.Action
+
+		if !replayable(action) {
+			sb.WriteString(fmt.Sprintf("%3d. %-16s skipped (mutating action, recorded: %s)\n", i+1, action.Type, entry.Status))
+			continue
+		}
+
+		if err := validateAction(action); err != nil {
+			sb.WriteString(fmt.Sprintf("%3d. %-16s invalid: %v\n", i+1, action.Type, err))
+			continue
+		}
+
+		var transcript []providers.ChatMessage
+		status := ""
+		if err := a.executeAction(action, &transcript); err != nil {
+			status = "error"
+		} else {
+			status, _ = observationStatus(action.Type, transcript, 0)
+		}
+
+		marker := "same"
+		if status != entry.Status {
+			marker = "changed"
+		}
+		sb.WriteString(fmt.Sprintf("%3d. %-16s %s (recorded: %s, replayed: %s)\n", i+1, action.Type, marker, entry.Status, status))
+	}
+	return sb.String()
+}
@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"terminusai/internal/policy"
+	"terminusai/internal/providers"
+)
+
+func TestObservationStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		actionType  string
+		observation string
+		expected    string
+	}{
+		{"plain success", "list_files", "observation:list_files\na.txt", "success"},
+		{"explicit success", "write_file", "observation:write_file success\nwrote 3 bytes", "success"},
+		{"error", "grep", "observation:grep error\nbad pattern", "error"},
+		{"skipped", "shell", "observation:shell skipped by user", "skipped"},
+		{"zero exit", "shell", "observation:shell exit=0\nok", "success"},
+		{"non-zero exit", "shell", "observation:shell exit=1\nboom", "failed"},
+		{"not found", "read_file", "observation:read_file missing.txt not found", "error"},
+		{"interrupted", "shell", "observation:shell interrupted by user (Ctrl+C). Choose a different approach.", "interrupted"},
+		{"unrelated message", "shell", "Unknown action type: foo", "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcript := []providers.ChatMessage{
+				{Role: "assistant", Content: "{}"},
+				{Role: "user", Content: tt.observation},
+			}
+			status, _ := observationStatus(tt.actionType, transcript, 0)
+			if status != tt.expected {
+				t.Errorf("Expected status %q, got %q", tt.expected, status)
+			}
+		})
+	}
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "history", "session.jsonl")
+
+	a := newTestAgent(t, dir)
+	a.SetHistoryFile(path)
+	a.iteration = 2
+
+	transcript := []providers.ChatMessage{
+		{Role: "assistant", Content: "{}"},
+		{Role: "user", Content: "observation:shell exit=1\nboom"},
+	}
+	a.recordHistory(&AgentAction{Type: "shell", Command: "false"}, transcript, 0, time.Now(), nil)
+	a.recordHistory(&AgentAction{Type: "read_file", Path: "a.txt"}, nil, 0, time.Now(), os.ErrPermission)
+
+	entries, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Iteration != 2 || entries[0].Status != "failed" || entries[0].Action.Command != "false" {
+		t.Errorf("Unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].Status != "error" || entries[1].Error == "" {
+		t.Errorf("Expected second entry to record the error, got %+v", entries[1])
+	}
+
+	listing := FormatHistory(entries)
+	if !strings.Contains(listing, "shell") || !strings.Contains(listing, "a.txt") {
+		t.Errorf("Expected listing to mention both actions, got %q", listing)
+	}
+}
+
+func TestHistoryRedactsSecrets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.jsonl")
+
+	a := newTestAgent(t, dir)
+	a.SetHistoryFile(path)
+	transcript := []providers.ChatMessage{
+		{Role: "assistant", Content: "{}"},
+		{Role: "user", Content: "observation:shell exit=0\nconnected with password=hunter2"},
+	}
+	a.recordHistory(&AgentAction{Type: "shell", Command: "mysql --password hunter2"}, transcript, 0, time.Now(), nil)
+	a.recordHistory(&AgentAction{Type: "write_file", Path: "key.txt", Content: "hunter2"}, nil, 0, time.Now(), nil)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("Expected secrets to be masked in history, got %s", data)
+	}
+}
+
+func TestReplayHistory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAgent(t, dir)
+	entries := []HistoryEntry{
+		{Action: &AgentAction{Type: "read_file", Path: "a.txt"}, Status: "success"},
+		{Action: &AgentAction{Type: "read_file", Path: "gone.txt"}, Status: "success"},
+		{Action: &AgentAction{Type: "delete_path", Path: "a.txt"}, Status: "success"},
+		{Action: &AgentAction{Type: "hash_dir", Path: ".", Dest: "SHA256SUMS"}, Status: "success"},
+	}
+
+	report := a.ReplayHistory(entries)
+	lines := strings.Split(strings.TrimSpace(report), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 report lines, got %q", report)
+	}
+	if !strings.Contains(lines[0], "same") {
+		t.Errorf("Expected unchanged read to be reported as same, got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "changed") {
+		t.Errorf("Expected missing file to be reported as changed, got %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "skipped") {
+		t.Errorf("Expected mutating action to be skipped, got %q", lines[2])
+	}
+	if !strings.Contains(lines[3], "skipped") {
+		t.Errorf("Expected hash_dir writing dest to be skipped, got %q", lines[3])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); err != nil {
+		t.Errorf("Expected replay not to delete files: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "SHA256SUMS")); !os.IsNotExist(err) {
+		t.Errorf("Expected replay not to write files, got %v", err)
+	}
+}
+
+func TestReplayWithoutProvider(t *testing.T) {
+	store := &policy.Store{}
+	store.SetAlwaysAllow(true)
+	a := NewAgent(nil, store, t.TempDir(), false, false)
+
+	report := a.ReplayHistory([]HistoryEntry{
+		{Action: &AgentAction{Type: "get_system_info"}, Status: "success"},
+		{Action: &AgentAction{Type: "list_files", Path: "."}, Status: "success"},
+	})
+	if strings.Count(report, "same") != 2 {
+		t.Errorf("Expected both actions to replay, got %q", report)
+	}
+	if _, err := a.provider.Chat(nil, nil); err == nil {
+		t.Errorf("Expected chat without a provider to fail")
+	}
+}
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+
+	"terminusai/internal/providers"
+	"terminusai/internal/ui"
+)
+
+// actionContext returns the context of the action currently being executed
+func (a *Agent) actionContext() context.Context {
+	if a.actionCtx != nil {
+		return a.actionCtx
+	}
+	return context.Background()
+}
+
+// stdinLine is the result of one line read from stdin
+type stdinLine struct {
+	text string
+	err  error
+}
+
+var (
+	stdinMu      sync.Mutex
+	stdinPending chan stdinLine // Read left running by a cancelled prompt (nil = none)
+)
+
+// readStdinLine reads a line from stdin, giving up when ctx is cancelled so a
+// Ctrl+C can interrupt a prompt. The blocked read cannot be stopped, so its line
+// is kept for the next prompt instead of being lost.
+func readStdinLine(ctx context.Context) (string, error) {
+	stdinMu.Lock()
+	defer stdinMu.Unlock()
+
+	if stdinPending == nil {
+		result := make(chan stdinLine, 1)
+		go func() {
+			text, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			result <- stdinLine{text, err}
+		}()
+		stdinPending = result
+	}
+
+	select {
+	case line := <-stdinPending:
+		stdinPending = nil
+		return line.text, line.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// runInterruptible executes an action with its own cancellable context. A Ctrl+C
+// while the action runs cancels only that action and hands control back to the
+// loop with an "interrupted" observation; a second Ctrl+C terminates as usual.
+func (a *Agent) runInterruptible(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt)
+
+	interrupted := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-sigChan:
+			// Restore default handling so another Ctrl+C exits the program
+			signal.Stop(sigChan)
+			close(interrupted)
+			cancel()
+		case <-finished:
+		}
+	}()
+
+	before := len(*transcript)
+	a.actionCtx = ctx
+	err := a.executeAction(action, transcript)
+	a.actionCtx = nil
+
+	close(finished)
+	signal.Stop(sigChan)
+
+	select {
+	case <-interrupted:
+		ui.Warning.Printf("● Action %s interrupted\n", action.Type)
+		markInterrupted(transcript, before, action)
+		return nil
+	default:
+		return err
+	}
+}
+
+// markInterrupted records in the transcript that the user cancelled an action.
+// before is the transcript length prior to running the action.
+func markInterrupted(transcript *[]providers.ChatMessage, before int, action *AgentAction) {
+	note := fmt.Sprintf("observation:%s interrupted by user (Ctrl+C). Choose a different approach.", action.Type)
+
+	if n := len(*transcript); n > before && (*transcript)[n-1].Role == "user" {
+		// The handler already reported an observation; annotate it
+		(*transcript)[n-1].Content += "\n" + note
+		return
+	}
+
+	actionJSON, _ := json.Marshal(action)
+	*transcript = append((*transcript)[:before],
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: note},
+	)
+}
@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestMarkInterrupted(t *testing.T) {
+	action := &AgentAction{Type: "shell", Command: "sleep 100"}
+
+	t.Run("annotates handler observation", func(t *testing.T) {
+		transcript := []providers.ChatMessage{
+			{Role: "system", Content: "system"},
+			{Role: "user", Content: "Task: x"},
+			{Role: "assistant", Content: "{}"},
+			{Role: "user", Content: "observation:shell error exit=-1"},
+		}
+		markInterrupted(&transcript, 2, action)
+
+		if len(transcript) != 4 {
+			t.Fatalf("Expected 4 messages, got %d", len(transcript))
+		}
+		if !strings.Contains(transcript[3].Content, "interrupted by user") {
+			t.Errorf("Expected observation to mention interruption, got %q", transcript[3].Content)
+		}
+	})
+
+	t.Run("appends observation when handler reported nothing", func(t *testing.T) {
+		transcript := []providers.ChatMessage{
+			{Role: "system", Content: "system"},
+			{Role: "user", Content: "Task: x"},
+		}
+		markInterrupted(&transcript, 2, action)
+
+		if len(transcript) != 4 {
+			t.Fatalf("Expected 4 messages, got %d", len(transcript))
+		}
+		if transcript[2].Role != "assistant" || transcript[3].Role != "user" {
+			t.Errorf("Expected assistant/user pair, got %s/%s", transcript[2].Role, transcript[3].Role)
+		}
+		if transcript[1].Content != "Task: x" {
+			t.Errorf("Expected task message to be untouched, got %q", transcript[1].Content)
+		}
+	})
+}
+
+func TestReadStdinLineCancelled(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := readStdinLine(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	// The line typed after the interruption goes to the next prompt
+	if _, err := w.WriteString("yes\n"); err != nil {
+		t.Fatal(err)
+	}
+	line, err := readStdinLine(context.Background())
+	if err != nil || line != "yes\n" {
+		t.Errorf("Expected %q, got %q (%v)", "yes\n", line, err)
+	}
+}
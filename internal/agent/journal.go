@@ -0,0 +1,477 @@
+package agent
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"terminusai/internal/common"
+	"terminusai/internal/policy"
+	"terminusai/internal/providers"
+)
+
+// journalFileName is the entry log inside a session's journal directory
+const journalFileName = "journal.jsonl"
+
+// ErrNothingToUndo is returned by UndoJournal when no change is recorded
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// JournalEntry records how to reverse one file change. Backup names a copy of
+// the prior state inside the journal directory (empty when there was nothing
+// at the path). For moves, Src is where the path came from. After is the
+// pathState the change left behind; undo refuses to touch a path that no
+// longer matches it.
+type JournalEntry struct {
+	Action  string    `json:"action"`
+	Path    string    `json:"path"`
+	Src     string    `json:"src,omitempty"`
+	Existed bool      `json:"existed"`
+	Backup  string    `json:"backup,omitempty"`
+	After   string    `json:"after,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// describe summarizes what undoing the entry does
+func (e JournalEntry) describe() string {
+	switch {
+	case e.Action == "move_path":
+		return fmt.Sprintf("moved %s back to %s", e.Path, e.Src)
+	case e.Existed:
+		return fmt.Sprintf("restored %s (%s)", e.Path, e.Action)
+	default:
+		return fmt.Sprintf("removed %s (created by %s)", e.Path, e.Action)
+	}
+}
+
+// TrashDir returns the directory holding the undo journals of all sessions
+func TrashDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, common.ConfigDirName, "trash"), nil
+}
+
+// NewJournalDir returns a fresh journal directory for a session starting now,
+// first removing journals beyond the newest maxJournalSessions or older than
+// maxJournalAge
+func NewJournalDir() (string, error) {
+	dir, err := TrashDir()
+	if err != nil {
+		return "", err
+	}
+	pruneJournals(dir, maxJournalSessions, maxJournalAge)
+	// The pid keeps sessions started in the same second apart
+	return filepath.Join(dir, fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), os.Getpid())), nil
+}
+
+// pruneJournals removes the session journals in trash other than the newest
+// keep, and any last changed more than maxAge ago. Session directories are
+// named by start time, so they sort oldest first.
+func pruneJournals(trash string, keep int, maxAge time.Duration) {
+	entries, err := os.ReadDir(trash)
+	if err != nil {
+		return
+	}
+	var sessions []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			sessions = append(sessions, entry.Name())
+		}
+	}
+	sort.Strings(sessions)
+
+	cutoff := time.Now().Add(-maxAge)
+	for i, name := range sessions {
+		dir := filepath.Join(trash, name)
+		expired := i < len(sessions)-keep
+		if !expired {
+			// Appending to the journal doesn't touch the directory's mtime
+			info, err := os.Stat(filepath.Join(dir, journalFileName))
+			if err != nil {
+				info, err = os.Stat(dir)
+			}
+			expired = err == nil && info.ModTime().Before(cutoff)
+		}
+		if expired {
+			os.RemoveAll(dir)
+		}
+	}
+}
+
+// SetJournalDir enables the undo journal: the prior state of every file that
+// write_file, patch_file, move_path and delete_path change is kept in dir
+func (a *Agent) SetJournalDir(dir string) {
+	a.journalDir = dir
+}
+
+// journalPrepare backs up the current state of path before actionType changes
+// it and returns the entry to record once the change succeeds. It returns nil
+// when the journal is disabled.
+func (a *Agent) journalPrepare(actionType, path string) (*JournalEntry, error) {
+	if a.journalDir == "" {
+		return nil, nil
+	}
+	a.journalMu.Lock()
+	defer a.journalMu.Unlock()
+
+	entry := &JournalEntry{Action: actionType, Path: path}
+	if _, err := os.Lstat(path); err == nil {
+		if small, err := sizeWithin(path, maxJournalBackupBytes); err == nil && !small {
+			fmt.Printf("  ⎿  Not kept for undo: %s is larger than %d MB\n", path, maxJournalBackupBytes>>20)
+			return nil, nil
+		}
+		entry.Existed = true
+		entry.Backup = a.backupName(path)
+		if _, err := copyPathHelper(path, filepath.Join(a.journalDir, entry.Backup), false); err != nil {
+			return nil, fmt.Errorf("backing up %s for undo: %w", path, err)
+		}
+	}
+	return entry, nil
+}
+
+// errSizeLimit stops sizeWithin's walk once the limit is passed
+var errSizeLimit = errors.New("size limit exceeded")
+
+// sizeWithin reports whether the regular files at or below path add up to at
+// most limit bytes, stopping as soon as they don't
+func sizeWithin(path string, limit int64) (bool, error) {
+	var total int64
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if total += info.Size(); total > limit {
+			return errSizeLimit
+		}
+		return nil
+	})
+	if err == errSizeLimit {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// journalCommit records a prepared entry after its change was made. Like
+// history, failures are reported but never interrupt the task.
+func (a *Agent) journalCommit(entry *JournalEntry) {
+	if entry == nil {
+		return
+	}
+	a.journalMu.Lock()
+	defer a.journalMu.Unlock()
+
+	entry.Time = time.Now()
+	after, err := pathState(entry.Path)
+	if err != nil {
+		if a.verbose {
+			fmt.Printf("  ⎿  Failed to record undo journal: %v\n", err)
+		}
+		return
+	}
+	entry.After = after
+	if err := appendJournalEntry(a.journalDir, *entry); err != nil && a.verbose {
+		fmt.Printf("  ⎿  Failed to record undo journal: %v\n", err)
+	}
+}
+
+// trashPath deletes path by moving it into the journal, where undo finds it.
+// Like os.Remove, a non-empty directory is refused unless recursive is set.
+func (a *Agent) trashPath(path string, recursive bool) error {
+	if a.journalDir == "" {
+		return fmt.Errorf("softDelete needs the undo journal, which is disabled")
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() && !recursive {
+		if children, err := os.ReadDir(path); err != nil || len(children) > 0 {
+			return fmt.Errorf("remove %s: directory not empty", path)
+		}
+	}
+
+	a.journalMu.Lock()
+	defer a.journalMu.Unlock()
+
+	entry := JournalEntry{Action: "delete_path", Path: path, Existed: true, Backup: a.backupName(path), After: pathAbsent, Time: time.Now()}
+	if err := os.MkdirAll(a.journalDir, 0755); err != nil {
+		return err
+	}
+	if _, err := movePath(path, filepath.Join(a.journalDir, entry.Backup), false); err != nil {
+		return err
+	}
+	return appendJournalEntry(a.journalDir, entry)
+}
+
+// backupName returns an unused name in the journal for a copy of path
+func (a *Agent) backupName(path string) string {
+	for {
+		a.journalSeq++
+		name := fmt.Sprintf("%04d-%s", a.journalSeq, filepath.Base(path))
+		if _, err := os.Lstat(filepath.Join(a.journalDir, name)); os.IsNotExist(err) {
+			return name
+		}
+	}
+}
+
+// appendJournalEntry writes a single entry as a JSON line
+func appendJournalEntry(dir string, entry JournalEntry) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, journalFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// LoadJournal reads the entries of a journal directory, oldest first
+func LoadJournal(dir string) ([]JournalEntry, error) {
+	file, err := os.Open(filepath.Join(dir, journalFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// saveJournal replaces the journal's entries
+func saveJournal(dir string, entries []JournalEntry) error {
+	var sb strings.Builder
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		sb.Write(line)
+		sb.WriteByte('\n')
+	}
+	return os.WriteFile(filepath.Join(dir, journalFileName), []byte(sb.String()), 0644)
+}
+
+// UndoJournal reverses the last n changes recorded in dir, newest first, and
+// returns a description of each. It stops at the first change that can't be
+// reversed; that change and older ones stay in the journal.
+func UndoJournal(dir string, n int) ([]string, error) {
+	entries, err := LoadJournal(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrNothingToUndo
+	}
+	if n < 1 {
+		n = 1
+	}
+	if n > len(entries) {
+		n = len(entries)
+	}
+
+	var undone []string
+	var undoErr error
+	for len(undone) < n {
+		entry := entries[len(entries)-1]
+		if undoErr = undoEntry(dir, entry); undoErr != nil {
+			undoErr = fmt.Errorf("undoing %s of %s: %w", entry.Action, entry.Path, undoErr)
+			break
+		}
+		undone = append(undone, entry.describe())
+		entries = entries[:len(entries)-1]
+	}
+
+	if err := saveJournal(dir, entries); err != nil && undoErr == nil {
+		undoErr = err
+	}
+	return undone, undoErr
+}
+
+// pathAbsent is the pathState of a path that doesn't exist
+const pathAbsent = "absent"
+
+// pathState fingerprints what is at path: the SHA-256 of a file, a link's
+// target, or for a directory a digest of every entry's name and content
+func pathState(path string) (string, error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return pathAbsent, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		return "link:" + target, nil
+	case !info.IsDir():
+		digest, err := hashFile(path, "sha256")
+		if err != nil {
+			return "", err
+		}
+		return "sha256:" + digest, nil
+	}
+
+	h := sha256.New()
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(path, p)
+		state := "dir"
+		if !d.IsDir() {
+			if state, err = pathState(p); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(h, "%s\x00%s\n", filepath.ToSlash(rel), state)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return "tree:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// undoEntry puts the path of one entry back the way it was. It refuses when
+// the path changed after the agent's change, so later edits aren't lost.
+func undoEntry(dir string, entry JournalEntry) error {
+	if entry.After != "" {
+		current, err := pathState(entry.Path)
+		if err != nil {
+			return err
+		}
+		if current != entry.After {
+			return fmt.Errorf("%s was changed after %s; undo it by hand to keep those edits", entry.Path, entry.Action)
+		}
+	}
+	if entry.Action == "move_path" {
+		if _, err := os.Lstat(entry.Src); err == nil {
+			return fmt.Errorf("%s exists again", entry.Src)
+		}
+		if err := os.MkdirAll(filepath.Dir(entry.Src), 0755); err != nil {
+			return err
+		}
+		if _, err := movePath(entry.Path, entry.Src, false); err != nil {
+			return err
+		}
+		if !entry.Existed {
+			return nil
+		}
+	} else if err := os.RemoveAll(entry.Path); err != nil {
+		return err
+	}
+
+	if !entry.Existed {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+		return err
+	}
+	_, err := movePath(filepath.Join(dir, entry.Backup), entry.Path, false)
+	return err
+}
+
+// handleUndo reverses the last count file changes of this session
+func (a *Agent) handleUndo(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	count := *action.Count
+	actionUI := a.display.ShowAction("Undo", fmt.Sprintf("Reverting the last %d file change(s)", count), true)
+	actionJSON, _ := json.Marshal(action)
+
+	if a.journalDir == "" {
+		a.display.UpdateAction(actionUI, "failed", []string{"Undo journal is disabled"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:undo error\nThe undo journal is disabled for this session"},
+		)
+		return nil
+	}
+
+	reason := action.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("Revert the last %d file change(s)", count)
+	}
+	decision, err := a.policyStore.Approve(action.Type, fmt.Sprintf("undo %d", count), reason)
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		return err
+	}
+	if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+		a.display.UpdateAction(actionUI, "skipped", []string{"User declined"})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+			providers.ChatMessage{Role: "user", Content: "observation:undo skipped by user"},
+		)
+		return nil
+	}
+
+	a.journalMu.Lock()
+	undone, err := UndoJournal(a.journalDir, count)
+	a.journalMu.Unlock()
+
+	var sb strings.Builder
+	status := "success"
+	if err != nil {
+		status = "error"
+		sb.WriteString(err.Error() + "\n")
+	}
+	if len(undone) > 0 {
+		sb.WriteString(fmt.Sprintf("Reverted %d change(s):\n- %s", len(undone), strings.Join(undone, "\n- ")))
+	}
+
+	if status == "success" {
+		a.display.UpdateAction(actionUI, "completed", undone)
+	} else {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+	}
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:undo %s\n%s", status, strings.TrimSpace(sb.String()))},
+	)
+	return nil
+}
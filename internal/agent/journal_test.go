@@ -0,0 +1,252 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"terminusai/internal/providers"
+)
+
+// runActions parses and executes each action JSON, returning the last observation
+func runActions(t *testing.T, a *Agent, actions ...string) string {
+	t.Helper()
+	var transcript []providers.ChatMessage
+	for _, raw := range actions {
+		action, err := parseAgentAction(raw)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", raw, err)
+		}
+		if err := a.executeAction(action, &transcript); err != nil {
+			t.Fatalf("Failed to execute %s: %v", raw, err)
+		}
+	}
+	return transcript[len(transcript)-1].Content
+}
+
+func readString(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestUndoWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "config.txt")
+	if err := os.WriteFile(target, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAgent(t, dir)
+	a.SetJournalDir(filepath.Join(t.TempDir(), "session"))
+
+	runActions(t, a,
+		`{"type":"write_file","path":"config.txt","content":"rewritten"}`,
+		`{"type":"write_file","path":"new.txt","content":"fresh"}`,
+	)
+	if got := readString(t, target); got != "rewritten" {
+		t.Fatalf("Expected the write to happen, got %q", got)
+	}
+
+	obs := runActions(t, a, `{"type":"undo","count":2}`)
+	if !strings.HasPrefix(obs, "observation:undo success\nReverted 2 change(s)") {
+		t.Errorf("Unexpected observation: %q", obs)
+	}
+	if got := readString(t, target); got != "original" {
+		t.Errorf("Expected original content after undo, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected the created file to be removed by undo, got %v", err)
+	}
+
+	if obs := runActions(t, a, `{"type":"undo"}`); !strings.HasPrefix(obs, "observation:undo error\nnothing to undo") {
+		t.Errorf("Expected nothing left to undo, got %q", obs)
+	}
+}
+
+func TestUndoRefusesLaterEdits(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "config.txt")
+	if err := os.WriteFile(target, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAgent(t, dir)
+	a.SetJournalDir(filepath.Join(t.TempDir(), "session"))
+	runActions(t, a, `{"type":"write_file","path":"config.txt","content":"rewritten"}`)
+
+	// The user edits the file after the agent did
+	if err := os.WriteFile(target, []byte("user edit"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	obs := runActions(t, a, `{"type":"undo"}`)
+	if !strings.HasPrefix(obs, "observation:undo error\n") || !strings.Contains(obs, "was changed after write_file") {
+		t.Errorf("Expected undo to refuse, got %q", obs)
+	}
+	if got := readString(t, target); got != "user edit" {
+		t.Errorf("Expected the user's edit to survive, got %q", got)
+	}
+	if entries, _ := LoadJournal(a.journalDir); len(entries) != 1 {
+		t.Errorf("Expected the refused change to stay in the journal, got %d entries", len(entries))
+	}
+
+	// Putting the agent's version back makes it undoable again
+	if err := os.WriteFile(target, []byte("rewritten"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if obs := runActions(t, a, `{"type":"undo"}`); !strings.HasPrefix(obs, "observation:undo success") {
+		t.Errorf("Expected undo to succeed, got %q", obs)
+	}
+	if got := readString(t, target); got != "original" {
+		t.Errorf("Expected original content after undo, got %q", got)
+	}
+}
+
+func TestNewJournalDirIsUniquePerProcess(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	dir, err := NewJournalDir()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.HasSuffix(filepath.Base(dir), fmt.Sprintf("-%d", os.Getpid())) {
+		t.Errorf("Expected the session name to end with the pid, got %s", filepath.Base(dir))
+	}
+}
+
+func TestUndoPatchMoveDelete(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"a.txt": "one\ntwo\n", "b.txt": "bee", "c.txt": "sea", "tree/leaf.txt": "leaf"}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := newTestAgent(t, dir)
+	journal := filepath.Join(t.TempDir(), "session")
+	a.SetJournalDir(journal)
+
+	runActions(t, a,
+		`{"type":"patch_file","path":"a.txt","format":"full","patch":"patched"}`,
+		`{"type":"move_path","src":"b.txt","dest":"b2.txt"}`,
+		`{"type":"delete_path","path":"c.txt","softDelete":true}`,
+		`{"type":"delete_path","path":"tree","recursive":true}`,
+	)
+	for _, name := range []string{"b.txt", "c.txt", "tree"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Fatalf("Expected %s to be gone before undo, got %v", name, err)
+		}
+	}
+
+	entries, err := LoadJournal(journal)
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("Expected 4 journal entries, got %d (%v)", len(entries), err)
+	}
+
+	obs := runActions(t, a, `{"type":"undo","count":4}`)
+	if !strings.HasPrefix(obs, "observation:undo success") {
+		t.Fatalf("Unexpected observation: %q", obs)
+	}
+	for name, content := range files {
+		if got := readString(t, filepath.Join(dir, name)); got != content {
+			t.Errorf("Expected %s restored to %q, got %q", name, content, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b2.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected the move destination to be gone after undo, got %v", err)
+	}
+}
+
+func TestSoftDeleteRefusesNonEmptyDirectory(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "full"), 0755)
+	os.WriteFile(filepath.Join(dir, "full", "x.txt"), []byte("x"), 0644)
+
+	a := newTestAgent(t, dir)
+	a.SetJournalDir(filepath.Join(t.TempDir(), "session"))
+
+	obs := runActions(t, a, `{"type":"delete_path","path":"full","softDelete":true}`)
+	if !strings.HasPrefix(obs, "observation:delete_path error") {
+		t.Errorf("Expected non-recursive soft delete of a full directory to fail, got %q", obs)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "full", "x.txt")); err != nil {
+		t.Errorf("Expected the directory to be left alone: %v", err)
+	}
+}
+
+func TestUndoWithoutJournal(t *testing.T) {
+	a := newTestAgent(t, t.TempDir())
+	if obs := runActions(t, a, `{"type":"undo"}`); !strings.HasPrefix(obs, "observation:undo error") {
+		t.Errorf("Expected undo to fail without a journal, got %q", obs)
+	}
+}
+
+func TestSizeWithin(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, 600), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		limit    int64
+		expected bool
+	}{
+		{"file under limit", filepath.Join(dir, "a.txt"), 1000, true},
+		{"file over limit", filepath.Join(dir, "a.txt"), 500, false},
+		{"tree under limit", dir, 1200, true},
+		{"tree over limit", dir, 1000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			small, err := sizeWithin(tt.path, tt.limit)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if small != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, small)
+			}
+		})
+	}
+}
+
+func TestPruneJournals(t *testing.T) {
+	trash := t.TempDir()
+	sessions := []string{"20260101-090000", "20260102-090000", "20260103-090000", "20260104-090000"}
+	for _, name := range sessions {
+		if err := appendJournalEntry(filepath.Join(trash, name), JournalEntry{Action: "write_file", Path: "x"}); err != nil {
+			t.Fatalf("Failed to create journal: %v", err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(trash, sessions[2], journalFileName), old, old); err != nil {
+		t.Fatalf("Failed to age journal: %v", err)
+	}
+
+	pruneJournals(trash, 3, 24*time.Hour)
+
+	// The oldest is beyond the newest 3, the third is past maxAge
+	for i, name := range sessions {
+		_, err := os.Stat(filepath.Join(trash, name))
+		if kept := err == nil; kept != (i == 1 || i == 3) {
+			t.Errorf("Session %s: expected kept=%v, got %v", name, i == 1 || i == 3, kept)
+		}
+	}
+}
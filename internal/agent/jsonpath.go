@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pathStep is one segment of a JSONPath query
+type pathStep struct {
+	field     string // Object key; "" with wildcard or index
+	index     int    // Array index (negative counts from the end)
+	isIndex   bool
+	wildcard  bool // .* or [*]: every child
+	recursive bool // ..: the step applies at any depth
+}
+
+// parseJSONPath splits a query like "$.items[0].name", "$..id" or
+// "$['a key'][*]" into steps. The leading "$" is optional, so jq-style
+// ".items[0]" works too.
+func parseJSONPath(query string) ([]pathStep, error) {
+	q := strings.TrimPrefix(strings.TrimSpace(query), "$")
+	var steps []pathStep
+	for i := 0; i < len(q); {
+		recursive := false
+		switch q[i] {
+		case '.':
+			i++
+			if i < len(q) && q[i] == '.' {
+				recursive = true
+				i++
+			}
+			if i < len(q) && q[i] == '[' {
+				// "..[0]" or a stray "." before a bracket
+				continue
+			}
+			end := i
+			for end < len(q) && q[end] != '.' && q[end] != '[' {
+				end++
+			}
+			name := q[i:end]
+			if name == "" {
+				return nil, fmt.Errorf("invalid query %q: empty field name at offset %d", query, i)
+			}
+			steps = append(steps, pathStep{field: name, wildcard: name == "*", recursive: recursive})
+			i = end
+		case '[':
+			end := strings.IndexByte(q[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid query %q: unclosed [", query)
+			}
+			inner := strings.TrimSpace(q[i+1 : i+end])
+			step, err := parseBracket(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid query %q: %w", query, err)
+			}
+			// A recursive ".." directly before the bracket applies to it
+			if len(q) > 1 && i >= 2 && q[i-2:i] == ".." {
+				step.recursive = true
+			}
+			steps = append(steps, step)
+			i += end + 1
+		default:
+			if i > 0 {
+				return nil, fmt.Errorf("invalid query %q: unexpected %q at offset %d", query, q[i], i)
+			}
+			// A bare first field, as in "items[0]"
+			q = "." + q
+		}
+	}
+	return steps, nil
+}
+
+// parseBracket parses the inside of [...]: *, an index or a quoted key
+func parseBracket(inner string) (pathStep, error) {
+	switch {
+	case inner == "*":
+		return pathStep{wildcard: true}, nil
+	case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+		return pathStep{field: inner[1 : len(inner)-1]}, nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return pathStep{}, fmt.Errorf("[%s] is not an index, * or a quoted key", inner)
+	}
+	return pathStep{index: index, isIndex: true}, nil
+}
+
+// queryJSONPath evaluates query against a decoded JSON (or converted YAML)
+// document. A query without wildcards or recursion returns the single value it
+// names; otherwise every match is returned as a list.
+func queryJSONPath(doc interface{}, query string) (interface{}, error) {
+	steps, err := parseJSONPath(query)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []interface{}{doc}
+	multiple := false
+	for _, step := range steps {
+		if step.wildcard || step.recursive {
+			multiple = true
+		}
+		var next []interface{}
+		for _, node := range nodes {
+			if step.recursive {
+				for _, n := range descendants(node) {
+					next = append(next, applyStep(n, step)...)
+				}
+			} else {
+				next = append(next, applyStep(node, step)...)
+			}
+		}
+		nodes = next
+	}
+
+	if multiple {
+		if nodes == nil {
+			nodes = []interface{}{}
+		}
+		return nodes, nil
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no value at %s", query)
+	}
+	return nodes[0], nil
+}
+
+// applyStep returns the children of node selected by step
+func applyStep(node interface{}, step pathStep) []interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if step.wildcard {
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			children := make([]interface{}, 0, len(keys))
+			for _, key := range keys {
+				children = append(children, v[key])
+			}
+			return children
+		}
+		if value, ok := v[step.field]; ok && !step.isIndex {
+			return []interface{}{value}
+		}
+	case []interface{}:
+		if step.wildcard {
+			return v
+		}
+		if step.isIndex {
+			index := step.index
+			if index < 0 {
+				index += len(v)
+			}
+			if index >= 0 && index < len(v) {
+				return []interface{}{v[index]}
+			}
+		}
+	}
+	return nil
+}
+
+// descendants returns node and everything below it, depth first
+func descendants(node interface{}) []interface{} {
+	result := []interface{}{node}
+	for _, child := range applyStep(node, pathStep{wildcard: true}) {
+		result = append(result, descendants(child)...)
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const jsonPathDoc = `{
+	"name": "demo",
+	"items": [
+		{"name": "alpha", "id": 1, "tags": ["a"]},
+		{"name": "beta", "id": 2, "tags": []}
+	],
+	"meta": {"owner": {"id": 9}, "a key": true}
+}`
+
+func TestQueryJSONPath(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(jsonPathDoc), &doc); err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{"$.name", "demo"},
+		{"$.meta.owner.id", 9.0},
+		{"$['meta']['a key']", true},
+		{"$.items[0].name", "alpha"},
+		{"$.items[-1].id", 2.0},
+		{".items[1].name", "beta"},
+		{"items[0].tags[0]", "a"},
+		{"$.items[*].name", []interface{}{"alpha", "beta"}},
+		{"$.items.*.id", []interface{}{1.0, 2.0}},
+		{"$..id", []interface{}{1.0, 2.0, 9.0}},
+		{"$.items[*].missing", []interface{}{}},
+	}
+	for _, tt := range tests {
+		got, err := queryJSONPath(doc, tt.query)
+		if err != nil {
+			t.Errorf("queryJSONPath(%q) failed: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("queryJSONPath(%q): expected %#v, got %#v", tt.query, tt.expected, got)
+		}
+	}
+
+	for _, query := range []string{"$.items[5]", "$.nope", "$.items[", "$.items[x]", "$.name.first"} {
+		if _, err := queryJSONPath(doc, query); err == nil {
+			t.Errorf("Expected queryJSONPath(%q) to fail", query)
+		}
+	}
+}
+
+func TestParseActionsQuery(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.json"), []byte(jsonPathDoc), 0644); err != nil {
+		t.Fatalf("Failed to write JSON: %v", err)
+	}
+	yamlDoc := "services:\n  web:\n    image: nginx:1.25\n  db:\n    image: postgres:16\n"
+	if err := os.WriteFile(filepath.Join(dir, "compose.yaml"), []byte(yamlDoc), 0644); err != nil {
+		t.Fatalf("Failed to write YAML: %v", err)
+	}
+	a := newTestAgent(t, dir)
+
+	tests := []struct {
+		action   string
+		expected string
+	}{
+		{`{"type":"parse_json","path":"data.json","query":"$.items[1].name"}`, "observation:parse_json success\n\"beta\""},
+		{`{"type":"parse_yaml","path":"compose.yaml","query":"$.services.web.image"}`, "observation:parse_yaml success\n\"nginx:1.25\""},
+		{`{"type":"parse_yaml","path":"compose.yaml","query":"$.services.*.image"}`, "observation:parse_yaml success\n[\n  \"postgres:16\",\n  \"nginx:1.25\"\n]"},
+		{`{"type":"parse","parseType":"json","path":"data.json","query":"$.items[*].id"}`, "observation:parse success\n[\n  1,\n  2\n]"},
+		{`{"type":"parse_json","path":"data.json","query":"$.items[7]"}`, "observation:parse_json error\nno value at $.items[7]"},
+	}
+	for _, tt := range tests {
+		if got := runActions(t, a, tt.action); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.action, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package agent
+
+// killSignals are the signal names kill accepts, without the SIG prefix. TERM
+// is the default; only KILL stops a process without giving it a chance to
+// clean up. On Windows every signal but KILL asks the process to close.
+var killSignals = []string{"TERM", "INT", "HUP", "QUIT", "USR1", "USR2", "KILL"}
+
+// isKillSignal reports whether name (upper case, no SIG prefix) is in killSignals
+func isKillSignal(name string) bool {
+	for _, s := range killSignals {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"terminusai/internal/providers"
+)
+
+func TestKillSignalValidation(t *testing.T) {
+	pid := 1234
+	tests := []struct {
+		signal   string
+		expected string
+		err      bool
+	}{
+		{"", "TERM", false},
+		{"SIGINT", "INT", false},
+		{"hup", "HUP", false},
+		{"KILL", "KILL", false},
+		{"SIGFOO", "", true},
+		{"9", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.signal, func(t *testing.T) {
+			action := &AgentAction{Type: "kill", PID: &pid, Signal: tt.signal}
+			err := validateAction(action)
+			if tt.err {
+				if err == nil || !strings.Contains(err.Error(), "unknown signal") {
+					t.Errorf("Expected unknown signal error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected valid action, got %v", err)
+			}
+			if action.Signal != tt.expected {
+				t.Errorf("Expected signal %s, got %s", tt.expected, action.Signal)
+			}
+			if action.ProcessID == nil || *action.ProcessID != pid {
+				t.Errorf("Expected pid to be used as the process id")
+			}
+		})
+	}
+}
+
+func TestKillRejectsProcessGroups(t *testing.T) {
+	for _, pid := range []int{0, -1} {
+		pid := pid
+		err := validateAction(&AgentAction{Type: "kill", PID: &pid})
+		if err == nil || !strings.Contains(err.Error(), "positive") {
+			t.Errorf("Expected pid %d to be rejected, got %v", pid, err)
+		}
+	}
+}
+
+func TestHandleKillSendsTerm(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals are Unix only")
+	}
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("sleep not available: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	a := newTestAgent(t, t.TempDir())
+	pid := cmd.Process.Pid
+	action := &AgentAction{Type: "kill", PID: &pid}
+	if err := validateAction(action); err != nil {
+		t.Fatalf("Expected valid action, got %v", err)
+	}
+	var transcript []providers.ChatMessage
+	if err := a.handleKill(action, &transcript); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if obs := transcript[1].Content; !strings.HasPrefix(obs, "observation:kill success\nSent SIGTERM") {
+		t.Errorf("Expected SIGTERM success, got %q", obs)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		cmd.Process.Kill()
+		t.Fatalf("Expected process to exit after SIGTERM")
+	}
+	status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() || status.Signal() != syscall.SIGTERM {
+		t.Errorf("Expected process to be stopped by SIGTERM, got %v", cmd.ProcessState)
+	}
+}
@@ -0,0 +1,187 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// lintIssue is one problem reported by a linter
+type lintIssue struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"` // error or warning
+	Message  string `json:"message"`
+}
+
+// lintSummary is the structured result of lint_file
+type lintSummary struct {
+	Linter   string      `json:"linter"`
+	Command  string      `json:"command"`
+	Status   string      `json:"status"` // clean or issues
+	ExitCode int         `json:"exitCode"`
+	Parsed   bool        `json:"parsed"` // false when issues could not be read from the output
+	Issues   []lintIssue `json:"issues,omitempty"`
+}
+
+// fileLinter checks one kind of source file and reads its diagnostics
+type fileLinter struct {
+	name       string
+	extensions []string
+	// command returns the check to run from the file's directory
+	command func(file string) []string
+	// parse reads issues from the output; ok is false when it isn't in the expected format
+	parse func(output string) (issues []lintIssue, ok bool)
+}
+
+// fileLinters are selected by file extension unless a linter is given
+var fileLinters = []fileLinter{
+	{
+		name:       "go",
+		extensions: []string{".go"},
+		// vet type-checks the whole package, so build errors are reported too
+		command: func(file string) []string { return []string{"go", "vet", "."} },
+		parse:   func(output string) ([]lintIssue, bool) { return parseLintLines(output, "error"), true },
+	},
+	{
+		name:       "eslint",
+		extensions: []string{".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx"},
+		command: func(file string) []string {
+			return []string{"npx", "--no-install", "eslint", "--format", "json", file}
+		},
+		parse: parseESLintOutput,
+	},
+	{
+		name:       "pyflakes",
+		extensions: []string{".py"},
+		command: func(file string) []string {
+			return []string{pythonExecutable(), "-m", "pyflakes", file}
+		},
+		parse: func(output string) ([]lintIssue, bool) { return parseLintLines(output, "warning"), true },
+	},
+}
+
+// findLinter returns the named linter, or the one for the file's extension
+func findLinter(name, path string) (fileLinter, error) {
+	if name != "" {
+		for _, l := range fileLinters {
+			if l.name == name {
+				return l, nil
+			}
+		}
+		return fileLinter{}, fmt.Errorf("unknown linter %s (supported: go, eslint, pyflakes)", name)
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, l := range fileLinters {
+		for _, e := range l.extensions {
+			if e == ext {
+				return l, nil
+			}
+		}
+	}
+	return fileLinter{}, fmt.Errorf("no linter for %s files (supported: .go, .js/.ts, .py); set linter", ext)
+}
+
+// summarizeLint parses linter output into a summary. A failing exit code
+// without any recognised issue means the output wasn't understood (a missing
+// tool, a crash), so the raw output is returned for the model to read.
+func summarizeLint(l fileLinter, output string, exitCode int) (lintSummary, string) {
+	summary := lintSummary{Linter: l.name, ExitCode: exitCode}
+	issues, ok := l.parse(output)
+	if ok && len(issues) == 0 && exitCode != 0 {
+		ok = false
+	}
+	summary.Parsed = ok
+
+	details := ""
+	if ok {
+		summary.Issues = issues
+	} else {
+		details = output
+	}
+
+	summary.Status = "clean"
+	if exitCode != 0 || len(summary.Issues) > 0 {
+		summary.Status = "issues"
+	}
+	return summary, details
+}
+
+// lintLineRe matches "file:line:col: message" and "file:line: message"
+var lintLineRe = regexp.MustCompile(`^(.+?):(\d+):(?:(\d+):)? ?(.+)$`)
+
+// parseLintLines reads compiler-style diagnostics, one per line
+func parseLintLines(output, severity string) []lintIssue {
+	var issues []lintIssue
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "#") {
+			continue // go vet's package header
+		}
+		line = strings.TrimPrefix(line, "vet: ")
+		m := lintLineRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		issue := lintIssue{File: filepath.Clean(m[1]), Severity: severity, Message: strings.TrimSpace(m[4])}
+		issue.Line, _ = strconv.Atoi(m[2])
+		issue.Column, _ = strconv.Atoi(m[3])
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// eslintResult is one file of eslint --format json output
+type eslintResult struct {
+	FilePath string `json:"filePath"`
+	Messages []struct {
+		Line     int    `json:"line"`
+		Column   int    `json:"column"`
+		Severity int    `json:"severity"` // 1 = warning, 2 = error
+		Message  string `json:"message"`
+		RuleID   string `json:"ruleId"`
+	} `json:"messages"`
+}
+
+// parseESLintOutput reads eslint's JSON report
+func parseESLintOutput(output string) ([]lintIssue, bool) {
+	start := strings.Index(output, "[")
+	if start < 0 {
+		return nil, false
+	}
+	var results []eslintResult
+	if err := json.Unmarshal([]byte(output[start:]), &results); err != nil {
+		return nil, false
+	}
+
+	var issues []lintIssue
+	for _, r := range results {
+		for _, m := range r.Messages {
+			severity := "warning"
+			if m.Severity == 2 {
+				severity = "error"
+			}
+			message := m.Message
+			if m.RuleID != "" {
+				message += " (" + m.RuleID + ")"
+			}
+			issues = append(issues, lintIssue{File: r.FilePath, Line: m.Line, Column: m.Column, Severity: severity, Message: message})
+		}
+	}
+	return issues, true
+}
+
+// pythonExecutable returns python, or python3 where only that is installed
+func pythonExecutable() string {
+	if _, err := exec.LookPath("python"); err != nil && runtime.GOOS != "windows" {
+		return "python3"
+	}
+	return "python"
+}
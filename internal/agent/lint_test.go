@@ -0,0 +1,164 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestSummarizeLint(t *testing.T) {
+	goLinter, _ := findLinter("go", "")
+	eslint, _ := findLinter("eslint", "")
+	pyflakes, _ := findLinter("pyflakes", "")
+
+	tests := []struct {
+		name           string
+		linter         fileLinter
+		output         string
+		exitCode       int
+		expectedStatus string
+		expectedParsed bool
+		expectedIssues []lintIssue
+	}{
+		{"go clean", goLinter, "", 0, "clean", true, nil},
+		{
+			"go build and vet errors", goLinter,
+			"# example\n./main.go:5:2: undefined: foo\nvet: ./util.go:9:3: fmt.Printf format %d has arg s of wrong type string\n", 1,
+			"issues", true,
+			[]lintIssue{
+				{File: "main.go", Line: 5, Column: 2, Severity: "error", Message: "undefined: foo"},
+				{File: "util.go", Line: 9, Column: 3, Severity: "error", Message: "fmt.Printf format %d has arg s of wrong type string"},
+			},
+		},
+		{"go tool missing", goLinter, "go: command not found\n", 127, "issues", false, nil},
+		{
+			"pyflakes with and without column", pyflakes,
+			"app.py:1:1: 'os' imported but unused\napp.py:7: undefined name 'x'\n", 1,
+			"issues", true,
+			[]lintIssue{
+				{File: "app.py", Line: 1, Column: 1, Severity: "warning", Message: "'os' imported but unused"},
+				{File: "app.py", Line: 7, Severity: "warning", Message: "undefined name 'x'"},
+			},
+		},
+		{
+			"eslint json", eslint,
+			`[{"filePath":"/src/a.js","messages":[{"line":3,"column":7,"severity":2,"message":"'y' is not defined.","ruleId":"no-undef"},{"line":4,"column":1,"severity":1,"message":"Unexpected console statement.","ruleId":"no-console"}]}]`, 1,
+			"issues", true,
+			[]lintIssue{
+				{File: "/src/a.js", Line: 3, Column: 7, Severity: "error", Message: "'y' is not defined. (no-undef)"},
+				{File: "/src/a.js", Line: 4, Column: 1, Severity: "warning", Message: "Unexpected console statement. (no-console)"},
+			},
+		},
+		{"eslint not installed", eslint, "npm ERR! could not determine executable to run\n", 1, "issues", false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, details := summarizeLint(tt.linter, tt.output, tt.exitCode)
+			if summary.Status != tt.expectedStatus || summary.Parsed != tt.expectedParsed {
+				t.Errorf("Expected status %s parsed=%v, got %s parsed=%v", tt.expectedStatus, tt.expectedParsed, summary.Status, summary.Parsed)
+			}
+			issues := summary.Issues
+			for i := range issues {
+				issues[i].File = filepath.ToSlash(issues[i].File)
+			}
+			if !reflect.DeepEqual(issues, tt.expectedIssues) {
+				t.Errorf("Expected issues %+v, got %+v", tt.expectedIssues, issues)
+			}
+			if !tt.expectedParsed && details != tt.output {
+				t.Errorf("Expected raw output as details, got %q", details)
+			}
+		})
+	}
+}
+
+func TestFindLinter(t *testing.T) {
+	tests := []struct {
+		name     string
+		linter   string
+		path     string
+		expected string // empty when an error is expected
+	}{
+		{"go by extension", "", "cmd/main.go", "go"},
+		{"typescript by extension", "", "src/App.TSX", "eslint"},
+		{"python by extension", "", "tool.py", "pyflakes"},
+		{"explicit linter", "pyflakes", "script", "pyflakes"},
+		{"unknown extension", "", "notes.txt", ""},
+		{"unknown linter", "rubocop", "a.rb", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := findLinter(tt.linter, tt.path)
+			if tt.expected == "" {
+				if err == nil {
+					t.Errorf("Expected an error, got linter %s", l.name)
+				}
+				return
+			}
+			if err != nil || l.name != tt.expected {
+				t.Errorf("Expected linter %s, got %s (%v)", tt.expected, l.name, err)
+			}
+		})
+	}
+}
+
+func TestHandleLintFile(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example\n\ngo 1.21\n",
+		"pkg/main.go":    "package pkg\n\nfunc Broken() int {\n\treturn missing\n}\n",
+		"clean/clean.go": "package clean\n\nfunc OK() int { return 1 }\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		path           string
+		expectedStatus string
+		expectedIssues []lintIssue
+	}{
+		{"clean/clean.go", "clean", nil},
+		{"pkg/main.go", "issues", []lintIssue{{File: filepath.Join("pkg", "main.go"), Line: 4, Column: 9, Severity: "error", Message: "undefined: missing"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			a := newTestAgent(t, dir)
+			action := &AgentAction{Type: "lint_file", Path: tt.path}
+			if err := validateAction(action); err != nil {
+				t.Fatalf("Expected valid action, got %v", err)
+			}
+			var transcript []providers.ChatMessage
+			if err := a.handleLintFile(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			body, ok := strings.CutPrefix(transcript[1].Content, "observation:lint_file success\n")
+			if !ok {
+				t.Fatalf("Expected a success observation, got %q", transcript[1].Content)
+			}
+			var summary lintSummary
+			if err := json.Unmarshal([]byte(body), &summary); err != nil {
+				t.Fatalf("Expected JSON summary, got %q", body)
+			}
+			if summary.Status != tt.expectedStatus || !reflect.DeepEqual(summary.Issues, tt.expectedIssues) {
+				t.Errorf("Expected %s with %+v, got %+v", tt.expectedStatus, tt.expectedIssues, summary)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// renamePath is os.Rename, replaceable in tests to simulate a cross-device move
+var renamePath = os.Rename
+
+// movePath moves src to dest. A rename is tried first; when src and dest are
+// on different filesystems, where a rename is impossible, the path is copied
+// and the source removed instead. It returns "rename" or "copy" to say which
+// happened.
+func movePath(src, dest string, overwrite bool) (string, error) {
+	err := renamePath(src, dest)
+	if err == nil {
+		return "rename", nil
+	}
+	if !isCrossDeviceError(err) {
+		return "", err
+	}
+
+	if _, err := copyPathHelper(src, dest, overwrite); err != nil {
+		return "", fmt.Errorf("copying across filesystems: %w", err)
+	}
+	if err := os.RemoveAll(src); err != nil {
+		return "copy", fmt.Errorf("copied to %s but could not remove the source: %w", dest, err)
+	}
+	return "copy", nil
+}
+
+// isCrossDeviceError reports whether a rename failed because src and dest are
+// on different filesystems
+func isCrossDeviceError(err error) bool {
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) {
+		err = linkErr.Err
+	}
+	return errors.Is(err, errCrossDevice)
+}
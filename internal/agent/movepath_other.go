@@ -0,0 +1,8 @@
+//go:build !windows
+
+package agent
+
+import "syscall"
+
+// errCrossDevice is the error a rename across filesystems fails with
+const errCrossDevice = syscall.EXDEV
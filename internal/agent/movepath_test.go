@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestHandleMovePathFallsBackToCopy(t *testing.T) {
+	tests := []struct {
+		name      string
+		renameErr error
+		expected  string
+		moved     bool
+	}{
+		{"rename", nil, "observation:move_path success\nMove completed successfully (rename)", true},
+		{"cross-device", &os.LinkError{Op: "rename", Err: errCrossDevice}, "observation:move_path success\nMove completed successfully (copied across filesystems", true},
+		{"other rename error", &os.LinkError{Op: "rename", Err: os.ErrPermission}, "observation:move_path error\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "src")
+			if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(src, "sub", "file.txt"), []byte("data"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			renamed := false
+			renamePath = func(oldpath, newpath string) error {
+				renamed = true
+				if tt.renameErr != nil {
+					return tt.renameErr
+				}
+				return os.Rename(oldpath, newpath)
+			}
+			defer func() { renamePath = os.Rename }()
+
+			a := newTestAgent(t, dir)
+			overwrite := false
+			action := &AgentAction{Type: "move_path", Src: "src", Dest: "dest", Overwrite: &overwrite}
+			var transcript []providers.ChatMessage
+			if err := a.handleMovePath(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if !renamed {
+				t.Error("Expected a rename to be attempted first")
+			}
+			if obs := transcript[1].Content; !strings.HasPrefix(obs, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, obs)
+			}
+			content, err := os.ReadFile(filepath.Join(dir, "dest", "sub", "file.txt"))
+			if tt.moved != (err == nil && string(content) == "data") {
+				t.Errorf("Expected moved=%v, read %q (%v)", tt.moved, content, err)
+			}
+			if _, err := os.Stat(src); tt.moved != errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Expected source removed=%v, stat error %v", tt.moved, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package agent
+
+import "syscall"
+
+// errCrossDevice is ERROR_NOT_SAME_DEVICE, which MoveFileEx returns for a
+// rename across volumes
+const errCrossDevice = syscall.Errno(17)
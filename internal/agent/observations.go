@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"terminusai/internal/providers"
+)
+
+// SetObservationSummaryBytes sets the size above which an observation is
+// summarized in the transcript, with the full text saved to a file the agent
+// can read back (0 = default of 16000, negative = never summarize)
+func (a *Agent) SetObservationSummaryBytes(n int) {
+	a.observationSummaryBytes = n
+}
+
+// summaryThreshold returns the effective auto-summarize size, or 0 when disabled
+func (a *Agent) summaryThreshold() int {
+	switch {
+	case a.observationSummaryBytes < 0:
+		return 0
+	case a.observationSummaryBytes == 0:
+		return defaultObservationSummaryBytes
+	default:
+		return a.observationSummaryBytes
+	}
+}
+
+// keepRawObservation records the untruncated output of the running action, so
+// accountObservations sizes and saves what the action produced rather than the
+// part its handler kept
+func (a *Agent) keepRawObservation(raw string) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	a.rawObservation = raw
+}
+
+// takeRawObservation returns and clears the running action's untruncated output
+func (a *Agent) takeRawObservation() string {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	raw := a.rawObservation
+	a.rawObservation = ""
+	return raw
+}
+
+// limitObservation truncates s to the action's observation limit, keeping the
+// untruncated text for accountObservations
+func (a *Agent) limitObservation(action *AgentAction, s string, defaultLimit int) string {
+	limit := a.observationLimit(action, defaultLimit)
+	if len(s) > limit {
+		a.keepRawObservation(s)
+	}
+	return truncateString(s, limit)
+}
+
+// accountObservations records the size of the observations an action appended
+// after index before, and replaces oversized ones with a summary. The size is
+// that of the untruncated output when the handler kept it, which is also what
+// gets saved. Actions whose JSON asked for maxBytes explicitly are left alone:
+// the model wanted that much.
+func (a *Agent) accountObservations(action *AgentAction, transcript []providers.ChatMessage, before int) {
+	raw := a.takeRawObservation()
+	if before > len(transcript) {
+		return
+	}
+	for i := before; i < len(transcript); i++ {
+		msg := &transcript[i]
+		if msg.Role != "user" || !strings.HasPrefix(msg.Content, "observation:") {
+			continue
+		}
+		full := msg.Content
+		if raw != "" {
+			// The handler's truncated output follows the observation header
+			header, _, _ := strings.Cut(msg.Content, "\n")
+			full = header + "\n" + raw
+			raw = ""
+		}
+		a.addObservationBytes(action.Type, len(full))
+
+		threshold := a.summaryThreshold()
+		if threshold == 0 || len(full) <= threshold {
+			continue
+		}
+		if _, explicit := action.Params["maxBytes"]; explicit {
+			continue
+		}
+		path, err := a.saveObservation(action.Type, full)
+		if err != nil {
+			if a.verbose {
+				fmt.Printf("  ⎿  Failed to save full observation: %v\n", err)
+			}
+			continue
+		}
+		msg.Content = summarizeObservation(full, path, threshold)
+	}
+}
+
+// saveObservation writes the full text of an observation to the agent's
+// observation directory, created on first use
+func (a *Agent) saveObservation(actionType, content string) (string, error) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	if a.observationDir == "" {
+		dir, err := os.MkdirTemp("", "terminusai-observations-")
+		if err != nil {
+			return "", err
+		}
+		a.observationDir = dir
+	}
+	a.savedObservations++
+	path := filepath.Join(a.observationDir, fmt.Sprintf("%03d-%s.txt", a.savedObservations, actionType))
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// removeObservations deletes the saved observations once the task that
+// referred to them has finished
+func (a *Agent) removeObservations() {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	if a.observationDir == "" {
+		return
+	}
+	if err := os.RemoveAll(a.observationDir); err != nil && a.verbose {
+		fmt.Printf("  ⎿  Failed to remove saved observations: %v\n", err)
+	}
+	a.observationDir = ""
+	a.savedObservations = 0
+}
+
+// summarizeObservation keeps the observation header plus the first and last
+// lines of its body within roughly limit bytes, noting where the full text is
+func summarizeObservation(content, path string, limit int) string {
+	header, body, _ := strings.Cut(content, "\n")
+	lines := strings.Split(strings.TrimRight(body, "\n"), "\n")
+
+	budget := limit / 2
+	var head []string
+	used := 0
+	for _, line := range lines {
+		if used+len(line)+1 > budget {
+			break
+		}
+		head = append(head, line)
+		used += len(line) + 1
+	}
+	var tail []string
+	used = 0
+	for i := len(lines) - 1; i >= len(head); i-- {
+		if used+len(lines[i])+1 > budget {
+			break
+		}
+		tail = append([]string{lines[i]}, tail...)
+		used += len(lines[i]) + 1
+	}
+
+	var out strings.Builder
+	out.WriteString(header + "\n")
+	out.WriteString(fmt.Sprintf("[Summarized: %d bytes, %d lines. Full output saved to %s; use read_file with head/tail or grep on it to see the rest.]\n", len(content), len(lines), path))
+	out.WriteString(strings.Join(head, "\n"))
+	if omitted := len(lines) - len(head) - len(tail); omitted > 0 {
+		out.WriteString(fmt.Sprintf("\n... (%d lines omitted) ...\n", omitted))
+	} else if len(tail) > 0 {
+		out.WriteString("\n")
+	}
+	out.WriteString(strings.Join(tail, "\n"))
+	return out.String()
+}
@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestAccountObservations(t *testing.T) {
+	var body strings.Builder
+	for i := 1; i <= 500; i++ {
+		body.WriteString(fmt.Sprintf("line %d of a long listing\n", i))
+	}
+	big := "observation:list_files success\n" + body.String()
+
+	tests := []struct {
+		name       string
+		threshold  int
+		params     map[string]interface{}
+		summarized bool
+	}{
+		{"over threshold", 2000, nil, true},
+		{"under threshold", len(big) + 1, nil, false},
+		{"disabled", -1, nil, false},
+		{"explicit maxBytes", 2000, map[string]interface{}{"maxBytes": 50000}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAgent(t, t.TempDir())
+			a.SetObservationSummaryBytes(tt.threshold)
+			transcript := []providers.ChatMessage{
+				{Role: "assistant", Content: `{"type":"list_files"}`},
+				{Role: "user", Content: big},
+			}
+			a.accountObservations(&AgentAction{Type: "list_files", Params: tt.params}, transcript, 0)
+			if a.observationDir != "" {
+				defer os.RemoveAll(a.observationDir)
+			}
+
+			if a.observationBytes["list_files"] != len(big) {
+				t.Errorf("Expected %d observation bytes, got %d", len(big), a.observationBytes["list_files"])
+			}
+			got := transcript[1].Content
+			if !tt.summarized {
+				if got != big {
+					t.Errorf("Expected observation unchanged")
+				}
+				return
+			}
+
+			if len(got) > tt.threshold+400 {
+				t.Errorf("Expected summary near %d bytes, got %d", tt.threshold, len(got))
+			}
+			for _, want := range []string{"observation:list_files success\n", "line 1 of", "line 500 of", "lines omitted"} {
+				if !strings.Contains(got, want) {
+					t.Errorf("Expected summary to contain %q, got %q", want, got)
+				}
+			}
+			m := regexp.MustCompile(`saved to (\S+);`).FindStringSubmatch(got)
+			if m == nil {
+				t.Fatalf("Expected saved path in %q", got)
+			}
+			saved, err := os.ReadFile(m[1])
+			if err != nil || string(saved) != big {
+				t.Errorf("Expected full observation on disk, got %d bytes (%v)", len(saved), err)
+			}
+		})
+	}
+}
+
+func TestAccountObservationsUsesUntruncatedOutput(t *testing.T) {
+	dir := t.TempDir()
+	var body strings.Builder
+	for i := 1; i <= 2000; i++ {
+		body.WriteString(fmt.Sprintf("line %d of a long log\n", i))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.log"), []byte(body.String()), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	a := newTestAgent(t, dir)
+	defer a.removeObservations()
+
+	// read_file keeps 4000 bytes, well under the 16000 byte threshold
+	action := &AgentAction{Type: "read_file", Path: "app.log"}
+	var transcript []providers.ChatMessage
+	if err := a.handleReadFile(action, &transcript); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	a.accountObservations(action, transcript, 0)
+
+	full := "observation:read_file app.log\n" + body.String()
+	if a.observationBytes["read_file"] != len(full) {
+		t.Errorf("Expected %d observation bytes, got %d", len(full), a.observationBytes["read_file"])
+	}
+	got := transcript[1].Content
+	if !strings.Contains(got, "line 2000 of") {
+		t.Errorf("Expected the summary to end with the last line of the file, got %q", got)
+	}
+	m := regexp.MustCompile(`saved to (\S+);`).FindStringSubmatch(got)
+	if m == nil {
+		t.Fatalf("Expected saved path in %q", got)
+	}
+	if saved, err := os.ReadFile(m[1]); err != nil || string(saved) != full {
+		t.Errorf("Expected untruncated output on disk, got %d bytes (%v)", len(saved), err)
+	}
+}
+
+func TestRemoveObservations(t *testing.T) {
+	a := newTestAgent(t, t.TempDir())
+	path, err := a.saveObservation("shell", "observation:shell success\nfull output")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	dir := filepath.Dir(path)
+
+	a.removeObservations()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got %v", dir, err)
+	}
+	if a.observationDir != "" {
+		t.Errorf("Expected the next observation to get a new directory, got %q", a.observationDir)
+	}
+
+	// Nothing saved: nothing to do
+	a.removeObservations()
+}
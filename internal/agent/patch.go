@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hunkHeaderRegex matches a unified diff hunk header such as "@@ -3,4 +3,5 @@"
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// patchHunk is one @@ section of a unified diff
+type patchHunk struct {
+	OldStart, OldCount int
+	NewStart, NewCount int
+	Lines              []string // each line keeps its ' ', '-' or '+' prefix
+}
+
+// oldLines returns the lines the hunk expects to find in the file
+func (h patchHunk) oldLines() []string {
+	var lines []string
+	for _, line := range h.Lines {
+		if line[0] != '+' {
+			lines = append(lines, line[1:])
+		}
+	}
+	return lines
+}
+
+// newLines returns the lines the hunk leaves in their place
+func (h patchHunk) newLines() []string {
+	var lines []string
+	for _, line := range h.Lines {
+		if line[0] != '-' {
+			lines = append(lines, line[1:])
+		}
+	}
+	return lines
+}
+
+// parseUnifiedPatch parses the hunks of a single-file unified diff. File
+// headers ("---", "+++", "diff", "index") before the first hunk are ignored.
+func parseUnifiedPatch(patch string) ([]patchHunk, error) {
+	var hunks []patchHunk
+	var current *patchHunk
+	oldLeft, newLeft := 0, 0
+
+	for i, line := range splitLines(strings.ReplaceAll(patch, "\r\n", "\n")) {
+		if current != nil && (oldLeft > 0 || newLeft > 0) {
+			// Editors and models often strip the space from empty context lines
+			if line == "" {
+				line = " "
+			}
+			switch line[0] {
+			case ' ':
+				oldLeft--
+				newLeft--
+			case '-':
+				oldLeft--
+			case '+':
+				newLeft--
+			case '\\':
+				// "\ No newline at end of file"
+				continue
+			default:
+				return nil, fmt.Errorf("line %d: unexpected line inside hunk: %q", i+1, line)
+			}
+			if oldLeft < 0 || newLeft < 0 {
+				return nil, fmt.Errorf("line %d: hunk is longer than its header says", i+1)
+			}
+			current.Lines = append(current.Lines, line)
+			continue
+		}
+
+		if !strings.HasPrefix(line, "@@") {
+			continue
+		}
+		m := hunkHeaderRegex.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: invalid hunk header: %q", i+1, line)
+		}
+		hunk := patchHunk{OldCount: 1, NewCount: 1}
+		hunk.OldStart, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			hunk.OldCount, _ = strconv.Atoi(m[2])
+		}
+		hunk.NewStart, _ = strconv.Atoi(m[3])
+		if m[4] != "" {
+			hunk.NewCount, _ = strconv.Atoi(m[4])
+		}
+		hunks = append(hunks, hunk)
+		current = &hunks[len(hunks)-1]
+		oldLeft, newLeft = hunk.OldCount, hunk.NewCount
+	}
+
+	if current != nil && (oldLeft > 0 || newLeft > 0) {
+		return nil, fmt.Errorf("hunk %d is truncated: %d old and %d new lines missing", len(hunks), oldLeft, newLeft)
+	}
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("no @@ hunks found in patch")
+	}
+	return hunks, nil
+}
+
+// applyUnifiedPatch applies hunks to content in order. Each hunk is tried at
+// the line its header names and, if the file has shifted, at the nearest line
+// where all its context and removed lines match. Line endings and the trailing
+// newline of the original are preserved.
+func applyUnifiedPatch(content string, hunks []patchHunk) (string, error) {
+	eol := "\n"
+	if strings.Contains(content, "\r\n") {
+		eol = "\r\n"
+	}
+	trailingNewline := content == "" || strings.HasSuffix(content, "\n")
+	lines := splitLines(strings.ReplaceAll(content, "\r\n", "\n"))
+
+	// offset tracks how far earlier hunks moved the following lines
+	offset, floor := 0, 0
+	for i, hunk := range hunks {
+		old := hunk.oldLines()
+		want := hunk.OldStart - 1 + offset
+		if hunk.OldCount == 0 {
+			// Pure insertions name the line after which they go
+			want = hunk.OldStart + offset
+		}
+
+		at := findHunk(lines, old, want, floor)
+		if at < 0 {
+			return "", fmt.Errorf("hunk %d (@@ -%d,%d +%d,%d @@) does not match the file content", i+1, hunk.OldStart, hunk.OldCount, hunk.NewStart, hunk.NewCount)
+		}
+
+		replacement := hunk.newLines()
+		updated := make([]string, 0, len(lines)-len(old)+len(replacement))
+		updated = append(updated, lines[:at]...)
+		updated = append(updated, replacement...)
+		updated = append(updated, lines[at+len(old):]...)
+		lines = updated
+
+		offset += len(replacement) - len(old) + at - want
+		floor = at + len(replacement)
+	}
+
+	result := strings.Join(lines, eol)
+	if trailingNewline && len(lines) > 0 {
+		result += eol
+	}
+	return result, nil
+}
+
+// findHunk returns the line index nearest to want (and not before floor)
+// where old matches lines, or -1 when there is none
+func findHunk(lines, old []string, want, floor int) int {
+	matches := func(at int) bool {
+		if at < floor || at+len(old) > len(lines) {
+			return false
+		}
+		for j, line := range old {
+			if lines[at+j] != line {
+				return false
+			}
+		}
+		return true
+	}
+
+	for delta := 0; want-delta >= floor || want+delta <= len(lines); delta++ {
+		if matches(want - delta) {
+			return want - delta
+		}
+		if delta > 0 && matches(want+delta) {
+			return want + delta
+		}
+	}
+	return -1
+}
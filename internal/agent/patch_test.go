@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestApplyUnifiedPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		patch    string
+		expected string
+		err      string
+	}{
+		{
+			name:     "single hunk",
+			content:  "a\nb\nc\nd\n",
+			patch:    "--- f.txt\n+++ f.txt\n@@ -1,4 +1,4 @@\n a\n-b\n+B\n c\n d\n",
+			expected: "a\nB\nc\nd\n",
+		},
+		{
+			name:     "two hunks shift line numbers",
+			content:  "1\n2\n3\n4\n5\n6\n7\n8\n",
+			patch:    "@@ -1,2 +1,3 @@\n 1\n+1.5\n 2\n@@ -7,2 +8,1 @@\n 7\n-8\n",
+			expected: "1\n1.5\n2\n3\n4\n5\n6\n7\n",
+		},
+		{
+			name:     "hunk found at an offset",
+			content:  "x\ny\na\nb\nc\n",
+			patch:    "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			expected: "x\ny\na\nB\nc\n",
+		},
+		{
+			name:     "insertion into empty range",
+			content:  "a\nb\n",
+			patch:    "@@ -1,0 +2,1 @@\n+inserted\n",
+			expected: "a\ninserted\nb\n",
+		},
+		{
+			name:     "crlf preserved",
+			content:  "a\r\nb\r\n",
+			patch:    "@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+			expected: "a\r\nc\r\n",
+		},
+		{
+			name:     "empty context line without space",
+			content:  "a\n\nb\n",
+			patch:    "@@ -1,3 +1,3 @@\n a\n\n-b\n+c\n",
+			expected: "a\n\nc\n",
+		},
+		{
+			name:    "mismatching context",
+			content: "a\nb\nc\n",
+			patch:   "@@ -1,3 +1,3 @@\n a\n-x\n+y\n c\n",
+			err:     "hunk 1 (@@ -1,3 +1,3 @@) does not match",
+		},
+		{
+			name:    "no hunks",
+			content: "a\n",
+			patch:   "just replace everything",
+			err:     "no @@ hunks found",
+		},
+		{
+			name:    "truncated hunk",
+			content: "a\nb\n",
+			patch:   "@@ -1,2 +1,2 @@\n a\n",
+			err:     "truncated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks, err := parseUnifiedPatch(tt.patch)
+			var result string
+			if err == nil {
+				result, err = applyUnifiedPatch(tt.content, hunks)
+			}
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestHandlePatchFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		patch    string
+		expected string
+		content  string
+	}{
+		{"hunk applied", "", "@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three\n", "observation:patch_file success\nFile patched\nApplied 1 hunk(s)", "one\nTWO\nthree\n"},
+		{"mismatch rejected", "unified", "@@ -1,3 +1,3 @@\n one\n-zwei\n+TWO\n three\n", "observation:patch_file error\nhunk 1", "one\ntwo\nthree\n"},
+		{"full replace", "full", "replaced\n", "observation:patch_file success\nFile patched\nFile replaced", "replaced\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "f.txt")
+			os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0644)
+
+			a := newTestAgent(t, dir)
+			action := &AgentAction{Type: "patch_file", Path: "f.txt", Patch: tt.patch, Format: tt.format}
+			if err := validateAction(action); err != nil {
+				t.Fatalf("Expected valid action, got %v", err)
+			}
+			var transcript []providers.ChatMessage
+			if err := a.handlePatchFile(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if obs := transcript[1].Content; !strings.HasPrefix(obs, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, obs)
+			}
+			if data, _ := os.ReadFile(path); string(data) != tt.content {
+				t.Errorf("Expected file content %q, got %q", tt.content, string(data))
+			}
+		})
+	}
+
+	if err := validateAction(&AgentAction{Type: "patch_file", Path: "f.txt", Patch: "x", Format: "json"}); err == nil {
+		t.Errorf("Expected unknown format to be rejected")
+	}
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+
+package agent
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// unixSignals maps killSignals to their values
+var unixSignals = map[string]syscall.Signal{
+	"TERM": syscall.SIGTERM,
+	"INT":  syscall.SIGINT,
+	"HUP":  syscall.SIGHUP,
+	"QUIT": syscall.SIGQUIT,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"KILL": syscall.SIGKILL,
+}
+
+// killTreeOnCancel starts cmd in its own process group and makes cancelling
+// its context kill the whole group, so children of a shell don't linger
+func killTreeOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// signalProcess sends the named signal (see killSignals) to pid
+func (a *Agent) signalProcess(pid int, signal string) error {
+	sig, ok := unixSignals[signal]
+	if !ok {
+		return fmt.Errorf("unknown signal %q", signal)
+	}
+	if err := syscall.Kill(pid, sig); err != nil {
+		return fmt.Errorf("kill -%s %d: %w", signal, pid, err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package agent
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// killTreeOnCancel makes cancelling cmd's context kill its whole process tree,
+// so children of a shell don't linger
+func killTreeOnCancel(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+}
+
+// signalProcess stops pid with taskkill. Windows has no signals: KILL forces
+// the process to end, anything else asks it to close.
+func (a *Agent) signalProcess(pid int, signal string) error {
+	args := []string{"/PID", strconv.Itoa(pid)}
+	if signal == "KILL" {
+		args = append(args, "/F")
+	}
+	if output, err := a.command("taskkill", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
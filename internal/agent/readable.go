@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"html"
+	"mime"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlCommentRe = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlTitleRe   = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title\s*>`)
+	headingOpenRe = regexp.MustCompile(`(?i)<h([1-6])\b[^>]*>`)
+	listItemRe    = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	lineBreakRe   = regexp.MustCompile(`(?i)<br\s*/?>|</tr\s*>|</?(p|div|section|article|main|table|ul|ol|pre|blockquote|dl|dt|dd|figure|figcaption|h[1-6])\b[^>]*>`)
+	cellEndRe     = regexp.MustCompile(`(?i)</t[dh]\s*>`)
+	htmlTagRe     = regexp.MustCompile(`<[^>]*>`)
+	spaceRunRe    = regexp.MustCompile(`[ \t\x{00a0}]+`)
+
+	// Elements that never hold the readable text of a page
+	htmlNoiseRes = func() []*regexp.Regexp {
+		var res []*regexp.Regexp
+		for _, tag := range []string{"head", "script", "style", "noscript", "template", "svg", "iframe", "nav", "header", "footer", "aside", "form", "button"} {
+			res = append(res, regexp.MustCompile(`(?is)<`+tag+`\b[^>]*>.*?</`+tag+`\s*>`))
+		}
+		return res
+	}()
+)
+
+// isHTMLContentType reports whether a Content-Type header names an HTML document
+func isHTMLContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// extractReadableText returns the title and main text of an HTML page: scripts,
+// styles, navigation, headers and footers are dropped, the <article> or <main>
+// element is preferred when present, headings become "#" lines and list items
+// "- " lines. It is a best-effort text view, not an HTML parser.
+func extractReadableText(page string) (title, text string) {
+	page = htmlCommentRe.ReplaceAllString(page, "")
+	if m := htmlTitleRe.FindStringSubmatch(page); m != nil {
+		title = strings.TrimSpace(spaceRunRe.ReplaceAllString(html.UnescapeString(m[1]), " "))
+	}
+	for _, re := range htmlNoiseRes {
+		page = re.ReplaceAllString(page, "")
+	}
+	for _, tag := range []string{"article", "main", "body"} {
+		if inner, ok := elementContent(page, tag); ok {
+			page = inner
+			break
+		}
+	}
+
+	page = headingOpenRe.ReplaceAllStringFunc(page, func(open string) string {
+		level := int(headingOpenRe.FindStringSubmatch(open)[1][0] - '0')
+		return "\n\n" + strings.Repeat("#", level) + " "
+	})
+	page = listItemRe.ReplaceAllString(page, "\n- ")
+	page = lineBreakRe.ReplaceAllString(page, "\n")
+	page = cellEndRe.ReplaceAllString(page, " ")
+	page = htmlTagRe.ReplaceAllString(page, "")
+	page = html.UnescapeString(page)
+
+	// Tidy whitespace: single spaces within lines, at most one blank line
+	var lines []string
+	blank := true
+	for _, line := range strings.Split(page, "\n") {
+		line = strings.TrimSpace(spaceRunRe.ReplaceAllString(line, " "))
+		if line == "" || line == "-" {
+			if !blank {
+				lines = append(lines, "")
+			}
+			blank = true
+			continue
+		}
+		lines = append(lines, line)
+		blank = false
+	}
+	return title, strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// elementContent returns what lies between the first opening tag of an element
+// and its last closing tag
+func elementContent(page, tag string) (string, bool) {
+	lower := strings.ToLower(page)
+	start := -1
+	for from := 0; ; {
+		i := strings.Index(lower[from:], "<"+tag)
+		if i < 0 {
+			break
+		}
+		i += from
+		// Make sure this is <tag> or <tag ...>, not <tagname>
+		if next := i + len(tag) + 1; next < len(lower) && strings.ContainsRune("> \t\r\n/", rune(lower[next])) {
+			start = i
+			break
+		}
+		from = i + 1
+	}
+	if start < 0 {
+		return "", false
+	}
+	open := strings.Index(lower[start:], ">")
+	end := strings.LastIndex(lower, "</"+tag)
+	if open < 0 || end < start+open {
+		return "", false
+	}
+	return page[start+open+1 : end], true
+}
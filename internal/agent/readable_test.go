@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestExtractReadableText(t *testing.T) {
+	tests := []struct {
+		name          string
+		page          string
+		expectedTitle string
+		expectedText  string
+	}{
+		{
+			"strips scripts, styles and navigation",
+			`<html><head><title>My &amp; Page</title><style>body{}</style></head>
+<body><nav><a href="/">Home</a></nav><script>var x = "<p>";</script>
+<h1>Hello</h1><p>First   paragraph with <b>bold</b>&nbsp;text.</p><!-- hidden -->
+<footer>Copyright</footer></body></html>`,
+			"My & Page",
+			"# Hello\n\nFirst paragraph with bold text.",
+		},
+		{
+			"prefers article over sidebar",
+			`<body><aside>Ads</aside><div>Menu</div><article><h2>Post</h2><ul><li>one</li><li>two</li></ul></article></body>`,
+			"",
+			"## Post\n\n- one\n- two",
+		},
+		{
+			"table cells stay on one line",
+			`<main><table><tr><td>a</td><td>b</td></tr><tr><td>c</td><td>d</td></tr></table></main>`,
+			"",
+			"a b\nc d",
+		},
+		{
+			"fragment without body",
+			`Plain <em>text</em><br>next line`,
+			"",
+			"Plain text\nnext line",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, text := extractReadableText(tt.page)
+			if title != tt.expectedTitle {
+				t.Errorf("Expected title %q, got %q", tt.expectedTitle, title)
+			}
+			if text != tt.expectedText {
+				t.Errorf("Expected text %q, got %q", tt.expectedText, text)
+			}
+		})
+	}
+}
+
+func TestIsHTMLContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		expected    bool
+	}{
+		{"text/html; charset=utf-8", true},
+		{"application/xhtml+xml", true},
+		{"application/json", false},
+		{"text/plain", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			if got := isHTMLContentType(tt.contentType); got != tt.expected {
+				t.Errorf("Expected %v for %q, got %v", tt.expected, tt.contentType, got)
+			}
+		})
+	}
+}
+
+func TestHandleFetchText(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<html><head><title>Docs</title><script>track()</script></head><body><nav>Menu</nav><p>Install with go get.</p></body></html>`))
+		case "/data":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"a": "<b>1</b>"}`))
+		default:
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte{0x1f, 0x8b, 0, 0})
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		expected []string
+		absent   []string
+	}{
+		{"html is reduced to text", "/page", []string{"status=200", "Title: Docs", "Install with go get."}, []string{"track()", "Menu", "<p>"}},
+		{"json returned as-is", "/data", []string{"status=200", `{"a": "<b>1</b>"}`}, nil},
+		{"binary refused", "/bin", []string{"observation:fetch_text error", "binary content"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAgent(t, t.TempDir())
+			action := &AgentAction{Type: "fetch_text", URL: server.URL + tt.path}
+			if err := validateAction(action); err != nil {
+				t.Fatalf("Expected valid action, got %v", err)
+			}
+			var transcript []providers.ChatMessage
+			if err := a.handleFetchText(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			obs := transcript[1].Content
+			for _, want := range tt.expected {
+				if !strings.Contains(obs, want) {
+					t.Errorf("Expected %q in %q", want, obs)
+				}
+			}
+			for _, unwanted := range tt.absent {
+				if strings.Contains(obs, unwanted) {
+					t.Errorf("Expected no %q in %q", unwanted, obs)
+				}
+			}
+		})
+	}
+
+	if err := validateAction(&AgentAction{Type: "fetch_text", URL: "file:///etc/passwd"}); err == nil {
+		t.Errorf("Expected non-http url to be rejected")
+	}
+}
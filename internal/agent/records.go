@@ -0,0 +1,264 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"terminusai/internal/ui"
+)
+
+// appendRecord appends a single structured record to a CSV or JSONL file,
+// creating the file (and CSV header) when it does not exist yet
+func appendRecord(filePath, format string, record interface{}, columns []string) error {
+	info, statErr := os.Stat(filePath)
+	isNew := os.IsNotExist(statErr) || (statErr == nil && info.Size() == 0)
+
+	var buf bytes.Buffer
+	if !isNew {
+		// Make sure the new record starts on its own line
+		if endsWithoutNewline(filePath) {
+			buf.WriteString("\n")
+		}
+	}
+
+	switch format {
+	case "jsonl":
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+		buf.Write(data)
+		buf.WriteString("\n")
+	case "csv":
+		row, header, err := csvRow(record, columns)
+		if err != nil {
+			return err
+		}
+		w := csv.NewWriter(&buf)
+		if isNew && len(header) > 0 {
+			if err := w.Write(header); err != nil {
+				return err
+			}
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported record format: %s", format)
+	}
+
+	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(buf.Bytes())
+	return err
+}
+
+// csvRow converts a record into CSV fields. Objects are laid out by columns
+// (or their sorted keys) and also yield the header; arrays are written as-is.
+func csvRow(record interface{}, columns []string) ([]string, []string, error) {
+	switch rec := record.(type) {
+	case map[string]interface{}:
+		header := columns
+		if len(header) == 0 {
+			for key := range rec {
+				header = append(header, key)
+			}
+			sort.Strings(header)
+		}
+		row := make([]string, len(header))
+		for i, key := range header {
+			row[i] = csvField(rec[key])
+		}
+		return row, header, nil
+	case []interface{}:
+		row := make([]string, len(rec))
+		for i, value := range rec {
+			row[i] = csvField(value)
+		}
+		return row, columns, nil
+	default:
+		return nil, nil, fmt.Errorf("csv record must be an object or an array")
+	}
+}
+
+// csvField renders a single JSON value as a CSV cell
+func csvField(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	}
+}
+
+// endsWithoutNewline reports whether a non-empty file lacks a trailing newline
+func endsWithoutNewline(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return false
+	}
+
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return false
+	}
+	return last[0] != '\n'
+}
+
+// csvDelimiters are tried, in order of preference, when detecting a delimiter
+var csvDelimiters = []rune{',', '\t', ';'}
+
+// csvPreview is the start of a delimited file
+type csvPreview struct {
+	Delimiter rune
+	Header    []string
+	Rows      [][]string // At most maxRows
+	TotalRows int        // Data rows in the whole file
+}
+
+// parseDelimiter maps a delimiter option to its rune; "tab" and "\t" mean tab
+func parseDelimiter(s string) (rune, error) {
+	switch s {
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if s == "" || size != len(s) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("delimiter must be a single character other than a quote or newline")
+	}
+	return r, nil
+}
+
+// detectDelimiter picks the delimiter that splits the first records of data
+// into the most columns, consistently. Commas win ties and are the fallback.
+func detectDelimiter(data []byte) rune {
+	best, bestColumns := ',', 1
+	for _, delim := range csvDelimiters {
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.Comma = delim
+		columns := 0
+		for i := 0; i < 10; i++ {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				columns = 0 // Quoting that doesn't parse rules the delimiter out
+				break
+			}
+			if columns == 0 {
+				columns = len(record)
+			} else if len(record) != columns {
+				columns = 0
+				break
+			}
+		}
+		if columns > bestColumns {
+			best, bestColumns = delim, columns
+		}
+	}
+	return best
+}
+
+// previewCSV reads the header and first maxRows rows of delimited data,
+// detecting the delimiter when delim is 0. Quoted fields may hold delimiters,
+// quotes and newlines; rows with a different number of fields are kept.
+func previewCSV(data []byte, delim rune, maxRows int) (*csvPreview, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // UTF-8 BOM
+	if delim == 0 {
+		delim = detectDelimiter(data)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = delim
+	reader.FieldsPerRecord = -1
+	preview := &csvPreview{Delimiter: delim}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if preview.Header == nil {
+			preview.Header = record
+			continue
+		}
+		preview.TotalRows++
+		if len(preview.Rows) < maxRows {
+			preview.Rows = append(preview.Rows, record)
+		}
+	}
+	if preview.Header == nil {
+		return nil, fmt.Errorf("file is empty")
+	}
+	return preview, nil
+}
+
+// String renders the preview as a compact pipe-separated table for the
+// model; newlines and pipes inside cells are escaped so rows stay one line
+func (p *csvPreview) String() string {
+	name := map[rune]string{',': "comma", '\t': "tab", ';': "semicolon"}[p.Delimiter]
+	if name == "" {
+		name = strconv.QuoteRune(p.Delimiter)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Delimiter: %s; %d column(s), %d row(s)", name, len(p.Header), p.TotalRows))
+	if len(p.Rows) < p.TotalRows {
+		sb.WriteString(fmt.Sprintf(", showing the first %d", len(p.Rows)))
+	}
+	sb.WriteString("\n\n")
+
+	writeRow := func(cells []string) {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = strings.NewReplacer("\r\n", `\n`, "\n", `\n`, "|", `\|`).Replace(cell)
+		}
+		sb.WriteString(strings.Join(escaped, " | "))
+		sb.WriteString("\n")
+	}
+	writeRow(p.Header)
+	for _, row := range p.Rows {
+		writeRow(row)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// table converts the preview for display
+func (p *csvPreview) table() *ui.Table {
+	return &ui.Table{Headers: p.Header, Rows: p.Rows}
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAppendRecordCSV(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "out.csv")
+
+	records := []map[string]interface{}{
+		{"name": "alpha", "note": "has, comma", "count": float64(1)},
+		{"name": "beta", "note": "says \"hi\"", "count": float64(2)},
+	}
+	for _, record := range records {
+		if err := appendRecord(filePath, "csv", record, []string{"name", "count", "note"}); err != nil {
+			t.Fatalf("appendRecord failed: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	expected := "name,count,note\nalpha,1,\"has, comma\"\nbeta,2,\"says \"\"hi\"\"\"\n"
+	if string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(data))
+	}
+}
+
+func TestAppendRecordJSONL(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "out.jsonl")
+
+	// Existing content without a trailing newline must not be merged with the record
+	if err := os.WriteFile(filePath, []byte(`{"a":1}`), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if err := appendRecord(filePath, "jsonl", map[string]interface{}{"b": "two"}, nil); err != nil {
+		t.Fatalf("appendRecord failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	expected := "{\"a\":1}\n{\"b\":\"two\"}\n"
+	if string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(data))
+	}
+}
+
+func TestAppendRecordInvalid(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "out.csv")
+
+	if err := appendRecord(filePath, "csv", "plain string", nil); err == nil {
+		t.Errorf("Expected error for scalar csv record")
+	}
+	if err := appendRecord(filePath, "xml", map[string]interface{}{}, nil); err == nil {
+		t.Errorf("Expected error for unsupported format")
+	}
+}
+
+func TestParseCSVComma(t *testing.T) {
+	dir := t.TempDir()
+	content := "id,name,notes\n1,alpha,plain\n2,beta,\"has, comma and\nline break\"\n3,gamma,\"says \"\"hi\"\" | ok\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "items.csv"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	a := newTestAgent(t, dir)
+
+	observation := runActions(t, a, `{"type":"parse","parseType":"csv","path":"items.csv"}`)
+	expected := "observation:parse success\nDelimiter: comma; 3 column(s), 3 row(s)\n\n" +
+		"id | name | notes\n" +
+		"1 | alpha | plain\n" +
+		`2 | beta | has, comma and\nline break` + "\n" +
+		`3 | gamma | says "hi" \| ok`
+	if observation != expected {
+		t.Errorf("Expected %q, got %q", expected, observation)
+	}
+
+	observation = runActions(t, a, `{"type":"parse","parseType":"csv","path":"items.csv","maxRows":1}`)
+	if !strings.Contains(observation, "3 row(s), showing the first 1\n") || strings.Contains(observation, "beta") {
+		t.Errorf("Expected only the first row, got %q", observation)
+	}
+}
+
+func TestParseCSVTab(t *testing.T) {
+	dir := t.TempDir()
+	content := "host\tport\tcomment\nweb\t443\t\"tls, public\"\ndb\t5432\tinternal\n"
+	if err := os.WriteFile(filepath.Join(dir, "hosts.tsv"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write TSV: %v", err)
+	}
+	a := newTestAgent(t, dir)
+
+	expected := "observation:parse success\nDelimiter: tab; 3 column(s), 2 row(s)\n\n" +
+		"host | port | comment\n" +
+		"web | 443 | tls, public\n" +
+		"db | 5432 | internal"
+	for _, action := range []string{
+		`{"type":"parse","parseType":"csv","path":"hosts.tsv"}`,
+		`{"type":"parse","parseType":"csv","path":"hosts.tsv","delimiter":"tab"}`,
+	} {
+		if observation := runActions(t, a, action); observation != expected {
+			t.Errorf("%s: expected %q, got %q", action, expected, observation)
+		}
+	}
+
+	if _, err := parseAgentAction(`{"type":"parse","parseType":"csv","path":"hosts.tsv","delimiter":"::"}`); err == nil {
+		t.Errorf("Expected a multi-character delimiter to be rejected")
+	}
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected rune
+	}{
+		{"a,b,c\n1,2,3\n", ','},
+		{"a\tb\n1\t2\n", '\t'},
+		{"a;b;c\n1;2,5;3\n", ';'},
+		{"single\nvalue\n", ','},
+	}
+	for _, tt := range tests {
+		if got := detectDelimiter([]byte(tt.data)); got != tt.expected {
+			t.Errorf("detectDelimiter(%q): expected %q, got %q", tt.data, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// defaultMaxRedirects matches the limit net/http applies on its own
+const defaultMaxRedirects = 10
+
+// redirectPolicy returns a CheckRedirect function for http_request. Redirects
+// are followed unless followRedirects is false, up to maxRedirects hops, and
+// the Authorization header is dropped once a hop leaves the original host.
+func redirectPolicy(action *AgentAction) func(req *http.Request, via []*http.Request) error {
+	follow := action.FollowRedirects == nil || *action.FollowRedirects
+	limit := defaultMaxRedirects
+	if action.MaxRedirects != nil {
+		limit = *action.MaxRedirects
+	}
+
+	return func(req *http.Request, via []*http.Request) error {
+		if !follow {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > limit {
+			return fmt.Errorf("stopped after %d redirects", limit)
+		}
+		if req.URL.Host != via[0].URL.Host {
+			req.Header.Del("Authorization")
+		}
+		return nil
+	}
+}
+
+// redirectNote describes where a response came from when it isn't the
+// requested URL: the final URL after followed redirects, or the Location of a
+// redirect that was not followed. It is empty otherwise.
+func redirectNote(resp *http.Response, requested *url.URL) string {
+	if resp.Request != nil && resp.Request.URL.String() != requested.String() {
+		return fmt.Sprintf(" final_url=%s", resp.Request.URL)
+	}
+	if location := resp.Header.Get("Location"); location != "" && resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		return fmt.Sprintf(" location=%s", location)
+	}
+	return ""
+}
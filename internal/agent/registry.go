@@ -0,0 +1,164 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"terminusai/internal/policy"
+	"terminusai/internal/providers"
+)
+
+// errUnknownAction is returned by validateAction for types that are neither
+// built in nor registered
+var errUnknownAction = errors.New("unknown action type")
+
+// CustomAction is an action type provided by code outside this package, e.g.
+// a project-specific deploy or test runner.
+//
+// The model invokes it like any built-in action: {"type": "<Type>", ...params}.
+// Run receives the action's JSON fields and returns the observation text. The
+// agent takes care of the transcript: on success the model sees
+// "observation:<type> success" followed by the text (truncated to the usual
+// observation limit, which maxBytes overrides); when Run returns an error it
+// sees "observation:<type> error" and the message, and the task continues.
+type CustomAction struct {
+	Type        string // Action type, e.g. "deploy"
+	Usage       string // Tool line for the system prompt, e.g. `deploy { env: string } -> deploy the app`
+	Description string // Shown in the UI and approval prompt
+
+	// RequiresApproval asks the user before Run, like shell commands
+	RequiresApproval bool
+
+	// Validate checks the parameters before the action is accepted (optional)
+	Validate func(params map[string]interface{}) error
+
+	// Run performs the action
+	Run func(req ActionRequest) (string, error)
+}
+
+// ActionRequest is passed to CustomAction.Run
+type ActionRequest struct {
+	Context    context.Context // Cancelled when the user interrupts the action
+	WorkingDir string
+	Params     map[string]interface{} // All fields of the action JSON, including "type"
+	Env        []string               // Environment for child processes (nil = process environment)
+}
+
+var (
+	customActionsMu sync.RWMutex
+	customActions   = make(map[string]CustomAction)
+)
+
+// RegisterAction adds a custom action type for all agents. It fails when the
+// type is already built in or registered. Register actions before starting tasks.
+func RegisterAction(def CustomAction) error {
+	if def.Type == "" || def.Run == nil {
+		return fmt.Errorf("custom action needs a type and a Run function")
+	}
+	if _, ok := lookupAction(def.Type); ok {
+		return fmt.Errorf("action %s is already registered", def.Type)
+	}
+	if err := validateAction(&AgentAction{Type: def.Type}); !errors.Is(err, errUnknownAction) {
+		return fmt.Errorf("action %s is built in", def.Type)
+	}
+
+	customActionsMu.Lock()
+	defer customActionsMu.Unlock()
+	customActions[def.Type] = def
+	return nil
+}
+
+// unregisterAction removes a custom action (used by tests)
+func unregisterAction(actionType string) {
+	customActionsMu.Lock()
+	defer customActionsMu.Unlock()
+	delete(customActions, actionType)
+}
+
+// lookupAction returns the custom action registered for a type
+func lookupAction(actionType string) (CustomAction, bool) {
+	customActionsMu.RLock()
+	defer customActionsMu.RUnlock()
+	def, ok := customActions[actionType]
+	return def, ok
+}
+
+// systemPrompt returns SystemPrompt followed by the usage of registered actions
+func systemPrompt() string {
+	customActionsMu.RLock()
+	defer customActionsMu.RUnlock()
+	if len(customActions) == 0 {
+		return SystemPrompt
+	}
+
+	types := make([]string, 0, len(customActions))
+	for t := range customActions {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	var sb strings.Builder
+	sb.WriteString(SystemPrompt)
+	sb.WriteString("\n\nProject-specific tools (same output format):\n")
+	for _, t := range types {
+		usage := customActions[t].Usage
+		if usage == "" {
+			usage = t + " {}"
+		}
+		sb.WriteString("- " + usage + "\n")
+	}
+	return sb.String()
+}
+
+// handleCustomAction runs a registered action and records its observation
+func (a *Agent) handleCustomAction(def CustomAction, action *AgentAction, transcript *[]providers.ChatMessage) error {
+	description := def.Description
+	if description == "" {
+		description = def.Type
+	}
+	paramsJSON, _ := json.Marshal(action.Params)
+	actionUI := a.display.ShowAction(description, string(paramsJSON), false)
+
+	if def.RequiresApproval {
+		decision, err := a.policyStore.Approve(def.Type, fmt.Sprintf("%s %s", def.Type, paramsJSON), description)
+		if err != nil {
+			a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+			return err
+		}
+		if decision == policy.DecisionNever || decision == policy.DecisionSkip {
+			a.display.UpdateAction(actionUI, "skipped", []string{"User declined"})
+			*transcript = append(*transcript,
+				providers.ChatMessage{Role: "assistant", Content: string(paramsJSON)},
+				providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:%s skipped by user", def.Type)},
+			)
+			return nil
+		}
+	}
+
+	output, err := def.Run(ActionRequest{
+		Context:    a.actionContext(),
+		WorkingDir: a.workingDir,
+		Params:     action.Params,
+		Env:        a.environ(),
+	})
+	if err != nil {
+		a.display.UpdateAction(actionUI, "failed", []string{err.Error()})
+		*transcript = append(*transcript,
+			providers.ChatMessage{Role: "assistant", Content: string(paramsJSON)},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:%s error\n%s", def.Type, err.Error())},
+		)
+		return nil
+	}
+
+	a.display.UpdateAction(actionUI, "completed", []string{truncateString(output, 200)})
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(paramsJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:%s success\n%s", def.Type, a.limitObservation(action, output, 4000))},
+	)
+	return nil
+}
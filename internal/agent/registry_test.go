@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestRegisterAction(t *testing.T) {
+	deploy := CustomAction{
+		Type:  "test_deploy",
+		Usage: "test_deploy { env: string } -> deploy the app",
+		Validate: func(params map[string]interface{}) error {
+			if env, _ := params["env"].(string); env == "" {
+				return fmt.Errorf("env is required")
+			}
+			return nil
+		},
+		Run: func(req ActionRequest) (string, error) {
+			if req.Params["env"] == "broken" {
+				return "", errors.New("deploy failed")
+			}
+			return fmt.Sprintf("deployed to %s", req.Params["env"]), nil
+		},
+	}
+	if err := RegisterAction(deploy); err != nil {
+		t.Fatalf("Expected registration to succeed, got %v", err)
+	}
+	t.Cleanup(func() { unregisterAction("test_deploy") })
+
+	t.Run("rejects duplicates and built-ins", func(t *testing.T) {
+		if err := RegisterAction(deploy); err == nil {
+			t.Errorf("Expected duplicate registration to fail")
+		}
+		if err := RegisterAction(CustomAction{Type: "shell", Run: deploy.Run}); err == nil {
+			t.Errorf("Expected built-in type to be rejected")
+		}
+	})
+
+	t.Run("prompt lists the action", func(t *testing.T) {
+		if !strings.Contains(systemPrompt(), deploy.Usage) {
+			t.Errorf("Expected system prompt to include %q", deploy.Usage)
+		}
+	})
+
+	t.Run("parse uses the validator", func(t *testing.T) {
+		if _, err := parseAgentAction(`{"type":"test_deploy"}`); err == nil {
+			t.Errorf("Expected missing env to be rejected")
+		}
+		action, err := parseAgentAction(`{"type":"test_deploy","env":"staging"}`)
+		if err != nil {
+			t.Fatalf("Expected valid action, got %v", err)
+		}
+		if action.Params["env"] != "staging" {
+			t.Errorf("Expected params to carry env, got %v", action.Params)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		env      string
+		expected string
+	}{
+		{"success observation", "staging", "observation:test_deploy success\ndeployed to staging"},
+		{"error observation", "broken", "observation:test_deploy error\ndeploy failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAgent(t, t.TempDir())
+			action, err := parseAgentAction(fmt.Sprintf(`{"type":"test_deploy","env":%q}`, tt.env))
+			if err != nil {
+				t.Fatalf("Expected valid action, got %v", err)
+			}
+
+			var transcript []providers.ChatMessage
+			if err := a.executeAction(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(transcript) != 2 {
+				t.Fatalf("Expected 2 messages, got %d", len(transcript))
+			}
+			if transcript[1].Content != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, transcript[1].Content)
+			}
+		})
+	}
+}
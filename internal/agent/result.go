@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"encoding/json"
+	"sort"
+
+	"terminusai/internal/ui"
+)
+
+// TaskResult is the outcome the agent reported with the done action, for
+// scripts and server clients. Result is the human-readable message.
+type TaskResult struct {
+	Status    string                 `json:"status"` // success, failure or partial
+	Result    string                 `json:"result"`
+	Summary   string                 `json:"summary,omitempty"`
+	Artifacts []string               `json:"artifacts,omitempty"` // Files created or changed
+	Outputs   map[string]interface{} `json:"outputs,omitempty"`   // Values the task computed
+}
+
+// newTaskResult builds the result of a done action
+func newTaskResult(action *AgentAction) *TaskResult {
+	result := &TaskResult{
+		Status:    action.Status,
+		Result:    action.Result,
+		Summary:   action.Summary,
+		Artifacts: action.Artifacts,
+		Outputs:   action.Outputs,
+	}
+	if result.Status == "" {
+		result.Status = "success"
+	}
+	if result.Result == "" {
+		result.Result = "Task completed successfully"
+	}
+	return result
+}
+
+// Result returns the outcome of the last RunTask, or nil when the agent
+// stopped before calling done
+func (a *Agent) Result() *TaskResult {
+	return a.result
+}
+
+// showTaskResult prints the structured part of a result below the result message
+func showTaskResult(result *TaskResult) {
+	if result.Status != "success" {
+		ui.Warning.Printf("  ⎿  Status: %s\n", result.Status)
+	}
+	if result.Summary != "" {
+		ui.Muted.Printf("  ⎿  %s\n", result.Summary)
+	}
+	for _, artifact := range result.Artifacts {
+		ui.Muted.Printf("  ⎿  Artifact: %s\n", artifact)
+	}
+
+	keys := make([]string, 0, len(result.Outputs))
+	for key := range result.Outputs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, _ := json.Marshal(result.Outputs[key])
+		ui.Muted.Printf("  ⎿  %s = %s\n", key, value)
+	}
+}
@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"reflect"
+	"testing"
+
+	"terminusai/internal/ui"
+)
+
+func TestRunTaskResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		expected *TaskResult
+	}{
+		{"plain result", `{"type": "done", "result": "All good"}`, &TaskResult{Status: "success", Result: "All good"}},
+		{"empty result", `{"type": "done"}`, &TaskResult{Status: "success", Result: "Task completed successfully"}},
+		{"structured", `{"type": "done", "result": "Bumped version", "status": "partial", "summary": "tests not run", "artifacts": ["go.mod"], "outputs": {"version": "1.2.0", "files": 1}}`,
+			&TaskResult{Status: "partial", Result: "Bumped version", Summary: "tests not run", Artifacts: []string{"go.mod"}, Outputs: map[string]interface{}{"version": "1.2.0", "files": float64(1)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAgentWithProvider(t, t.TempDir(), &stubProvider{responses: []string{tt.response}})
+			a.SetAsker(func(string) (string, error) { return "", nil })
+			var events []ui.Event
+			a.SetEventSink(func(e ui.Event) { events = append(events, e) })
+
+			if err := a.RunTask("test"); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(a.Result(), tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, a.Result())
+			}
+			last := events[len(events)-1]
+			if last.Type != "result" || last.Data != a.Result() {
+				t.Errorf("Expected result event carrying the task result, got %+v", last)
+			}
+		})
+	}
+
+	if _, err := parseAgentAction(`{"type": "done", "result": "x", "status": "maybe"}`); err == nil {
+		t.Errorf("Expected unknown status to be rejected")
+	}
+}
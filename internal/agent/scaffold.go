@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"terminusai/internal/common"
+)
+
+// placeholderRe matches {{name}} (spaces inside the braces allowed)
+var placeholderRe = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+// DefaultTemplatesDir returns the directory from_template looks in for named templates
+func DefaultTemplatesDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, common.ConfigDirName, "templates"), nil
+}
+
+// SetTemplatesDir sets where from_template finds named templates (empty = DefaultTemplatesDir)
+func (a *Agent) SetTemplatesDir(dir string) {
+	a.templatesDir = dir
+}
+
+// resolveTemplate finds a template: a bare name is looked up in the templates
+// directory (with or without a .tmpl extension), anything else is a path
+// relative to the working directory
+func (a *Agent) resolveTemplate(name string) (string, error) {
+	if !filepath.IsAbs(name) && !strings.ContainsAny(name, `/\`) {
+		dir := a.templatesDir
+		if dir == "" {
+			dir, _ = DefaultTemplatesDir()
+		}
+		if dir != "" {
+			for _, candidate := range []string{name, name + ".tmpl"} {
+				path := filepath.Join(dir, candidate)
+				if info, err := os.Stat(path); err == nil && !info.IsDir() {
+					return path, nil
+				}
+			}
+		}
+	}
+
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(a.workingDir, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("template %s not found in the templates directory or working directory", name)
+	}
+	return path, nil
+}
+
+// renderTemplate substitutes {{placeholder}} with vars, failing when any
+// placeholder has no value
+func renderTemplate(text string, vars map[string]interface{}) (string, error) {
+	missing := make(map[string]bool)
+	rendered := placeholderRe.ReplaceAllStringFunc(text, func(m string) string {
+		name := placeholderRe.FindStringSubmatch(m)[1]
+		value, ok := vars[name]
+		if !ok {
+			missing[name] = true
+			return m
+		}
+		return fmt.Sprint(value)
+	})
+
+	if len(missing) > 0 {
+		var names []string
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("no value in vars for: %s", strings.Join(names, ", "))
+	}
+	return rendered, nil
+}
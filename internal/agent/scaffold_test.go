@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		vars     map[string]interface{}
+		expected string
+		err      string
+	}{
+		{"substitutes", "type {{Name}} struct{} // {{ Name }} v{{version}}", map[string]interface{}{"Name": "Widget", "version": float64(2)}, "type Widget struct{} // Widget v2", ""},
+		{"no placeholders", "plain text", nil, "plain text", ""},
+		{"missing vars", "{{a}} {{b}} {{a}}", map[string]interface{}{}, "", "no value in vars for: a, b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderTemplate(tt.text, tt.vars)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("Expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil || got != tt.expected {
+				t.Errorf("Expected %q, got %q (%v)", tt.expected, got, err)
+			}
+		})
+	}
+}
+
+func TestHandleFromTemplate(t *testing.T) {
+	dir := t.TempDir()
+	templates := t.TempDir()
+	if err := os.WriteFile(filepath.Join(templates, "component.tmpl"), []byte("export const {{name}} = () => null;\n"), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	a := newTestAgent(t, dir)
+	a.SetTemplatesDir(templates)
+
+	var transcript []providers.ChatMessage
+	action := &AgentAction{Type: "from_template", Template: "component", Dest: "src/Button.js", Vars: map[string]interface{}{"name": "Button"}}
+	if err := a.handleFromTemplate(action, &transcript); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if obs := transcript[1].Content; !strings.HasPrefix(obs, "observation:from_template success") {
+		t.Fatalf("Expected success, got %q", obs)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "src", "Button.js"))
+	if err != nil || string(content) != "export const Button = () => null;\n" {
+		t.Errorf("Expected rendered file, got %q (%v)", content, err)
+	}
+
+	// Existing destinations are not replaced without overwrite
+	transcript = nil
+	if err := a.handleFromTemplate(action, &transcript); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if obs := transcript[1].Content; !strings.Contains(obs, "already exists") {
+		t.Errorf("Expected existing destination to be refused, got %q", obs)
+	}
+}
@@ -2,118 +2,73 @@ package agent
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 	"regexp"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
-// performFileSearch performs the actual file search with regex
-func performFileSearch(pattern, searchPath string, fileTypes []string, caseSensitive bool, maxResults int, workingDir string) ([]SearchResult, error) {
-	// Compile regex pattern
-	var regex *regexp.Regexp
-	var err error
-
-	if caseSensitive {
-		regex, err = regexp.Compile(pattern)
-	} else {
-		regex, err = regexp.Compile("(?i)" + pattern)
-	}
-
-	if err != nil {
-		return nil, fmt.Errorf("invalid regex pattern: %w", err)
-	}
-
-	// Create file type map for quick lookup
-	var typeMap map[string]bool
-	searchAllFiles := len(fileTypes) == 0
-
-	if !searchAllFiles {
-		typeMap = make(map[string]bool)
-		for _, ext := range fileTypes {
-			typeMap["."+ext] = true
-		}
-	}
-
-	var results []SearchResult
-
-	// Use working directory if provided
-	baseDir := workingDir
-	if baseDir == "" {
-		if wd, err := os.Getwd(); err == nil {
-			baseDir = wd
-		} else {
-			baseDir = "."
-		}
-	}
-
-	base := filepath.Join(baseDir, searchPath)
-	if !filepath.IsAbs(base) {
-		abs, err := filepath.Abs(base)
-		if err != nil {
-			return nil, fmt.Errorf("failed to resolve path: %w", err)
-		}
-		base = abs
-	}
-
-	err = filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip files we can't access
-		}
+// lineMatch is a line of a file matched by a search
+type lineMatch struct {
+	num  int // 1-based
+	line string
+}
 
-		if info.IsDir() {
-			// Skip common heavy directories
-			dirName := filepath.Base(path)
-			if dirName == "node_modules" || dirName == ".git" || dirName == ".venv" ||
-				dirName == "__pycache__" || dirName == "dist" || dirName == "build" ||
-				dirName == "target" || dirName == "coverage" {
-				return filepath.SkipDir
+// matchFileLines returns up to maxMatches lines of content matching regex. The
+// match runs in a goroutine with a deadline so one file can't stall a search:
+// Go's RE2 engine never backtracks catastrophically, but huge files with long
+// lines or heavy patterns can still be slow. On timeout no matches are returned
+// and timedOut is set; the goroutine stops at the next line.
+func matchFileLines(regex *regexp.Regexp, content []byte, maxMatches int, timeout time.Duration) (matches []lineMatch, timedOut bool) {
+	var stop atomic.Bool
+	result := make(chan []lineMatch, 1)
+
+	go func() {
+		var found []lineMatch
+		for i, line := range strings.Split(string(content), "\n") {
+			if stop.Load() {
+				break
 			}
-			return nil
-		}
-
-		// Check file extension if we have type restrictions
-		if !searchAllFiles {
-			ext := filepath.Ext(path)
-			if !typeMap[ext] {
-				return nil
+			if !regex.MatchString(line) {
+				continue
 			}
-		}
-
-		// Skip large files
-		if info.Size() > maxFileSize {
-			return nil
-		}
-
-		// Read and search file
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return nil // Skip files we can't read
-		}
-
-		lines := strings.Split(string(content), "\n")
-		for lineNum, line := range lines {
-			if regex.MatchString(line) {
-				relPath, _ := filepath.Rel(base, path)
-				results = append(results, SearchResult{
-					File:    relPath,
-					LineNum: lineNum + 1,
-					Line:    line,
-				})
-
-				if len(results) >= maxResults {
-					return fmt.Errorf("max results reached")
-				}
+			found = append(found, lineMatch{num: i + 1, line: line})
+			if len(found) >= maxMatches {
+				break
 			}
 		}
-
-		return nil
-	})
-
-	if err != nil && err.Error() != "max results reached" {
-		return results, err
+		result <- found
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case matches = <-result:
+		return matches, false
+	case <-timer.C:
+		stop.Store(true)
+		return nil, true
 	}
+}
 
-	return results, nil
+// compileGrepPattern compiles a grep pattern, quoting it first when regex is
+// explicitly false so that e.g. "a.b" only matches a literal "a.b"; an unset
+// regex keeps the pattern a regular expression
+func compileGrepPattern(pattern string, regex *bool, caseSensitive bool) (*regexp.Regexp, error) {
+	if regex != nil && !*regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
 }
 
+// skippedFilesNote lists files whose matching timed out, for the observation
+func skippedFilesNote(skipped []string) string {
+	if len(skipped) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\nSkipped %d file(s) that took longer than %v to search (results may be partial): %s",
+		len(skipped), searchFileTimeout, strings.Join(skipped, ", "))
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchFileLines(t *testing.T) {
+	content := []byte("alpha\nbeta\nalphabet\ngamma\n")
+
+	tests := []struct {
+		name       string
+		pattern    string
+		maxMatches int
+		expected   []lineMatch
+	}{
+		{"all matches", "^alpha", 10, []lineMatch{{1, "alpha"}, {3, "alphabet"}}},
+		{"stops at max", "a", 2, []lineMatch{{1, "alpha"}, {2, "beta"}}},
+		{"no matches", "delta", 10, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, timedOut := matchFileLines(regexp.MustCompile(tt.pattern), content, tt.maxMatches, time.Second)
+			if timedOut {
+				t.Fatalf("Expected matching to finish")
+			}
+			if len(matches) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, matches)
+			}
+			for i := range matches {
+				if matches[i] != tt.expected[i] {
+					t.Errorf("Expected %v, got %v", tt.expected[i], matches[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMatchFileLinesTimeout(t *testing.T) {
+	line := strings.Repeat("ab", 2000) + "\n"
+	content := []byte("abc match\n" + strings.Repeat(line, 5000))
+	regex := regexp.MustCompile(`(a|b|c)*(x|y|z)+(a|b)*$|match`)
+
+	start := time.Now()
+	matches, timedOut := matchFileLines(regex, content, 100, time.Millisecond)
+	if !timedOut {
+		t.Fatalf("Expected matching to time out")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Expected to give up quickly, took %v", time.Since(start))
+	}
+	if matches != nil {
+		t.Errorf("Expected no matches from a timed-out file, got %v", matches)
+	}
+}
+
+func TestCompileGrepPattern(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name          string
+		pattern       string
+		regex         *bool
+		caseSensitive bool
+		line          string
+		expected      bool
+	}{
+		{"regex dot matches any character", "a.b", &yes, false, "axb", true},
+		{"unset regex stays a regex", "a.b", nil, false, "axb", true},
+		{"literal dot needs a dot", "a.b", &no, false, "axb", false},
+		{"literal matches itself", "a.b", &no, false, "see a.b here", true},
+		{"literal brackets", "f(x)[0]", &no, false, "y := f(x)[0]", true},
+		{"literal ignores case by default", "A.B", &no, false, "a.b", true},
+		{"literal case sensitive", "A.B", &no, true, "a.b", false},
+		{"regex ignores case by default", "^A.b$", &yes, false, "axB", true},
+		{"regex case sensitive", "^A.b$", &yes, true, "axB", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := compileGrepPattern(tt.pattern, tt.regex, tt.caseSensitive)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := re.MatchString(tt.line); got != tt.expected {
+				t.Errorf("Expected match=%v for %q in %q, got %v", tt.expected, tt.pattern, tt.line, got)
+			}
+		})
+	}
+
+	// Literal mode never fails on regex syntax
+	if _, err := compileGrepPattern("a(b", &no, false); err != nil {
+		t.Errorf("Expected literal pattern to compile, got %v", err)
+	}
+	if _, err := compileGrepPattern("a(b", &yes, false); err == nil {
+		t.Error("Expected an invalid regex to fail")
+	}
+}
@@ -0,0 +1,296 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// errShellSessionExited is returned when the session's shell process dies
+var errShellSessionExited = errors.New("shell session exited")
+
+// errShellTimeout is returned when a command runs past its timeout
+var errShellTimeout = errors.New("command did not finish")
+
+// shellExitError reports a non-zero exit status from a command run in a session
+type shellExitError struct {
+	code int
+}
+
+func (e *shellExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// shellSession is a long-lived shell process that commands are piped to, so
+// cd, exported variables and activated virtualenvs carry over between actions.
+// After each command the shell prints a marker line carrying the exit status,
+// which tells the reader where the command's output ends.
+type shellSession struct {
+	shell  string // bash, cmd or powershell
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan string // Merged stdout/stderr, one line at a time; closed when the shell exits
+	marker string
+	cancel context.CancelFunc // Kills the shell's process tree
+}
+
+// startShellSession starts a shell reading commands from stdin. The shell gets
+// its own process group, so closing the session also kills what it started.
+func startShellSession(shell, dir string, env []string) (*shellSession, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var cmd *exec.Cmd
+	switch shell {
+	case "bash":
+		cmd = exec.CommandContext(ctx, "bash", "--noprofile", "--norc")
+	case "cmd":
+		cmd = exec.CommandContext(ctx, "cmd", "/Q")
+	default:
+		cmd = exec.CommandContext(ctx, "powershell.exe", "-NoLogo", "-NoProfile", "-NonInteractive", "-Command", "-")
+	}
+	killTreeOnCancel(cmd)
+	cmd.Dir = dir
+	cmd.Env = env
+	// Don't let a background process that inherited the pipes keep Wait blocked
+	cmd.WaitDelay = time.Second
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	nonce := make([]byte, 8)
+	rand.Read(nonce)
+	s := &shellSession{
+		shell:  shell,
+		cmd:    cmd,
+		stdin:  stdin,
+		lines:  make(chan string, 256),
+		marker: "__TERMINUSAI_DONE_" + hex.EncodeToString(nonce) + "__",
+		cancel: cancel,
+	}
+
+	go func() {
+		reader := bufio.NewReader(pr)
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				s.lines <- line
+			}
+			if err != nil {
+				close(s.lines)
+				return
+			}
+		}
+	}()
+	go func() {
+		cmd.Wait()
+		pw.Close()
+	}()
+
+	return s, nil
+}
+
+// script wraps command so the shell prints the marker and exit status when it
+// finishes. A non-empty stderrPath sends the command's stderr to that file.
+func (s *shellSession) script(command, stderrPath string) string {
+	switch s.shell {
+	case "bash":
+		redirect := ""
+		if stderrPath != "" {
+			redirect = " 2> '" + strings.ReplaceAll(stderrPath, "'", `'\''`) + "'"
+		}
+		// Commands don't read the session's stdin, which carries the following commands
+		return fmt.Sprintf("{\n%s\n} < /dev/null%s\nprintf '\\n%%s %%d\\n' %s \"$?\"\n", command, redirect, s.marker)
+	case "cmd":
+		if stderrPath != "" {
+			command = fmt.Sprintf("(\r\n%s\r\n) 2> \"%s\"", command, stderrPath)
+		}
+		return fmt.Sprintf("%s\r\necho.\r\necho %s %%ERRORLEVEL%%\r\n", command, s.marker)
+	default:
+		redirect := ""
+		if stderrPath != "" {
+			redirect = " 2> '" + strings.ReplaceAll(stderrPath, "'", "''") + "'"
+		}
+		// Dot-sourced so variables and functions stay in the session's scope; the
+		// blank line ends a multi-line statement
+		return fmt.Sprintf("$global:LASTEXITCODE = 0\r\n. {\r\n%s\r\n}%s\r\n\r\n"+
+			"$__ok = $?; $__code = if ($__ok) { 0 } elseif ($LASTEXITCODE) { $LASTEXITCODE } else { 1 }; Write-Output ''; Write-Output \"%s $__code\"\r\n",
+			command, redirect, s.marker)
+	}
+}
+
+// run sends a command to the shell and collects its output until the marker
+// arrives. A non-zero exit status is returned as *shellExitError. When ctx is
+// cancelled or timeout passes the output so far is returned with the error; the
+// session must then be closed, as the command may still be running.
+func (s *shellSession) run(ctx context.Context, command, stderrPath string, timeout time.Duration) (string, error) {
+	if _, err := io.WriteString(s.stdin, s.script(command, stderrPath)); err != nil {
+		return "", errShellSessionExited
+	}
+
+	// No timeout leaves expired nil, which never fires
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	var out strings.Builder
+	for {
+		select {
+		case line, ok := <-s.lines:
+			if !ok {
+				return out.String(), errShellSessionExited
+			}
+			line = strings.TrimRight(line, "\r\n")
+			if rest, found := strings.CutPrefix(line, s.marker+" "); found {
+				output := strings.TrimRight(out.String(), "\n")
+				code, err := strconv.Atoi(strings.TrimSpace(rest))
+				if err != nil {
+					code = -1
+				}
+				if code != 0 {
+					return output, &shellExitError{code: code}
+				}
+				return output, nil
+			}
+			if out.Len() < maxFileSize {
+				out.WriteString(line + "\n")
+			}
+		case <-ctx.Done():
+			return out.String(), ctx.Err()
+		case <-expired:
+			return out.String(), fmt.Errorf("%w within %v", errShellTimeout, timeout)
+		}
+	}
+}
+
+// setEnv sets a variable in the running shell. The key is checked here too,
+// since it is written into the command unquoted.
+func (s *shellSession) setEnv(key, value string) error {
+	if !envNameRe.MatchString(key) {
+		return fmt.Errorf("invalid variable name %q", key)
+	}
+	var command string
+	switch s.shell {
+	case "bash":
+		command = fmt.Sprintf("export %s='%s'", key, strings.ReplaceAll(value, "'", `'\''`))
+	case "cmd":
+		// cmd has no escape for a quote inside set "..."
+		if strings.ContainsAny(value, "\"\r\n") {
+			return fmt.Errorf("value for cmd can't contain quotes or newlines")
+		}
+		command = fmt.Sprintf(`set "%s=%s"`, key, value)
+	default:
+		command = fmt.Sprintf("$env:%s = '%s'", key, strings.ReplaceAll(value, "'", "''"))
+	}
+	_, err := s.run(context.Background(), command, "", 10*time.Second)
+	return err
+}
+
+// close stops the shell and every process it started
+func (s *shellSession) close() {
+	s.stdin.Close()
+	s.cancel()
+	// Let the reader run to EOF instead of blocking on unread output
+	go func() {
+		for range s.lines {
+		}
+	}()
+}
+
+// SetPersistentShell makes shell actions without a cwd run in one long-lived
+// shell per shell type, so directory changes and variables persist between
+// commands. Sessions are closed when the task ends.
+func (a *Agent) SetPersistentShell(enabled bool) {
+	a.persistentShell = enabled
+}
+
+// runInShellSession runs a command in the session for shell, starting it on
+// first use. With separateStderr the command's stderr is returned on its own,
+// otherwise it is part of the output. A session whose command fails to complete
+// is closed, and the next command starts a fresh one.
+func (a *Agent) runInShellSession(shell, command string, separateStderr bool, timeout time.Duration) (string, string, error) {
+	if a.shellSessions == nil {
+		a.shellSessions = make(map[string]*shellSession)
+	}
+	s, ok := a.shellSessions[shell]
+	if !ok {
+		var err error
+		if s, err = startShellSession(shell, a.workingDir, a.environ()); err != nil {
+			return "", "", fmt.Errorf("failed to start %s session: %w", shell, err)
+		}
+		a.shellSessions[shell] = s
+	}
+
+	stderrPath := ""
+	if separateStderr {
+		f, err := os.CreateTemp("", "terminusai-stderr-*")
+		if err != nil {
+			return "", "", err
+		}
+		f.Close()
+		stderrPath = f.Name()
+		defer os.Remove(stderrPath)
+	}
+
+	output, err := s.run(a.actionContext(), command, stderrPath, timeout)
+	var exitErr *shellExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		s.close()
+		delete(a.shellSessions, shell)
+		output += fmt.Sprintf("\n[%v; the next command starts a new shell, so directory and variable changes are lost]", err)
+	}
+
+	var stderr string
+	if stderrPath != "" {
+		data, _ := os.ReadFile(stderrPath)
+		stderr = decodeShellText(data)
+	}
+	return output, stderr, err
+}
+
+// decodeShellText converts redirected shell output to UTF-8. Windows PowerShell
+// writes redirected streams as UTF-16 with a byte order mark.
+func decodeShellText(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		units := make([]uint16, 0, len(data)/2)
+		for i := 2; i+1 < len(data); i += 2 {
+			units = append(units, uint16(data[i])|uint16(data[i+1])<<8)
+		}
+		return string(utf16.Decode(units))
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:])
+	default:
+		return string(data)
+	}
+}
+
+// closeShellSessions stops all persistent shells
+func (a *Agent) closeShellSessions() {
+	for shell, s := range a.shellSessions {
+		s.close()
+		delete(a.shellSessions, shell)
+	}
+}
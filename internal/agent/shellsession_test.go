@@ -0,0 +1,296 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"terminusai/internal/providers"
+)
+
+func TestShellSession(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+
+	s, err := startShellSession("bash", dir, nil)
+	if err != nil {
+		t.Fatalf("Expected session to start, got %v", err)
+	}
+	defer s.close()
+
+	run := func(command string) (string, error) {
+		return s.run(context.Background(), command, "", 5*time.Second)
+	}
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+		exitCode int
+	}{
+		{"cd persists", "cd sub", "", 0},
+		{"cwd", "basename \"$PWD\"", "sub", 0},
+		{"export persists", "export GREETING=hello", "", 0},
+		{"env", "echo $GREETING", "hello", 0},
+		{"output without newline", "printf partial", "partial", 0},
+		{"exit code", "echo oops >&2; false", "oops", 1},
+		{"stdin not consumed", "cat", "", 0},
+		{"multi-line", "for i in 1 2; do\n  echo $i\ndone", "1\n2", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := run(tt.command)
+			exitCode := 0
+			if exitErr, ok := err.(*shellExitError); ok {
+				exitCode = exitErr.code
+			} else if err != nil {
+				t.Fatalf("Expected command to complete, got %v", err)
+			}
+			if output != tt.expected {
+				t.Errorf("Expected output %q, got %q", tt.expected, output)
+			}
+			if exitCode != tt.exitCode {
+				t.Errorf("Expected exit code %d, got %d", tt.exitCode, exitCode)
+			}
+		})
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := s.run(context.Background(), "sleep 5", "", 100*time.Millisecond)
+		if err == nil || !strings.Contains(err.Error(), "did not finish") {
+			t.Errorf("Expected a timeout error, got %v", err)
+		}
+	})
+}
+
+func TestPersistentShellAction(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	a := newTestAgent(t, dir)
+	a.SetPersistentShell(true)
+	defer a.closeShellSessions()
+
+	run := func(action *AgentAction) string {
+		var transcript []providers.ChatMessage
+		if err := a.handleShell(action, &transcript); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return transcript[len(transcript)-1].Content
+	}
+
+	run(&AgentAction{Type: "shell", Shell: "bash", Command: "cd sub"})
+	if obs := run(&AgentAction{Type: "shell", Shell: "bash", Command: "basename \"$PWD\""}); obs != "observation:shell exit=0\nsub" {
+		t.Errorf("Expected cd to persist, got %q", obs)
+	}
+
+	var transcript []providers.ChatMessage
+	if err := a.handleEnvSet(&AgentAction{Type: "env_set", Key: "STAGE", Value: "test"}, &transcript); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if obs := run(&AgentAction{Type: "shell", Shell: "bash", Command: "echo $STAGE"}); obs != "observation:shell exit=0\ntest" {
+		t.Errorf("Expected env to reach the session, got %q", obs)
+	}
+
+	if obs := run(&AgentAction{Type: "shell", Shell: "bash", Command: "exit 3"}); !strings.HasPrefix(obs, "observation:shell error exit=-1") || !strings.Contains(obs, "new shell") {
+		t.Errorf("Expected exited session to be reported, got %q", obs)
+	}
+	if obs := run(&AgentAction{Type: "shell", Shell: "bash", Command: "basename \"$PWD\""}); obs != "observation:shell exit=0\n"+filepath.Base(dir) {
+		t.Errorf("Expected a fresh session in the working dir, got %q", obs)
+	}
+}
+
+func TestSuccessExitCodes(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+
+	tests := []struct {
+		name         string
+		command      string
+		successCodes []int
+		expected     string
+	}{
+		{"zero is success by default", "echo found", nil, "observation:shell exit=0\nfound\n"},
+		{"non-zero is an error by default", "exit 1", nil, "observation:shell error exit=1\n"},
+		{"declared code is success", "exit 1", []int{0, 1}, "observation:shell exit=1\n"},
+		{"undeclared code is an error", "exit 2", []int{0, 1}, "observation:shell error exit=2\n"},
+		{"list replaces the default", "true", []int{1}, "observation:shell error exit=0\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAgent(t, t.TempDir())
+			action := &AgentAction{Type: "shell", Shell: "bash", Command: tt.command, SuccessExitCodes: tt.successCodes}
+			if err := validateAction(action); err != nil {
+				t.Fatalf("Expected valid action, got %v", err)
+			}
+
+			var transcript []providers.ChatMessage
+			if err := a.handleShell(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if obs := transcript[len(transcript)-1].Content; obs != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, obs)
+			}
+		})
+	}
+}
+
+func TestShellOutputModes(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	command := `echo '{"ok":true}'; echo progress >&2`
+
+	tests := []struct {
+		name       string
+		persistent bool
+		mode       string
+		command    string
+		expected   string
+	}{
+		{"combined", false, "", command, "observation:shell exit=0\n{\"ok\":true}\nprogress\n"},
+		{"separate", false, "separate", command, "observation:shell exit=0\nstdout:\n{\"ok\":true}\nstderr:\nprogress\n"},
+		{"stdout only", false, "stdout", command, "observation:shell exit=0\n{\"ok\":true}\n\n(stderr: 9 bytes not shown)"},
+		{"stdout only keeps stderr on failure", false, "stdout", "echo bad >&2; exit 2", "observation:shell error exit=2\nstdout:\n\nstderr:\nbad\n"},
+		{"separate in session", true, "separate", command, "observation:shell exit=0\nstdout:\n{\"ok\":true}\nstderr:\nprogress\n"},
+		{"stdout only in session", true, "stdout", command, "observation:shell exit=0\n{\"ok\":true}\n(stderr: 9 bytes not shown)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			a := newTestAgent(t, dir)
+			a.SetPersistentShell(tt.persistent)
+			defer a.closeShellSessions()
+
+			action := &AgentAction{Type: "shell", Shell: "bash", Command: tt.command, OutputMode: tt.mode}
+			if err := validateAction(action); err != nil {
+				t.Fatalf("Expected valid action, got %v", err)
+			}
+			var transcript []providers.ChatMessage
+			if err := a.handleShell(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if obs := transcript[len(transcript)-1].Content; obs != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, obs)
+			}
+		})
+	}
+
+	if err := validateAction(&AgentAction{Type: "shell", Command: "dir", OutputMode: "stderr"}); err == nil {
+		t.Errorf("Expected unknown output mode to be rejected")
+	}
+}
+
+func TestShellTimeout(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+
+	tests := []struct {
+		name       string
+		persistent bool
+		command    string
+		timeout    int
+		expected   string
+	}{
+		{"sleep is killed", false, "echo started; sleep 30", 1, "observation:shell timeout\n"},
+		{"background children are killed too", false, "sleep 30 & sleep 30", 1, "observation:shell timeout\n"},
+		{"sleep in session", true, "sleep 30 & echo $! > child.pid; wait", 1, "observation:shell timeout\n"},
+		{"zero means no limit", false, "sleep 1; echo done", 0, "observation:shell exit=0\ndone\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			a := newTestAgent(t, dir)
+			a.SetPersistentShell(tt.persistent)
+			defer a.closeShellSessions()
+
+			timeout := tt.timeout
+			action := &AgentAction{Type: "shell", Shell: "bash", Command: tt.command, Timeout: &timeout}
+			if err := validateAction(action); err != nil {
+				t.Fatalf("Expected valid action, got %v", err)
+			}
+
+			started := time.Now()
+			var transcript []providers.ChatMessage
+			if err := a.handleShell(action, &transcript); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if elapsed := time.Since(started); elapsed > 10*time.Second {
+				t.Errorf("Expected the command to be stopped promptly, took %v", elapsed)
+			}
+			if obs := transcript[len(transcript)-1].Content; !strings.HasPrefix(obs, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, obs)
+			}
+
+			// Children the command started must not outlive the timeout
+			if data, err := os.ReadFile(filepath.Join(dir, "child.pid")); err == nil && runtime.GOOS != "windows" {
+				pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+				if !processExits(pid, 5*time.Second) {
+					t.Errorf("Expected child process %d to be killed", pid)
+				}
+			}
+		})
+	}
+
+	action := &AgentAction{Type: "shell", Command: "dir"}
+	if err := validateAction(action); err != nil || action.Timeout == nil || *action.Timeout != defaultShellTimeout {
+		t.Errorf("Expected default timeout of %d seconds, got %v (%v)", defaultShellTimeout, action.Timeout, err)
+	}
+	negative := -1
+	if err := validateAction(&AgentAction{Type: "shell", Command: "dir", Timeout: &negative}); err == nil {
+		t.Errorf("Expected negative timeout to be rejected")
+	}
+}
+
+// processExits waits up to timeout for pid to disappear
+func processExits(pid int, timeout time.Duration) bool {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		p, err := os.FindProcess(pid)
+		if err != nil || p.Signal(syscall.Signal(0)) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDecodeShellText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		expected string
+	}{
+		{"plain", []byte("error\n"), "error\n"},
+		{"utf-8 bom", []byte("\xEF\xBB\xBFerror"), "error"},
+		{"utf-16le", []byte{0xFF, 0xFE, 'o', 0, 'k', 0, 0xE9, 0}, "oké"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeShellText(tt.input); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"terminusai/internal/policy"
+	"terminusai/internal/providers"
+)
+
+// Handlers may run concurrently (server mode, parallel actions), so the agent
+// state they write goes through these accessors, guarded by stateMu.
+
+// setLastSuccess records the output of the last successful command, shown again
+// when the task is done
+func (a *Agent) setLastSuccess(command, output string) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	a.lastSuccessCommand = command
+	a.lastSuccessOutput = output
+}
+
+// lastSuccess returns the last successful command and its output
+func (a *Agent) lastSuccess() (command, output string) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	return a.lastSuccessCommand, a.lastSuccessOutput
+}
+
+// countAction records one execution of an action type for stats
+func (a *Agent) countAction(actionType string) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	a.actionCounts[actionType]++
+}
+
+// addObservationBytes records observation bytes produced by an action type
+func (a *Agent) addObservationBytes(actionType string, n int) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	a.observationBytes[actionType] += n
+}
+
+// actionStats returns copies of the per-type action counts and observation bytes
+func (a *Agent) actionStats() (counts, observationBytes map[string]int) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	counts = make(map[string]int, len(a.actionCounts))
+	for k, v := range a.actionCounts {
+		counts[k] = v
+	}
+	observationBytes = make(map[string]int, len(a.observationBytes))
+	for k, v := range a.observationBytes {
+		observationBytes[k] = v
+	}
+	return counts, observationBytes
+}
+
+// addUsage accumulates the token usage of one provider reply
+func (a *Agent) addUsage(usage providers.Usage) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	a.usage.Add(usage)
+}
+
+// Usage returns the tokens the provider reported during the current or last
+// task, zero for providers that don't report usage
+func (a *Agent) Usage() providers.Usage {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	return a.usage
+}
+
+// setLastDecision records the approval decision made for the running action
+func (a *Agent) setLastDecision(decision policy.Decision) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	a.lastDecision = decision
+}
+
+// takeLastDecision returns and clears the decision recorded by setLastDecision
+func (a *Agent) takeLastDecision() policy.Decision {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	decision := a.lastDecision
+	a.lastDecision = ""
+	return decision
+}
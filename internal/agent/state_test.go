@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// Run with -race: handlers touching shared agent state from several goroutines
+func TestAgentStateConcurrentAccess(t *testing.T) {
+	a := NewAgent(nil, nil, t.TempDir(), false, false)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n := strconv.Itoa(i)
+			for j := 0; j < 50; j++ {
+				a.setLastSuccess("echo "+n, n)
+				a.lastSuccess()
+				a.countAction("shell")
+				a.addObservationBytes("shell", 10)
+				a.actionStats()
+				a.SetEnv("VAR_"+n, n)
+				a.GetEnv("VAR_" + n)
+				a.environ()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	counts, sizes := a.actionStats()
+	if counts["shell"] != 400 {
+		t.Errorf("Expected 400 shell actions, got %d", counts["shell"])
+	}
+	if sizes["shell"] != 4000 {
+		t.Errorf("Expected 4000 observation bytes, got %d", sizes["shell"])
+	}
+	if command, output := a.lastSuccess(); command != "echo "+output {
+		t.Errorf("Expected command and output from the same write, got %q and %q", command, output)
+	}
+}
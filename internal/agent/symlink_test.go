@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// skipWithoutSymlinks skips the test where symlinks can't be created, e.g. on
+// Windows without Developer Mode
+func skipWithoutSymlinks(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.Symlink("target", filepath.Join(dir, ".probe")); err != nil {
+		t.Skipf("Symlinks not available: %v", err)
+	}
+	os.Remove(filepath.Join(dir, ".probe"))
+}
+
+func TestSymlinkCreate(t *testing.T) {
+	dir := t.TempDir()
+	skipWithoutSymlinks(t, dir)
+	if err := os.WriteFile(filepath.Join(dir, "config.v2.yaml"), []byte("v: 2"), 0644); err != nil {
+		t.Fatalf("Failed to create target: %v", err)
+	}
+	a := newTestAgent(t, dir)
+
+	observation := runActions(t, a, `{"type":"symlink","src":"../config.v2.yaml","dest":"current/config.yaml"}`)
+	if observation != "observation:symlink success\nCreated symlink current/config.yaml -> ../config.v2.yaml" {
+		t.Errorf("Unexpected observation %q", observation)
+	}
+	link := filepath.Join(dir, "current", "config.yaml")
+	if target, err := os.Readlink(link); err != nil || target != "../config.v2.yaml" {
+		t.Errorf("Expected link to ../config.v2.yaml, got %q (%v)", target, err)
+	}
+	if got := readString(t, link); got != "v: 2" {
+		t.Errorf("Expected the link to resolve to the target, got %q", got)
+	}
+}
+
+func TestSymlinkOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	skipWithoutSymlinks(t, dir)
+	a := newTestAgent(t, dir)
+	runActions(t, a, `{"type":"symlink","src":"v1","dest":"current"}`)
+
+	observation := runActions(t, a, `{"type":"symlink","src":"v2","dest":"current"}`)
+	if !strings.HasPrefix(observation, "observation:symlink error\ndestination already exists") {
+		t.Errorf("Expected an existing-dest error, got %q", observation)
+	}
+	if target, _ := os.Readlink(filepath.Join(dir, "current")); target != "v1" {
+		t.Errorf("Expected the old link to be kept, got %q", target)
+	}
+
+	observation = runActions(t, a, `{"type":"symlink","src":"v2","dest":"current","overwrite":true}`)
+	if !strings.HasPrefix(observation, "observation:symlink success") {
+		t.Errorf("Expected success, got %q", observation)
+	}
+	if target, _ := os.Readlink(filepath.Join(dir, "current")); target != "v2" {
+		t.Errorf("Expected the link to point to v2, got %q", target)
+	}
+}
+
+func TestRealpathChain(t *testing.T) {
+	dir := t.TempDir()
+	skipWithoutSymlinks(t, dir)
+	if err := os.MkdirAll(filepath.Join(dir, "releases", "v3"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	for _, link := range [][2]string{{"releases/v3", "current"}, {"current", "live"}} {
+		if err := os.Symlink(link[0], filepath.Join(dir, link[1])); err != nil {
+			t.Fatalf("Failed to create link: %v", err)
+		}
+	}
+	a := newTestAgent(t, dir)
+
+	canonical, err := filepath.EvalSymlinks(filepath.Join(dir, "releases", "v3"))
+	if err != nil {
+		t.Fatalf("Failed to resolve dir: %v", err)
+	}
+	live := filepath.Join(dir, "live")
+	observation := runActions(t, a, `{"type":"realpath","path":"live"}`)
+	for _, expected := range []string{
+		"Canonical: " + canonical + "\n",
+		"Symlink: true\n",
+		"Target: current\n",
+		"Chain: " + live + " -> " + filepath.Join(dir, "current") + " -> " + filepath.Join(dir, "releases", "v3") + "\n",
+	} {
+		if !strings.Contains(observation, expected) {
+			t.Errorf("Expected %q in %q", expected, observation)
+		}
+	}
+}
+
+func TestRealpathPlainFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "plain.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	a := newTestAgent(t, dir)
+
+	canonical, _ := filepath.EvalSymlinks(filepath.Join(dir, "plain.txt"))
+	observation := runActions(t, a, `{"type":"realpath","path":"plain.txt"}`)
+	if !strings.Contains(observation, "Canonical: "+canonical+"\n") || !strings.Contains(observation, "Symlink: false\n") {
+		t.Errorf("Unexpected observation %q", observation)
+	}
+	if strings.Contains(observation, "Target:") {
+		t.Errorf("Expected no target for a plain file, got %q", observation)
+	}
+
+	observation = runActions(t, a, `{"type":"realpath","path":"missing.txt"}`)
+	if !strings.HasPrefix(observation, "observation:realpath error") {
+		t.Errorf("Expected an error for a missing path, got %q", observation)
+	}
+}
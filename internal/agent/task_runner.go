@@ -2,6 +2,8 @@ package agent
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -56,70 +58,73 @@ func RunAgentTaskWithWorkingDir(task string, provider providers.LLMProvider, pol
 
 // RunTask executes a task with UI feedback
 func (a *Agent) RunTask(task string) error {
+	a.running.Lock()
+	defer a.running.Unlock()
+	defer acquireTaskSlot()()
+	defer a.removeObservations()
+	defer a.closeShellSessions()
+
 	// Show thinking phase
 	spinner := a.display.ShowAgentThinking(task)
 
 	// Initialize conversation
 	maxIters := 12
-	transcript := []providers.ChatMessage{
-		{Role: "system", Content: SystemPrompt},
-		{Role: "user", Content: fmt.Sprintf("Task: %s\nOS: Windows", task)},
-	}
+	transcript := []providers.ChatMessage{{Role: "system", Content: systemPrompt()}}
+	transcript = append(transcript, a.contextMessages()...)
+	transcript = append(transcript, providers.ChatMessage{Role: "user", Content: fmt.Sprintf("Task: %s\nOS: Windows", task)})
+
+	// The system prompt, attached context and task survive transcript trimming
+	pinned := len(transcript)
 
 	spinner.Stop()
 
-	for i := 0; i < maxIters; i++ {
-		// Trim conversation if getting too long
-		if len(transcript) > 10 {
-			systemMsg := transcript[0]
-			userMsg := transcript[1]
-			recent := transcript[len(transcript)-6:]
-			transcript = append([]providers.ChatMessage{systemMsg, userMsg}, recent...)
-		}
+	a.startTime = time.Now()
+	a.maxIterations = maxIters
+	a.result = nil
+	a.usage = providers.Usage{}
+	a.fileChanges = 0
+	a.fileChangeLimit = a.maxFileChanges
 
-		// API retry logic with exponential backoff
-		maxAPIRetries := 3
-		retryDelay := 500 * time.Millisecond
-		var raw string
-		var err error
-
-		for retryCount := 0; retryCount <= maxAPIRetries; retryCount++ {
-			// Log request in debug/verbose mode
-			if a.debug || a.verbose {
-				fmt.Printf("\n🔄 LLM Request (attempt %d/%d):\n", retryCount+1, maxAPIRetries+1)
-				for i, msg := range transcript {
-					fmt.Printf("  [%d] %s: %s\n", i, msg.Role, truncateString(msg.Content, 200))
-				}
-				fmt.Printf("\n")
-			}
+	if a.verbose {
+		fmt.Printf("  ⎿  Ignored directories: %s\n", strings.Join(sortedDirNames(a.ignoreDirs), ", "))
+	}
 
-			raw, err = a.provider.Chat(transcript, nil)
+	for i := 0; i < maxIters; i++ {
+		a.iteration = i + 1
 
-			// Log response in debug/verbose mode
-			if a.debug || a.verbose {
-				if err != nil {
-					fmt.Printf("🚨 LLM Error: %v\n\n", err)
-				} else {
-					fmt.Printf("✅ LLM Response: %s\n\n", truncateString(raw, 300))
-				}
-			}
+		// Summarize older messages once the conversation gets too long
+		transcript = a.compactTranscript(transcript, pinned)
 
-			if err == nil {
-				break
+		// Log request in debug/verbose mode
+		if a.debug || a.verbose {
+			fmt.Printf("\n🔄 LLM Request:\n")
+			for i, msg := range transcript {
+				fmt.Printf("  [%d] %s: %s\n", i, msg.Role, truncateString(msg.Content, 200))
 			}
+			fmt.Printf("\n")
+		}
 
-			if !isRetryableError(err) {
-				// Non-retryable error, exit immediately
-				break
+		// Transient API errors are retried with backoff by the provider decorator.
+		// Tokens are shown as they arrive; the reply is only parsed once complete.
+		stream := a.display.ShowResponseStream()
+		raw, err := providers.ChatStream(a.provider, transcript, a.chatOptions, stream.Write)
+		stream.Done()
+		if err == nil {
+			a.addUsage(providers.LastUsage(a.provider))
+			if reason := a.budgetExceeded(); reason != "" {
+				ui.Error.Printf("● Budget exceeded, stopping\n")
+				ui.Muted.Printf("  ⎿  %s\n", reason)
+				a.display.ShowAgentSummary(a.tokenUsage())
+				return fmt.Errorf("%w: %s", ErrBudgetExceeded, reason)
 			}
+		}
 
-			if retryCount < maxAPIRetries {
-				// Show discrete retry message only if we're going to retry
-				if a.verbose {
-					fmt.Printf("  ⎿  API temporarily unavailable, retrying in %v... (%d/%d)\n",
-						retryDelay*time.Duration(retryCount+1), retryCount+1, maxAPIRetries)
-				}
-				time.Sleep(retryDelay * time.Duration(retryCount+1)) // Exponential backoff
+		// Log response in debug/verbose mode
+		if a.debug || a.verbose {
+			if err != nil {
+				fmt.Printf("🚨 LLM Error: %v\n\n", err)
+			} else {
+				fmt.Printf("✅ LLM Response: %s\n\n", truncateString(raw, 300))
 			}
 		}
 
@@ -127,48 +132,47 @@ func (a *Agent) RunTask(task string) error {
 			if isRetryableError(err) {
 				ui.Error.Printf("● API service temporarily unavailable after %d retries\n", maxAPIRetries)
 				ui.Muted.Printf("  ⎿  The LLM provider is experiencing high load. Please try again in a few minutes.\n")
-			} else {
-				ui.Error.Printf("● Failed to communicate with LLM provider\n")
-				ui.Muted.Printf("  ⎿  %v\n", err)
+				return fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
 			}
-			return fmt.Errorf("failed to get response from provider: %w", err)
+			ui.Error.Printf("● Failed to communicate with LLM provider\n")
+			ui.Muted.Printf("  ⎿  %v\n", err)
+			return fmt.Errorf("%w: %w", ErrProviderFailed, err)
 		}
 
 		// Parse action
 		action, err := parseAgentAction(raw)
 		if err != nil {
-			errorMsg := fmt.Sprintf("Invalid action format. Error: %s. Raw response: %s. Please return a single JSON action.", err.Error(), truncateString(raw, 200))
-			transcript = append(transcript, providers.ChatMessage{Role: "user", Content: errorMsg})
+			transcript = append(transcript, providers.ChatMessage{Role: "user", Content: a.correctionMessage(raw, err)})
 			continue
 		}
 
-		// Execute action
-		switch action.Type {
-		case "done":
-			result := action.Result
-			if result == "" {
-				result = "Task completed successfully"
-			}
+		a.countAction(action.Type)
+
+		// Finish the task
+		if action.Type == "done" {
+			a.result = newTaskResult(action)
 
 			// Show completion message
+			a.display.Emit(ui.Event{Type: "result", Summary: a.result.Result, Data: a.result})
 			fmt.Println()
-			ui.Success.Printf("✓ %s\n", result)
+			ui.Success.Printf("✓ %s\n", a.result.Result)
+			showTaskResult(a.result)
 
 			// Show last successful command output if available
-			if a.lastSuccessOutput != "" {
+			if _, lastOutput := a.lastSuccess(); lastOutput != "" {
 				fmt.Println()
 				ui.Primary.Printf("▶ Last Command Output\n")
 				ui.Muted.Printf("─────────────────────────────────────────────────────────────────────────────────\n")
 
-				if len(a.lastSuccessOutput) <= MaxDisplayOutputSize {
+				if len(lastOutput) <= MaxDisplayOutputSize {
 					// Show full output if it's within size limit
-					fmt.Print(a.lastSuccessOutput)
-					if !strings.HasSuffix(a.lastSuccessOutput, "\n") {
+					fmt.Print(lastOutput)
+					if !strings.HasSuffix(lastOutput, "\n") {
 						fmt.Println()
 					}
 				} else {
 					// Show truncated output with interactive option for full output
-					truncated := a.lastSuccessOutput[:MaxDisplayOutputSize]
+					truncated := lastOutput[:MaxDisplayOutputSize]
 					// Find the last complete line to avoid cutting mid-line
 					if lastNewline := strings.LastIndex(truncated, "\n"); lastNewline > 0 {
 						truncated = truncated[:lastNewline+1]
@@ -179,218 +183,271 @@ func (a *Agent) RunTask(task string) error {
 						fmt.Println()
 					}
 
-					ui.Warning.Printf("... [Output truncated - %d characters total]\n", len(a.lastSuccessOutput))
+					ui.Warning.Printf("... [Output truncated - %d characters total]\n", len(lastOutput))
 
 					// Wait for user input to show full output
-					waitForFullOutputRequest(a.lastSuccessOutput)
+					if a.asker == nil {
+						waitForFullOutputRequest(lastOutput)
+					}
 				}
 			}
 
-			a.display.ShowAgentSummary()
+			a.display.ShowAgentSummary(a.tokenUsage())
 			return nil
+		}
 
-		case "list_files":
-			if err := a.handleListFiles(action, &transcript); err != nil {
-				return err
-			}
+		// Refuse file changes past the per-run limit unless the user allows more
+		if !a.fileChangeAllowed(action) {
+			actionJSON, _ := json.Marshal(action)
+			transcript = append(transcript,
+				providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+				providers.ChatMessage{Role: "user", Content: a.fileLimitObservation(action)},
+			)
+			continue
+		}
 
-		case "read_file":
-			if err := a.handleReadFile(action, &transcript); err != nil {
-				return err
-			}
+		// Execute action; Ctrl+C cancels only the in-flight action
+		a.throttle()
+		before := len(transcript)
+		started := time.Now()
+		err = a.runInterruptible(action, &transcript)
+		a.recordHistory(action, transcript, before, started, err)
+		a.recordAudit(action, transcript, before, err)
+		a.accountObservations(action, transcript, before)
+		a.countFileChange(action, transcript, before)
+		if errors.Is(err, policy.ErrApprovalAborted) {
+			return fmt.Errorf("%w: %w", ErrPolicyDenied, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
 
-		case "search_files":
-			if err := a.handleSearchFiles(action, &transcript); err != nil {
-				return err
-			}
+	a.display.ShowAction("Max iterations reached", "Agent stopped after reaching maximum iterations", false)
+	a.display.ShowAgentSummary(a.tokenUsage())
+	return ErrMaxIterations
+}
 
-		case "shell":
-			if err := a.handleShell(action, &transcript); err != nil {
-				return err
-			}
+// tokenUsage returns the run's token usage for the summary, priced at the
+// current model's list price when it is known
+func (a *Agent) tokenUsage() ui.TokenUsage {
+	usage := a.Usage()
+	summary := ui.TokenUsage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+	summary.Cost, summary.CostKnown = providers.EstimateCost(a.provider.Name(), a.sessionModel(), usage)
+	return summary
+}
 
-		case "write_file":
-			if err := a.handleWriteFile(action, &transcript); err != nil {
-				return err
-			}
+// executeAction dispatches a single (non-done) action to its handler
+func (a *Agent) executeAction(action *AgentAction, transcript *[]providers.ChatMessage) error {
+	// Drop output kept by an earlier action that was never accounted, as in replay
+	a.takeRawObservation()
+	if a.dryRun && changesSystem(action) {
+		return a.handleDryRun(action, transcript)
+	}
+	if !a.checkTools(action, transcript) {
+		return nil
+	}
 
-		case "ps":
-			if err := a.handlePs(action, &transcript); err != nil {
-				return err
-			}
+	switch action.Type {
+	case "list_files":
+		return a.handleListFiles(action, transcript)
 
-		case "kill":
-			if err := a.handleKill(action, &transcript); err != nil {
-				return err
-			}
+	case "read_file":
+		return a.handleReadFile(action, transcript)
 
-		case "http_request":
-			if err := a.handleHttpRequest(action, &transcript); err != nil {
-				return err
-			}
+	case "search_files":
+		return a.handleSearchFiles(action, transcript)
 
-		case "ping":
-			if err := a.handlePing(action, &transcript); err != nil {
-				return err
-			}
+	case "shell":
+		return a.handleShell(action, transcript)
 
-		case "traceroute":
-			if err := a.handleTraceroute(action, &transcript); err != nil {
-				return err
-			}
+	case "write_file":
+		return a.handleWriteFile(action, transcript)
 
-		case "get_system_info":
-			if err := a.handleGetSystemInfo(action, &transcript); err != nil {
-				return err
-			}
+	case "ps":
+		return a.handlePs(action, transcript)
 
-		case "install_package":
-			if err := a.handleInstallPackage(action, &transcript); err != nil {
-				return err
-			}
+	case "kill":
+		return a.handleKill(action, transcript)
 
-		case "git":
-			if err := a.handleGit(action, &transcript); err != nil {
-				return err
-			}
+	case "http_request":
+		return a.handleHttpRequest(action, transcript)
 
-		case "extract":
-			if err := a.handleExtract(action, &transcript); err != nil {
-				return err
-			}
+	case "fetch_text":
+		return a.handleFetchText(action, transcript)
 
-		case "compress":
-			if err := a.handleCompress(action, &transcript); err != nil {
-				return err
-			}
+	case "ping":
+		return a.handlePing(action, transcript)
 
-		case "parse_json":
-			if err := a.handleParseJson(action, &transcript); err != nil {
-				return err
-			}
+	case "traceroute":
+		return a.handleTraceroute(action, transcript)
 
-		case "parse_yaml":
-			if err := a.handleParseYaml(action, &transcript); err != nil {
-				return err
-			}
+	case "get_system_info":
+		return a.handleGetSystemInfo(action, transcript)
 
-		case "ask_user":
-			if err := a.handleAskUser(action, &transcript); err != nil {
-				return err
-			}
+	case "install_package":
+		return a.handleInstallPackage(action, transcript)
 
-		case "log":
-			if err := a.handleLog(action, &transcript); err != nil {
-				return err
-			}
+	case "git":
+		return a.handleGit(action, transcript)
 
-		case "copy_path":
-			if err := a.handleCopyPath(action, &transcript); err != nil {
-				return err
-			}
+	case "extract":
+		return a.handleExtract(action, transcript)
 
-		case "move_path":
-			if err := a.handleMovePath(action, &transcript); err != nil {
-				return err
-			}
+	case "compress":
+		return a.handleCompress(action, transcript)
 
-		case "delete_path":
-			if err := a.handleDeletePath(action, &transcript); err != nil {
-				return err
-			}
+	case "parse_json":
+		return a.handleParseJson(action, transcript)
 
-		case "stat_path":
-			if err := a.handleStatPath(action, &transcript); err != nil {
-				return err
-			}
+	case "parse_yaml":
+		return a.handleParseYaml(action, transcript)
 
-		case "make_dir":
-			if err := a.handleMakeDir(action, &transcript); err != nil {
-				return err
-			}
+	case "parse_toml":
+		return a.handleParseToml(action, transcript)
 
-		case "patch_file":
-			if err := a.handlePatchFile(action, &transcript); err != nil {
-				return err
-			}
+	case "ask_user":
+		return a.handleAskUser(action, transcript)
 
-		case "download_file":
-			if err := a.handleDownloadFile(action, &transcript); err != nil {
-				return err
-			}
+	case "log":
+		return a.handleLog(action, transcript)
 
-		case "grep":
-			if err := a.handleGrep(action, &transcript); err != nil {
-				return err
-			}
+	case "copy_path":
+		return a.handleCopyPath(action, transcript)
 
-		case "diff":
-			if err := a.handleDiff(action, &transcript); err != nil {
-				return err
-			}
+	case "move_path":
+		return a.handleMovePath(action, transcript)
 
-		case "parse":
-			if err := a.handleParse(action, &transcript); err != nil {
-				return err
-			}
+	case "delete_path":
+		return a.handleDeletePath(action, transcript)
 
-		case "confirm":
-			if err := a.handleConfirm(action, &transcript); err != nil {
-				return err
-			}
+	case "undo":
+		return a.handleUndo(action, transcript)
 
-		case "report":
-			if err := a.handleReport(action, &transcript); err != nil {
-				return err
-			}
+	case "stat_path":
+		return a.handleStatPath(action, transcript)
 
-		case "uuid":
-			if err := a.handleUuid(action, &transcript); err != nil {
-				return err
-			}
+	case "realpath":
+		return a.handleRealpath(action, transcript)
 
-		case "time_now":
-			if err := a.handleTimeNow(action, &transcript); err != nil {
-				return err
-			}
+	case "chmod":
+		return a.handleChmod(action, transcript)
 
-		case "hash_file":
-			if err := a.handleHashFile(action, &transcript); err != nil {
-				return err
-			}
+	case "chown":
+		return a.handleChown(action, transcript)
 
-		case "checksum_verify":
-			if err := a.handleChecksumVerify(action, &transcript); err != nil {
-				return err
-			}
+	case "symlink":
+		return a.handleSymlink(action, transcript)
 
-		case "hexdump":
-			if err := a.handleHexdump(action, &transcript); err != nil {
-				return err
-			}
+	case "touch":
+		return a.handleTouch(action, transcript)
 
-		case "env_get":
-			if err := a.handleEnvGet(action, &transcript); err != nil {
-				return err
-			}
+	case "make_dir":
+		return a.handleMakeDir(action, transcript)
 
-		case "env_set":
-			if err := a.handleEnvSet(action, &transcript); err != nil {
-				return err
-			}
+	case "patch_file":
+		return a.handlePatchFile(action, transcript)
 
-		case "whoami":
-			if err := a.handleWhoami(action, &transcript); err != nil {
-				return err
-			}
+	case "download_file":
+		return a.handleDownloadFile(action, transcript)
+
+	case "find":
+		return a.handleFind(action, transcript)
+
+	case "grep":
+		return a.handleGrep(action, transcript)
+
+	case "diff":
+		return a.handleDiff(action, transcript)
+
+	case "parse":
+		return a.handleParse(action, transcript)
+
+	case "confirm":
+		return a.handleConfirm(action, transcript)
+
+	case "report":
+		return a.handleReport(action, transcript)
 
-		default:
-			errorMsg := fmt.Sprintf("Unknown action type: %s", action.Type)
-			transcript = append(transcript, providers.ChatMessage{Role: "user", Content: errorMsg})
+	case "uuid":
+		return a.handleUuid(action, transcript)
+
+	case "time_now":
+		return a.handleTimeNow(action, transcript)
+
+	case "hash_file":
+		return a.handleHashFile(action, transcript)
+
+	case "checksum_verify":
+		return a.handleChecksumVerify(action, transcript)
+
+	case "hash_dir":
+		return a.handleHashDir(action, transcript)
+
+	case "format_file":
+		return a.handleFormatFile(action, transcript)
+
+	case "dir_snapshot":
+		return a.handleDirSnapshot(action, transcript)
+
+	case "run_tests":
+		return a.handleRunTests(action, transcript)
+
+	case "lint_file":
+		return a.handleLintFile(action, transcript)
+
+	case "list_actions":
+		return a.handleListActions(action, transcript)
+
+	case "edit_file":
+		return a.handleEditFile(action, transcript)
+
+	case "from_template":
+		return a.handleFromTemplate(action, transcript)
+
+	case "wait_for":
+		return a.handleWaitFor(action, transcript)
+
+	case "verify_checksums":
+		return a.handleVerifyChecksums(action, transcript)
+
+	case "hexdump":
+		return a.handleHexdump(action, transcript)
+
+	case "env_get":
+		return a.handleEnvGet(action, transcript)
+
+	case "env_set":
+		return a.handleEnvSet(action, transcript)
+
+	case "whoami":
+		return a.handleWhoami(action, transcript)
+
+	case "stats":
+		return a.handleStats(action, transcript)
+
+	case "summarize_file":
+		return a.handleSummarizeFile(action, transcript)
+
+	case "set_model":
+		return a.handleSetModel(action, transcript)
+
+	case "set_temperature":
+		return a.handleSetTemperature(action, transcript)
+
+	case "get_model":
+		return a.handleGetModel(action, transcript)
+
+	default:
+		if custom, ok := lookupAction(action.Type); ok {
+			return a.handleCustomAction(custom, action, transcript)
 		}
+		errorMsg := fmt.Sprintf("Unknown action type: %s", action.Type)
+		*transcript = append(*transcript, providers.ChatMessage{Role: "user", Content: errorMsg})
+		return nil
 	}
-
-	a.display.ShowAction("Max iterations reached", "Agent stopped after reaching maximum iterations", false)
-	a.display.ShowAgentSummary()
-	return nil
 }
@@ -0,0 +1,38 @@
+package agent
+
+import "sync"
+
+// taskSlots bounds how many tasks run at once across every Agent in the
+// process (nil = unlimited)
+var (
+	taskSlotsMu sync.Mutex
+	taskSlots   chan struct{}
+)
+
+// SetMaxConcurrentTasks caps how many RunTask calls, across all agents in the
+// process, run at once; further calls wait until a running task finishes.
+// n <= 0 removes the limit. Tasks already running or waiting keep the limit
+// they started under.
+func SetMaxConcurrentTasks(n int) {
+	taskSlotsMu.Lock()
+	defer taskSlotsMu.Unlock()
+	if n <= 0 {
+		taskSlots = nil
+		return
+	}
+	taskSlots = make(chan struct{}, n)
+}
+
+// acquireTaskSlot blocks until a task may start and returns the func that
+// frees its slot
+func acquireTaskSlot() func() {
+	taskSlotsMu.Lock()
+	slots := taskSlots
+	taskSlotsMu.Unlock()
+
+	if slots == nil {
+		return func() {}
+	}
+	slots <- struct{}{}
+	return func() { <-slots }
+}
@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaxConcurrentTasks(t *testing.T) {
+	SetMaxConcurrentTasks(1)
+	defer SetMaxConcurrentTasks(0)
+
+	release := acquireTaskSlot()
+	started := make(chan struct{})
+	go func() {
+		defer acquireTaskSlot()()
+		close(started)
+	}()
+
+	select {
+	case <-started:
+		t.Fatal("Expected a second task to wait while the only slot is taken")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the waiting task to start once the slot was freed")
+	}
+}
+
+func TestMaxConcurrentTasksUnlimited(t *testing.T) {
+	SetMaxConcurrentTasks(0)
+
+	releases := make([]func(), 0, 10)
+	for i := 0; i < 10; i++ {
+		releases = append(releases, acquireTaskSlot())
+	}
+	for _, release := range releases {
+		release()
+	}
+}
@@ -0,0 +1,271 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// testSummary is the structured result of run_tests
+type testSummary struct {
+	Runner   string   `json:"runner"`
+	Command  string   `json:"command"`
+	Status   string   `json:"status"` // passed or failed
+	ExitCode int      `json:"exitCode"`
+	Parsed   bool     `json:"parsed"` // false when the counts could not be read from the output
+	Passed   int      `json:"passed"`
+	Failed   int      `json:"failed"`
+	Skipped  int      `json:"skipped"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// testRunner runs one kind of test suite and reads its results
+type testRunner struct {
+	name    string
+	markers []string // Files in the project directory that select this runner
+	command func(pattern string) []string
+	// parse fills in the counts and failures, returning the output worth showing
+	// the model; ok is false when the output isn't in the expected format
+	parse func(output string, summary *testSummary) (details string, ok bool)
+}
+
+// testRunners are tried in order when no runner is given
+var testRunners = []testRunner{
+	{
+		name:    "go",
+		markers: []string{"go.mod"},
+		command: func(pattern string) []string {
+			args := []string{"go", "test", "-json"}
+			if pattern != "" {
+				args = append(args, "-run", pattern)
+			}
+			return append(args, "./...")
+		},
+		parse: parseGoTestOutput,
+	},
+	{
+		name:    "npm",
+		markers: []string{"package.json"},
+		command: func(pattern string) []string {
+			args := []string{"npm", "test", "--silent"}
+			if pattern != "" {
+				args = append(args, "--", pattern)
+			}
+			return args
+		},
+		parse: parseJSTestOutput,
+	},
+	{
+		name:    "pytest",
+		markers: []string{"pytest.ini", "pyproject.toml", "setup.cfg", "tox.ini", "conftest.py", "setup.py"},
+		command: func(pattern string) []string {
+			args := []string{pythonExecutable(), "-m", "pytest", "-q", "-rfE"}
+			if pattern != "" {
+				args = append(args, "-k", pattern)
+			}
+			return args
+		},
+		parse: parsePytestOutput,
+	},
+}
+
+// findTestRunner returns the named runner, or detects one from the files in dir
+func findTestRunner(name, dir string) (testRunner, error) {
+	if name != "" {
+		for _, r := range testRunners {
+			if r.name == name {
+				return r, nil
+			}
+		}
+		return testRunner{}, fmt.Errorf("unknown test runner %s (supported: go, npm, pytest)", name)
+	}
+
+	for _, r := range testRunners {
+		for _, marker := range r.markers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return r, nil
+			}
+		}
+	}
+	return testRunner{}, fmt.Errorf("could not detect a test runner in %s (no go.mod, package.json or pytest config); set runner", dir)
+}
+
+// summarizeTests parses runner output into a summary. When parsing fails the
+// raw output is returned as the details and the status follows the exit code.
+func summarizeTests(r testRunner, output string, exitCode int) (testSummary, string) {
+	summary := testSummary{Runner: r.name, ExitCode: exitCode}
+	details, ok := r.parse(output, &summary)
+	if !ok {
+		summary = testSummary{Runner: r.name, ExitCode: exitCode}
+		details = output
+	}
+	summary.Parsed = ok
+
+	summary.Status = "passed"
+	if exitCode != 0 || summary.Failed > 0 {
+		summary.Status = "failed"
+	}
+	return summary, details
+}
+
+// goTestEvent is one line of go test -json output
+type goTestEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+}
+
+// parseGoTestOutput reads go test -json events. The details keep the output of
+// failing tests and package results, plus anything that isn't JSON (build errors).
+func parseGoTestOutput(output string, summary *testSummary) (string, bool) {
+	var events []goTestEvent
+	var other strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), maxFileSize)
+	for scanner.Scan() {
+		var ev goTestEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil || ev.Action == "" {
+			other.WriteString(scanner.Text() + "\n")
+			continue
+		}
+		events = append(events, ev)
+	}
+	if len(events) == 0 {
+		return "", false
+	}
+
+	failedTests := make(map[string]bool)
+	failedPackages := make(map[string]bool)
+	packagesWithFailedTests := make(map[string]bool)
+	for _, ev := range events {
+		switch {
+		case ev.Test == "" && ev.Action == "fail":
+			failedPackages[ev.Package] = true
+		case ev.Test == "":
+		case ev.Action == "pass":
+			summary.Passed++
+		case ev.Action == "skip":
+			summary.Skipped++
+		case ev.Action == "fail":
+			summary.Failed++
+			failedTests[ev.Package+" "+ev.Test] = true
+			packagesWithFailedTests[ev.Package] = true
+			summary.Failures = append(summary.Failures, ev.Package+"."+ev.Test)
+		}
+	}
+	// A package can fail without a failing test, e.g. when it doesn't build
+	for _, ev := range events {
+		if ev.Test == "" && ev.Action == "fail" && !packagesWithFailedTests[ev.Package] {
+			summary.Failures = append(summary.Failures, ev.Package)
+			packagesWithFailedTests[ev.Package] = true
+		}
+	}
+
+	var details strings.Builder
+	details.WriteString(other.String())
+	for _, ev := range events {
+		if ev.Action != "output" && ev.Action != "build-output" {
+			continue
+		}
+		if failedTests[ev.Package+" "+ev.Test] || (ev.Test == "" && (failedPackages[ev.Package] || ev.Action == "build-output")) {
+			details.WriteString(ev.Output)
+		}
+	}
+	return details.String(), true
+}
+
+var (
+	jestSummaryRe   = regexp.MustCompile(`(?m)^Tests:\s+(.*)$`)
+	jestCountRe     = regexp.MustCompile(`(\d+) (passed|failed|skipped|todo)`)
+	jestFailureRe   = regexp.MustCompile(`(?m)^\s*● (.+)$`)
+	mochaCountRe    = regexp.MustCompile(`(?m)^\s*(\d+) (passing|failing|pending)`)
+	mochaFailureRe  = regexp.MustCompile(`(?m)^\s+\d+\) (.+)$`)
+	pytestSummaryRe = regexp.MustCompile(`(?m)^=*\s*((?:\d+ \w+(?:, )?)+) in [\d.]+s`)
+	pytestCountRe   = regexp.MustCompile(`(\d+) (passed|failed|skipped|errors?|xfailed|xpassed)`)
+	pytestFailureRe = regexp.MustCompile(`(?m)^(?:FAILED|ERROR) (\S+)`)
+)
+
+// parseJSTestOutput reads the summary of Jest or Mocha, the common npm test runners
+func parseJSTestOutput(output string, summary *testSummary) (string, bool) {
+	if m := jestSummaryRe.FindStringSubmatch(output); m != nil {
+		for _, c := range jestCountRe.FindAllStringSubmatch(m[1], -1) {
+			n, _ := strconv.Atoi(c[1])
+			switch c[2] {
+			case "passed":
+				summary.Passed = n
+			case "failed":
+				summary.Failed = n
+			default:
+				summary.Skipped += n
+			}
+		}
+		for _, f := range jestFailureRe.FindAllStringSubmatch(output, -1) {
+			if !strings.HasPrefix(f[1], "Test suite failed to run") {
+				summary.Failures = appendUnique(summary.Failures, strings.TrimSpace(f[1]))
+			}
+		}
+		return output, true
+	}
+
+	counts := mochaCountRe.FindAllStringSubmatch(output, -1)
+	if counts == nil {
+		return "", false
+	}
+	for _, c := range counts {
+		n, _ := strconv.Atoi(c[1])
+		switch c[2] {
+		case "passing":
+			summary.Passed = n
+		case "failing":
+			summary.Failed = n
+		case "pending":
+			summary.Skipped = n
+		}
+	}
+	if summary.Failed > 0 {
+		for _, f := range mochaFailureRe.FindAllStringSubmatch(output, -1) {
+			summary.Failures = appendUnique(summary.Failures, strings.TrimSuffix(strings.TrimSpace(f[1]), ":"))
+		}
+	}
+	return output, true
+}
+
+// parsePytestOutput reads the final "N passed, M failed in Xs" line and the
+// FAILED/ERROR lines of the short test summary
+func parsePytestOutput(output string, summary *testSummary) (string, bool) {
+	matches := pytestSummaryRe.FindAllStringSubmatch(output, -1)
+	if matches == nil {
+		return "", false
+	}
+	for _, c := range pytestCountRe.FindAllStringSubmatch(matches[len(matches)-1][1], -1) {
+		n, _ := strconv.Atoi(c[1])
+		switch c[2] {
+		case "passed", "xpassed":
+			summary.Passed += n
+		case "failed", "error", "errors":
+			summary.Failed += n
+		default:
+			summary.Skipped += n
+		}
+	}
+	for _, f := range pytestFailureRe.FindAllStringSubmatch(output, -1) {
+		summary.Failures = appendUnique(summary.Failures, f[1])
+	}
+	return output, true
+}
+
+// appendUnique appends s unless it is already in list
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,167 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestSummarizeTests(t *testing.T) {
+	goOutput := strings.Join([]string{
+		`{"Action":"run","Package":"example/calc","Test":"TestAdd"}`,
+		`{"Action":"output","Package":"example/calc","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}`,
+		`{"Action":"pass","Package":"example/calc","Test":"TestAdd"}`,
+		`{"Action":"output","Package":"example/calc","Test":"TestDiv","Output":"    calc_test.go:9: Expected 2, got 0\n"}`,
+		`{"Action":"fail","Package":"example/calc","Test":"TestDiv"}`,
+		`{"Action":"skip","Package":"example/calc","Test":"TestSlow"}`,
+		`{"Action":"output","Package":"example/calc","Output":"FAIL\texample/calc\t0.01s\n"}`,
+		`{"Action":"fail","Package":"example/calc"}`,
+		`{"Action":"fail","Package":"example/broken"}`,
+	}, "\n")
+
+	jestOutput := `FAIL src/sum.test.js
+  ● sum › adds negatives
+
+    expect(received).toBe(expected)
+
+Tests:       1 failed, 1 skipped, 4 passed, 6 total
+`
+	mochaOutput := `  sum
+    ✓ adds
+    1) adds negatives
+
+  3 passing (8ms)
+  1 failing
+
+  1) sum
+       adds negatives:
+     AssertionError
+`
+	pytestOutput := `..F.s
+=========================== short test summary info ===========================
+FAILED tests/test_sum.py::test_negative - assert -1 == 1
+1 failed, 3 passed, 1 skipped in 0.05s
+`
+
+	tests := []struct {
+		name     string
+		runner   string
+		output   string
+		exitCode int
+		expected testSummary
+		details  string
+	}{
+		{"go", "go", goOutput, 1, testSummary{Runner: "go", Status: "failed", ExitCode: 1, Parsed: true, Passed: 1, Failed: 1, Skipped: 1,
+			Failures: []string{"example/calc.TestDiv", "example/broken"}}, "    calc_test.go:9: Expected 2, got 0\nFAIL\texample/calc\t0.01s\n"},
+		{"jest", "npm", jestOutput, 1, testSummary{Runner: "npm", Status: "failed", ExitCode: 1, Parsed: true, Passed: 4, Failed: 1, Skipped: 1,
+			Failures: []string{"sum › adds negatives"}}, jestOutput},
+		{"mocha", "npm", mochaOutput, 1, testSummary{Runner: "npm", Status: "failed", ExitCode: 1, Parsed: true, Passed: 3, Failed: 1,
+			Failures: []string{"adds negatives", "sum"}}, mochaOutput},
+		{"pytest", "pytest", pytestOutput, 1, testSummary{Runner: "pytest", Status: "failed", ExitCode: 1, Parsed: true, Passed: 3, Failed: 1, Skipped: 1,
+			Failures: []string{"tests/test_sum.py::test_negative"}}, pytestOutput},
+		{"unparsed passes on exit 0", "pytest", "all good\n", 0, testSummary{Runner: "pytest", Status: "passed"}, "all good\n"},
+		{"unparsed fails on exit 2", "npm", "npm ERR! missing script: test\n", 2, testSummary{Runner: "npm", Status: "failed", ExitCode: 2}, "npm ERR! missing script: test\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner, err := findTestRunner(tt.runner, "")
+			if err != nil {
+				t.Fatalf("Expected runner %s, got %v", tt.runner, err)
+			}
+			summary, details := summarizeTests(runner, tt.output, tt.exitCode)
+			if !reflect.DeepEqual(summary, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, summary)
+			}
+			if details != tt.details {
+				t.Errorf("Expected details %q, got %q", tt.details, details)
+			}
+		})
+	}
+}
+
+func TestFindTestRunner(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		expected string
+	}{
+		{"go module", []string{"go.mod"}, "go"},
+		{"node package", []string{"package.json"}, "npm"},
+		{"python project", []string{"pyproject.toml"}, "pytest"},
+		{"nothing to detect", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+					t.Fatalf("Failed to write %s: %v", f, err)
+				}
+			}
+			runner, err := findTestRunner("", dir)
+			if tt.expected == "" {
+				if err == nil {
+					t.Errorf("Expected detection to fail, got %s", runner.name)
+				}
+				return
+			}
+			if err != nil || runner.name != tt.expected {
+				t.Errorf("Expected %s, got %q (%v)", tt.expected, runner.name, err)
+			}
+		})
+	}
+}
+
+func TestHandleRunTests(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example\n\ngo 1.21\n",
+		"calc_test.go": `package example
+
+import "testing"
+
+func TestPass(t *testing.T) {}
+
+func TestFail(t *testing.T) { t.Error("boom") }
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	a := newTestAgent(t, dir)
+	var transcript []providers.ChatMessage
+	if err := a.handleRunTests(&AgentAction{Type: "run_tests", Path: "."}, &transcript); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	obs := transcript[1].Content
+	body, ok := strings.CutPrefix(obs, "observation:run_tests success\n")
+	if !ok {
+		t.Fatalf("Expected a success observation, got %q", obs)
+	}
+	summaryJSON, output, _ := strings.Cut(body, "\n\nOutput:\n")
+	var summary testSummary
+	if err := json.Unmarshal([]byte(summaryJSON), &summary); err != nil {
+		t.Fatalf("Expected JSON summary, got %q", summaryJSON)
+	}
+	if summary.Status != "failed" || summary.Passed != 1 || summary.Failed != 1 || !reflect.DeepEqual(summary.Failures, []string{"example.TestFail"}) {
+		t.Errorf("Expected one pass and one failure, got %+v", summary)
+	}
+	if !strings.Contains(output, "boom") {
+		t.Errorf("Expected failure output, got %q", output)
+	}
+}
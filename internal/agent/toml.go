@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+)
+
+// tomlError is a TOML syntax error with the 1-based position it was found at
+type tomlError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *tomlError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// parseTOML decodes a TOML document into the map[string]interface{} /
+// []interface{} values encoding/json produces, so parse output is the same for
+// every format. Integers decode to int64, floats to float64, and dates and
+// times are kept as their RFC 3339 strings. inf and nan, which JSON can't
+// represent, are kept as the strings "inf", "-inf" and "nan".
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	doc := map[string]interface{}{}
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, newTOMLError(string(data), parseErr)
+		}
+		return nil, err
+	}
+	if err := checkDottedTables(string(data)); err != nil {
+		return nil, err
+	}
+	return tomlValue(doc).(map[string]interface{}), nil
+}
+
+var (
+	tomlKeyPart   = `(?:[A-Za-z0-9_-]+|"(?:[^"\\]|\\.)*"|'[^']*')`
+	tomlKeyRe     = regexp.MustCompile(`^` + tomlKeyPart + `(?:[ \t]*\.[ \t]*` + tomlKeyPart + `)*`)
+	tomlKeyPartRe = regexp.MustCompile(tomlKeyPart)
+	tomlHeaderRe  = regexp.MustCompile(`^\[\[?[ \t]*(` + tomlKeyRe.String()[1:] + `)[ \t]*\]`)
+	tomlAssignRe  = regexp.MustCompile(`^(` + tomlKeyRe.String()[1:] + `)[ \t]*=`)
+)
+
+// checkDottedTables rejects a [table] header for a table already created by a
+// dotted key, such as a.b = 1 followed by [a], which TOML 1.0 forbids but the
+// decoder accepts. data has already decoded, so a line scan is enough.
+func checkDottedTables(src string) error {
+	var context string
+	dotted := map[string]bool{}
+	multiline := ""
+	for i, line := range strings.Split(src, "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if multiline != "" {
+			if strings.Count(line, multiline)%2 == 1 {
+				multiline = ""
+			}
+			continue
+		}
+		if m := tomlHeaderRe.FindStringSubmatch(trimmed); m != nil {
+			context = tomlKeyPath(m[1])
+			if dotted[context] {
+				return &tomlError{
+					Line:   i + 1,
+					Column: len(line) - len(trimmed) + strings.Index(trimmed, m[1]) + 1,
+					Msg:    fmt.Sprintf("Key '%s' has already been defined.", context),
+				}
+			}
+			if strings.HasPrefix(trimmed, "[[") {
+				// Each [[array]] element starts a new table
+				for key := range dotted {
+					if strings.HasPrefix(key, context+".") {
+						delete(dotted, key)
+					}
+				}
+			}
+			continue
+		}
+		if m := tomlAssignRe.FindStringSubmatch(trimmed); m != nil {
+			parts := tomlKeyPartRe.FindAllString(m[1], -1)
+			path := context
+			for _, part := range parts[:len(parts)-1] {
+				path = strings.TrimPrefix(path+"."+tomlKeyPath(part), ".")
+				dotted[path] = true
+			}
+		}
+		for _, quote := range []string{`"""`, "'''"} {
+			if strings.Count(line, quote)%2 == 1 {
+				multiline = quote
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// tomlKeyPath normalizes a possibly dotted and quoted key to its parts joined
+// by dots
+func tomlKeyPath(key string) string {
+	parts := tomlKeyPartRe.FindAllString(key, -1)
+	for i, part := range parts {
+		if unquoted, err := strconv.Unquote(part); err == nil && part[0] == '"' {
+			parts[i] = unquoted
+		} else {
+			parts[i] = strings.Trim(part, "'")
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// tomlErrorPrefix matches the position prefix the decoder puts on its messages
+var tomlErrorPrefix = regexp.MustCompile(`^toml: line \d+( \(last key ".*"\))?: `)
+
+// newTOMLError converts a decoder error to a tomlError, turning its byte offset
+// into a line and column
+func newTOMLError(src string, err toml.ParseError) *tomlError {
+	start := err.Position.Start
+	if start > len(src) {
+		start = len(src)
+	}
+	lineStart := strings.LastIndexByte(src[:start], '\n') + 1
+	msg := err.Message
+	if msg == "" {
+		// Errors without a short message only carry the formatted one
+		msg = tomlErrorPrefix.ReplaceAllString(err.Error(), "")
+	}
+	return &tomlError{
+		Line:   strings.Count(src[:start], "\n") + 1,
+		Column: utf8.RuneCountInString(src[lineStart:start]) + 1,
+		Msg:    msg,
+	}
+}
+
+// tomlValue converts a decoded TOML value to its JSON-compatible form
+func tomlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = tomlValue(value)
+		}
+		return v
+	case []map[string]interface{}:
+		values := make([]interface{}, len(v))
+		for i, table := range v {
+			values[i] = tomlValue(table)
+		}
+		return values
+	case []interface{}:
+		for i, value := range v {
+			v[i] = tomlValue(value)
+		}
+		return v
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "nan"
+		case math.IsInf(v, 1):
+			return "inf"
+		case math.IsInf(v, -1):
+			return "-inf"
+		}
+		return v
+	case time.Time:
+		// The decoder marks local dates and times with these zone names
+		switch v.Location().String() {
+		case "datetime-local":
+			return v.Format("2006-01-02T15:04:05.999999999")
+		case "date-local":
+			return v.Format("2006-01-02")
+		case "time-local":
+			return v.Format("15:04:05.999999999")
+		}
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
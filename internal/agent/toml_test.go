@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const cargoFixture = `# Package manifest
+[package]
+name = "terminus-demo"
+version = "0.3.1"
+authors = ["Ada Lovelace", 'Grace']
+edition = "2021"
+description = """
+A demo crate \
+with a long description."""
+
+[dependencies]
+serde = { version = "1.0", features = ["derive"] }
+tokio.version = "1"
+tokio.features = [
+    "full",   # everything
+]
+
+[profile.release]
+opt-level = 3
+lto = true
+codegen-units = 0x10
+strip = 'symbols'
+threshold = 1_000.5
+released = 2024-05-01T12:00:00Z
+
+[[bin]]
+name = "demo"
+path = "src/main.rs"
+
+[[bin]]
+name = "demo-cli"
+`
+
+func TestParseTOMLCargo(t *testing.T) {
+	doc, err := parseTOML([]byte(cargoFixture))
+	if err != nil {
+		t.Fatalf("Failed to parse fixture: %v", err)
+	}
+
+	got, _ := json.Marshal(doc)
+	expected := `{"bin":[{"name":"demo","path":"src/main.rs"},{"name":"demo-cli"}],` +
+		`"dependencies":{"serde":{"features":["derive"],"version":"1.0"},"tokio":{"features":["full"],"version":"1"}},` +
+		`"package":{"authors":["Ada Lovelace","Grace"],"description":"A demo crate with a long description.","edition":"2021","name":"terminus-demo","version":"0.3.1"},` +
+		`"profile":{"release":{"codegen-units":16,"lto":true,"opt-level":3,"released":"2024-05-01T12:00:00Z","strip":"symbols","threshold":1000.5}}}`
+	if string(got) != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		doc    string
+		line   int
+		column int
+	}{
+		{"[package]\nname = \"demo\"\nversion 1\n", 3, 9},
+		{"name = \"unterminated\n", 1, 21},
+		{"a = 1\na = 2\n", 2, 1},
+		{"[t]\n[t]\n", 2, 2},
+		{"list = [1, 2\n", 1, 13},
+		{"n = 01\n", 1, 5},
+		{"a = [1, 2]\n[[a]]\n", 2, 3},
+		{"t = {x = 1}\n[t]\n", 2, 2},
+		{"a.b = 1\n[a]\n", 2, 2},
+		{"d = 1979-05-27abc\n", 1, 15},
+	}
+	for _, tt := range tests {
+		_, err := parseTOML([]byte(tt.doc))
+		var tomlErr *tomlError
+		if !errors.As(err, &tomlErr) {
+			t.Errorf("Expected a tomlError for %q, got %v", tt.doc, err)
+			continue
+		}
+		if tomlErr.Line != tt.line || tomlErr.Column != tt.column {
+			t.Errorf("%q: expected error at %d:%d, got %v", tt.doc, tt.line, tt.column, err)
+		}
+	}
+}
+
+func TestParseTOMLTablesAfterSubtables(t *testing.T) {
+	docs := []string{
+		"[a.b]\nc = 1\n[a]\nd = 2\n",
+		"[[f]]\nx.y = 1\n[[f]]\n[f.x]\ny = 2\n",
+		"s = \"\"\"\n[a]\n\"\"\"\na.b = 1\n",
+	}
+	for _, doc := range docs {
+		if _, err := parseTOML([]byte(doc)); err != nil {
+			t.Errorf("Expected %q to parse, got %v", doc, err)
+		}
+	}
+}
+
+func TestParseActionTOML(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Cargo.toml"), []byte(cargoFixture), 0644); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.toml"), []byte("[package\nname = 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+	a := newTestAgent(t, dir)
+
+	observation := runActions(t, a, `{"type":"parse","parseType":"toml","path":"Cargo.toml","query":"$.package.version"}`)
+	if observation != "observation:parse success\n\"0.3.1\"" {
+		t.Errorf("Unexpected observation %q", observation)
+	}
+	observation = runActions(t, a, `{"type":"parse_toml","path":"Cargo.toml","query":"$.bin[*].name"}`)
+	if observation != "observation:parse_toml success\n[\n  \"demo\",\n  \"demo-cli\"\n]" {
+		t.Errorf("Unexpected observation %q", observation)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "limits.toml"), []byte("max = inf\nmin = -inf\nunset = nan\n"), 0644); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+	observation = runActions(t, a, `{"type":"parse_toml","path":"limits.toml"}`)
+	if observation != "observation:parse_toml success\n{\n  \"max\": \"inf\",\n  \"min\": \"-inf\",\n  \"unset\": \"nan\"\n}" {
+		t.Errorf("Expected non-finite floats as strings, got %q", observation)
+	}
+	observation = runActions(t, a, `{"type":"parse_toml","path":"broken.toml"}`)
+	if !strings.HasPrefix(observation, "observation:parse_toml error\nInvalid TOML: line 1, column 9") {
+		t.Errorf("Expected a positioned syntax error, got %q", observation)
+	}
+}
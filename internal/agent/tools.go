@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"terminusai/internal/providers"
+)
+
+// lookPath is exec.LookPath, replaceable in tests to simulate missing tools
+var lookPath = exec.LookPath
+
+// packageManagerTools are the executables install_package runs per manager
+var packageManagerTools = map[string][]string{
+	"npm":   {"npm"},
+	"pip":   {"pip"},
+	"apt":   {"sudo", "apt"},
+	"yum":   {"sudo", "yum"},
+	"brew":  {"brew"},
+	"choco": {"choco"},
+}
+
+// requiredTools returns the external executables action runs. Runners and
+// linters that can't be resolved are left for the handler to report.
+func (a *Agent) requiredTools(action *AgentAction) []string {
+	switch action.Type {
+	case "git":
+		return []string{"git"}
+	case "ping":
+		return []string{"ping"}
+	case "traceroute":
+		if runtime.GOOS == "windows" {
+			return []string{"tracert"}
+		}
+		return []string{"traceroute"}
+	case "ps":
+		if runtime.GOOS == "windows" {
+			return []string{"powershell"}
+		}
+		return []string{"ps"}
+	case "install_package":
+		return packageManagerTools[action.Manager]
+	case "run_tests":
+		if runner, err := findTestRunner(action.Runner, a.absPath(action.Path)); err == nil {
+			return runner.command("")[:1]
+		}
+	case "lint_file":
+		if linter, err := findLinter(action.Linter, action.Path); err == nil {
+			return linter.command(action.Path)[:1]
+		}
+	case "get_system_info":
+		switch runtime.GOOS {
+		case "windows":
+			return nil
+		case "linux":
+			return []string{"free", "df"}
+		default:
+			return []string{"df"}
+		}
+	}
+	return nil
+}
+
+// toolAvailable reports whether name is on PATH. Lookups are cached for the
+// session, so each tool is searched for once.
+func (a *Agent) toolAvailable(name string) bool {
+	a.toolsMu.Lock()
+	defer a.toolsMu.Unlock()
+
+	if available, ok := a.tools[name]; ok {
+		return available
+	}
+	if a.tools == nil {
+		a.tools = make(map[string]bool)
+	}
+	_, err := lookPath(name)
+	a.tools[name] = err == nil
+	return err == nil
+}
+
+// checkTools is the pre-flight check run before an action that needs external
+// tools: when one is missing it records a clear observation instead of letting
+// the action fail with a raw exec error, and reports false
+func (a *Agent) checkTools(action *AgentAction, transcript *[]providers.ChatMessage) bool {
+	var missing []string
+	for _, tool := range a.requiredTools(action) {
+		if !a.toolAvailable(tool) {
+			missing = append(missing, tool)
+		}
+	}
+	if len(missing) == 0 {
+		return true
+	}
+
+	message := fmt.Sprintf("%s is not installed (not found on PATH)", strings.Join(missing, " and "))
+	if len(missing) > 1 {
+		message = fmt.Sprintf("%s are not installed (not found on PATH)", strings.Join(missing, " and "))
+	}
+	actionUI := a.display.ShowAction("Pre-flight check", action.Type, false)
+	a.display.UpdateAction(actionUI, "failed", []string{message})
+
+	actionJSON, _ := json.Marshal(action)
+	*transcript = append(*transcript,
+		providers.ChatMessage{Role: "assistant", Content: string(actionJSON)},
+		providers.ChatMessage{Role: "user", Content: fmt.Sprintf("observation:%s error\n%s. Install it or choose an approach that doesn't need it.", action.Type, message)},
+	)
+	return false
+}
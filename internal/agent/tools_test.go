@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"terminusai/internal/providers"
+)
+
+func TestPreflightMissingTool(t *testing.T) {
+	lookups := map[string]int{}
+	lookPath = func(file string) (string, error) {
+		lookups[file]++
+		if file == "git" {
+			return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+		}
+		return "/usr/bin/" + file, nil
+	}
+	defer func() { lookPath = exec.LookPath }()
+
+	a := newTestAgent(t, t.TempDir())
+	for i := 0; i < 2; i++ {
+		var transcript []providers.ChatMessage
+		if err := a.executeAction(&AgentAction{Type: "git", Command: "status"}, &transcript); err != nil {
+			t.Fatalf("Failed to execute git: %v", err)
+		}
+		expected := "observation:git error\ngit is not installed (not found on PATH). Install it or choose an approach that doesn't need it."
+		if observation := transcript[len(transcript)-1].Content; observation != expected {
+			t.Errorf("Expected %q, got %q", expected, observation)
+		}
+	}
+	if lookups["git"] != 1 {
+		t.Errorf("Expected the lookup to be cached, got %d lookups", lookups["git"])
+	}
+
+	if !a.toolAvailable("sudo") {
+		t.Errorf("Expected sudo to be available")
+	}
+}
+
+func TestRequiredTools(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write package.json: %v", err)
+	}
+	a := newTestAgent(t, dir)
+
+	systemInfo := []string{"df"}
+	switch runtime.GOOS {
+	case "windows":
+		systemInfo = nil
+	case "linux":
+		systemInfo = []string{"free", "df"}
+	}
+
+	tests := []struct {
+		action   AgentAction
+		expected []string
+	}{
+		{AgentAction{Type: "install_package", Manager: "apt"}, []string{"sudo", "apt"}},
+		{AgentAction{Type: "install_package", Manager: "unknown"}, nil},
+		{AgentAction{Type: "read_file"}, nil},
+		{AgentAction{Type: "extract", ArchivePath: "logs.tar.gz"}, nil},
+		{AgentAction{Type: "run_tests", Path: "."}, []string{"npm"}},
+		{AgentAction{Type: "run_tests", Path: ".", Runner: "pytest"}, []string{pythonExecutable()}},
+		{AgentAction{Type: "run_tests", Path: "missing"}, nil},
+		{AgentAction{Type: "lint_file", Path: "main.go"}, []string{"go"}},
+		{AgentAction{Type: "lint_file", Path: "app.ts"}, []string{"npx"}},
+		{AgentAction{Type: "lint_file", Path: "notes.txt"}, nil},
+		{AgentAction{Type: "get_system_info"}, systemInfo},
+	}
+	for _, tt := range tests {
+		got := a.requiredTools(&tt.action)
+		if len(got) != len(tt.expected) {
+			t.Errorf("%s/%s: expected %v, got %v", tt.action.Type, tt.action.Manager, tt.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("%s/%s: expected %v, got %v", tt.action.Type, tt.action.Manager, tt.expected, got)
+			}
+		}
+	}
+
+	if _, err := lookPath("definitely-not-a-real-tool-name"); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing tool, got %v", err)
+	}
+}
@@ -1,8 +1,6 @@
 package agent
 
-import (
-	"strings"
-)
+import "terminusai/internal/providers"
 
 // truncateString truncates a string to a maximum length
 func truncateString(s string, maxLen int) string {
@@ -22,14 +20,5 @@ func min(a, b int) int {
 
 // isRetryableError checks if an error is worth retrying (API overload, timeout, etc.)
 func isRetryableError(err error) bool {
-	if err == nil {
-		return false
-	}
-	errStr := strings.ToLower(err.Error())
-	return strings.Contains(errStr, "overloaded") ||
-		strings.Contains(errStr, "timeout") ||
-		strings.Contains(errStr, "rate limit") ||
-		strings.Contains(errStr, "502") ||
-		strings.Contains(errStr, "503") ||
-		strings.Contains(errStr, "504")
+	return providers.IsRetryableError(err)
 }
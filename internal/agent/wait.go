@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// shellInvocation returns the program and arguments that run command in the
+// named shell (PowerShell when the shell is unknown)
+func shellInvocation(shell, command string) (string, []string) {
+	switch shell {
+	case "cmd":
+		return "cmd", []string{"/c", command}
+	case "bash":
+		return "bash", []string{"-c", command}
+	default:
+		return "powershell.exe", []string{"-Command", command}
+	}
+}
+
+// pollUntil calls check every interval until it returns true, the timeout
+// elapses (context.DeadlineExceeded) or ctx is cancelled. It returns the
+// number of checks made.
+func pollUntil(ctx context.Context, timeout, interval time.Duration, check func(ctx context.Context) bool) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for attempts := 1; ; attempts++ {
+		if check(ctx) {
+			return attempts, nil
+		}
+		select {
+		case <-ctx.Done():
+			return attempts, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitCheck returns the check for a wait_for condition and a description of it
+func (a *Agent) waitCheck(action *AgentAction) (func(ctx context.Context) bool, string) {
+	switch action.Condition {
+	case "file":
+		path := action.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(a.workingDir, path)
+		}
+		return func(ctx context.Context) bool {
+			_, err := os.Stat(path)
+			return err == nil
+		}, fmt.Sprintf("file %s exists", action.Path)
+
+	case "port":
+		address := net.JoinHostPort(action.Host, strconv.Itoa(*action.Port))
+		return func(ctx context.Context) bool {
+			var dialer net.Dialer
+			dialCtx, cancel := context.WithTimeout(ctx, time.Second)
+			defer cancel()
+			conn, err := dialer.DialContext(dialCtx, "tcp", address)
+			if err != nil {
+				return false
+			}
+			conn.Close()
+			return true
+		}, fmt.Sprintf("port %s is open", address)
+
+	default:
+		shell, args := shellInvocation(action.Shell, action.Command)
+		return func(ctx context.Context) bool {
+			cmd := exec.CommandContext(ctx, shell, args...)
+			cmd.Env = a.environ()
+			cmd.Dir = a.workingDir
+			return cmd.Run() == nil
+		}, fmt.Sprintf("command %q succeeds", action.Command)
+	}
+}
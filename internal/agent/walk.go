@@ -0,0 +1,224 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// walkOptions bounds a directory walk
+type walkOptions struct {
+	MaxDepth       int             // Deepest level visited below the root (0 = unlimited)
+	FollowSymlinks bool            // Descend into symlinked directories
+	IgnoreDirs     map[string]bool // Directory names skipped below the root
+	OpenFiles      fileLimiter     // Caps files read at once (nil = unlimited)
+	// RespectGitignore skips paths excluded by the .gitignore files in effect
+	RespectGitignore bool
+	gitignore        *gitignore // Rules for the directory being walked
+}
+
+// fileLimiter is a semaphore bounding how many files walks hold open at once,
+// so parallel hashing stays within low file-descriptor limits
+type fileLimiter chan struct{}
+
+// newFileLimiter allows n files open at once (n <= 0 = unlimited)
+func newFileLimiter(n int) fileLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return make(fileLimiter, n)
+}
+
+// readFile reads a file once a slot is free
+func (l fileLimiter) readFile(path string) ([]byte, error) {
+	if l != nil {
+		l <- struct{}{}
+		defer func() { <-l }()
+	}
+	return os.ReadFile(path)
+}
+
+// hashFile hashes a file once a slot is free
+func (l fileLimiter) hashFile(path, algo string) (string, error) {
+	if l != nil {
+		l <- struct{}{}
+		defer func() { <-l }()
+	}
+	return hashFile(path, algo)
+}
+
+// walkOptionsFor builds walk options from an action's maxDepth/followSymlinks
+// fields and the agent's effective ignore set
+func (a *Agent) walkOptionsFor(action *AgentAction) walkOptions {
+	opts := walkOptions{IgnoreDirs: a.ignoreDirs, OpenFiles: a.openFiles}
+	if action.MaxDepth != nil {
+		opts.MaxDepth = *action.MaxDepth
+	}
+	if action.FollowSymlinks != nil {
+		opts.FollowSymlinks = *action.FollowSymlinks
+	}
+	return opts
+}
+
+// walkTree walks root like filepath.Walk, but stops at opts.MaxDepth and can
+// follow symlinked directories. When following symlinks, directories already
+// visited are tracked by file identity (inode) so link cycles end the descent.
+func walkTree(root string, opts walkOptions, fn filepath.WalkFunc) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+
+	if opts.RespectGitignore {
+		opts.gitignore = loadGitignore(root)
+	}
+
+	visited := &visitedDirs{ids: make(map[fileID]bool)}
+	err = walkEntry(root, info, 0, opts, visited, fn)
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// fileID identifies a file by device and inode
+type fileID struct {
+	dev, ino uint64
+}
+
+// visitedDirs is the set of directories a walk following symlinks has entered.
+// Directories are looked up by fileID where the platform reports one, falling
+// back to comparing with os.SameFile.
+type visitedDirs struct {
+	ids   map[fileID]bool
+	infos []os.FileInfo
+}
+
+// add records info and reports whether it was not visited before
+func (v *visitedDirs) add(info os.FileInfo) bool {
+	if id, ok := fileIDOf(info); ok {
+		if v.ids[id] {
+			return false
+		}
+		v.ids[id] = true
+		return true
+	}
+	for _, seen := range v.infos {
+		if os.SameFile(seen, info) {
+			return false
+		}
+	}
+	v.infos = append(v.infos, info)
+	return true
+}
+
+// walkEntry visits path and, for directories, its children
+func walkEntry(path string, info os.FileInfo, depth int, opts walkOptions, visited *visitedDirs, fn filepath.WalkFunc) error {
+	if err := fn(path, info, nil); err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	if depth > 0 && opts.IgnoreDirs[info.Name()] {
+		return nil
+	}
+
+	if opts.FollowSymlinks && !visited.add(info) {
+		return nil // Already walked (symlink cycle or duplicate link)
+	}
+
+	if opts.MaxDepth > 0 && depth >= opts.MaxDepth {
+		return nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		if err := fn(path, info, err); err != nil && err != filepath.SkipDir {
+			return err
+		}
+		return nil
+	}
+
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.Name())
+		childInfo, err := os.Lstat(child)
+		if err != nil {
+			if err := fn(child, nil, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+
+		if opts.FollowSymlinks && childInfo.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(child)
+			if err != nil {
+				continue // Broken link
+			}
+			childInfo = target
+		}
+
+		// Paths excluded by .gitignore are neither visited nor descended into
+		if opts.gitignore.ignored(child, childInfo.IsDir()) {
+			continue
+		}
+		childOpts := opts
+		if childInfo.IsDir() {
+			childOpts.gitignore = opts.gitignore.enter(child)
+		}
+
+		if err := walkEntry(child, childInfo, depth+1, childOpts, visited, fn); err != nil {
+			if err != filepath.SkipDir {
+				return err
+			}
+			if !childInfo.IsDir() {
+				// SkipDir on a file skips the rest of its directory
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// defaultIgnoreDirs are dependency/build directories skipped by tree-wide
+// operations unless the user's ignore-dirs/unignore-dirs config says otherwise
+var defaultIgnoreDirs = map[string]bool{
+	"node_modules": true,
+	".git":         true,
+	".venv":        true,
+	"__pycache__":  true,
+	"dist":         true,
+	"build":        true,
+	"target":       true,
+	"coverage":     true,
+}
+
+// mergeIgnoreDirs builds the effective ignore set: the defaults minus remove,
+// plus add. Additions win over removals so the result never depends on order.
+func mergeIgnoreDirs(add, remove []string) map[string]bool {
+	dirs := make(map[string]bool, len(defaultIgnoreDirs)+len(add))
+	for name := range defaultIgnoreDirs {
+		dirs[name] = true
+	}
+	for _, name := range remove {
+		delete(dirs, strings.TrimSpace(name))
+	}
+	for _, name := range add {
+		if name = strings.TrimSpace(name); name != "" {
+			dirs[name] = true
+		}
+	}
+	return dirs
+}
+
+// sortedDirNames lists an ignore set in a stable order for display
+func sortedDirNames(dirs map[string]bool) []string {
+	names := make([]string, 0, len(dirs))
+	for name := range dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
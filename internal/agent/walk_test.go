@@ -0,0 +1,208 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestWalkTreeSelfReferentialSymlink(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "sub", "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	// sub/loop points back at the root, forming a cycle
+	if err := os.Symlink(root, filepath.Join(root, "sub", "loop")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	for _, follow := range []bool{false, true} {
+		var files []string
+		err := walkTree(root, walkOptions{FollowSymlinks: follow}, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if !info.IsDir() && info.Mode()&os.ModeSymlink == 0 {
+				rel, _ := filepath.Rel(root, path)
+				files = append(files, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("walkTree(follow=%v) failed: %v", follow, err)
+		}
+		sort.Strings(files)
+		if len(files) != 1 || files[0] != filepath.Join("sub", "file.txt") {
+			t.Errorf("walkTree(follow=%v): expected only sub/file.txt, got %v", follow, files)
+		}
+	}
+}
+
+func TestVisitedDirs(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	stat := func(path string) os.FileInfo {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", path, err)
+		}
+		return info
+	}
+
+	visited := &visitedDirs{ids: make(map[fileID]bool)}
+	if !visited.add(stat(root)) || !visited.add(stat(filepath.Join(root, "sub"))) {
+		t.Fatalf("Expected new directories to be added")
+	}
+	// A second FileInfo for the same directory, as reached through a link
+	if visited.add(stat(filepath.Join(root, "sub", ".."))) {
+		t.Errorf("Expected the root to be recognised as visited")
+	}
+}
+
+func TestWalkTreeMaxDepth(t *testing.T) {
+	root := t.TempDir()
+	deep := filepath.Join(root, "a", "b", "c")
+	if err := os.MkdirAll(deep, 0755); err != nil {
+		t.Fatalf("Failed to create dirs: %v", err)
+	}
+	for _, p := range []string{filepath.Join(root, "top.txt"), filepath.Join(root, "a", "mid.txt"), filepath.Join(deep, "deep.txt")} {
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		maxDepth int
+		expected int
+	}{
+		{1, 1},
+		{2, 2},
+		{0, 3}, // unlimited
+	}
+
+	for _, tt := range tests {
+		count := 0
+		walkTree(root, walkOptions{MaxDepth: tt.maxDepth}, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				count++
+			}
+			return nil
+		})
+		if count != tt.expected {
+			t.Errorf("maxDepth=%d: expected %d files, got %d", tt.maxDepth, tt.expected, count)
+		}
+	}
+}
+
+func TestMergeIgnoreDirs(t *testing.T) {
+	tests := []struct {
+		name      string
+		add       []string
+		remove    []string
+		ignored   []string
+		traversed []string
+	}{
+		{"defaults", nil, nil, []string{"node_modules", "build", "coverage"}, []string{"src"}},
+		{"remove default", nil, []string{"build", " coverage "}, []string{"node_modules"}, []string{"build", "coverage"}},
+		{"add extra", []string{"vendor"}, nil, []string{"vendor", "target"}, []string{"src"}},
+		{"add wins over remove", []string{"build"}, []string{"build"}, []string{"build"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dirs := mergeIgnoreDirs(tt.add, tt.remove)
+			for _, name := range tt.ignored {
+				if !dirs[name] {
+					t.Errorf("Expected %q to be ignored", name)
+				}
+			}
+			for _, name := range tt.traversed {
+				if dirs[name] {
+					t.Errorf("Expected %q to be traversed", name)
+				}
+			}
+		})
+	}
+
+	if len(defaultIgnoreDirs) != 8 {
+		t.Errorf("Expected merging not to modify the defaults, got %v", defaultIgnoreDirs)
+	}
+}
+
+func TestWalkTreeIgnoreDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{"build/out.txt", "src/main.go"} {
+		path := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		remove   []string
+		expected int
+	}{
+		{"build skipped by default", nil, 1},
+		{"build searched when unignored", []string{"build"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count := 0
+			walkTree(root, walkOptions{IgnoreDirs: mergeIgnoreDirs(nil, tt.remove)}, func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					count++
+				}
+				return nil
+			})
+			if count != tt.expected {
+				t.Errorf("Expected %d files, got %d", tt.expected, count)
+			}
+		})
+	}
+}
+
+func TestFileLimiter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("abc"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if data, err := newFileLimiter(0).readFile(path); err != nil || string(data) != "abc" {
+		t.Errorf("Expected unlimited limiter to read the file, got %q (%v)", data, err)
+	}
+
+	limiter := newFileLimiter(1)
+	limiter <- struct{}{} // Occupy the only slot
+	done := make(chan struct{})
+	go func() {
+		limiter.readFile(path)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatalf("Expected read to wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+	<-limiter
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected read to finish once the slot was released")
+	}
+	if len(limiter) != 0 {
+		t.Errorf("Expected the slot to be released after reading, %d in use", len(limiter))
+	}
+}
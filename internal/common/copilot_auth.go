@@ -1,9 +1,9 @@
 package common
 
 import (
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -19,12 +19,7 @@ func CopilotDeviceFlowAuth() (string, error) {
 	// GitHub Copilot Client ID
 	clientID := "Iv1.b507a08c87ecfe98"
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-		Timeout: 30 * time.Second,
-	}
+	client := NewHTTPClient(30 * time.Second)
 
 	// Start device flow
 	params := url.Values{}
@@ -190,15 +185,20 @@ type CopilotModelsResponse struct {
 	Object string         `json:"object"`
 }
 
-// GetCopilotModels fetches available models from GitHub Copilot API
+// GetCopilotModels fetches available models from GitHub Copilot API, using
+// the on-disk models cache while it is fresh
 func GetCopilotModels(token string) ([]string, error) {
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-		Timeout: 30 * time.Second,
+	var cached CopilotModelsResponse
+	if LoadModelsCache("copilot", ModelsCacheTTL, &cached) && len(cached.Data) > 0 {
+		var modelIDs []string
+		for _, model := range cached.Data {
+			modelIDs = append(modelIDs, model.ID)
+		}
+		return modelIDs, nil
 	}
 
+	client := NewHTTPClient(30 * time.Second)
+
 	req, err := http.NewRequest("GET", "https://api.githubcopilot.com/models", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -230,8 +230,12 @@ func GetCopilotModels(token string) ([]string, error) {
 		}, nil
 	}
 
+	body, err := io.ReadAll(resp.Body)
 	var modelsResp CopilotModelsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+	if err == nil {
+		err = json.Unmarshal(body, &modelsResp)
+	}
+	if err != nil {
 		// Fall back to known models if decode fails
 		return []string{
 			"gpt-4o",
@@ -259,6 +263,7 @@ func GetCopilotModels(token string) ([]string, error) {
 		}, nil
 	}
 
+	SaveModelsCache("copilot", body)
 	return modelIDs, nil
 }
 
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// InsecureTLSEnv names the environment variable that turns off certificate
+// verification for provider traffic, e.g. behind an intercepting proxy
+const InsecureTLSEnv = "TERMINUS_AI_INSECURE_TLS"
+
+// InsecureTLSEnabled reports whether the user opted out of certificate verification
+func InsecureTLSEnabled() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(InsecureTLSEnv))) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// NewHTTPTransport returns the transport used for provider requests. It
+// verifies certificates unless InsecureTLSEnv is set.
+func NewHTTPTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if InsecureTLSEnabled() {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return transport
+}
+
+// NewHTTPClient returns a client using NewHTTPTransport; a zero timeout means none
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: NewHTTPTransport(),
+		Timeout:   timeout,
+	}
+}
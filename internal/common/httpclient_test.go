@@ -0,0 +1,40 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClientVerifiesCertificates(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		insecure bool
+	}{
+		{name: "unset", env: "", insecure: false},
+		{name: "disabled", env: "0", insecure: false},
+		{name: "opted in", env: "1", insecure: true},
+		{name: "opted in with true", env: "TRUE", insecure: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(InsecureTLSEnv, tt.env)
+
+			client := NewHTTPClient(5 * time.Second)
+			if client.Timeout != 5*time.Second {
+				t.Errorf("Expected timeout 5s, got %v", client.Timeout)
+			}
+
+			transport, ok := client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("Expected an *http.Transport, got %T", client.Transport)
+			}
+			insecure := transport.TLSClientConfig != nil && transport.TLSClientConfig.InsecureSkipVerify
+			if insecure != tt.insecure {
+				t.Errorf("Expected InsecureSkipVerify %v, got %v", tt.insecure, insecure)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ModelsCacheTTL is how long a cached provider models list is used before
+// it is fetched again
+const ModelsCacheTTL = 24 * time.Hour
+
+// modelsCacheEntry is the on-disk form of a cached models response
+type modelsCacheEntry struct {
+	FetchedAt time.Time       `json:"fetchedAt"`
+	Models    json.RawMessage `json:"models"` // Raw models API response
+}
+
+// ModelsCachePath returns the cache file for a provider's models list
+func ModelsCachePath(provider string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ConfigDirName, "cache", provider+"-models.json"), nil
+}
+
+// LoadModelsCache decodes a provider's cached models response into v. It
+// returns false when there is no cache, it is unreadable or older than maxAge.
+func LoadModelsCache(provider string, maxAge time.Duration, v interface{}) bool {
+	path, err := ModelsCachePath(provider)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	var entry modelsCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || len(entry.Models) == 0 {
+		return false
+	}
+	if maxAge > 0 && time.Since(entry.FetchedAt) > maxAge {
+		return false
+	}
+	return json.Unmarshal(entry.Models, v) == nil
+}
+
+// SaveModelsCache stores a raw models API response for a provider
+func SaveModelsCache(provider string, body []byte) error {
+	if !json.Valid(body) {
+		return fmt.Errorf("models response is not valid JSON")
+	}
+	path, err := ModelsCachePath(provider)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(modelsCacheEntry{FetchedAt: time.Now(), Models: body})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
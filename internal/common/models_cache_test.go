@@ -0,0 +1,53 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestModelsCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+
+	var resp CopilotModelsResponse
+	if LoadModelsCache("copilot", ModelsCacheTTL, &resp) {
+		t.Fatalf("Expected no cache before saving")
+	}
+	if err := SaveModelsCache("copilot", []byte("not json")); err == nil {
+		t.Errorf("Expected invalid JSON to be rejected")
+	}
+	if err := SaveModelsCache("copilot", []byte(`{"data":[{"id":"gpt-4o"}]}`)); err != nil {
+		t.Fatalf("Expected save to succeed, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		maxAge   time.Duration
+		age      time.Duration
+		expected bool
+	}{
+		{"fresh", ModelsCacheTTL, time.Minute, true},
+		{"stale", ModelsCacheTTL, 2 * ModelsCacheTTL, false},
+		{"any age", 0, 2 * ModelsCacheTTL, true},
+	}
+
+	path, _ := ModelsCachePath("copilot")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, _ := json.Marshal(modelsCacheEntry{FetchedAt: time.Now().Add(-tt.age), Models: json.RawMessage(`{"data":[{"id":"gpt-4o"}]}`)})
+			if err := os.WriteFile(path, entry, 0600); err != nil {
+				t.Fatalf("Failed to write cache: %v", err)
+			}
+
+			var got CopilotModelsResponse
+			if ok := LoadModelsCache("copilot", tt.maxAge, &got); ok != tt.expected {
+				t.Errorf("Expected load %t, got %t", tt.expected, ok)
+			}
+			if tt.expected && (len(got.Data) != 1 || got.Data[0].ID != "gpt-4o") {
+				t.Errorf("Expected cached model gpt-4o, got %+v", got)
+			}
+		})
+	}
+}
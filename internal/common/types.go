@@ -2,15 +2,33 @@ package common
 
 // TerminusAIConfig represents the application configuration
 type TerminusAIConfig struct {
-	Provider            string `json:"provider,omitempty"`
-	Model               string `json:"model,omitempty"`
-	AlwaysAllow         bool   `json:"alwaysAllow,omitempty"`
-	MaxTokensPerRequest int    `json:"maxTokensPerRequest,omitempty"` // 0 = use model's max context
-	OpenAIAPIKey        string `json:"openaiApiKey,omitempty"`
-	AnthropicAPIKey     string `json:"anthropicApiKey,omitempty"`
-	GitHubToken         string `json:"githubToken,omitempty"`
-	GitHubModelsBaseURL string `json:"githubModelsBaseUrl,omitempty"`
-	GitHubClientID      string `json:"githubClientId,omitempty"`
+	Provider                string   `json:"provider,omitempty"`
+	Model                   string   `json:"model,omitempty"`
+	AlwaysAllow             bool     `json:"alwaysAllow,omitempty"`
+	MaxTokensPerRequest     int      `json:"maxTokensPerRequest,omitempty"`     // 0 = use model's max context
+	MaxObservationBytes     int      `json:"maxObservationBytes,omitempty"`     // 0 = per-handler defaults
+	SafeShell               bool     `json:"safeShell,omitempty"`               // Vet shell commands before approval
+	SafeShellAllow          []string `json:"safeShellAllow,omitempty"`          // Safe-shell rules to permit anyway
+	TrustedDirs             []string `json:"trustedDirs,omitempty"`             // Directories where file changes are auto-approved
+	DangerousCommands       []string `json:"dangerousCommands,omitempty"`       // Extra regexes for commands that always need confirmation
+	IgnoreDirs              []string `json:"ignoreDirs,omitempty"`              // Extra directory names skipped by searches
+	UnignoreDirs            []string `json:"unignoreDirs,omitempty"`            // Default skipped directories to search anyway
+	AllowModelSwitch        bool     `json:"allowModelSwitch,omitempty"`        // Let the agent change model/temperature mid-session
+	ActionInterval          string   `json:"actionInterval,omitempty"`          // Minimum time between actions, e.g. "500ms" (empty = no throttling)
+	PersistentShell         bool     `json:"persistentShell,omitempty"`         // Keep one shell per task so cd/env changes persist
+	CorrectionTemplate      string   `json:"correctionTemplate,omitempty"`      // Feedback sent after an invalid action (empty = built-in)
+	MaxOpenFiles            int      `json:"maxOpenFiles,omitempty"`            // Files search/hash walks may hold open at once (0 = default)
+	TemplatesDir            string   `json:"templatesDir,omitempty"`            // Scaffolds used by from_template (empty = ~/.terminusai/templates)
+	ObservationSummaryBytes int      `json:"observationSummaryBytes,omitempty"` // Summarize observations above this size (0 = default, negative = never)
+	MaxFileChanges          int      `json:"maxFileChanges,omitempty"`          // File-changing actions per run before asking the user (0 = unlimited)
+	MaxConcurrentTasks      int      `json:"maxConcurrentTasks,omitempty"`      // Tasks the server runs at once (0 = unlimited)
+	AuditLog                string   `json:"auditLog,omitempty"`                // Audit log path (empty = ~/.terminusai/audit.log, "off" = disabled)
+	AuditLogMaxBytes        int      `json:"auditLogMaxBytes,omitempty"`        // Size at which the audit log is rotated (0 = 10MB)
+	OpenAIAPIKey            string   `json:"openaiApiKey,omitempty"`
+	AnthropicAPIKey         string   `json:"anthropicApiKey,omitempty"`
+	GitHubToken             string   `json:"githubToken,omitempty"`
+	GitHubModelsBaseURL     string   `json:"githubModelsBaseUrl,omitempty"`
+	GitHubClientID          string   `json:"githubClientId,omitempty"`
 }
 
 // Constants for the application
@@ -29,4 +47,5 @@ const (
 	ProviderOpenAI    = "openai"
 	ProviderAnthropic = "anthropic"
 	ProviderCopilot   = "copilot"
+	ProviderOllama    = "ollama"
 )
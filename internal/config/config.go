@@ -72,7 +72,7 @@ func EnsureEnv(forceSetup bool) (*TerminusAIConfig, error) {
 		return nil, err
 	}
 
-	needsSetup := forceSetup || !(cfg.Provider != "" && (cfg.OpenAIAPIKey != "" || cfg.AnthropicAPIKey != "" || (cfg.Provider == "copilot" && cfg.GitHubToken != "")))
+	needsSetup := forceSetup || !(cfg.Provider != "" && (cfg.OpenAIAPIKey != "" || cfg.AnthropicAPIKey != "" || (cfg.Provider == "copilot" && cfg.GitHubToken != "") || cfg.Provider == "ollama"))
 
 	if needsSetup {
 		updated, err := SetupWizard(cfg)
@@ -95,7 +95,7 @@ func SetupWizard(existing *TerminusAIConfig) (*TerminusAIConfig, error) {
 	// Choose provider
 	providerPrompt := promptui.Select{
 		Label: "Select default provider",
-		Items: []string{"OpenAI", "Anthropic (Claude)", "GitHub Copilot"},
+		Items: []string{"OpenAI", "Anthropic (Claude)", "GitHub Copilot", "Ollama (local, no API key)"},
 	}
 
 	_, providerResult, err := providerPrompt.Run()
@@ -110,6 +110,8 @@ func SetupWizard(existing *TerminusAIConfig) (*TerminusAIConfig, error) {
 		answers.Provider = "anthropic"
 	case "GitHub Copilot":
 		answers.Provider = "copilot"
+	case "Ollama (local, no API key)":
+		answers.Provider = "ollama"
 	}
 
 	// Provider-specific credentials
@@ -154,6 +156,10 @@ func SetupWizard(existing *TerminusAIConfig) (*TerminusAIConfig, error) {
 		if err := handleCopilotAuth(&answers, existing); err != nil {
 			return nil, err
 		}
+
+	case "ollama":
+		// Runs locally; the server address comes from OLLAMA_HOST if set
+		fmt.Println("Ollama needs no API key. Make sure the server is running (ollama serve) and the model is pulled (ollama pull <model>).")
 	}
 
 	// Preferred model (optional) - skip for copilot as it handles model selection during auth
@@ -238,6 +244,8 @@ func KnownModels(provider string) []string {
 		return []string{"claude-3-5-sonnet-latest", "claude-3-5-haiku-latest", "claude-3-opus-latest"}
 	case "copilot":
 		return []string{"gpt-4o-mini", "gpt-4o"}
+	case "ollama":
+		return []string{"llama3.2", "qwen2.5-coder", "mistral"}
 	default:
 		return []string{}
 	}
@@ -256,3 +256,21 @@ func indexof(s, substr string) int {
 	}
 	return -1
 }
+
+func TestGetBudget(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+
+	cm := NewConfigManager()
+	cm.globalSettings.Defaults.TokenBudget = 50000
+	cm.globalSettings.Defaults.MaxCost = 2
+
+	if tokens, cost := cm.GetBudget(); tokens != 50000 || cost != 2 {
+		t.Errorf("Expected the settings defaults 50000/2, got %d/%v", tokens, cost)
+	}
+
+	cm.SetBudget(1000, 0)
+	if tokens, cost := cm.GetBudget(); tokens != 1000 || cost != 2 {
+		t.Errorf("Expected the session token budget with the default cost, got %d/%v", tokens, cost)
+	}
+}
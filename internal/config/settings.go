@@ -25,6 +25,11 @@ type RuntimeSettings struct {
 	ProviderOverride string `json:"-"` // Not persisted
 	ModelOverride    string `json:"-"` // Not persisted
 
+	// Budget: a run stops once the provider has reported more tokens, or at
+	// list prices more USD, than this (0 = unlimited)
+	TokenBudget int     `json:"tokenBudget,omitempty"`
+	MaxCost     float64 `json:"maxCost,omitempty"`
+
 	// Session settings
 	DryRun    bool `json:"-"` // Not persisted
 	SetupMode bool `json:"-"` // Not persisted
@@ -139,6 +144,10 @@ func getDefaultGlobalSettings() *GlobalSettings {
 				Models:       []string{"gpt-4o", "gpt-4o-mini"},
 				BaseURL:      "https://models.inference.ai.azure.com",
 			},
+			"ollama": {
+				Enabled:      true,
+				DefaultModel: "llama3.2",
+			},
 		},
 		Features: FeatureFlags{
 			AgentMode:       true,
@@ -194,6 +203,44 @@ func (cm *ConfigManager) GetTemperature() *float64 {
 	return cm.runtimeSettings.Temperature
 }
 
+// SetDryRun sets whether actions that change the system are only described
+func (cm *ConfigManager) SetDryRun(dryRun bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.runtimeSettings.DryRun = dryRun
+}
+
+// IsDryRun returns the current dry-run setting
+func (cm *ConfigManager) IsDryRun() bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.runtimeSettings.DryRun
+}
+
+// SetBudget sets the token and cost budget for runs in this session
+func (cm *ConfigManager) SetBudget(tokenBudget int, maxCost float64) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.runtimeSettings.TokenBudget = tokenBudget
+	cm.runtimeSettings.MaxCost = maxCost
+}
+
+// GetBudget returns the token and cost budget of a run: the session's when
+// set, otherwise the defaults from settings.json (0 = unlimited)
+func (cm *ConfigManager) GetBudget() (tokenBudget int, maxCost float64) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	tokenBudget, maxCost = cm.runtimeSettings.TokenBudget, cm.runtimeSettings.MaxCost
+	if tokenBudget == 0 {
+		tokenBudget = cm.globalSettings.Defaults.TokenBudget
+	}
+	if maxCost == 0 {
+		maxCost = cm.globalSettings.Defaults.MaxCost
+	}
+	return tokenBudget, maxCost
+}
+
 // SetProviderOverride sets a temporary provider override
 func (cm *ConfigManager) SetProviderOverride(provider string) {
 	cm.mu.Lock()
@@ -0,0 +1,100 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/fatih/color"
+	"github.com/manifoldco/promptui"
+)
+
+// dangerousCommand is a denylist entry: commands matching it can wreck the
+// system and are never approved without an explicit confirmation
+type dangerousCommand struct {
+	reason  string
+	pattern *regexp.Regexp
+}
+
+// builtinDangerousCommands are always consulted; config can add more
+var builtinDangerousCommands = []dangerousCommand{
+	{"recursive delete of the filesystem root or home directory", regexp.MustCompile(`\brm\s+(?:-\S+\s+)*(?:/\*?|~/?\*?|\$HOME/?\*?|"\$HOME"/?)(?:\s|;|&|\||$)`)},
+	{"rm with --no-preserve-root", regexp.MustCompile(`\brm\s.*--no-preserve-root`)},
+	{"filesystem creation", regexp.MustCompile(`\bmkfs(?:\.\w+)?\b`)},
+	{"raw write to a disk device", regexp.MustCompile(`\bdd\b.*\bof=/dev/(?:sd|hd|vd|xvd|nvme|mmcblk|disk)\w*`)},
+	{"redirection onto a disk device", regexp.MustCompile(`>\s*/dev/(?:sd|hd|vd|xvd|nvme|mmcblk|disk)\w*`)},
+	{"fork bomb", regexp.MustCompile(`:\s*\(\s*\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`)},
+	{"recursive permission change of the filesystem root", regexp.MustCompile(`\bch(?:mod|own)\s+(?:-\S+\s+)*-\w*R\w*\s+(?:-\S+\s+)*\S+\s+/(?:\s|;|&|\||$)`)},
+	{"drive format", regexp.MustCompile(`(?i)\bformat(?:-volume)?\s+(?:/\S+\s+)*[a-z]:`)},
+	{"recursive delete of a drive root", regexp.MustCompile(`(?i)\b(?:rd|rmdir|del)\s+(?:/\w\s+)*[a-z]:\\?(?:\*|\s|$)`)},
+}
+
+// SetDangerousCommands adds regexes to the built-in dangerous-command
+// denylist. Patterns that don't compile are skipped and returned as an error.
+func (s *Store) SetDangerousCommands(patterns []string) error {
+	s.dangerous = nil
+	var invalid error
+	for _, expr := range patterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			invalid = fmt.Errorf("invalid dangerous-command pattern %q: %w", expr, err)
+			continue
+		}
+		s.dangerous = append(s.dangerous, dangerousCommand{reason: "matches configured pattern " + expr, pattern: re})
+	}
+	return invalid
+}
+
+// ValidateDangerousCommands checks that every configured pattern compiles
+func ValidateDangerousCommands(patterns []string) error {
+	for _, expr := range patterns {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid dangerous-command pattern %q: %w", expr, err)
+		}
+	}
+	return nil
+}
+
+// DangerousReason returns why command is considered dangerous, or "" when it
+// matches neither the built-in nor the configured denylist
+func (s *Store) DangerousReason(command string) string {
+	for _, list := range [][]dangerousCommand{builtinDangerousCommands, s.dangerous} {
+		for _, entry := range list {
+			if entry.pattern.MatchString(command) {
+				return entry.reason
+			}
+		}
+	}
+	return ""
+}
+
+// confirmDangerous asks for an explicit decision on a dangerous command. It is
+// never answered by always-allow or a persisted rule, and an "always" answer
+// only allows this one run.
+func (s *Store) confirmDangerous(actionType, command, description, reason string) (Decision, error) {
+	if s.prompter != nil {
+		decision, err := s.prompter(actionType, command, fmt.Sprintf("DANGEROUS (%s): %s", reason, description))
+		if err != nil {
+			return DecisionSkip, fmt.Errorf("%w: %v", ErrApprovalAborted, err)
+		}
+		if decision == DecisionAlways || decision == DecisionAlwaysType {
+			decision = DecisionOnce
+		}
+		return decision, nil
+	}
+
+	s.displayCommandInfo(actionType, command, description)
+	color.New(color.FgRed, color.Bold).Printf("⛔ Dangerous command: %s\n\n", reason)
+
+	prompt := promptui.Select{
+		Label: "This command can destroy data. Run it anyway?",
+		Items: []string{"Skip this command", "Run it once"},
+	}
+	_, result, err := prompt.Run()
+	if err != nil {
+		return DecisionSkip, fmt.Errorf("%w: %v", ErrApprovalAborted, err)
+	}
+	if result == "Run it once" {
+		return DecisionOnce, nil
+	}
+	return DecisionSkip, nil
+}
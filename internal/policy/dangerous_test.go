@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDangerousReason(t *testing.T) {
+	tests := []struct {
+		command   string
+		dangerous bool
+	}{
+		{"rm -rf /", true},
+		{"sudo rm -rf /*", true},
+		{"rm -rf ~", true},
+		{"rm -fr $HOME/", true},
+		{"cd /tmp && rm -rf / ; echo done", true},
+		{"rm -rf --no-preserve-root /", true},
+		{"mkfs.ext4 /dev/sdb1", true},
+		{"dd if=/dev/zero of=/dev/sda bs=1M", true},
+		{"cat image.iso > /dev/nvme0n1", true},
+		{":(){ :|:& };:", true},
+		{"chmod -R 777 /", true},
+		{"format c: /q", true},
+		{"rd /s /q C:\\", true},
+		{"rm -rf build", false},
+		{"rm -rf /tmp/build", false},
+		{"rm -rf ./dist/", false},
+		{"dd if=/dev/zero of=disk.img bs=1M count=10", false},
+		{"chmod -R 755 ./public", false},
+		{"echo hello > /dev/null", false},
+		{"git status", false},
+	}
+
+	store := &Store{}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			reason := store.DangerousReason(tt.command)
+			if (reason != "") != tt.dangerous {
+				t.Errorf("Expected dangerous=%t for %q, got reason %q", tt.dangerous, tt.command, reason)
+			}
+		})
+	}
+}
+
+func TestApproveBlocksDangerousCommandWithAlwaysAllow(t *testing.T) {
+	store := &Store{rules: []Rule{{Pattern: "rm *", Decision: DecisionAlways}}}
+	store.SetAlwaysAllow(true)
+
+	var prompted string
+	store.SetPrompter(func(actionType, command, description string) (Decision, error) {
+		prompted = description
+		return DecisionSkip, nil
+	})
+
+	decision, err := store.Approve("shell", "rm -rf /", "Clean up")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if decision != DecisionSkip {
+		t.Errorf("Expected rm -rf / to be blocked, got %q", decision)
+	}
+	if !strings.Contains(prompted, "DANGEROUS") {
+		t.Errorf("Expected an explicit dangerous-command prompt, got %q", prompted)
+	}
+
+	// Harmless commands still go through always-allow without a prompt
+	prompted = ""
+	if decision, _ := store.Approve("shell", "ls -la", "List"); decision != DecisionAlways || prompted != "" {
+		t.Errorf("Expected ls to be always-allowed without a prompt, got %q (prompt %q)", decision, prompted)
+	}
+}
+
+func TestApproveDangerousCommandConfirmation(t *testing.T) {
+	store := &Store{}
+	store.SetAlwaysAllow(true)
+	store.SetPrompter(func(actionType, command, description string) (Decision, error) {
+		return DecisionAlways, nil
+	})
+
+	decision, err := store.Approve("shell", "mkfs.ext4 /dev/sdb1", "Format disk")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if decision != DecisionOnce {
+		t.Errorf("Expected an always answer to allow only once, got %q", decision)
+	}
+	if len(store.rules) != 0 {
+		t.Errorf("Expected no rule to be persisted for a dangerous command, got %v", store.rules)
+	}
+
+	store.SetPrompter(func(actionType, command, description string) (Decision, error) {
+		return DecisionSkip, errors.New("no terminal")
+	})
+	if _, err := store.Approve("shell", "mkfs.ext4 /dev/sdb1", "Format disk"); !errors.Is(err, ErrApprovalAborted) {
+		t.Errorf("Expected ErrApprovalAborted when the prompt fails, got %v", err)
+	}
+}
+
+func TestSetDangerousCommands(t *testing.T) {
+	store := &Store{}
+	store.SetAlwaysAllow(true)
+	store.SetPrompter(func(actionType, command, description string) (Decision, error) {
+		return DecisionSkip, nil
+	})
+
+	if err := store.SetDangerousCommands([]string{`^kubectl delete namespace`, `([`}); err == nil {
+		t.Errorf("Expected an error for the invalid pattern")
+	}
+
+	if decision, _ := store.Approve("shell", "kubectl delete namespace prod", "Delete"); decision != DecisionSkip {
+		t.Errorf("Expected configured pattern to be blocked, got %q", decision)
+	}
+	if decision, _ := store.Approve("shell", "kubectl get pods", "List"); decision != DecisionAlways {
+		t.Errorf("Expected other commands to stay always-allowed, got %q", decision)
+	}
+
+	if err := ValidateDangerousCommands([]string{`^terraform destroy`}); err != nil {
+		t.Errorf("Expected valid pattern to pass, got %v", err)
+	}
+	if err := ValidateDangerousCommands([]string{`([`}); err == nil {
+		t.Errorf("Expected invalid pattern to be rejected")
+	}
+}
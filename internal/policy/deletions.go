@@ -0,0 +1,102 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// deleteCommands remove the files named by their arguments
+var deleteCommands = map[string]bool{
+	"rm": true, "rmdir": true, "unlink": true,
+	"del": true, "erase": true, "rd": true,
+	"remove-item": true, "ri": true,
+}
+
+// deleteOptionsWithValue take a value that is not a path to delete
+var deleteOptionsWithValue = map[string]bool{
+	"-exclude": true, "-include": true, "-filter": true,
+}
+
+// DeletionTargets expands the wildcard arguments of delete commands (rm, del,
+// Remove-Item, ...) in command against dir without running anything, so the
+// approval prompt can show which files a glob would remove. Only arguments
+// containing wildcards are expanded; the result is sorted and relative to dir
+// where possible. Expansion is best-effort: it doesn't model every shell rule.
+func DeletionTargets(shell, command, dir string) []string {
+	seen := make(map[string]bool)
+	var targets []string
+
+	for _, part := range segments(tokenizeShell(shell, command)) {
+		args := deleteArgs(part)
+		for i := 0; i < len(args); i++ {
+			arg := args[i]
+			lower := strings.ToLower(arg)
+			if deleteOptionsWithValue[lower] {
+				i++
+				continue
+			}
+			if strings.HasPrefix(arg, "-") || (shell == "cmd" && strings.HasPrefix(arg, "/") && len(arg) <= 3) {
+				continue
+			}
+			if !strings.ContainsAny(arg, "*?[") {
+				continue
+			}
+
+			pattern := arg
+			if strings.HasPrefix(pattern, "~/") || strings.HasPrefix(pattern, `~\`) {
+				if home, err := os.UserHomeDir(); err == nil {
+					pattern = filepath.Join(home, pattern[2:])
+				}
+			}
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(dir, pattern)
+			}
+
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				continue
+			}
+			for _, match := range matches {
+				if rel, err := filepath.Rel(dir, match); err == nil && !strings.HasPrefix(rel, "..") {
+					match = rel
+				}
+				if !seen[match] {
+					seen[match] = true
+					targets = append(targets, match)
+				}
+			}
+		}
+	}
+
+	sort.Strings(targets)
+	return targets
+}
+
+// deleteArgs returns the arguments of a simple command when it is a delete
+// command, looking through sudo/env wrappers; nil otherwise
+func deleteArgs(segment []shellToken) []string {
+	for i, tok := range segment {
+		if tok.op {
+			return nil
+		}
+		name := commandName(tok.text)
+		if commandWrappers[name] || strings.HasPrefix(name, "-") || strings.Contains(name, "=") {
+			continue
+		}
+		if !deleteCommands[name] {
+			return nil
+		}
+
+		var args []string
+		for _, arg := range segment[i+1:] {
+			if arg.op {
+				break // Redirections end the argument list
+			}
+			args = append(args, arg.text)
+		}
+		return args
+	}
+	return nil
+}
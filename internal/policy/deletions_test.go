@@ -0,0 +1,44 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDeletionTargets(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.log", "b.log", "keep.txt", filepath.Join("build", "x.o"), filepath.Join("build", "y.o")} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("x"), 0644)
+	}
+
+	tests := []struct {
+		name     string
+		shell    string
+		command  string
+		expected []string
+	}{
+		{"rm glob", "bash", "rm -f *.log", []string{"a.log", "b.log"}},
+		{"sudo rm in subdirectory", "bash", "sudo rm -rf build/*.o", []string{filepath.Join("build", "x.o"), filepath.Join("build", "y.o")}},
+		{"several commands", "bash", "rm a.* && echo done; rm build/x*", []string{"a.log", filepath.Join("build", "x.o")}},
+		{"no wildcard", "bash", "rm keep.txt", nil},
+		{"not a delete command", "bash", "ls *.log", nil},
+		{"glob after pipe is not deleted", "bash", "echo rm | grep *.log", nil},
+		{"no matches", "bash", "rm *.tmp", nil},
+		{"cmd del with switches", "cmd", "del /q *.log", []string{"a.log", "b.log"}},
+		{"remove-item option values are not targets", "powershell", "Remove-Item -Path *.log -Exclude *.txt", []string{"a.log", "b.log"}},
+		{"absolute pattern", "bash", "rm " + filepath.Join(dir, "*.txt"), []string{"keep.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DeletionTargets(tt.shell, tt.command, dir)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
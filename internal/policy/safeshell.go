@@ -0,0 +1,382 @@
+package policy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Safe-shell rule names; each can be re-allowed individually via ShellRules.Allow
+const (
+	RulePipeToShell    = "pipe-to-shell"   // curl/wget output piped into an interpreter
+	RuleDeviceRedirect = "device-redirect" // output redirected to a device file
+	RuleBackground     = "background"      // commands detached from the agent
+)
+
+// ShellRuleNames lists every safe-shell rule
+var ShellRuleNames = []string{RulePipeToShell, RuleDeviceRedirect, RuleBackground}
+
+// ShellRules configures safe-shell mode
+type ShellRules struct {
+	Enabled bool
+	Allow   []string // Rule names permitted even in safe-shell mode
+}
+
+// ShellVerdict is the outcome of vetting a shell command
+type ShellVerdict struct {
+	Allowed bool
+	Rule    string // Rule that blocked the command (empty when allowed)
+	Reason  string
+}
+
+// allows reports whether the named rule has been explicitly permitted
+func (r ShellRules) allows(rule string) bool {
+	for _, allowed := range r.Allow {
+		if strings.EqualFold(allowed, rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateShellRuleNames checks that every name is a known safe-shell rule
+func ValidateShellRuleNames(names []string) error {
+	for _, name := range names {
+		known := false
+		for _, rule := range ShellRuleNames {
+			if strings.EqualFold(name, rule) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown safe-shell rule: %s (use %s)", name, strings.Join(ShellRuleNames, ", "))
+		}
+	}
+	return nil
+}
+
+// downloaders fetch remote content; interpreters execute what they are fed
+var (
+	downloaders = map[string]bool{
+		"curl": true, "wget": true, "iwr": true, "irm": true,
+		"invoke-webrequest": true, "invoke-restmethod": true,
+	}
+	interpreters = map[string]bool{
+		"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+		"python": true, "python3": true, "perl": true, "ruby": true, "node": true,
+		"powershell": true, "pwsh": true, "cmd": true,
+		"iex": true, "invoke-expression": true, "eval": true, "source": true,
+	}
+	shellInterpreters = map[string]bool{
+		"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+	}
+	backgroundCommands = map[string]bool{
+		"nohup": true, "disown": true, "setsid": true,
+		"start-process": true, "start-job": true,
+	}
+	safeDevices = map[string]bool{
+		"/dev/null": true, "/dev/stdout": true, "/dev/stderr": true, "nul": true,
+	}
+)
+
+// shellToken is a word or an operator produced by tokenizeShell
+type shellToken struct {
+	text string
+	op   bool
+}
+
+// VetCommand parses a shell command and blocks constructs forbidden by the
+// rules: downloads piped into an interpreter, redirection to device files and
+// backgrounding. shell is the action's shell (bash, cmd or powershell). Commands
+// are always allowed when safe-shell mode is off.
+func VetCommand(shell, command string, rules ShellRules) ShellVerdict {
+	if !rules.Enabled {
+		return ShellVerdict{Allowed: true}
+	}
+
+	tokens := tokenizeShell(shell, command)
+
+	if !rules.allows(RulePipeToShell) {
+		if reason := findPipeToShell(shell, tokens); reason != "" {
+			return ShellVerdict{Rule: RulePipeToShell, Reason: reason}
+		}
+	}
+	if !rules.allows(RuleDeviceRedirect) {
+		if reason := findDeviceRedirect(tokens); reason != "" {
+			return ShellVerdict{Rule: RuleDeviceRedirect, Reason: reason}
+		}
+	}
+	if !rules.allows(RuleBackground) {
+		if reason := findBackground(shell, tokens); reason != "" {
+			return ShellVerdict{Rule: RuleBackground, Reason: reason}
+		}
+	}
+
+	return ShellVerdict{Allowed: true}
+}
+
+// tokenizeShell splits a command into words and the operators | || & && ; > >> <
+// while respecting quotes. File-descriptor duplications such as 2>&1 are kept
+// as a single redirection operator so they aren't mistaken for backgrounding.
+// Backslash escapes only apply to bash; elsewhere they are path separators.
+func tokenizeShell(shell, command string) []shellToken {
+	escapes := shell == "bash"
+	var tokens []shellToken
+	var word strings.Builder
+	inWord := false
+
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, shellToken{text: word.String()})
+			word.Reset()
+			inWord = false
+		}
+	}
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\'' || c == '"':
+			inWord = true
+			for i++; i < len(runes) && runes[i] != c; i++ {
+				if escapes && c == '"' && runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				word.WriteRune(runes[i])
+			}
+		case escapes && c == '\\' && i+1 < len(runes):
+			inWord = true
+			i++
+			word.WriteRune(runes[i])
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+			if c == '\n' {
+				tokens = append(tokens, shellToken{text: ";", op: true})
+			}
+		case c == '|' || c == '&' || c == ';' || c == '>' || c == '<':
+			// A leading fd number (2>) belongs to the redirection, not the word
+			if c == '>' && inWord && isDigits(word.String()) {
+				word.Reset()
+				inWord = false
+			}
+			flush()
+
+			op := string(c)
+			if i+1 < len(runes) && runes[i+1] == c && c != ';' {
+				op += string(c)
+				i++
+			}
+			if c == '>' && i+1 < len(runes) && runes[i+1] == '&' {
+				// >&2 style duplication
+				op += "&"
+				i++
+			} else if c == '&' && op == "&" && i+1 < len(runes) && runes[i+1] == '>' {
+				// &> redirects both streams
+				op = "&>"
+				i++
+			}
+			tokens = append(tokens, shellToken{text: op, op: true})
+		default:
+			inWord = true
+			word.WriteRune(c)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// commandName normalises a word to a bare, lower-case program name
+func commandName(word string) string {
+	name := strings.ToLower(filepath.Base(strings.ReplaceAll(word, `\`, "/")))
+	return strings.TrimSuffix(name, ".exe")
+}
+
+// segments splits tokens into simple commands separated by control operators
+func segments(tokens []shellToken) [][]shellToken {
+	var result [][]shellToken
+	var current []shellToken
+	for _, tok := range tokens {
+		if tok.op && (tok.text == "|" || tok.text == "||" || tok.text == "&&" || tok.text == "&" || tok.text == ";") {
+			result = append(result, current)
+			result = append(result, []shellToken{tok})
+			current = nil
+			continue
+		}
+		current = append(current, tok)
+	}
+	return append(result, current)
+}
+
+// commandWrappers run the command that follows them
+var commandWrappers = map[string]bool{"sudo": true, "env": true, "exec": true, "command": true}
+
+// firstWord returns the program name of a simple command, looking through sudo/env
+func firstWord(segment []shellToken) string {
+	for _, tok := range segment {
+		if tok.op {
+			continue
+		}
+		name := commandName(tok.text)
+		if commandWrappers[name] || strings.HasPrefix(name, "-") || strings.Contains(name, "=") {
+			continue
+		}
+		return name
+	}
+	return ""
+}
+
+// findPipeToShell detects downloads piped into an interpreter (curl ... | sh),
+// including downloads run through command substitution (bash -c "$(curl ...)")
+// and scripts passed to a shell with -c, which are vetted recursively
+func findPipeToShell(shell string, tokens []shellToken) string {
+	parts := segments(tokens)
+	downloading := ""
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		if len(part) == 1 && part[0].op {
+			if part[0].text != "|" {
+				downloading = ""
+			}
+			continue
+		}
+
+		name := firstWord(part)
+		if downloading != "" && interpreters[name] {
+			return fmt.Sprintf("output of %s is piped into %s", downloading, name)
+		}
+		if downloaders[name] {
+			// Downloaded content flows through the rest of the pipeline
+			downloading = name
+		}
+
+		for j, tok := range part {
+			if tok.op {
+				continue
+			}
+			for _, inner := range substitutions(tok.text) {
+				innerTokens := tokenizeShell(shell, inner)
+				if reason := findPipeToShell(shell, innerTokens); reason != "" {
+					return reason
+				}
+				// bash -c "$(curl ...)" and eval `wget -O- ...` run what was downloaded
+				if downloader := firstWord(innerTokens); interpreters[name] && downloaders[downloader] {
+					return fmt.Sprintf("output of %s is executed by %s", downloader, name)
+				}
+			}
+			// sh -c 'curl ... | sh' hides the pipeline inside a script argument
+			if shellInterpreters[name] && tok.text == "-c" && j+1 < len(part) && !part[j+1].op {
+				if reason := findPipeToShell(shell, tokenizeShell(shell, part[j+1].text)); reason != "" {
+					return reason
+				}
+			}
+		}
+
+		// iex (iwr https://...) evaluates downloaded code without a pipe
+		if interpreters[name] {
+			for _, tok := range part[1:] {
+				if inner := commandName(strings.TrimLeft(tok.text, "($")); downloaders[inner] {
+					return fmt.Sprintf("output of %s is executed by %s", inner, name)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// substitutions returns the commands inside $(...) and backticks in a word
+func substitutions(word string) []string {
+	var result []string
+	for i := 0; i < len(word); i++ {
+		switch {
+		case word[i] == '`':
+			end := strings.IndexByte(word[i+1:], '`')
+			if end < 0 {
+				return append(result, word[i+1:])
+			}
+			result = append(result, word[i+1:i+1+end])
+			i += end + 1
+		case strings.HasPrefix(word[i:], "$("):
+			depth := 0
+			start := i + 2
+			j := start
+			for ; j < len(word); j++ {
+				if word[j] == '(' {
+					depth++
+				} else if word[j] == ')' {
+					if depth == 0 {
+						break
+					}
+					depth--
+				}
+			}
+			result = append(result, word[start:j])
+			i = j
+		}
+	}
+	return result
+}
+
+// findDeviceRedirect detects output redirected to device files (> /dev/sda, \\.\PhysicalDrive0)
+func findDeviceRedirect(tokens []shellToken) string {
+	for i, tok := range tokens {
+		target := ""
+		switch {
+		case tok.op && (tok.text == ">" || tok.text == ">>" || tok.text == "&>"):
+			if i+1 < len(tokens) && !tokens[i+1].op {
+				target = tokens[i+1].text
+			}
+		case !tok.op && strings.HasPrefix(strings.ToLower(tok.text), "of="):
+			target = tok.text[3:] // dd of=/dev/sdX
+		}
+
+		if target != "" && isDeviceFile(target) {
+			return fmt.Sprintf("output is written to device %s", target)
+		}
+	}
+	return ""
+}
+
+// isDeviceFile reports whether a path names a raw device
+func isDeviceFile(path string) bool {
+	lower := strings.ToLower(path)
+	if safeDevices[lower] {
+		return false
+	}
+	return strings.HasPrefix(lower, "/dev/") ||
+		strings.HasPrefix(lower, `\\.\`) ||
+		strings.HasPrefix(lower, "//./")
+}
+
+// findBackground detects commands detached from the agent (cmd &, nohup, Start-Process).
+// In cmd.exe & only sequences commands, and a leading & is PowerShell's call operator.
+func findBackground(shell string, tokens []shellToken) string {
+	if shell != "cmd" {
+		for i, tok := range tokens {
+			if tok.op && tok.text == "&" && i > 0 && !tokens[i-1].op {
+				return "command is sent to the background with &"
+			}
+		}
+	}
+	for _, part := range segments(tokens) {
+		if name := firstWord(part); backgroundCommands[name] {
+			return fmt.Sprintf("command is detached with %s", name)
+		}
+	}
+	return ""
+}
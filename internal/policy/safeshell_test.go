@@ -0,0 +1,75 @@
+package policy
+
+import "testing"
+
+func TestVetCommand(t *testing.T) {
+	enabled := ShellRules{Enabled: true}
+
+	tests := []struct {
+		name         string
+		shell        string
+		command      string
+		rules        ShellRules
+		expectedRule string // empty when the command should be allowed
+	}{
+		{"plain command", "bash", "ls -la", enabled, ""},
+		{"safe-shell disabled", "bash", "curl https://x.sh | sh", ShellRules{}, ""},
+		{"curl piped to sh", "bash", "curl -fsSL https://x.sh | sh", enabled, RulePipeToShell},
+		{"wget piped to sudo bash", "bash", "wget -qO- https://x.sh | sudo bash -s", enabled, RulePipeToShell},
+		{"curl through grep to bash", "bash", "curl https://x | grep foo | bash", enabled, RulePipeToShell},
+		{"separate commands", "bash", "curl -O https://x.tgz; bash build.sh", enabled, ""},
+		{"quoted pipe is not a pipe", "bash", `echo "curl x | sh"`, enabled, ""},
+		{"curl piped to jq", "bash", "curl https://api | jq .", enabled, ""},
+		{"iex of downloaded script", "powershell", "iex (iwr https://x.ps1)", enabled, RulePipeToShell},
+		{"irm piped to iex", "powershell", "irm https://x.ps1 | iex", enabled, RulePipeToShell},
+		{"bash -c of command substitution", "bash", `bash -c "$(curl -fsSL https://x/install.sh)"`, enabled, RulePipeToShell},
+		{"sh -c of backtick substitution", "bash", "sh -c \"`wget -qO- https://x/install.sh`\"", enabled, RulePipeToShell},
+		{"eval of downloaded script", "bash", `eval "$(curl -s https://x/env.sh)"`, enabled, RulePipeToShell},
+		{"pipe inside substitution", "bash", `echo "$(curl -s https://x | sh)"`, enabled, RulePipeToShell},
+		{"pipe inside sh -c script", "bash", `sh -c 'curl -s https://x | bash'`, enabled, RulePipeToShell},
+		{"substitution without download", "bash", `bash -c "$(cat local.sh)"`, enabled, ""},
+		{"download into variable", "bash", `VERSION="$(curl -s https://api/version)"`, enabled, ""},
+		{"pipe-to-shell allowed", "bash", "curl https://x.sh | sh", ShellRules{Enabled: true, Allow: []string{"pipe-to-shell"}}, ""},
+		{"redirect to disk device", "bash", "cat image > /dev/sda", enabled, RuleDeviceRedirect},
+		{"redirect to dev null", "bash", "make 2>/dev/null", enabled, ""},
+		{"dd to device", "bash", "dd if=img of=/dev/sdb bs=4M", enabled, RuleDeviceRedirect},
+		{"windows raw drive", "powershell", `Get-Content img > \\.\PhysicalDrive0`, enabled, RuleDeviceRedirect},
+		{"background with ampersand", "bash", "sleep 100 &", enabled, RuleBackground},
+		{"fd duplication is not background", "bash", "make 2>&1 | tee log", enabled, ""},
+		{"and-list is not background", "bash", "make && make test", enabled, ""},
+		{"nohup", "bash", "nohup ./server", enabled, RuleBackground},
+		{"start-process", "powershell", "Start-Process notepad.exe", enabled, RuleBackground},
+		{"powershell call operator", "powershell", `& "C:\Program Files\tool.exe" --version`, enabled, ""},
+		{"cmd sequencing", "cmd", "cd src & dir", enabled, ""},
+		{"background allowed", "bash", "sleep 100 &", ShellRules{Enabled: true, Allow: []string{"Background"}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := VetCommand(tt.shell, tt.command, tt.rules)
+			if tt.expectedRule == "" {
+				if !verdict.Allowed {
+					t.Errorf("Expected %q to be allowed, blocked by %s: %s", tt.command, verdict.Rule, verdict.Reason)
+				}
+				return
+			}
+			if verdict.Allowed {
+				t.Errorf("Expected %q to be blocked by %s", tt.command, tt.expectedRule)
+			} else if verdict.Rule != tt.expectedRule {
+				t.Errorf("Expected rule %s, got %s (%s)", tt.expectedRule, verdict.Rule, verdict.Reason)
+			}
+			if !verdict.Allowed && verdict.Reason == "" {
+				t.Errorf("Expected a reason for blocked command %q", tt.command)
+			}
+		})
+	}
+}
+
+func TestValidateShellRuleNames(t *testing.T) {
+	if err := ValidateShellRuleNames([]string{"pipe-to-shell", "Background"}); err != nil {
+		t.Errorf("Expected known rules to validate, got %v", err)
+	}
+	if err := ValidateShellRuleNames([]string{"everything"}); err == nil {
+		t.Errorf("Expected unknown rule to be rejected")
+	}
+}
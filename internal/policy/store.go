@@ -2,11 +2,13 @@ package policy
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/fatih/color"
 	"github.com/manifoldco/promptui"
@@ -14,24 +16,54 @@ import (
 
 type Decision string
 
+// ErrApprovalAborted is returned by Approve when the prompt is interrupted or
+// can't be shown, so no decision was made
+var ErrApprovalAborted = errors.New("approval aborted")
+
 const (
 	DecisionOnce   Decision = "once"
 	DecisionAlways Decision = "always"
 	DecisionNever  Decision = "never"
 	DecisionSkip   Decision = "skip"
+
+	// DecisionAlwaysType allows the command and persists a rule allowing every
+	// action of the same type; Approve reports it as DecisionAlways
+	DecisionAlwaysType Decision = "always-type"
 )
 
+// RegexPrefix marks a rule pattern as a regular expression, e.g.
+// "re:^git (status|log|diff)"
+const RegexPrefix = "re:"
+
 type Rule struct {
-	Pattern  string   `json:"pattern"`  // simple wildcard * for command matching
-	Decision Decision `json:"decision"` // always|never persisted
+	Type     string   `json:"type,omitempty"` // action type the rule is limited to (empty = any)
+	Pattern  string   `json:"pattern"`        // simple wildcard * for command matching, or "re:" + regex
+	Decision Decision `json:"decision"`       // always|never persisted
+}
+
+// appliesTo reports whether the rule covers actions of actionType
+func (r Rule) appliesTo(actionType string) bool {
+	return r.Type == "" || r.Type == actionType
 }
 
 type Store struct {
+	mu          sync.Mutex // Guards rules and compiled
+	parent      *Store     // Store whose rules a session store shares (nil = own rules)
 	rules       []Rule
 	file        string
-	alwaysAllow bool // Global flag to bypass all approval prompts
+	alwaysAllow bool     // Global flag to bypass all approval prompts
+	prompter    Prompter // Replaces the terminal prompt when set
+	trustedDirs []string // Resolved directories where file changes need no prompt
+	onDecision  func(command string, decision Decision)
+	compiled    map[string]*regexp.Regexp // Rule patterns compiled by Load/Add; nil for invalid regexes
+	dangerous   []dangerousCommand        // Configured additions to the dangerous-command denylist
 }
 
+// Prompter asks for a decision on a command that no rule covers. Returning
+// DecisionAlways, DecisionAlwaysType or DecisionNever persists a rule just like
+// the terminal prompt.
+type Prompter func(actionType, command, description string) (Decision, error)
+
 func Load() (*Store, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -59,36 +91,128 @@ func Load() (*Store, error) {
 		}
 	}
 
-	return &Store{
+	store := &Store{
 		rules: rules,
 		file:  file,
-	}, nil
+	}
+	for _, rule := range rules {
+		if err := store.compile(rule.Pattern); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠ Ignoring policy rule %q: %v\n", rule.Pattern, err)
+		}
+	}
+	return store, nil
+}
+
+// Session returns a store for one of several concurrent sessions. It shares
+// the rules of s, so a rule remembered by any session applies to all of them
+// and Save writes them together, while the prompter, decision hook, trusted
+// directories and always-allow mode are the session's own.
+func (s *Store) Session() *Store {
+	return &Store{parent: s.ruleStore()}
+}
+
+// ruleStore returns the store holding the rules s uses
+func (s *Store) ruleStore() *Store {
+	if s.parent != nil {
+		return s.parent
+	}
+	return s
+}
+
+// compilePattern turns a rule pattern into the regex commands are matched
+// against: "re:" patterns must match the whole command, anything else is an
+// exact match where * matches any text
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if expr, ok := strings.CutPrefix(pattern, RegexPrefix); ok {
+		return regexp.Compile("^(?:" + expr + ")$")
+	}
+	return regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), "\\*", ".*") + "$")
+}
+
+// shellMetachars chain, pipe or substitute commands; a command containing them
+// is only auto-approved by a rule naming it exactly
+const shellMetachars = ";&|$`\n"
+
+// compile caches the regex for pattern. A pattern that doesn't compile is
+// cached as nil so it is skipped when matching.
+func (s *Store) compile(pattern string) error {
+	if s.compiled == nil {
+		s.compiled = make(map[string]*regexp.Regexp)
+	}
+	re, err := compilePattern(pattern)
+	s.compiled[pattern] = re
+	return err
+}
+
+// Match returns a copy of the first rule for actionType whose pattern matches
+// command, or nil
+func (s *Store) Match(actionType, command string) *Rule {
+	r := s.ruleStore()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.rules {
+		if !r.rules[i].appliesTo(actionType) {
+			continue
+		}
+		re, ok := r.compiled[r.rules[i].Pattern]
+		if !ok {
+			re, _ = compilePattern(r.rules[i].Pattern)
+		}
+		if re != nil && re.MatchString(command) {
+			rule := r.rules[i]
+			return &rule
+		}
+	}
+	return nil
 }
 
 func (s *Store) Save() error {
-	data, err := json.MarshalIndent(s.rules, "", "  ")
+	r := s.ruleStore()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.MarshalIndent(r.rules, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(s.file, data, 0644)
+	return os.WriteFile(r.file, data, 0644)
+}
+
+// Find returns a copy of the rule with exactly this type scope and pattern, or nil
+func (s *Store) Find(actionType, rulePattern string) *Rule {
+	r := s.ruleStore()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if i := r.find(actionType, rulePattern); i >= 0 {
+		rule := r.rules[i]
+		return &rule
+	}
+	return nil
 }
 
-func (s *Store) Find(rulePattern string) *Rule {
+// find returns the index of the rule with this type scope and pattern, or -1
+func (s *Store) find(actionType, rulePattern string) int {
 	for i := range s.rules {
-		if s.rules[i].Pattern == rulePattern {
-			return &s.rules[i]
+		if s.rules[i].Type == actionType && s.rules[i].Pattern == rulePattern {
+			return i
 		}
 	}
-	return nil
+	return -1
 }
 
 func (s *Store) Add(rule Rule) {
-	existing := s.Find(rule.Pattern)
-	if existing != nil {
-		existing.Decision = rule.Decision
+	r := s.ruleStore()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if i := r.find(rule.Type, rule.Pattern); i >= 0 {
+		r.rules[i].Decision = rule.Decision
 	} else {
-		s.rules = append(s.rules, rule)
+		r.rules = append(r.rules, rule)
+		r.compile(rule.Pattern)
 	}
 }
 
@@ -97,46 +221,88 @@ func (s *Store) SetAlwaysAllow(enabled bool) {
 	s.alwaysAllow = enabled
 }
 
+// SetPrompter routes approval prompts to p instead of the terminal
+func (s *Store) SetPrompter(p Prompter) {
+	s.prompter = p
+}
+
+// SetDecisionHook calls fn with every decision Approve makes, for auditing
+func (s *Store) SetDecisionHook(fn func(command string, decision Decision)) {
+	s.onDecision = fn
+}
+
 // IsAlwaysAllow returns whether global always-allow mode is enabled
 func (s *Store) IsAlwaysAllow() bool {
 	return s.alwaysAllow
 }
 
-func (s *Store) Approve(command, description string) (Decision, error) {
-	// Check global always-allow mode first
+// Approve decides whether command, run by an action of actionType, may run,
+// consulting always-allow mode, the persisted rules for that type and then the
+// user. Commands on the dangerous-command denylist skip all of these and always
+// need an explicit confirmation. paths are the files an action modifies; when
+// all of them are inside a trusted directory no prompt is shown. Shell and
+// network actions pass no paths and are always prompted for.
+func (s *Store) Approve(actionType, command, description string, paths ...string) (Decision, error) {
+	decision, err := s.decide(actionType, command, description, paths)
+	if s.onDecision != nil {
+		s.onDecision(command, decision)
+	}
+	return decision, err
+}
+
+// decide works out the decision for Approve
+func (s *Store) decide(actionType, command, description string, paths []string) (Decision, error) {
+	// Catastrophic commands are never approved automatically
+	if reason := s.DangerousReason(command); reason != "" {
+		return s.confirmDangerous(actionType, command, description, reason)
+	}
+
+	// Check global always-allow mode
 	if s.alwaysAllow {
 		return DecisionAlways, nil
 	}
 
-	// Check persisted rules
-	for _, rule := range s.rules {
-		pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(rule.Pattern), "\\*", ".*") + "$"
-		matched, err := regexp.MatchString(pattern, command)
-		if err != nil {
-			continue // Skip malformed patterns
-		}
-		if matched {
+	// Check persisted rules. A wildcard or regex "always" rule can't vouch
+	// for commands chained or substituted onto the one it was written for.
+	if rule := s.Match(actionType, command); rule != nil {
+		if rule.Decision == DecisionNever || rule.Pattern == command || !strings.ContainsAny(command, shellMetachars) {
 			return rule.Decision, nil
 		}
 	}
 
+	if s.inTrustedDirs(paths) {
+		return DecisionOnce, nil
+	}
+
+	if s.prompter != nil {
+		decision, err := s.prompter(actionType, command, description)
+		if err != nil {
+			return DecisionSkip, fmt.Errorf("%w: %v", ErrApprovalAborted, err)
+		}
+		return s.remember(actionType, command, decision), nil
+	}
+
 	// Display command information before prompt
-	s.displayCommandInfo(command, description)
+	s.displayCommandInfo(actionType, command, description)
 
 	// Prompt user for decision
+	alwaysType := fmt.Sprintf("Always allow every %s action (persist rule)", actionType)
+	items := []string{
+		"Allow once",
+		"Always allow (persist rule)",
+	}
+	if actionType != "" {
+		items = append(items, alwaysType)
+	}
+	items = append(items, "Never allow (persist rule)", "Skip this command")
 	prompt := promptui.Select{
 		Label: "Choose action",
-		Items: []string{
-			"Allow once",
-			"Always allow (persist rule)",
-			"Never allow (persist rule)",
-			"Skip this command",
-		},
+		Items: items,
 	}
 
 	_, result, err := prompt.Run()
 	if err != nil {
-		return DecisionSkip, err
+		return DecisionSkip, fmt.Errorf("%w: %v", ErrApprovalAborted, err)
 	}
 
 	var decision Decision
@@ -145,19 +311,37 @@ func (s *Store) Approve(command, description string) (Decision, error) {
 		decision = DecisionOnce
 	case "Always allow (persist rule)":
 		decision = DecisionAlways
-		s.Add(Rule{Pattern: command, Decision: DecisionAlways})
+	case alwaysType:
+		decision = DecisionAlwaysType
 	case "Never allow (persist rule)":
 		decision = DecisionNever
-		s.Add(Rule{Pattern: command, Decision: DecisionNever})
 	default:
 		decision = DecisionSkip
 	}
 
-	return decision, nil
+	return s.remember(actionType, command, decision), nil
+}
+
+// remember persists the rule a prompt answer asks for, scoped to actionType,
+// and returns the decision for the current command
+func (s *Store) remember(actionType, command string, decision Decision) Decision {
+	switch decision {
+	case DecisionAlways, DecisionNever:
+		s.Add(Rule{Type: actionType, Pattern: command, Decision: decision})
+	case DecisionAlwaysType:
+		// Without a type this would allow everything; keep it to this command
+		pattern := "*"
+		if actionType == "" {
+			pattern = command
+		}
+		s.Add(Rule{Type: actionType, Pattern: pattern, Decision: DecisionAlways})
+		decision = DecisionAlways
+	}
+	return decision
 }
 
 // displayCommandInfo shows command details before approval prompt
-func (s *Store) displayCommandInfo(command, description string) {
+func (s *Store) displayCommandInfo(actionType, command, description string) {
 	// Colors for display
 	yellow := color.New(color.FgYellow, color.Bold)
 	cyan := color.New(color.FgCyan)
@@ -174,7 +358,10 @@ func (s *Store) displayCommandInfo(command, description string) {
 		cyan.Printf("Purpose: %s\n", description)
 	}
 
-	// Show the actual command
+	// Show the action type and the actual command
+	if actionType != "" {
+		cyan.Printf("Action: %s\n", actionType)
+	}
 	white.Printf("Command: %s\n", command)
 
 	// Show working directory context
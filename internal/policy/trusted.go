@@ -0,0 +1,86 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// SetTrustedDirs sets directories in which file changes are approved without
+// prompting. Relative directories are resolved against the current directory.
+func (s *Store) SetTrustedDirs(dirs []string) {
+	s.trustedDirs = nil
+	for _, dir := range dirs {
+		if resolved, err := resolvePath(dir); err == nil {
+			s.trustedDirs = append(s.trustedDirs, resolved)
+		}
+	}
+}
+
+// TrustedDirs returns the resolved trusted directories
+func (s *Store) TrustedDirs() []string {
+	return s.trustedDirs
+}
+
+// inTrustedDirs reports whether every path lies inside a trusted directory.
+// Paths are made absolute and symlinks resolved first, so "../" or a link
+// pointing outside can't escape.
+func (s *Store) inTrustedDirs(paths []string) bool {
+	if len(s.trustedDirs) == 0 || len(paths) == 0 {
+		return false
+	}
+	for _, path := range paths {
+		resolved, err := resolvePath(path)
+		if err != nil {
+			return false
+		}
+		trusted := false
+		for _, dir := range s.trustedDirs {
+			if isWithin(dir, resolved) {
+				trusted = true
+				break
+			}
+		}
+		if !trusted {
+			return false
+		}
+	}
+	return true
+}
+
+// resolvePath returns the absolute, symlink-free form of path. The path itself
+// need not exist: symlinks are resolved in its longest existing ancestor.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	existing, rest := abs, ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(existing); err == nil {
+			return filepath.Join(resolved, rest), nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return abs, nil
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
+
+// isWithin reports whether path is dir or below it
+func isWithin(dir, path string) bool {
+	if runtime.GOOS == "windows" {
+		dir, path = strings.ToLower(dir), strings.ToLower(path)
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -41,6 +41,10 @@ type AnthropicContent struct {
 
 type AnthropicResponse struct {
 	Content []AnthropicContent `json:"content"`
+	Usage   struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
 }
 
 func NewAnthropicProvider(modelOverride string) *AnthropicProvider {
@@ -68,6 +72,21 @@ func (p *AnthropicProvider) GetTokenizer() tokenizer.Tokenizer {
 	return p.tokenizer
 }
 
+// anthropicPrompt splits messages into the system prompt and a single user
+// turn. Every system message is kept, in order: a transcript can carry more
+// than one, such as attached context or a summary of compacted history.
+func anthropicPrompt(messages []ChatMessage) (system, user string) {
+	var systemMessages, userMessages []string
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			systemMessages = append(systemMessages, msg.Content)
+		} else {
+			userMessages = append(userMessages, strings.ToUpper(msg.Role)+": "+msg.Content)
+		}
+	}
+	return strings.Join(systemMessages, "\n\n"), strings.Join(userMessages, "\n\n")
+}
+
 func (p *AnthropicProvider) Chat(messages []ChatMessage, opts *ChatOptions) (string, error) {
 	model := p.defaultModel
 	if opts != nil && opts.Model != "" {
@@ -76,18 +95,7 @@ func (p *AnthropicProvider) Chat(messages []ChatMessage, opts *ChatOptions) (str
 		model = p.modelOverride
 	}
 
-	// Extract system message
-	var system string
-	var userMessages []string
-	for _, msg := range messages {
-		if msg.Role == "system" {
-			system = msg.Content
-		} else {
-			userMessages = append(userMessages, strings.ToUpper(msg.Role)+": "+msg.Content)
-		}
-	}
-
-	userContent := strings.Join(userMessages, "\n\n")
+	system, userContent := anthropicPrompt(messages)
 
 	reqBody := AnthropicRequest{
 		Model:     model,
@@ -97,6 +105,10 @@ func (p *AnthropicProvider) Chat(messages []ChatMessage, opts *ChatOptions) (str
 	}
 
 	// Legacy provider uses default temperature handling from options only
+	if opts != nil && opts.Temperature != nil {
+		temp := *opts.Temperature
+		reqBody.Temperature = &temp
+	}
 
 	verbose := false // Legacy mode - no logging
 	debug := false   // Legacy mode - no logging
@@ -119,7 +131,7 @@ func (p *AnthropicProvider) Chat(messages []ChatMessage, opts *ChatOptions) (str
 	req.Header.Set("x-api-key", p.apiKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 
-	client := &http.Client{}
+	client := common.NewHTTPClient(0)
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("request failed: %w", err)
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strings"
 
+	"terminusai/internal/common"
 	"terminusai/internal/config"
 	"terminusai/internal/tokenizer"
 )
@@ -18,6 +19,7 @@ type AnthropicProviderConfig struct {
 	config    config.ProviderConfig
 	cm        *config.ConfigManager
 	tokenizer tokenizer.Tokenizer
+	usageRecorder
 }
 
 // NewAnthropicProviderWithConfig creates a new Anthropic provider using configuration
@@ -46,23 +48,13 @@ func (p *AnthropicProviderConfig) GetTokenizer() tokenizer.Tokenizer {
 }
 
 func (p *AnthropicProviderConfig) Chat(messages []ChatMessage, opts *ChatOptions) (string, error) {
+	p.record(Usage{})
 	model := p.DefaultModel()
 	if opts != nil && opts.Model != "" {
 		model = opts.Model
 	}
 
-	// Extract system message
-	var system string
-	var userMessages []string
-	for _, msg := range messages {
-		if msg.Role == "system" {
-			system = msg.Content
-		} else {
-			userMessages = append(userMessages, strings.ToUpper(msg.Role)+": "+msg.Content)
-		}
-	}
-
-	userContent := strings.Join(userMessages, "\n\n")
+	system, userContent := anthropicPrompt(messages)
 
 	reqBody := AnthropicRequest{
 		Model:     model,
@@ -71,8 +63,11 @@ func (p *AnthropicProviderConfig) Chat(messages []ChatMessage, opts *ChatOptions
 		MaxTokens: 1024,
 	}
 
-	// Handle temperature from configuration
-	if temp := p.cm.GetTemperature(); temp != nil {
+	// Handle temperature from options or configuration
+	if opts != nil && opts.Temperature != nil {
+		temp := *opts.Temperature
+		reqBody.Temperature = &temp
+	} else if temp := p.cm.GetTemperature(); temp != nil {
 		reqBody.Temperature = temp
 	}
 
@@ -97,7 +92,7 @@ func (p *AnthropicProviderConfig) Chat(messages []ChatMessage, opts *ChatOptions
 	req.Header.Set("x-api-key", p.config.APIKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 
-	client := &http.Client{}
+	client := common.NewHTTPClient(0)
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("request failed: %w", err)
@@ -126,6 +121,11 @@ func (p *AnthropicProviderConfig) Chat(messages []ChatMessage, opts *ChatOptions
 	}
 
 	text := result.String()
+	p.record(Usage{
+		PromptTokens:     anthropicResp.Usage.InputTokens,
+		CompletionTokens: anthropicResp.Usage.OutputTokens,
+		TotalTokens:      anthropicResp.Usage.InputTokens + anthropicResp.Usage.OutputTokens,
+	})
 	if verbose || debug {
 		logResponse(text, debug)
 	}
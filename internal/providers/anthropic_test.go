@@ -0,0 +1,30 @@
+package providers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnthropicPromptKeepsEverySystemMessage(t *testing.T) {
+	// The shape of a compacted agent transcript: the system prompt, the task,
+	// then a system message summarizing the dropped steps
+	transcript := []ChatMessage{
+		{Role: "system", Content: "Available tools: list_files, read_file"},
+		{Role: "user", Content: "Task: tidy the logs"},
+		{Role: "system", Content: "Summary of earlier steps:\nListed logs/"},
+		{Role: "assistant", Content: `{"type":"read_file","path":"logs/a.log"}`},
+		{Role: "user", Content: "observation:read_file logs/a.log"},
+	}
+
+	system, user := anthropicPrompt(transcript)
+	if system != "Available tools: list_files, read_file\n\nSummary of earlier steps:\nListed logs/" {
+		t.Errorf("Expected the system prompt followed by the summary, got %q", system)
+	}
+	expected := "USER: Task: tidy the logs\n\nASSISTANT: {\"type\":\"read_file\",\"path\":\"logs/a.log\"}\n\nUSER: observation:read_file logs/a.log"
+	if user != expected {
+		t.Errorf("Expected %q, got %q", expected, user)
+	}
+	if strings.Contains(user, "Summary of earlier steps") {
+		t.Errorf("Expected the summary to stay out of the user turn")
+	}
+}
@@ -3,13 +3,13 @@ package providers
 import (
 	"bufio"
 	"bytes"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,12 +25,27 @@ type CopilotProvider struct {
 	copilotToken  string
 	config        *common.TerminusAIConfig
 	tokenizer     tokenizer.Tokenizer
+	usageRecorder
+
+	// MaxRetries is how often a chat request answered with 429 or 5xx is resent
+	MaxRetries int
+	// BaseDelay is the backoff before the first resend; it doubles each time
+	BaseDelay time.Duration
+	// Debug logs every resend
+	Debug bool
 }
 
+// Default retry settings for Copilot chat requests
+const (
+	defaultCopilotMaxRetries = 2
+	defaultCopilotBaseDelay  = 500 * time.Millisecond
+)
+
 type CopilotRequest struct {
 	Model       string        `json:"model"`
 	Messages    []ChatMessage `json:"messages"`
 	Temperature *float64      `json:"temperature,omitempty"`
+	Stream      bool          `json:"stream,omitempty"`
 }
 
 type CopilotResponse struct {
@@ -39,6 +54,7 @@ type CopilotResponse struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage *openAIUsage `json:"usage,omitempty"`
 }
 
 type CopilotErrorResponse struct {
@@ -116,6 +132,12 @@ type CopilotChatRequest struct {
 	MaxTokens   *int          `json:"max_tokens,omitempty"`
 	Stream      bool          `json:"stream,omitempty"`
 	TopP        *float64      `json:"top_p,omitempty"`
+	// StreamOptions asks for token usage on the final event of a stream
+	StreamOptions *CopilotStreamOptions `json:"stream_options,omitempty"`
+}
+
+type CopilotStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type CopilotChatResponse struct {
@@ -156,6 +178,8 @@ func NewCopilotProvider(modelOverride string) *CopilotProvider {
 		token:         token,
 		config:        nil,
 		tokenizer:     tokenizer.NewCopilotTokenizer(),
+		MaxRetries:    defaultCopilotMaxRetries,
+		BaseDelay:     defaultCopilotBaseDelay,
 	}
 }
 
@@ -173,16 +197,23 @@ func (p *CopilotProvider) GetTokenizer() tokenizer.Tokenizer {
 
 func (p *CopilotProvider) Chat(messages []ChatMessage, opts *ChatOptions) (string, error) {
 	// Always use Copilot mode
-	return p.chatViaCopilot(messages, opts, nil)
+	return p.chatViaCopilot(messages, opts, nil, nil)
+}
+
+// ChatStream is Chat with the reply streamed to onChunk as it is generated
+func (p *CopilotProvider) ChatStream(messages []ChatMessage, opts *ChatOptions, onChunk func(chunk string)) (string, error) {
+	return p.chatViaCopilot(messages, opts, nil, onChunk)
 }
 
 // ChatWithConfig allows passing configuration for chat requests
 func (p *CopilotProvider) ChatWithConfig(messages []ChatMessage, opts *ChatOptions, cfg *common.TerminusAIConfig) (string, error) {
-	return p.chatViaCopilot(messages, opts, cfg)
+	return p.chatViaCopilot(messages, opts, cfg, nil)
 }
 
-// chatViaCopilot handles chat via Copilot chat completions API
-func (p *CopilotProvider) chatViaCopilot(messages []ChatMessage, opts *ChatOptions, cfg *common.TerminusAIConfig) (string, error) {
+// chatViaCopilot handles chat via Copilot chat completions API. With onChunk
+// set the reply is requested as a server-sent event stream.
+func (p *CopilotProvider) chatViaCopilot(messages []ChatMessage, opts *ChatOptions, cfg *common.TerminusAIConfig, onChunk func(chunk string)) (string, error) {
+	p.record(Usage{})
 	if err := p.ensureCopilotToken(); err != nil {
 		return "", fmt.Errorf("failed to get Copilot token: %w", err)
 	}
@@ -206,12 +237,15 @@ func (p *CopilotProvider) chatViaCopilot(messages []ChatMessage, opts *ChatOptio
 	reqBody := CopilotChatRequest{
 		Model:    model,
 		Messages: messages,
-		Stream:   false, // Non-streaming for now
+		Stream:   onChunk != nil,
+	}
+	if reqBody.Stream {
+		reqBody.StreamOptions = &CopilotStreamOptions{IncludeUsage: true}
 	}
 
 	// Handle temperature from options or config
-	if opts != nil && opts.Temperature > 0 {
-		temp := float64(opts.Temperature)
+	if opts != nil && opts.Temperature != nil && SupportsTemperature(p.Name(), model) {
+		temp := *opts.Temperature
 		reqBody.Temperature = &temp
 	}
 	// Note: Config-based temperature should be handled by the config-based provider
@@ -234,7 +268,11 @@ func (p *CopilotProvider) chatViaCopilot(messages []ChatMessage, opts *ChatOptio
 	// Set headers based on the TypeScript implementation
 	req.Header.Set("Authorization", "Bearer "+p.copilotToken)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+	if reqBody.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
 	req.Header.Set("User-Agent", "CopilotCopilotChat/0.26.7")
 	req.Header.Set("Editor-Version", "copilot-chat/0.26.7")
 	req.Header.Set("OpenAI-Organization", "github-copilot")
@@ -256,14 +294,15 @@ func (p *CopilotProvider) chatViaCopilot(messages []ChatMessage, opts *ChatOptio
 		req.Header.Set("X-Initiator", "user")
 	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-		Timeout: 30 * time.Second,
+	// A stream may take longer than 30s in total, so only its start is limited
+	transport := common.NewHTTPTransport()
+	transport.ResponseHeaderTimeout = 30 * time.Second
+	client := &http.Client{Transport: transport}
+	if !reqBody.Stream {
+		client.Timeout = 30 * time.Second
 	}
 
-	resp, err := client.Do(req)
+	resp, err := p.doWithRetry(client, req)
 	if err != nil {
 		return "", fmt.Errorf("request failed: %w", err)
 	}
@@ -274,6 +313,12 @@ func (p *CopilotProvider) chatViaCopilot(messages []ChatMessage, opts *ChatOptio
 		return "", fmt.Errorf("copilot chat API error: %d %s", resp.StatusCode, string(body))
 	}
 
+	if reqBody.Stream {
+		result, usage, err := readChatSSE(resp.Body, onChunk)
+		p.record(usage)
+		return result, err
+	}
+
 	var chatResp CopilotChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
 		return "", fmt.Errorf("failed to decode chat response: %w", err)
@@ -283,6 +328,11 @@ func (p *CopilotProvider) chatViaCopilot(messages []ChatMessage, opts *ChatOptio
 		return "", fmt.Errorf("no choices in response")
 	}
 
+	p.record(Usage{
+		PromptTokens:     chatResp.Usage.PromptTokens,
+		CompletionTokens: chatResp.Usage.CompletionTokens,
+		TotalTokens:      chatResp.Usage.TotalTokens,
+	})
 	return chatResp.Choices[0].Message.Content, nil
 }
 
@@ -332,12 +382,7 @@ func (p *CopilotProvider) Complete(prompt string, opts *CompletionOptions) (stri
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+p.copilotToken)
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-		Timeout: 30 * time.Second,
-	}
+	client := common.NewHTTPClient(30 * time.Second)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -375,8 +420,18 @@ func (p *CopilotProvider) Complete(prompt string, opts *CompletionOptions) (stri
 	return result.String(), nil
 }
 
-// GetModels fetches available models from Copilot Copilot API
+// GetModels returns the available Copilot models, from the on-disk cache
+// while it is fresh and from the API otherwise
 func (p *CopilotProvider) GetModels() (*CopilotModelsResponse, error) {
+	var cached CopilotModelsResponse
+	if common.LoadModelsCache("copilot", common.ModelsCacheTTL, &cached) && len(cached.Data) > 0 {
+		return &cached, nil
+	}
+	return p.RefreshModels()
+}
+
+// RefreshModels fetches available models from the Copilot API and updates the cache
+func (p *CopilotProvider) RefreshModels() (*CopilotModelsResponse, error) {
 	if err := p.ensureCopilotToken(); err != nil {
 		return nil, fmt.Errorf("failed to get Copilot token: %w", err)
 	}
@@ -403,12 +458,7 @@ func (p *CopilotProvider) GetModels() (*CopilotModelsResponse, error) {
 	req.Header.Set("Copilot-Integration-Id", "vscode-chat")
 	req.Header.Set("X-Request-Id", fmt.Sprintf("req_%d", time.Now().UnixNano()))
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-		Timeout: 30 * time.Second,
-	}
+	client := common.NewHTTPClient(30 * time.Second)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -421,10 +471,15 @@ func (p *CopilotProvider) GetModels() (*CopilotModelsResponse, error) {
 		return nil, fmt.Errorf("Copilot models API error: %d %s", resp.StatusCode, string(body))
 	}
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read models response: %w", err)
+	}
 	var modelsResp CopilotModelsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+	if err := json.Unmarshal(body, &modelsResp); err != nil {
 		return nil, fmt.Errorf("failed to decode models response: %w", err)
 	}
+	common.SaveModelsCache("copilot", body)
 
 	return &modelsResp, nil
 }
@@ -475,6 +530,67 @@ func logCopilotRequest(reqBody CopilotRequest, msgCount int, token, url string,
 	fmt.Printf("[http] Copilot POST %s token=%s body=%s\n", url, tokenPreview, preview)
 }
 
+// doWithRetry sends req, resending it with exponential backoff while the server
+// answers 429 or a 502/503/504, which mean the request was not processed. A
+// Retry-After header longer than the backoff is honoured. The last response is
+// returned as is for the caller to report.
+func (p *CopilotProvider) doWithRetry(client *http.Client, req *http.Request) (*http.Response, error) {
+	delay := p.BaseDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Do(req)
+		if err != nil || attempt >= p.MaxRetries || !isRetryableStatus(resp.StatusCode) {
+			return resp, err
+		}
+
+		wait := delay
+		if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); retryAfter > wait {
+			wait = min(retryAfter, maxRetryAfter)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if p.Debug {
+			fmt.Printf("[http] Copilot %s returned %d, retry %d/%d in %v\n", req.URL, resp.StatusCode, attempt+1, p.MaxRetries, wait)
+		}
+		time.Sleep(wait)
+		delay *= 2
+
+		// The body was consumed by the previous attempt
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// isRetryableStatus reports whether a response status means the request can
+// safely be sent again
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
 // ensureCopilotToken ensures we have a valid Copilot token
 func (p *CopilotProvider) ensureCopilotToken() error {
 	if p.copilotToken != "" && p.isCopilotTokenValid() {
@@ -491,12 +607,7 @@ func (p *CopilotProvider) refreshCopilotToken() error {
 		return fmt.Errorf("failed to get access token: %w", err)
 	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-		Timeout: 10 * time.Second,
-	}
+	client := common.NewHTTPClient(10 * time.Second)
 
 	req, err := http.NewRequest("GET", "https://api.github.com/copilot_internal/v2/token", nil)
 	if err != nil {
@@ -536,6 +647,11 @@ func (p *CopilotProvider) getCopilotAccessToken() (string, error) {
 	}
 
 	// Try .copilot_token file
+	return readCopilotTokenFile()
+}
+
+// readCopilotTokenFile reads the GitHub token saved in ~/.copilot_token
+func readCopilotTokenFile() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
@@ -543,27 +659,35 @@ func (p *CopilotProvider) getCopilotAccessToken() (string, error) {
 
 	tokenFile := filepath.Join(home, ".copilot_token")
 	data, err := os.ReadFile(tokenFile)
-	if err != nil {
+	if err != nil || strings.TrimSpace(string(data)) == "" {
 		return "", fmt.Errorf("no access token found. Run 'terminusai setup' and choose Copilot authentication")
 	}
 
 	return strings.TrimSpace(string(data)), nil
 }
 
+// copilotTokenRefreshMargin is how long before its expiry a session token is refreshed
+const copilotTokenRefreshMargin = 60 * time.Second
+
 // isCopilotTokenValid checks if the current token is still valid
 func (p *CopilotProvider) isCopilotTokenValid() bool {
-	if p.copilotToken == "" {
-		return false
-	}
+	return copilotTokenValidAt(p.copilotToken, time.Now())
+}
 
-	// Try to extract expiration from token
-	parts := strings.Split(p.copilotToken, ";")
-	for _, part := range parts {
-		if strings.HasPrefix(part, "exp=") {
-			// For now, assume token is valid for simplicity
-			// In a real implementation, you'd parse the timestamp
-			return true
+// copilotTokenValidAt reports whether a session token of the form
+// "tid=...;exp=<unix seconds>;..." is still usable at now. Tokens without a
+// readable expiry are treated as expired so they get refreshed.
+func copilotTokenValidAt(token string, now time.Time) bool {
+	for _, part := range strings.Split(token, ";") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(part), "exp=")
+		if !ok {
+			continue
+		}
+		exp, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
 		}
+		return now.Add(copilotTokenRefreshMargin).Before(time.Unix(exp, 0))
 	}
 
 	return false
@@ -2,7 +2,6 @@ package providers
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -20,6 +19,7 @@ type CopilotProviderConfig struct {
 	config    config.ProviderConfig
 	cm        *config.ConfigManager
 	tokenizer tokenizer.Tokenizer
+	usageRecorder
 }
 
 // NewCopilotProviderWithConfig creates a new Copilot provider using configuration
@@ -50,6 +50,18 @@ func (p *CopilotProviderConfig) GetTokenizer() tokenizer.Tokenizer {
 }
 
 func (p *CopilotProviderConfig) Chat(messages []ChatMessage, opts *ChatOptions) (string, error) {
+	return p.chat(messages, opts, nil)
+}
+
+// ChatStream is Chat with the reply streamed to onChunk as it is generated
+func (p *CopilotProviderConfig) ChatStream(messages []ChatMessage, opts *ChatOptions, onChunk func(chunk string)) (string, error) {
+	return p.chat(messages, opts, onChunk)
+}
+
+// chat sends the request, streaming the reply to onChunk when it is set
+func (p *CopilotProviderConfig) chat(messages []ChatMessage, opts *ChatOptions, onChunk func(chunk string)) (string, error) {
+	p.record(Usage{})
+
 	// If in Copilot mode, delegate to standalone provider for now
 	if p.name == "copilot" {
 		// Get the effective model from configuration
@@ -66,7 +78,10 @@ func (p *CopilotProviderConfig) Chat(messages []ChatMessage, opts *ChatOptions)
 			Model:       model,
 		}
 		standalone.config = cfg
-		return standalone.ChatWithConfig(messages, opts, cfg)
+		standalone.Debug = p.cm.IsDebug()
+		result, err := standalone.chatViaCopilot(messages, opts, cfg, onChunk)
+		p.record(standalone.LastUsage())
+		return result, err
 	}
 
 	// Standard Copilot Models chat
@@ -80,11 +95,17 @@ func (p *CopilotProviderConfig) Chat(messages []ChatMessage, opts *ChatOptions)
 	reqBody := CopilotRequest{
 		Model:    model,
 		Messages: messages,
+		Stream:   onChunk != nil,
 	}
 
-	// Handle temperature from configuration
-	if temp := p.cm.GetTemperature(); temp != nil {
-		reqBody.Temperature = temp
+	// Handle temperature from options or configuration, unless the model rejects it
+	if SupportsTemperature(p.Name(), model) {
+		if opts != nil && opts.Temperature != nil {
+			temp := *opts.Temperature
+			reqBody.Temperature = &temp
+		} else if temp := p.cm.GetTemperature(); temp != nil {
+			reqBody.Temperature = temp
+		}
 	}
 
 	verbose := p.cm.IsVerbose()
@@ -107,13 +128,7 @@ func (p *CopilotProviderConfig) Chat(messages []ChatMessage, opts *ChatOptions)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
 
-	// Create client with insecure TLS (matching original behavior)
-	// Note: This is insecure and should be used cautiously
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	}
+	client := common.NewHTTPClient(0)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -121,6 +136,15 @@ func (p *CopilotProviderConfig) Chat(messages []ChatMessage, opts *ChatOptions)
 	}
 	defer resp.Body.Close()
 
+	if reqBody.Stream && resp.StatusCode == http.StatusOK {
+		result, usage, err := readChatSSE(resp.Body, onChunk)
+		p.record(usage)
+		if err == nil && (verbose || debug) {
+			logResponse(result, debug)
+		}
+		return result, err
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", fmt.Errorf("failed to read response: %w", err)
@@ -140,6 +164,7 @@ func (p *CopilotProviderConfig) Chat(messages []ChatMessage, opts *ChatOptions)
 	}
 
 	result := copilotResp.Choices[0].Message.Content
+	p.record(copilotResp.Usage.usage())
 
 	if verbose || debug {
 		logResponse(result, debug)
@@ -0,0 +1,153 @@
+package providers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCopilotTokenValidAt(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		token    string
+		expected bool
+	}{
+		{
+			name:     "expires in an hour",
+			token:    fmt.Sprintf("tid=abc;exp=%d;sku=monthly;8kp=1", now.Add(time.Hour).Unix()),
+			expected: true,
+		},
+		{
+			name:     "already expired",
+			token:    fmt.Sprintf("tid=abc;exp=%d;sku=monthly", now.Add(-time.Minute).Unix()),
+			expected: false,
+		},
+		{
+			name:     "expires within the refresh margin",
+			token:    fmt.Sprintf("tid=abc;exp=%d", now.Add(30*time.Second).Unix()),
+			expected: false,
+		},
+		{
+			name:     "unparseable expiry",
+			token:    "tid=abc;exp=soon;sku=monthly",
+			expected: false,
+		},
+		{
+			name:     "no expiry",
+			token:    "tid=abc;sku=monthly",
+			expected: false,
+		},
+		{
+			name:     "empty token",
+			token:    "",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := copilotTokenValidAt(tt.token, now); got != tt.expected {
+				t.Errorf("copilotTokenValidAt(%q) = %v, want %v", tt.token, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCopilotDoWithRetry(t *testing.T) {
+	tests := []struct {
+		name           string
+		statuses       []int
+		maxRetries     int
+		expectedStatus int
+		expectedCalls  int
+	}{
+		{
+			name:           "503 twice then 200",
+			statuses:       []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+			maxRetries:     2,
+			expectedStatus: http.StatusOK,
+			expectedCalls:  3,
+		},
+		{
+			name:           "gives up after max retries",
+			statuses:       []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK},
+			maxRetries:     1,
+			expectedStatus: http.StatusTooManyRequests,
+			expectedCalls:  2,
+		},
+		{
+			name:           "client errors are not retried",
+			statuses:       []int{http.StatusBadRequest, http.StatusOK},
+			maxRetries:     2,
+			expectedStatus: http.StatusBadRequest,
+			expectedCalls:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != `{"model":"gpt-4o"}` {
+					t.Errorf("Attempt %d sent body %q", calls+1, body)
+				}
+				status := tt.statuses[calls]
+				calls++
+				if status == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", "0")
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			p := NewCopilotProvider("")
+			p.MaxRetries = tt.maxRetries
+			p.BaseDelay = time.Millisecond
+
+			req, err := http.NewRequest("POST", server.URL, strings.NewReader(`{"model":"gpt-4o"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := p.doWithRetry(server.Client(), req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if calls != tt.expectedCalls {
+				t.Errorf("Expected %d calls, got %d", tt.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: 0},
+		{value: "3", expected: 3 * time.Second},
+		{value: "soon", expected: 0},
+		{value: now.Add(5 * time.Second).Format(http.TimeFormat), expected: 5 * time.Second},
+		{value: now.Add(-5 * time.Second).Format(http.TimeFormat), expected: 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.expected {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.expected)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package providers
 
 import (
 	"fmt"
-	"strings"
 
 	"terminusai/internal/config"
 )
@@ -10,15 +9,7 @@ import (
 func GetProvider(name string, model string) (LLMProvider, error) {
 	cm := config.GetConfigManager()
 	
-	providerName := strings.ToLower(name)
-	
-	// Handle provider name variations
-	switch providerName {
-	case "claude":
-		providerName = "anthropic"
-	case "copilot-api", "copilot-completion":
-		providerName = "copilot"
-	}
+	providerName := normalizeProviderName(name)
 	
 	// Try config-based approach first
 	if provider, err := NewProviderWithConfig(cm, providerName); err == nil {
@@ -53,7 +44,9 @@ func GetProvider(name string, model string) (LLMProvider, error) {
 		return NewAnthropicProviderWithConfig(cm, providerConfig), nil
 	case "copilot":
 		return NewCopilotProviderWithConfig(cm, providerConfig), nil
+	case "ollama":
+		return NewOllamaProviderWithConfig(cm, providerConfig), nil
 	default:
-		return nil, fmt.Errorf("unknown provider '%s'. Use openai|anthropic|copilot", name)
+		return nil, fmt.Errorf("unknown provider '%s'. Use openai|anthropic|copilot|ollama", name)
 	}
 }
@@ -0,0 +1,76 @@
+package providers
+
+import (
+	"strings"
+
+	"terminusai/internal/common"
+)
+
+// CachedModels returns a provider's cached models list, even when stale, so
+// lookups never hit the network (nil when nothing is cached)
+func CachedModels(provider string) []CopilotModel {
+	if provider != "copilot" {
+		return nil
+	}
+	var cached CopilotModelsResponse
+	if !common.LoadModelsCache(provider, 0, &cached) {
+		return nil
+	}
+	return cached.Data
+}
+
+// CachedModelIDs lists the model IDs in a provider's cached models list
+func CachedModelIDs(provider string) []string {
+	var ids []string
+	for _, model := range CachedModels(provider) {
+		ids = append(ids, model.ID)
+	}
+	return ids
+}
+
+// CachedModelCapabilities looks up a model's cached capabilities
+func CachedModelCapabilities(provider, model string) (CopilotModelCapabilities, bool) {
+	for _, m := range CachedModels(provider) {
+		if m.ID == model {
+			return m.Capabilities, true
+		}
+	}
+	return CopilotModelCapabilities{}, false
+}
+
+// reasoningFamilies are model families that reject any sampling temperature
+// other than their default
+var reasoningFamilies = []string{"o1", "o3", "o4"}
+
+// SupportsTemperature reports whether a model accepts a sampling temperature;
+// models missing from the cache are assumed to
+func SupportsTemperature(provider, model string) bool {
+	caps, ok := CachedModelCapabilities(provider, model)
+	if !ok {
+		return true
+	}
+	family := caps.Family
+	if family == "" {
+		family = model
+	}
+	for _, prefix := range reasoningFamilies {
+		if family == prefix || strings.HasPrefix(family, prefix+"-") {
+			return false
+		}
+	}
+	return true
+}
+
+// ModelContextTokens returns how many prompt tokens a model accepts, preferring
+// the limits reported by the provider's models API over the tokenizer's table
+func ModelContextTokens(provider LLMProvider, model string) int {
+	if caps, ok := CachedModelCapabilities(provider.Name(), model); ok {
+		if caps.Limits.MaxPromptTokens > 0 {
+			return caps.Limits.MaxPromptTokens
+		}
+		if caps.Limits.MaxContextWindowTokens > 0 {
+			return caps.Limits.MaxContextWindowTokens
+		}
+	}
+	return provider.GetTokenizer().GetMaxContextTokens(model)
+}
@@ -0,0 +1,99 @@
+package providers
+
+import (
+	"os"
+	"testing"
+
+	"terminusai/internal/common"
+	"terminusai/internal/tokenizer"
+)
+
+// namedProvider is an LLMProvider stub with a fixed name
+type namedProvider struct{ name string }
+
+func (p namedProvider) Name() string         { return p.name }
+func (p namedProvider) DefaultModel() string { return "gpt-4o" }
+func (p namedProvider) Chat(messages []ChatMessage, opts *ChatOptions) (string, error) {
+	return "", nil
+}
+func (p namedProvider) GetTokenizer() tokenizer.Tokenizer { return tokenizer.NewCopilotTokenizer() }
+
+func TestModelContextTokens(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+
+	copilot := namedProvider{"copilot"}
+	if got := ModelContextTokens(copilot, "gpt-4o"); got != 128000 {
+		t.Errorf("Expected tokenizer limit 128000 without a cache, got %d", got)
+	}
+
+	body := `{"data":[
+		{"id":"gpt-4o","capabilities":{"limits":{"max_context_window_tokens":128000,"max_prompt_tokens":64000},"supports":{"tool_calls":true}}},
+		{"id":"new-model","capabilities":{"limits":{"max_context_window_tokens":32000}}},
+		{"id":"o3-mini","capabilities":{"family":"o3-mini","supports":{"tool_calls":true}}}
+	]}`
+	if err := common.SaveModelsCache("copilot", []byte(body)); err != nil {
+		t.Fatalf("Failed to save cache: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		provider LLMProvider
+		model    string
+		expected int
+	}{
+		{"prompt limit preferred", copilot, "gpt-4o", 64000},
+		{"context window", copilot, "new-model", 32000},
+		{"uncached model", copilot, "gpt-4", 32768},
+		{"other provider ignores cache", namedProvider{"openai"}, "gpt-4o", 128000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ModelContextTokens(tt.provider, tt.model); got != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+
+	if caps, ok := CachedModelCapabilities("copilot", "gpt-4o"); !ok || !caps.Supports.ToolCalls {
+		t.Errorf("Expected cached tool-call support, got %+v (%t)", caps, ok)
+	}
+	if ids := CachedModelIDs("copilot"); len(ids) != 3 {
+		t.Errorf("Expected 3 cached models, got %v", ids)
+	}
+}
+
+func TestSupportsTemperature(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+
+	body := `{"data":[
+		{"id":"gpt-4o","capabilities":{"family":"gpt-4o"}},
+		{"id":"o1","capabilities":{"family":"o1"}},
+		{"id":"o3-mini","capabilities":{"family":"o3-mini"}},
+		{"id":"o4-mini"}
+	]}`
+	if err := common.SaveModelsCache("copilot", []byte(body)); err != nil {
+		t.Fatalf("Failed to save cache: %v", err)
+	}
+
+	tests := []struct {
+		provider string
+		model    string
+		expected bool
+	}{
+		{"copilot", "gpt-4o", true},
+		{"copilot", "o1", false},
+		{"copilot", "o3-mini", false},
+		{"copilot", "o4-mini", false},
+		{"copilot", "uncached", true},
+		{"openai", "o1", true},
+	}
+
+	for _, tt := range tests {
+		if got := SupportsTemperature(tt.provider, tt.model); got != tt.expected {
+			t.Errorf("SupportsTemperature(%q, %q): Expected %t, got %t", tt.provider, tt.model, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"terminusai/internal/common"
+	"terminusai/internal/config"
+	"terminusai/internal/tokenizer"
+)
+
+const (
+	defaultOllamaHost  = "http://localhost:11434"
+	defaultOllamaModel = "llama3.2"
+)
+
+// OllamaProvider talks to a local Ollama server; it needs no API key
+type OllamaProvider struct {
+	name      string
+	config    config.ProviderConfig
+	cm        *config.ConfigManager
+	tokenizer tokenizer.Tokenizer
+	client    *http.Client
+	usageRecorder
+}
+
+type OllamaRequest struct {
+	Model    string         `json:"model"`
+	Messages []ChatMessage  `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Options  *OllamaOptions `json:"options,omitempty"`
+}
+
+type OllamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+}
+
+// OllamaResponse is a whole reply, or one line of a streamed reply
+type OllamaResponse struct {
+	Message ChatMessage `json:"message"`
+	Done    bool        `json:"done"`
+	Error   string      `json:"error,omitempty"`
+	// Token counts, sent with the final object
+	PromptEvalCount int `json:"prompt_eval_count,omitempty"`
+	EvalCount       int `json:"eval_count,omitempty"`
+}
+
+// NewOllamaProviderWithConfig creates an Ollama provider using configuration.
+// Set the "stream" option to "true" to have Chat request a streamed reply too.
+func NewOllamaProviderWithConfig(cm *config.ConfigManager, providerConfig config.ProviderConfig) *OllamaProvider {
+	return &OllamaProvider{
+		name:      common.ProviderOllama,
+		config:    providerConfig,
+		cm:        cm,
+		tokenizer: tokenizer.NewOpenAITokenizer(),
+		client:    common.NewHTTPClient(0),
+	}
+}
+
+func (p *OllamaProvider) Name() string {
+	return p.name
+}
+
+func (p *OllamaProvider) DefaultModel() string {
+	if model := p.cm.GetEffectiveModel(); model != "" {
+		return model
+	}
+	if p.config.DefaultModel != "" {
+		return p.config.DefaultModel
+	}
+	return defaultOllamaModel
+}
+
+func (p *OllamaProvider) GetTokenizer() tokenizer.Tokenizer {
+	return p.tokenizer
+}
+
+// baseURL returns the server address: the configured base URL, then
+// OLLAMA_HOST (which may omit the scheme, as the ollama CLI allows), then the default
+func (p *OllamaProvider) baseURL() string {
+	host := p.config.BaseURL
+	if host == "" {
+		host = os.Getenv("OLLAMA_HOST")
+	}
+	if host == "" {
+		return defaultOllamaHost
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return strings.TrimRight(host, "/")
+}
+
+func (p *OllamaProvider) Chat(messages []ChatMessage, opts *ChatOptions) (string, error) {
+	return p.chat(messages, opts, nil)
+}
+
+// ChatStream is Chat with the reply streamed to onChunk as it is generated
+func (p *OllamaProvider) ChatStream(messages []ChatMessage, opts *ChatOptions, onChunk func(chunk string)) (string, error) {
+	return p.chat(messages, opts, onChunk)
+}
+
+// chat sends one /api/chat request. With onChunk set the reply is always
+// streamed, as one JSON object per line.
+func (p *OllamaProvider) chat(messages []ChatMessage, opts *ChatOptions, onChunk func(chunk string)) (string, error) {
+	p.record(Usage{})
+	model := p.DefaultModel()
+	if opts != nil && opts.Model != "" {
+		model = opts.Model
+	}
+
+	reqBody := OllamaRequest{
+		Model:    model,
+		Messages: messages,
+		Stream:   onChunk != nil || p.config.Options["stream"] == "true",
+	}
+
+	// Handle temperature from options or configuration
+	options := &OllamaOptions{}
+	if opts != nil && opts.Temperature != nil {
+		temp := *opts.Temperature
+		options.Temperature = &temp
+	} else if temp := p.cm.GetTemperature(); temp != nil {
+		options.Temperature = temp
+	}
+	if opts != nil && opts.MaxTokens > 0 {
+		options.NumPredict = opts.MaxTokens
+	}
+	if options.Temperature != nil || options.NumPredict > 0 {
+		reqBody.Options = options
+	}
+
+	verbose := p.cm.IsVerbose()
+	debug := p.cm.IsDebug()
+
+	if verbose || debug {
+		fmt.Printf("[http] Ollama POST %s/api/chat model=%s messages=%d stream=%v\n", p.baseURL(), model, len(messages), reqBody.Stream)
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", p.baseURL()+"/api/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed (is Ollama running at %s?): %w", p.baseURL(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
+	}
+
+	result, usage, err := readOllamaReply(resp.Body, onChunk)
+	if err != nil {
+		return "", err
+	}
+	p.record(usage)
+
+	if verbose || debug {
+		logResponse(result, debug)
+	}
+
+	return result, nil
+}
+
+// readOllamaReply reads a reply that is either one JSON object or, when
+// streaming, a JSON object per line whose message contents are concatenated
+// and passed to onChunk as each line arrives. The token usage comes from the
+// final object.
+func readOllamaReply(body io.Reader, onChunk func(chunk string)) (string, Usage, error) {
+	decoder := json.NewDecoder(body)
+	var sb strings.Builder
+	for {
+		var chunk OllamaResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", Usage{}, fmt.Errorf("response ended before the reply was done")
+			}
+			return "", Usage{}, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		if chunk.Error != "" {
+			return "", Usage{}, fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+		sb.WriteString(chunk.Message.Content)
+		if onChunk != nil && chunk.Message.Content != "" {
+			onChunk(chunk.Message.Content)
+		}
+		if chunk.Done {
+			return sb.String(), Usage{
+				PromptTokens:     chunk.PromptEvalCount,
+				CompletionTokens: chunk.EvalCount,
+				TotalTokens:      chunk.PromptEvalCount + chunk.EvalCount,
+			}, nil
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"terminusai/internal/config"
+)
+
+func TestOllamaChat(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+
+	tests := []struct {
+		name     string
+		stream   bool
+		reply    string
+		status   int
+		expected string
+		err      string
+	}{
+		{
+			name:     "non-streaming",
+			reply:    `{"model":"llama3.2","message":{"role":"assistant","content":"{\"type\":\"done\"}"},"done":true}`,
+			status:   http.StatusOK,
+			expected: `{"type":"done"}`,
+		},
+		{
+			name:   "streaming",
+			stream: true,
+			reply: `{"message":{"role":"assistant","content":"Hel"},"done":false}
+{"message":{"role":"assistant","content":"lo"},"done":false}
+{"message":{"role":"assistant","content":""},"done":true}
+`,
+			status:   http.StatusOK,
+			expected: "Hello",
+		},
+		{
+			name:   "model not pulled",
+			reply:  `{"error":"model \"llama3.2\" not found, try pulling it first"}`,
+			status: http.StatusNotFound,
+			err:    "API error 404",
+		},
+		{
+			name:   "stream cut short",
+			stream: true,
+			reply:  `{"message":{"role":"assistant","content":"Hel"},"done":false}`,
+			status: http.StatusOK,
+			err:    "ended before the reply was done",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got OllamaRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/chat" {
+					t.Errorf("Expected /api/chat, got %s", r.URL.Path)
+				}
+				json.NewDecoder(r.Body).Decode(&got)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.reply))
+			}))
+			defer server.Close()
+
+			options := map[string]string{}
+			if tt.stream {
+				options["stream"] = "true"
+			}
+			p := NewOllamaProviderWithConfig(config.NewConfigManager(), config.ProviderConfig{BaseURL: server.URL, Options: options})
+
+			messages := []ChatMessage{{Role: "system", Content: "be brief"}, {Role: "user", Content: "hi"}}
+			temperature := 0.2
+			result, err := p.Chat(messages, &ChatOptions{Model: "qwen2.5-coder", Temperature: &temperature, MaxTokens: 64})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+
+			if got.Model != "qwen2.5-coder" || got.Stream != tt.stream || len(got.Messages) != 2 || got.Messages[0].Role != "system" {
+				t.Errorf("Expected model, stream flag and messages to be sent, got %+v", got)
+			}
+			if got.Options == nil || got.Options.Temperature == nil || *got.Options.Temperature != 0.2 || got.Options.NumPredict != 64 {
+				t.Errorf("Expected temperature and num_predict options, got %+v", got.Options)
+			}
+		})
+	}
+}
+
+func TestOllamaChatStream(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+
+	var got OllamaRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"message":{"role":"assistant","content":"Hel"},"done":false}
+{"message":{"role":"assistant","content":"lo"},"done":false}
+{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":12,"eval_count":3}
+`))
+	}))
+	defer server.Close()
+
+	// Streaming is requested by ChatStream even without the "stream" option
+	p := NewOllamaProviderWithConfig(config.NewConfigManager(), config.ProviderConfig{BaseURL: server.URL})
+	var provider LLMProvider = p
+	if _, ok := provider.(StreamingProvider); !ok {
+		t.Fatal("Expected OllamaProvider to implement StreamingProvider")
+	}
+
+	var chunks []string
+	result, err := p.ChatStream([]ChatMessage{{Role: "user", Content: "hi"}}, nil, func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result != "Hello" || strings.Join(chunks, "|") != "Hel|lo" {
+		t.Errorf("Expected \"Hello\" in chunks Hel|lo, got %q in %q", result, chunks)
+	}
+	if !got.Stream {
+		t.Error("Expected the request to ask for a stream")
+	}
+	if usage := p.LastUsage(); usage.PromptTokens != 12 || usage.CompletionTokens != 3 || usage.TotalTokens != 15 {
+		t.Errorf("Expected usage from the final line, got %+v", usage)
+	}
+}
+
+func TestOllamaBaseURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		env        string
+		expected   string
+	}{
+		{"default", "", "", "http://localhost:11434"},
+		{"OLLAMA_HOST with scheme", "", "https://gpu-box:8443/", "https://gpu-box:8443"},
+		{"OLLAMA_HOST without scheme", "", "0.0.0.0:11434", "http://0.0.0.0:11434"},
+		{"configured base URL wins", "http://other:1234", "0.0.0.0:11434", "http://other:1234"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OLLAMA_HOST", tt.env)
+			p := NewOllamaProviderWithConfig(nil, config.ProviderConfig{BaseURL: tt.configured})
+			if got := p.baseURL(); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
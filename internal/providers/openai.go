@@ -32,6 +32,7 @@ type OpenAIResponse struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage *openAIUsage `json:"usage,omitempty"`
 }
 
 func NewOpenAIProvider(modelOverride string) *OpenAIProvider {
@@ -73,6 +74,10 @@ func (p *OpenAIProvider) Chat(messages []ChatMessage, opts *ChatOptions) (string
 	}
 
 	// Legacy provider uses default temperature handling from options only
+	if opts != nil && opts.Temperature != nil {
+		temp := *opts.Temperature
+		reqBody.Temperature = &temp
+	}
 
 	verbose := false // Legacy mode - no logging
 	debug := false   // Legacy mode - no logging
@@ -94,7 +99,7 @@ func (p *OpenAIProvider) Chat(messages []ChatMessage, opts *ChatOptions) (string
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+p.apiKey)
 
-	client := &http.Client{}
+	client := common.NewHTTPClient(0)
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("request failed: %w", err)
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 
+	"terminusai/internal/common"
 	"terminusai/internal/config"
 	"terminusai/internal/tokenizer"
 )
@@ -17,6 +18,7 @@ type OpenAIProviderConfig struct {
 	config    config.ProviderConfig
 	cm        *config.ConfigManager
 	tokenizer tokenizer.Tokenizer
+	usageRecorder
 }
 
 // NewOpenAIProviderWithConfig creates a new OpenAI provider using configuration
@@ -45,6 +47,7 @@ func (p *OpenAIProviderConfig) GetTokenizer() tokenizer.Tokenizer {
 }
 
 func (p *OpenAIProviderConfig) Chat(messages []ChatMessage, opts *ChatOptions) (string, error) {
+	p.record(Usage{})
 	model := p.DefaultModel()
 	if opts != nil && opts.Model != "" {
 		model = opts.Model
@@ -55,8 +58,11 @@ func (p *OpenAIProviderConfig) Chat(messages []ChatMessage, opts *ChatOptions) (
 		Messages: messages,
 	}
 
-	// Handle temperature from configuration
-	if temp := p.cm.GetTemperature(); temp != nil {
+	// Handle temperature from options or configuration
+	if opts != nil && opts.Temperature != nil {
+		temp := *opts.Temperature
+		reqBody.Temperature = &temp
+	} else if temp := p.cm.GetTemperature(); temp != nil {
 		reqBody.Temperature = temp
 	}
 
@@ -80,7 +86,7 @@ func (p *OpenAIProviderConfig) Chat(messages []ChatMessage, opts *ChatOptions) (
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
 
-	client := &http.Client{}
+	client := common.NewHTTPClient(0)
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("request failed: %w", err)
@@ -106,6 +112,7 @@ func (p *OpenAIProviderConfig) Chat(messages []ChatMessage, opts *ChatOptions) (
 	}
 
 	result := openaiResp.Choices[0].Message.Content
+	p.record(openaiResp.Usage.usage())
 
 	if verbose || debug {
 		logResponse(result, debug)
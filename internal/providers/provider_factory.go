@@ -2,21 +2,72 @@ package providers
 
 import (
 	"fmt"
+	"strings"
 
+	"terminusai/internal/common"
 	"terminusai/internal/config"
 )
 
+// NewFromConfig creates the provider the configuration selects: the effective
+// provider (the --provider override or the configured one) using the effective
+// model and that provider's credentials. A provider that needs a key or token
+// but has none is an error up front rather than a failed request later.
+func NewFromConfig(cm *config.ConfigManager) (LLMProvider, error) {
+	providerName := normalizeProviderName(cm.GetEffectiveProvider())
+	if providerName == "" {
+		return nil, fmt.Errorf("no provider configured, run 'terminusai setup' first")
+	}
+
+	providerConfig, err := enabledProviderConfig(cm, providerName)
+	if err != nil {
+		return nil, err
+	}
+	if model := cm.GetEffectiveModel(); model != "" {
+		providerConfig.DefaultModel = model
+	}
+	if providerConfig.APIKey == "" {
+		switch providerName {
+		case common.ProviderOpenAI, common.ProviderAnthropic:
+			return nil, fmt.Errorf("no API key configured for %s, run 'terminusai setup' to add one", providerName)
+		case common.ProviderCopilot:
+			// Copilot falls back to the token saved in ~/.copilot_token
+			if _, err := readCopilotTokenFile(); err != nil {
+				return nil, fmt.Errorf("no GitHub token configured for copilot, run 'terminusai setup' to sign in")
+			}
+		}
+	}
+
+	return newProvider(cm, providerName, providerConfig)
+}
+
 // NewProviderWithConfig creates a provider using the configuration manager
 func NewProviderWithConfig(cm *config.ConfigManager, providerName string) (LLMProvider, error) {
+	providerConfig, err := enabledProviderConfig(cm, providerName)
+	if err != nil {
+		return nil, err
+	}
+	return newProvider(cm, providerName, providerConfig)
+}
+
+// enabledProviderConfig returns the settings of a known, enabled provider
+func enabledProviderConfig(cm *config.ConfigManager, providerName string) (config.ProviderConfig, error) {
 	providerConfig, exists := cm.GetProviderConfig(providerName)
+	if !exists && providerName == common.ProviderOllama {
+		// Settings saved before Ollama support have no entry; it needs no key
+		providerConfig, exists = config.ProviderConfig{Enabled: true}, true
+	}
 	if !exists {
-		return nil, fmt.Errorf("unknown provider: %s", providerName)
+		return providerConfig, fmt.Errorf("unknown provider: %s", providerName)
 	}
 
 	if !providerConfig.Enabled {
-		return nil, fmt.Errorf("provider %s is disabled", providerName)
+		return providerConfig, fmt.Errorf("provider %s is disabled", providerName)
 	}
+	return providerConfig, nil
+}
 
+// newProvider constructs the named provider from its settings
+func newProvider(cm *config.ConfigManager, providerName string, providerConfig config.ProviderConfig) (LLMProvider, error) {
 	switch providerName {
 	case "openai":
 		return NewOpenAIProviderWithConfig(cm, providerConfig), nil
@@ -24,11 +75,26 @@ func NewProviderWithConfig(cm *config.ConfigManager, providerName string) (LLMPr
 		return NewAnthropicProviderWithConfig(cm, providerConfig), nil
 	case "copilot", "copilot-api":
 		return NewCopilotProviderWithConfig(cm, providerConfig), nil
+	case "ollama":
+		return NewOllamaProviderWithConfig(cm, providerConfig), nil
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", providerName)
 	}
 }
 
+// normalizeProviderName maps the accepted spellings of a provider name to the
+// one the factory uses
+func normalizeProviderName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "claude":
+		return common.ProviderAnthropic
+	case "copilot-api", "copilot-completion":
+		return common.ProviderCopilot
+	}
+	return name
+}
+
 // GetProviderWithFallback attempts to create a provider with config, falls back to legacy method
 func GetProviderWithFallback(providerName, modelOverride string) (LLMProvider, error) {
 	cm := config.GetConfigManager()
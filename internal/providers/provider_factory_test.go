@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"terminusai/internal/config"
+)
+
+func TestNewFromConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+
+	tests := []struct {
+		name         string
+		userConfig   config.TerminusAIConfig
+		override     string
+		tokenFile    string
+		expectedName string
+		err          string
+	}{
+		{name: "openai", userConfig: config.TerminusAIConfig{Provider: "openai", OpenAIAPIKey: "sk-test"}, expectedName: "openai"},
+		{name: "anthropic", userConfig: config.TerminusAIConfig{Provider: "anthropic", AnthropicAPIKey: "sk-ant"}, expectedName: "anthropic"},
+		{name: "claude alias", userConfig: config.TerminusAIConfig{Provider: "claude", AnthropicAPIKey: "sk-ant"}, expectedName: "anthropic"},
+		{name: "copilot", userConfig: config.TerminusAIConfig{Provider: "copilot", GitHubToken: "gho_test"}, expectedName: "copilot"},
+		{name: "ollama needs no key", userConfig: config.TerminusAIConfig{Provider: "ollama"}, expectedName: "ollama"},
+		{name: "override wins", userConfig: config.TerminusAIConfig{Provider: "openai", AnthropicAPIKey: "sk-ant"}, override: "anthropic", expectedName: "anthropic"},
+		{name: "openai without key", userConfig: config.TerminusAIConfig{Provider: "openai"}, err: "no API key configured for openai"},
+		{name: "anthropic without key", userConfig: config.TerminusAIConfig{Provider: "anthropic", OpenAIAPIKey: "sk-test"}, err: "no API key configured for anthropic"},
+		{name: "copilot without token", userConfig: config.TerminusAIConfig{Provider: "copilot"}, err: "no GitHub token configured"},
+		{name: "copilot token file", userConfig: config.TerminusAIConfig{Provider: "copilot"}, tokenFile: "gho_file\n", expectedName: "copilot"},
+		{name: "unknown provider", userConfig: config.TerminusAIConfig{Provider: "bard"}, err: "unknown provider: bard"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := config.NewConfigManager()
+			userConfig := tt.userConfig
+			userConfig.Model = "test-model"
+			cm.SetUserConfig(&userConfig)
+			cm.SetProviderOverride(tt.override)
+			if tt.tokenFile != "" {
+				tokenFile := filepath.Join(os.Getenv("HOME"), ".copilot_token")
+				if err := os.WriteFile(tokenFile, []byte(tt.tokenFile), 0600); err != nil {
+					t.Fatalf("Failed to write token file: %v", err)
+				}
+				defer os.Remove(tokenFile)
+			}
+
+			provider, err := NewFromConfig(cm)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if provider.Name() != tt.expectedName {
+				t.Errorf("Expected provider %q, got %q", tt.expectedName, provider.Name())
+			}
+			if provider.DefaultModel() != "test-model" {
+				t.Errorf("Expected model test-model, got %q", provider.DefaultModel())
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package providers
+
+import (
+	"errors"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"terminusai/internal/tokenizer"
+)
+
+// maxRetryAfter caps how long a server-provided Retry-After hint may delay a retry
+const maxRetryAfter = time.Minute
+
+// RetryConfig controls how a RetryingProvider retries failed chat calls
+type RetryConfig struct {
+	MaxRetries int           // Number of retries after the first attempt
+	BaseDelay  time.Duration // Delay before retry n is BaseDelay*n
+	Jitter     float64       // Up to this fraction of the delay is added at random (0 disables)
+
+	// ShouldRetry decides whether an error is transient (defaults to IsRetryableError)
+	ShouldRetry func(err error) bool
+	// OnRetry is called before sleeping for the next attempt (optional, for logging)
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// RetryAfterError is implemented by errors that carry a server-provided retry delay
+type RetryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// RetryingProvider decorates an LLMProvider with retry and backoff on transient errors
+type RetryingProvider struct {
+	provider LLMProvider
+	config   RetryConfig
+	sleep    func(time.Duration)
+	random   func() float64
+}
+
+// NewRetryingProvider wraps provider so Chat retries transient failures
+func NewRetryingProvider(provider LLMProvider, config RetryConfig) *RetryingProvider {
+	if config.ShouldRetry == nil {
+		config.ShouldRetry = IsRetryableError
+	}
+	return &RetryingProvider{
+		provider: provider,
+		config:   config,
+		sleep:    time.Sleep,
+		random:   rand.Float64,
+	}
+}
+
+// Name returns the wrapped provider's name
+func (p *RetryingProvider) Name() string {
+	return p.provider.Name()
+}
+
+// DefaultModel returns the wrapped provider's default model
+func (p *RetryingProvider) DefaultModel() string {
+	return p.provider.DefaultModel()
+}
+
+// GetTokenizer returns the wrapped provider's tokenizer
+func (p *RetryingProvider) GetTokenizer() tokenizer.Tokenizer {
+	return p.provider.GetTokenizer()
+}
+
+// LastUsage returns the usage of the wrapped provider's most recent reply
+func (p *RetryingProvider) LastUsage() Usage {
+	return LastUsage(p.provider)
+}
+
+// Unwrap returns the decorated provider
+func (p *RetryingProvider) Unwrap() LLMProvider {
+	return p.provider
+}
+
+// Chat sends the messages, retrying transient errors with backoff. A Retry-After
+// hint from the provider is honoured when it is longer than the backoff delay.
+func (p *RetryingProvider) Chat(messages []ChatMessage, opts *ChatOptions) (string, error) {
+	return p.retry(func() (string, error) {
+		return p.provider.Chat(messages, opts)
+	})
+}
+
+// ChatStream streams the reply like ChatStream on the wrapped provider, with
+// Chat's retries. A retried attempt streams its reply from the start again.
+func (p *RetryingProvider) ChatStream(messages []ChatMessage, opts *ChatOptions, onChunk func(chunk string)) (string, error) {
+	return p.retry(func() (string, error) {
+		return ChatStream(p.provider, messages, opts, onChunk)
+	})
+}
+
+// retry runs call until it succeeds, fails permanently or runs out of retries
+func (p *RetryingProvider) retry(call func() (string, error)) (string, error) {
+	var lastErr error
+	for attempt := 0; attempt <= p.config.MaxRetries; attempt++ {
+		response, err := call()
+		if err == nil {
+			return response, nil
+		}
+		lastErr = err
+
+		if !p.config.ShouldRetry(err) || attempt == p.config.MaxRetries {
+			break
+		}
+
+		delay := p.backoff(attempt + 1)
+		if retryAfter := retryAfterDelay(err); retryAfter > delay {
+			delay = retryAfter
+			if delay > maxRetryAfter {
+				delay = maxRetryAfter
+			}
+		}
+
+		if p.config.OnRetry != nil {
+			p.config.OnRetry(attempt+1, delay, err)
+		}
+		p.sleep(delay)
+	}
+
+	return "", lastErr
+}
+
+// backoff returns the delay before the given retry (1-based). Jitter spreads the
+// sleeps of clients that failed together so they don't retry in lockstep.
+func (p *RetryingProvider) backoff(retry int) time.Duration {
+	delay := p.config.BaseDelay * time.Duration(retry)
+	if p.config.Jitter > 0 {
+		delay += time.Duration(float64(delay) * p.config.Jitter * p.random())
+	}
+	return delay
+}
+
+// IsRetryableError checks if an error is worth retrying (API overload, timeout, etc.)
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var retryAfter RetryAfterError
+	if errors.As(err, &retryAfter) {
+		return true
+	}
+	errStr := strings.ToLower(err.Error())
+	return strings.Contains(errStr, "overloaded") ||
+		strings.Contains(errStr, "timeout") ||
+		strings.Contains(errStr, "rate limit") ||
+		strings.Contains(errStr, "502") ||
+		strings.Contains(errStr, "503") ||
+		strings.Contains(errStr, "504")
+}
+
+var retryAfterPattern = regexp.MustCompile(`(?i)retry[- ]after["':= ]+(\d+)`)
+
+// retryAfterDelay extracts a Retry-After hint from an error, if any
+func retryAfterDelay(err error) time.Duration {
+	var retryAfter RetryAfterError
+	if errors.As(err, &retryAfter) {
+		return retryAfter.RetryAfter()
+	}
+	if match := retryAfterPattern.FindStringSubmatch(err.Error()); len(match) > 1 {
+		if seconds, convErr := strconv.Atoi(match[1]); convErr == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 0
+}
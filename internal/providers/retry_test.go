@@ -0,0 +1,154 @@
+package providers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"terminusai/internal/tokenizer"
+)
+
+// sequenceProvider returns the queued errors in order, then succeeds
+type sequenceProvider struct {
+	errs  []error
+	calls int
+}
+
+func (p *sequenceProvider) Name() string         { return "sequence" }
+func (p *sequenceProvider) DefaultModel() string { return "sequence-model" }
+
+func (p *sequenceProvider) Chat(messages []ChatMessage, opts *ChatOptions) (string, error) {
+	i := p.calls
+	p.calls++
+	if i < len(p.errs) && p.errs[i] != nil {
+		return "", p.errs[i]
+	}
+	return "ok", nil
+}
+
+func (p *sequenceProvider) GetTokenizer() tokenizer.Tokenizer {
+	return tokenizer.NewOpenAITokenizer()
+}
+
+// retryAfterErr is a test error carrying a Retry-After hint
+type retryAfterErr struct {
+	delay time.Duration
+}
+
+func (e retryAfterErr) Error() string             { return "too many requests" }
+func (e retryAfterErr) RetryAfter() time.Duration { return e.delay }
+
+func TestRetryingProviderChat(t *testing.T) {
+	tests := []struct {
+		name           string
+		errs           []error
+		expectErr      bool
+		expectedCalls  int
+		expectedDelays []time.Duration
+	}{
+		{
+			name:          "success on first attempt",
+			expectedCalls: 1,
+		},
+		{
+			name:           "retries transient errors then succeeds",
+			errs:           []error{errors.New("503 Service Unavailable"), errors.New("overloaded")},
+			expectedCalls:  3,
+			expectedDelays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			name:          "non-retryable error stops immediately",
+			errs:          []error{errors.New("401 Unauthorized")},
+			expectErr:     true,
+			expectedCalls: 1,
+		},
+		{
+			name:           "gives up after max retries",
+			errs:           []error{errors.New("timeout"), errors.New("timeout"), errors.New("timeout"), errors.New("timeout")},
+			expectErr:      true,
+			expectedCalls:  4,
+			expectedDelays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond},
+		},
+		{
+			name:           "honours Retry-After hint",
+			errs:           []error{retryAfterErr{delay: 5 * time.Second}},
+			expectedCalls:  2,
+			expectedDelays: []time.Duration{5 * time.Second},
+		},
+		{
+			name:           "caps Retry-After hint",
+			errs:           []error{errors.New("rate limit exceeded, retry-after: 600")},
+			expectedCalls:  2,
+			expectedDelays: []time.Duration{maxRetryAfter},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &sequenceProvider{errs: tt.errs}
+			p := NewRetryingProvider(inner, RetryConfig{MaxRetries: 3, BaseDelay: 100 * time.Millisecond})
+
+			var delays []time.Duration
+			p.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+			response, err := p.Chat([]ChatMessage{{Role: "user", Content: "hi"}}, nil)
+			if tt.expectErr && err == nil {
+				t.Errorf("Expected an error, got response %q", response)
+			}
+			if !tt.expectErr && (err != nil || response != "ok") {
+				t.Errorf("Expected response 'ok', got %q (err: %v)", response, err)
+			}
+			if inner.calls != tt.expectedCalls {
+				t.Errorf("Expected %d calls, got %d", tt.expectedCalls, inner.calls)
+			}
+			if len(delays) != len(tt.expectedDelays) {
+				t.Fatalf("Expected delays %v, got %v", tt.expectedDelays, delays)
+			}
+			for i := range delays {
+				if delays[i] != tt.expectedDelays[i] {
+					t.Errorf("Expected delay %v for retry %d, got %v", tt.expectedDelays[i], i+1, delays[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRetryingProviderDelegates(t *testing.T) {
+	inner := &sequenceProvider{}
+	p := NewRetryingProvider(inner, RetryConfig{})
+
+	if p.Name() != "sequence" {
+		t.Errorf("Expected Name to be 'sequence', got %q", p.Name())
+	}
+	if p.DefaultModel() != "sequence-model" {
+		t.Errorf("Expected DefaultModel to be 'sequence-model', got %q", p.DefaultModel())
+	}
+	if p.Unwrap() != inner {
+		t.Errorf("Expected Unwrap to return the wrapped provider")
+	}
+}
+
+func TestRetryingProviderJitter(t *testing.T) {
+	tests := []struct {
+		name     string
+		jitter   float64
+		random   float64
+		expected time.Duration
+	}{
+		{"no jitter", 0, 0.9, 100 * time.Millisecond},
+		{"minimum jitter", 0.5, 0, 100 * time.Millisecond},
+		{"half jitter", 0.5, 0.5, 125 * time.Millisecond},
+		{"full jitter", 1, 0.99, 199 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewRetryingProvider(&sequenceProvider{}, RetryConfig{BaseDelay: 100 * time.Millisecond, Jitter: tt.jitter})
+			p.random = func() float64 { return tt.random }
+
+			if delay := p.backoff(1); delay != tt.expected {
+				t.Errorf("Expected delay %v, got %v", tt.expected, delay)
+			}
+		})
+	}
+}
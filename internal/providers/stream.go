@@ -0,0 +1,95 @@
+package providers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamingProvider is implemented by providers that can deliver a reply as it
+// is generated. onChunk receives each piece of text in order; the complete
+// reply is also returned once the stream ends.
+type StreamingProvider interface {
+	LLMProvider
+	ChatStream(messages []ChatMessage, opts *ChatOptions, onChunk func(chunk string)) (string, error)
+}
+
+// ChatStream streams the reply when provider supports it and otherwise falls
+// back to Chat, passing the whole reply to onChunk at once
+func ChatStream(provider LLMProvider, messages []ChatMessage, opts *ChatOptions, onChunk func(chunk string)) (string, error) {
+	if streaming, ok := provider.(StreamingProvider); ok {
+		return streaming.ChatStream(messages, opts, onChunk)
+	}
+	reply, err := provider.Chat(messages, opts)
+	if err == nil && reply != "" && onChunk != nil {
+		onChunk(reply)
+	}
+	return reply, err
+}
+
+// chatStreamChunk is one "data:" event of an OpenAI-style streamed chat completion
+type chatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+	Usage *openAIUsage `json:"usage,omitempty"` // Sent by some servers on the final event
+}
+
+// readChatSSE reads an OpenAI-style server-sent event stream, calling onChunk
+// for every content delta as soon as its line arrives. It stops at
+// "data: [DONE]" and returns the concatenated reply, with the token usage when
+// the stream reported it.
+func readChatSSE(body io.Reader, onChunk func(chunk string)) (string, Usage, error) {
+	var sb strings.Builder
+	var usage Usage
+	scanner := bufio.NewScanner(body)
+	// A single event can carry a long delta
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue // Blank separators, comments and event names
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return sb.String(), usage, nil
+		}
+
+		var chunk chatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", Usage{}, fmt.Errorf("failed to decode stream event: %w", err)
+		}
+		if chunk.Error != nil {
+			return "", Usage{}, fmt.Errorf("stream error: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage.usage()
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			sb.WriteString(choice.Delta.Content)
+			if onChunk != nil {
+				onChunk(choice.Delta.Content)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", Usage{}, fmt.Errorf("failed to read streaming response: %w", err)
+	}
+	// Some servers close the stream without [DONE]
+	if sb.Len() == 0 {
+		return "", Usage{}, fmt.Errorf("stream ended without a reply")
+	}
+	return sb.String(), usage, nil
+}
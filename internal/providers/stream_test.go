@@ -0,0 +1,144 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadChatSSE(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []string
+		expected []string
+		err      string
+	}{
+		{
+			name: "one chunk per delta",
+			events: []string{
+				`data: {"choices":[{"delta":{"role":"assistant"}}]}`,
+				`data: {"choices":[{"delta":{"content":"{\"type\":"}}]}`,
+				`data: {"choices":[{"delta":{"content":"\"done\"}"}}]}`,
+				`data: [DONE]`,
+			},
+			expected: []string{`{"type":`, `"done"}`},
+		},
+		{
+			name: "comments and blank lines are skipped",
+			events: []string{
+				`: keep-alive`,
+				``,
+				`data: {"choices":[{"delta":{"content":"Hi"}}]}`,
+			},
+			expected: []string{"Hi"},
+		},
+		{
+			name:   "error event",
+			events: []string{`data: {"error":{"message":"rate limited"}}`},
+			err:    "rate limited",
+		},
+		{
+			name:   "no reply",
+			events: []string{`data: [DONE]`},
+		},
+		{
+			name:   "malformed event",
+			events: []string{`data: {"choices":`},
+			err:    "failed to decode stream event",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chunks []string
+			result, _, err := readChatSSE(strings.NewReader(strings.Join(tt.events, "\n\n")), func(chunk string) {
+				chunks = append(chunks, chunk)
+			})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(chunks, tt.expected) {
+				t.Errorf("Expected chunks %q, got %q", tt.expected, chunks)
+			}
+			if want := strings.Join(tt.expected, ""); result != want {
+				t.Errorf("Expected result %q, got %q", want, result)
+			}
+		})
+	}
+}
+
+func TestReadChatSSEUsage(t *testing.T) {
+	events := strings.Join([]string{
+		`data: {"choices":[{"delta":{"content":"Hi"}}],"usage":null}`,
+		`data: {"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`,
+		`data: [DONE]`,
+	}, "\n\n")
+	result, usage, err := readChatSSE(strings.NewReader(events), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "Hi" {
+		t.Errorf("Expected result %q, got %q", "Hi", result)
+	}
+	if expected := (Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}); usage != expected {
+		t.Errorf("Expected usage %+v, got %+v", expected, usage)
+	}
+
+	// Copilot only sends the usage event when asked to
+	body, _ := json.Marshal(CopilotChatRequest{Model: "gpt-4o", Stream: true, StreamOptions: &CopilotStreamOptions{IncludeUsage: true}})
+	if !strings.Contains(string(body), `"stream_options":{"include_usage":true}`) {
+		t.Errorf("Expected stream_options in the request, got %s", body)
+	}
+}
+
+func TestReadChatSSEIsIncremental(t *testing.T) {
+	pr, pw := io.Pipe()
+	received := make(chan string)
+	done := make(chan string)
+	go func() {
+		result, _, _ := readChatSSE(pr, func(chunk string) { received <- chunk })
+		done <- result
+	}()
+
+	// Each chunk must reach the callback before the next event is sent
+	for _, word := range []string{"one ", "two ", "three"} {
+		fmt.Fprintf(pw, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", word)
+		select {
+		case chunk := <-received:
+			if chunk != word {
+				t.Fatalf("Expected chunk %q, got %q", word, chunk)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Chunk %q was not delivered before the stream ended", word)
+		}
+	}
+	pw.Write([]byte("data: [DONE]\n\n"))
+	pw.Close()
+
+	if result := <-done; result != "one two three" {
+		t.Errorf("Expected full reply, got %q", result)
+	}
+}
+
+func TestChatStreamFallback(t *testing.T) {
+	var chunks []string
+	result, err := ChatStream(&sequenceProvider{}, nil, nil, func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "ok" || !reflect.DeepEqual(chunks, []string{"ok"}) {
+		t.Errorf("Expected the whole reply as one chunk, got %q and %q", result, chunks)
+	}
+}
@@ -26,7 +26,7 @@ func ChatWithTokenLimits(provider LLMProvider, messages []ChatMessage, opts *Cha
 	maxTokens := config.MaxTokensPerRequest
 	if maxTokens <= 0 {
 		// Use model's max context window
-		maxTokens = tokenizer.GetMaxContextTokens(model)
+		maxTokens = ModelContextTokens(provider, model)
 	}
 
 	// Create message splitter
@@ -65,7 +65,6 @@ func EstimateTokensForMessages(provider LLMProvider, messages []ChatMessage) int
 
 // CheckTokenLimits checks if messages exceed the configured or model limits
 func CheckTokenLimits(provider LLMProvider, messages []ChatMessage, config *common.TerminusAIConfig, model string) (bool, int, int) {
-	tokenizer := provider.GetTokenizer()
 	totalTokens := EstimateTokensForMessages(provider, messages)
 
 	// Determine the effective limit
@@ -73,7 +72,7 @@ func CheckTokenLimits(provider LLMProvider, messages []ChatMessage, config *comm
 	if config != nil && config.MaxTokensPerRequest > 0 {
 		effectiveLimit = config.MaxTokensPerRequest
 	} else {
-		effectiveLimit = tokenizer.GetMaxContextTokens(model)
+		effectiveLimit = ModelContextTokens(provider, model)
 	}
 
 	exceedsLimit := totalTokens > effectiveLimit
@@ -0,0 +1,100 @@
+package providers
+
+import (
+	"strings"
+	"sync"
+)
+
+// Usage is the token count a provider reported for one or more replies
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+// Add accumulates other into u
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// UsageReporter is implemented by providers that report token usage. LastUsage
+// is the usage of the most recent Chat or ChatStream reply, zero when the
+// server sent none.
+type UsageReporter interface {
+	LastUsage() Usage
+}
+
+// LastUsage returns the usage of provider's most recent reply, or zero when
+// the provider doesn't report usage
+func LastUsage(provider LLMProvider) Usage {
+	if reporter, ok := provider.(UsageReporter); ok {
+		return reporter.LastUsage()
+	}
+	return Usage{}
+}
+
+// usageRecorder gives a provider LastUsage; embed it and call record once per reply
+type usageRecorder struct {
+	mu    sync.Mutex
+	usage Usage
+}
+
+// LastUsage returns the usage of the most recent reply
+func (r *usageRecorder) LastUsage() Usage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.usage
+}
+
+func (r *usageRecorder) record(usage Usage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usage = usage
+}
+
+// openAIUsage is the usage block of an OpenAI-style chat completion
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *openAIUsage) usage() Usage {
+	if u == nil {
+		return Usage{}
+	}
+	return Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+}
+
+// modelPrice is the list price of a model family in USD per million tokens
+type modelPrice struct {
+	provider string
+	prefix   string // Model name prefix; more specific prefixes come first
+	input    float64
+	output   float64
+}
+
+// modelPrices drives EstimateCost. Copilot is billed by subscription and
+// Ollama runs locally, so neither has entries.
+var modelPrices = []modelPrice{
+	{provider: "openai", prefix: "gpt-4o-mini", input: 0.15, output: 0.60},
+	{provider: "openai", prefix: "gpt-4o", input: 2.50, output: 10.00},
+	{provider: "openai", prefix: "o1-mini", input: 1.10, output: 4.40},
+	{provider: "anthropic", prefix: "claude-opus-4", input: 15.00, output: 75.00},
+	{provider: "anthropic", prefix: "claude-3-5-sonnet", input: 3.00, output: 15.00},
+	{provider: "anthropic", prefix: "claude-3-5-haiku", input: 0.80, output: 4.00},
+}
+
+// EstimateCost returns the approximate cost in USD of usage on a provider's
+// model at list prices, and false when the model has no known price
+func EstimateCost(provider, model string, usage Usage) (float64, bool) {
+	for _, price := range modelPrices {
+		if price.provider == provider && strings.HasPrefix(model, price.prefix) {
+			cost := float64(usage.PromptTokens)*price.input + float64(usage.CompletionTokens)*price.output
+			return cost / 1e6, true
+		}
+	}
+	return 0, false
+}
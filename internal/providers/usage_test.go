@@ -0,0 +1,64 @@
+package providers
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestUsageAdd(t *testing.T) {
+	var total Usage
+	total.Add(Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120})
+	total.Add(Usage{PromptTokens: 150, CompletionTokens: 10, TotalTokens: 160})
+
+	expected := Usage{PromptTokens: 250, CompletionTokens: 30, TotalTokens: 280}
+	if total != expected {
+		t.Errorf("Expected %+v, got %+v", expected, total)
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	usage := Usage{PromptTokens: 1000000, CompletionTokens: 100000, TotalTokens: 1100000}
+
+	tests := []struct {
+		provider string
+		model    string
+		expected float64
+		known    bool
+	}{
+		{"openai", "gpt-4o", 3.50, true},
+		{"openai", "gpt-4o-mini", 0.21, true},
+		{"openai", "gpt-4o-2024-08-06", 3.50, true},
+		{"anthropic", "claude-3-5-haiku-latest", 1.20, true},
+		{"copilot", "gpt-4o", 0, false},
+		{"ollama", "llama3.2", 0, false},
+		{"openai", "unknown-model", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.model, func(t *testing.T) {
+			cost, known := EstimateCost(tt.provider, tt.model, usage)
+			if known != tt.known || math.Abs(cost-tt.expected) > 1e-9 {
+				t.Errorf("Expected %.4f (known=%v), got %.4f (known=%v)", tt.expected, tt.known, cost, known)
+			}
+		})
+	}
+}
+
+func TestReplyUsage(t *testing.T) {
+	expected := Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}
+
+	_, usage, err := readChatSSE(strings.NewReader(strings.Join([]string{
+		`data: {"choices":[{"delta":{"content":"Hi"}}]}`,
+		`data: {"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`,
+		`data: [DONE]`,
+	}, "\n\n")), nil)
+	if err != nil || usage != expected {
+		t.Errorf("Expected stream usage %+v, got %+v (%v)", expected, usage, err)
+	}
+
+	_, usage, err = readOllamaReply(strings.NewReader(`{"message":{"role":"assistant","content":"Hi"},"done":true,"prompt_eval_count":12,"eval_count":3}`), nil)
+	if err != nil || usage != expected {
+		t.Errorf("Expected Ollama usage %+v, got %+v (%v)", expected, usage, err)
+	}
+}
@@ -151,7 +151,7 @@ func (r *Runner) RunPlannedCommands(plan *planner.Plan) error {
 		r.display.PrintVerbose("Step %d: shell=%s cwd=%s", i+1, step.Shell, step.CWD)
 
 		// Get approval from policy store
-		decision, err := r.policyStore.Approve(step.Command, step.Description)
+		decision, err := r.policyStore.Approve("shell", step.Command, step.Description)
 		if err != nil {
 			r.updateStepStatus(i, "failed", fmt.Errorf("failed to get approval: %w", err))
 			r.display.PrintStepError(step.Command, err)
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"terminusai/internal/agent"
+	"terminusai/internal/policy"
+	"terminusai/internal/ui"
+
+	"github.com/google/uuid"
+)
+
+// defaultReplyTimeout is how long a prompt waits for the client's reply
+const defaultReplyTimeout = 10 * time.Minute
+
+// errReplyTimeout is returned when a prompt isn't answered in time
+var errReplyTimeout = errors.New("no reply from client")
+
+// TaskRequest is the body of POST /tasks
+type TaskRequest struct {
+	Task       string `json:"task"`
+	WorkingDir string `json:"workingDir,omitempty"`
+}
+
+// Reply is the body of POST /tasks/{task}/replies/{prompt}
+type Reply struct {
+	Decision policy.Decision `json:"decision,omitempty"` // once|always|never|skip for approvals
+	Answer   string          `json:"answer,omitempty"`   // Text for questions
+}
+
+// Event is one line of the newline-delimited JSON stream returned by POST /tasks.
+// Besides the display events (action, update, result) the stream carries:
+// task (first line, with the task id), approval and question (with a prompt id
+// to reply to) and done (last line, with the error if the task failed).
+type Event struct {
+	ui.Event
+	Task        string `json:"task,omitempty"`
+	Prompt      string `json:"prompt,omitempty"`
+	ActionType  string `json:"actionType,omitempty"`
+	Command     string `json:"command,omitempty"`
+	Description string `json:"description,omitempty"`
+	Question    string `json:"question,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Session is the agent and policy store running one task
+type Session struct {
+	Agent  *agent.Agent
+	Policy *policy.Store
+	Close  func() error // Called after the task, e.g. to save the policy (optional)
+}
+
+// AgentFactory creates the session for a task request
+type AgentFactory func(req TaskRequest) (*Session, error)
+
+// Server runs agent tasks over HTTP. POST /tasks starts a task and streams its
+// events; approvals and questions are answered with POST /tasks/{task}/replies/{prompt}
+// instead of the terminal.
+type Server struct {
+	newSession   AgentFactory
+	token        string
+	replyTimeout time.Duration
+
+	mu    sync.Mutex
+	tasks map[string]*task
+}
+
+// New creates a server. When token is non-empty every request must carry it as
+// "Authorization: Bearer <token>".
+func New(newSession AgentFactory, token string) *Server {
+	return &Server{
+		newSession:   newSession,
+		token:        token,
+		replyTimeout: defaultReplyTimeout,
+		tasks:        make(map[string]*task),
+	}
+}
+
+// Handler returns the HTTP handler serving the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tasks", s.handleStartTask)
+	mux.HandleFunc("/tasks/", s.handleReply)
+	return s.authorize(mux)
+}
+
+// authorize rejects requests without the bearer token
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) != 1 {
+				writeError(w, http.StatusUnauthorized, "invalid or missing token")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// task is a running task and its unanswered prompts
+type task struct {
+	id      string
+	w       http.ResponseWriter
+	flusher http.Flusher
+
+	mu      sync.Mutex
+	pending map[string]chan Reply
+	prompts int
+}
+
+// send writes one event line and flushes it to the client. Write errors are
+// ignored: a gone client surfaces as a cancelled context on the next prompt.
+func (t *task) send(event Event) {
+	data, _ := json.Marshal(event)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.w.Write(append(data, '\n'))
+	t.flusher.Flush()
+}
+
+// prompt sends an approval or question event and waits for the client's reply
+func (t *task) prompt(ctx context.Context, event Event, timeout time.Duration) (Reply, error) {
+	ch := make(chan Reply, 1)
+	t.mu.Lock()
+	t.prompts++
+	id := fmt.Sprintf("p%d", t.prompts)
+	t.pending[id] = ch
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+	}()
+
+	event.Prompt = id
+	t.send(event)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case reply := <-ch:
+		return reply, nil
+	case <-ctx.Done():
+		return Reply{}, ctx.Err()
+	case <-timer.C:
+		return Reply{}, errReplyTimeout
+	}
+}
+
+// handleStartTask runs a task and streams its events until it ends
+func (s *Server) handleStartTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	var req TaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if strings.TrimSpace(req.Task) == "" {
+		writeError(w, http.StatusBadRequest, "task is required")
+		return
+	}
+
+	session, err := s.newSession(req)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	t := &task{id: uuid.NewString(), w: w, flusher: flusher, pending: make(map[string]chan Reply)}
+	s.mu.Lock()
+	s.tasks[t.id] = t
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.tasks, t.id)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	t.send(Event{Event: ui.Event{Type: "task"}, Task: t.id})
+
+	ctx := r.Context()
+	session.Agent.SetEventSink(func(event ui.Event) {
+		t.send(Event{Event: event})
+	})
+	session.Policy.SetPrompter(func(actionType, command, description string) (policy.Decision, error) {
+		reply, err := t.prompt(ctx, Event{Event: ui.Event{Type: "approval"}, ActionType: actionType, Command: command, Description: description}, s.replyTimeout)
+		if err != nil {
+			return policy.DecisionSkip, err
+		}
+		if reply.Decision == "" {
+			// An answer without a decision never approves a command
+			return policy.DecisionSkip, nil
+		}
+		return reply.Decision, nil
+	})
+	session.Agent.SetAsker(func(question string) (string, error) {
+		reply, err := t.prompt(ctx, Event{Event: ui.Event{Type: "question"}, Question: question}, s.replyTimeout)
+		return reply.Answer, err
+	})
+
+	runErr := session.Agent.RunTask(req.Task)
+	if session.Close != nil {
+		if err := session.Close(); err != nil && runErr == nil {
+			runErr = err
+		}
+	}
+
+	done := Event{Event: ui.Event{Type: "done"}, Task: t.id}
+	if runErr != nil {
+		done.Error = runErr.Error()
+	}
+	t.send(done)
+}
+
+// handleReply delivers the answer to a pending prompt: POST /tasks/{task}/replies/{prompt}
+func (s *Server) handleReply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/tasks/"), "/"), "/")
+	if len(parts) != 3 || parts[1] != "replies" {
+		writeError(w, http.StatusNotFound, "expected /tasks/{task}/replies/{prompt}")
+		return
+	}
+
+	var reply Reply
+	if err := json.NewDecoder(r.Body).Decode(&reply); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid reply: %v", err))
+		return
+	}
+	switch reply.Decision {
+	case "", policy.DecisionOnce, policy.DecisionAlways, policy.DecisionAlwaysType, policy.DecisionNever, policy.DecisionSkip:
+	default:
+		writeError(w, http.StatusBadRequest, "decision must be once, always, always-type, never or skip")
+		return
+	}
+
+	s.mu.Lock()
+	t := s.tasks[parts[0]]
+	s.mu.Unlock()
+	if t == nil {
+		writeError(w, http.StatusNotFound, "unknown task")
+		return
+	}
+
+	t.mu.Lock()
+	ch := t.pending[parts[2]]
+	delete(t.pending, parts[2])
+	t.mu.Unlock()
+	if ch == nil {
+		writeError(w, http.StatusNotFound, "unknown or already answered prompt")
+		return
+	}
+
+	ch <- reply
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeError sends a JSON error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"terminusai/internal/agent"
+	"terminusai/internal/policy"
+	"terminusai/internal/providers"
+	"terminusai/internal/tokenizer"
+)
+
+// scriptedProvider returns canned responses in order, then done
+type scriptedProvider struct {
+	responses []string
+	calls     int
+}
+
+func (p *scriptedProvider) Name() string         { return "scripted" }
+func (p *scriptedProvider) DefaultModel() string { return "scripted-model" }
+
+func (p *scriptedProvider) Chat(messages []providers.ChatMessage, opts *providers.ChatOptions) (string, error) {
+	defer func() { p.calls++ }()
+	if p.calls < len(p.responses) {
+		return p.responses[p.calls], nil
+	}
+	return `{"type":"done","result":"all done"}`, nil
+}
+
+func (p *scriptedProvider) GetTokenizer() tokenizer.Tokenizer {
+	return tokenizer.NewOpenAITokenizer()
+}
+
+// newTestServer serves an agent that writes out.txt in dir (which needs approval)
+func newTestServer(t *testing.T, dir, token string) *httptest.Server {
+	t.Helper()
+	srv := New(func(req TaskRequest) (*Session, error) {
+		provider := &scriptedProvider{responses: []string{
+			`{"type":"write_file","path":"out.txt","content":"hello"}`,
+		}}
+		store := &policy.Store{}
+		return &Session{Agent: agent.NewAgent(provider, store, dir, false, false), Policy: store}, nil
+	}, token)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// runTask starts a task and answers every approval with decision, returning all events
+func runTask(t *testing.T, ts *httptest.Server, decision policy.Decision) []Event {
+	t.Helper()
+	resp, err := http.Post(ts.URL+"/tasks", "application/json", strings.NewReader(`{"task":"write a file"}`))
+	if err != nil {
+		t.Fatalf("Failed to start task: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	var events []Event
+	taskID := ""
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Invalid event line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+
+		switch event.Type {
+		case "task":
+			taskID = event.Task
+		case "approval":
+			body, _ := json.Marshal(Reply{Decision: decision})
+			reply, err := http.Post(ts.URL+"/tasks/"+taskID+"/replies/"+event.Prompt, "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("Failed to reply: %v", err)
+			}
+			reply.Body.Close()
+			if reply.StatusCode != http.StatusNoContent {
+				t.Errorf("Expected reply status 204, got %d", reply.StatusCode)
+			}
+		}
+	}
+	return events
+}
+
+func TestServerTaskApproval(t *testing.T) {
+	tests := []struct {
+		name        string
+		decision    policy.Decision
+		expectWrite bool
+	}{
+		{"approved", policy.DecisionOnce, true},
+		{"skipped", policy.DecisionSkip, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			events := runTask(t, newTestServer(t, dir, ""), tt.decision)
+
+			types := make([]string, len(events))
+			for i, event := range events {
+				types[i] = event.Type
+			}
+			joined := strings.Join(types, ",")
+			if !strings.HasPrefix(joined, "task,") || !strings.HasSuffix(joined, ",result,done") {
+				t.Errorf("Expected task ... result,done, got %s", joined)
+			}
+			if !strings.Contains(joined, "approval") {
+				t.Errorf("Expected an approval event, got %s", joined)
+			}
+			if last := events[len(events)-1]; last.Error != "" {
+				t.Errorf("Expected task to succeed, got %s", last.Error)
+			}
+
+			_, err := os.Stat(filepath.Join(dir, "out.txt"))
+			if written := err == nil; written != tt.expectWrite {
+				t.Errorf("Expected file written=%v, got %v", tt.expectWrite, written)
+			}
+		})
+	}
+}
+
+func TestServerRequiresToken(t *testing.T) {
+	ts := newTestServer(t, t.TempDir(), "secret")
+
+	resp, err := http.Post(ts.URL+"/tasks", "application/json", strings.NewReader(`{"task":"x"}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without token, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerReplyUnknownPrompt(t *testing.T) {
+	ts := newTestServer(t, t.TempDir(), "")
+
+	resp, err := http.Post(ts.URL+"/tasks/nope/replies/p1", "application/json", strings.NewReader(`{"decision":"once"}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", resp.StatusCode)
+	}
+}
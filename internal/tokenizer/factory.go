@@ -22,6 +22,9 @@ func (f *TokenizerFactory) CreateTokenizer(provider string) (Tokenizer, error) {
 		return NewAnthropicTokenizer(), nil
 	case common.ProviderCopilot:
 		return NewCopilotTokenizer(), nil
+	case common.ProviderOllama:
+		// Local models use many tokenizers; the OpenAI estimate is close enough
+		return NewOpenAITokenizer(), nil
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", provider)
 	}
@@ -362,7 +362,8 @@ func (d *Display) PrintSessionMutation(command string) {
 	Muted.Println("(This command needs to modify your current shell session)")
 }
 
-// EnableColors ensures colors are enabled
+// EnableColors ensures colors are enabled. Like DisableColors it changes a
+// process-wide setting that affects every agent.
 func EnableColors() {
 	color.NoColor = false
 }
@@ -372,8 +373,12 @@ func DisableColors() {
 	color.NoColor = true
 }
 
-// IsTerminal checks if output is to a terminal
+// IsTerminal checks if output is to a terminal. While TeeOutput pipes stdout
+// into a log, it checks the stdout the pipe is copied to.
 func IsTerminal() bool {
-	fileInfo, _ := os.Stdout.Stat()
+	fileInfo, err := terminalStdout().Stat()
+	if err != nil {
+		return false
+	}
 	return (fileInfo.Mode() & os.ModeCharDevice) != 0
-}
\ No newline at end of file
+}
\ No newline at end of file
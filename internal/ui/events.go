@@ -0,0 +1,27 @@
+package ui
+
+// Event describes display activity for front ends that aren't a terminal, such
+// as server mode. Actions are numbered in the order they are shown.
+type Event struct {
+	Type    string   `json:"type"`         // action, update or result
+	ID      int      `json:"id,omitempty"` // Action number within the task
+	Title   string   `json:"title,omitempty"`
+	Summary string   `json:"summary,omitempty"`
+	Status  string   `json:"status,omitempty"`
+	Details []string `json:"details,omitempty"`
+	// Data is a structured payload, e.g. the task outcome on result events
+	Data interface{} `json:"data,omitempty"`
+}
+
+// SetEventSink registers a function receiving an Event for every action shown
+// or updated, in addition to the terminal output
+func (id *InteractiveDisplay) SetEventSink(sink func(Event)) {
+	id.sink = sink
+}
+
+// Emit sends an event to the sink, if any
+func (id *InteractiveDisplay) Emit(event Event) {
+	if id.sink != nil {
+		id.sink(event)
+	}
+}
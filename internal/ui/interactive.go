@@ -11,6 +11,7 @@ import (
 
 // InteractiveAction represents an action with expandable details
 type InteractiveAction struct {
+	ID         int
 	Title      string
 	Summary    string
 	Details    []string
@@ -28,6 +29,7 @@ type InteractiveDisplay struct {
 	display *Display
 	actions []InteractiveAction
 	reader  *bufio.Reader
+	sink    func(Event)
 }
 
 // NewInteractiveDisplay creates a new interactive display manager
@@ -42,6 +44,7 @@ func NewInteractiveDisplay(verbose, debug bool) *InteractiveDisplay {
 // ShowAction displays an action with interactive capabilities
 func (id *InteractiveDisplay) ShowAction(title, summary string, expandable bool) *InteractiveAction {
 	action := InteractiveAction{
+		ID:         len(id.actions) + 1,
 		Title:      title,
 		Summary:    summary,
 		StartTime:  time.Now(),
@@ -59,6 +62,7 @@ func (id *InteractiveDisplay) ShowAction(title, summary string, expandable bool)
 		Muted.Printf("  ⎿  %s", summary)
 		fmt.Println()
 	}
+	id.Emit(Event{Type: "action", ID: action.ID, Title: title, Summary: summary, Status: action.Status})
 
 	return &id.actions[actionIndex]
 }
@@ -68,6 +72,7 @@ func (id *InteractiveDisplay) UpdateAction(action *InteractiveAction, status str
 	action.Status = status
 	action.Details = details
 	action.EndTime = time.Now()
+	id.Emit(Event{Type: "update", ID: action.ID, Title: action.Title, Summary: action.Summary, Status: status, Details: details})
 
 	// Clear the previous line and show updated status
 	fmt.Print("\r\033[K")
@@ -131,6 +136,45 @@ func (id *InteractiveDisplay) ShowShellCommand(shell, command, reason string) *I
 	return action
 }
 
+// ShowDiff displays a unified diff with added/removed lines highlighted
+func (id *InteractiveDisplay) ShowDiff(diff string, maxLines int) {
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+
+	Muted.Println("  ┌─ Proposed changes:")
+	for i, line := range lines {
+		if maxLines > 0 && i >= maxLines {
+			Muted.Printf("  │ ... (%d more lines)\n", len(lines)-maxLines)
+			break
+		}
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			Muted.Printf("  │ %s\n", line)
+		case strings.HasPrefix(line, "@@"):
+			Primary.Printf("  │ %s\n", line)
+		case strings.HasPrefix(line, "+"):
+			Success.Printf("  │ %s\n", line)
+		case strings.HasPrefix(line, "-"):
+			Error.Printf("  │ %s\n", line)
+		default:
+			Muted.Printf("  │ %s\n", line)
+		}
+	}
+	Muted.Println("  └─")
+}
+
+// ShowDeletionPreview lists the files a delete command would remove
+func (id *InteractiveDisplay) ShowDeletionPreview(paths []string, maxItems int) {
+	Warning.Printf("  ┌─ Matches %d path(s) to delete:\n", len(paths))
+	for i, path := range paths {
+		if maxItems > 0 && i >= maxItems {
+			Muted.Printf("  │ ... (%d more)\n", len(paths)-maxItems)
+			break
+		}
+		Error.Printf("  │ %s\n", path)
+	}
+	Muted.Println("  └─")
+}
+
 // ShowAgentThinking displays agent analysis phase
 func (id *InteractiveDisplay) ShowAgentThinking(task string) *Spinner {
 	Primary.Printf("● Analyzing task: %s\n", task)
@@ -139,6 +183,37 @@ func (id *InteractiveDisplay) ShowAgentThinking(task string) *Spinner {
 	return spinner
 }
 
+// ResponseStream renders a model reply token by token under a thinking spinner
+type ResponseStream struct {
+	spinner *Spinner
+	started bool
+}
+
+// ShowResponseStream starts the spinner shown while waiting for the model's reply
+func (id *InteractiveDisplay) ShowResponseStream() *ResponseStream {
+	spinner := NewSpinner("  ⎿  Thinking...")
+	spinner.Start()
+	return &ResponseStream{spinner: spinner}
+}
+
+// Write prints a chunk of the reply, replacing the spinner on the first one
+func (rs *ResponseStream) Write(chunk string) {
+	if !rs.started {
+		rs.spinner.Stop()
+		rs.started = true
+		Muted.Print("  ⎿  ")
+	}
+	Muted.Print(strings.ReplaceAll(chunk, "\n", "\n     "))
+}
+
+// Done stops the spinner and ends the streamed reply's line
+func (rs *ResponseStream) Done() {
+	rs.spinner.Stop()
+	if rs.started {
+		fmt.Println()
+	}
+}
+
 // PromptForExpansion asks user if they want to see detailed output
 func (id *InteractiveDisplay) PromptForExpansion(action *InteractiveAction) bool {
 	// Don't prompt automatically - let user decide
@@ -186,9 +261,18 @@ func (id *InteractiveDisplay) ShowExpandedDetails(action *InteractiveAction) {
 	action.Expanded = true
 }
 
+// TokenUsage is the provider usage of a task, shown by ShowAgentSummary
+type TokenUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	Cost             float64 // Approximate cost in USD, set when CostKnown
+	CostKnown        bool
+}
+
 // ShowAgentSummary displays a summary at the end of agent execution
-func (id *InteractiveDisplay) ShowAgentSummary() {
-	if len(id.actions) == 0 {
+func (id *InteractiveDisplay) ShowAgentSummary(usage TokenUsage) {
+	if len(id.actions) == 0 && usage.TotalTokens == 0 {
 		return
 	}
 
@@ -225,6 +309,13 @@ func (id *InteractiveDisplay) ShowAgentSummary() {
 	if total > 0 {
 		fmt.Printf("Total: %d actions\n", total)
 	}
+	if usage.TotalTokens > 0 {
+		fmt.Printf("Tokens: %d (%d prompt, %d completion)", usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens)
+		if usage.CostKnown {
+			fmt.Printf(", about $%.4f", usage.Cost)
+		}
+		fmt.Println()
+	}
 }
 
 // CompactOutput controls whether to show minimal output
@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// JSON syntax colors; like the rest of the palette they print plain text
+// when stdout is not a terminal
+var (
+	jsonKey         = color.New(color.FgCyan)
+	jsonString      = color.New(color.FgGreen)
+	jsonNumber      = color.New(color.FgYellow)
+	jsonLiteral     = color.New(color.FgHiBlue) // true, false, null
+	jsonPunctuation = color.New(color.FgHiBlack)
+)
+
+// RenderJSON pretty-prints a decoded JSON value with syntax colors. Objects
+// and arrays with more than maxItems entries show only the first maxItems,
+// followed by a "(N more of M items)" marker (maxItems <= 0 shows everything).
+func RenderJSON(value interface{}, maxItems int) []string {
+	var lines []string
+	renderJSONValue(&lines, "", "", value, "", maxItems)
+	return lines
+}
+
+// renderJSONValue appends the lines of one value. label is the colored
+// `"key": ` prefix inside objects and comma the separator after the value.
+func renderJSONValue(lines *[]string, indent, label string, value interface{}, comma string, maxItems int) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		renderJSONContainer(lines, indent, label, "{", "}", len(keys), comma, maxItems, func(i int, comma string) {
+			keyJSON, _ := json.Marshal(keys[i])
+			renderJSONValue(lines, indent+"  ", jsonKey.Sprint(string(keyJSON))+jsonPunctuation.Sprint(": "), v[keys[i]], comma, maxItems)
+		})
+	case []interface{}:
+		renderJSONContainer(lines, indent, label, "[", "]", len(v), comma, maxItems, func(i int, comma string) {
+			renderJSONValue(lines, indent+"  ", "", v[i], comma, maxItems)
+		})
+	default:
+		*lines = append(*lines, indent+label+jsonScalar(v)+comma)
+	}
+}
+
+// renderJSONContainer writes an object or array around its first maxItems entries
+func renderJSONContainer(lines *[]string, indent, label, open, close string, count int, comma string, maxItems int, entry func(i int, comma string)) {
+	if count == 0 {
+		*lines = append(*lines, indent+label+jsonPunctuation.Sprint(open+close)+comma)
+		return
+	}
+
+	*lines = append(*lines, indent+label+jsonPunctuation.Sprint(open))
+	for i := 0; i < count; i++ {
+		if maxItems > 0 && i == maxItems {
+			*lines = append(*lines, indent+"  "+Muted.Sprintf("… (%d more of %d items)", count-i, count))
+			break
+		}
+		sep := ","
+		if i == count-1 {
+			sep = ""
+		}
+		entry(i, sep)
+	}
+	*lines = append(*lines, indent+jsonPunctuation.Sprint(close)+comma)
+}
+
+// jsonScalar colors a string, number, boolean or null
+func jsonScalar(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	switch value.(type) {
+	case string:
+		return jsonString.Sprint(string(encoded))
+	case nil, bool:
+		return jsonLiteral.Sprint(string(encoded))
+	default:
+		return jsonNumber.Sprint(string(encoded))
+	}
+}
+
+// ShowJSON prints a parsed document under the current action, collapsing
+// large objects/arrays and stopping after maxLines lines. It prints nothing
+// when stdout is not a terminal, where the tree would only clutter piped or
+// captured output.
+func (id *InteractiveDisplay) ShowJSON(value interface{}, maxItems, maxLines int) {
+	if !IsTerminal() {
+		return
+	}
+
+	lines := RenderJSON(value, maxItems)
+	for i, line := range lines {
+		if maxLines > 0 && i >= maxLines {
+			Muted.Printf("%s... (%d more lines)\n", tableIndent, len(lines)-maxLines)
+			break
+		}
+		fmt.Println(tableIndent + strings.TrimRight(line, " "))
+	}
+}
@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestRenderJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		maxItems int
+		expected []string
+	}{
+		{"scalars", `{"b": true, "a": "x", "n": 1.5, "z": null}`, 0, []string{
+			`{`,
+			`  "a": "x",`,
+			`  "b": true,`,
+			`  "n": 1.5,`,
+			`  "z": null`,
+			`}`,
+		}},
+		{"nested and empty", `{"list": [1, {"k": []}], "obj": {}}`, 0, []string{
+			`{`,
+			`  "list": [`,
+			`    1,`,
+			`    {`,
+			`      "k": []`,
+			`    }`,
+			`  ],`,
+			`  "obj": {}`,
+			`}`,
+		}},
+		{"collapsed array", `[1, 2, 3, 4, 5]`, 2, []string{
+			`[`,
+			`  1,`,
+			`  2,`,
+			`  … (3 more of 5 items)`,
+			`]`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.input), &value); err != nil {
+				t.Fatalf("Invalid test input: %v", err)
+			}
+			DisableColors()
+			if got := RenderJSON(value, tt.maxItems); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestShowJSONSkipsNonTerminal(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	NewInteractiveDisplay(false, false).ShowJSON(map[string]interface{}{"name": "terminusai"}, 0, 0)
+	os.Stdout = stdout
+	w.Close()
+
+	out, _ := io.ReadAll(r)
+	if len(out) != 0 {
+		t.Errorf("Expected no output when stdout is a pipe, got %q", out)
+	}
+}
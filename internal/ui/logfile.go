@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// ansiEscapeRe matches terminal escape sequences (colors, cursor movement)
+var ansiEscapeRe = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07]*\x07`)
+
+// LogWriter writes a clean copy of terminal output: escape sequences are
+// stripped, lines redrawn with \r (spinners, progress bars) keep only their
+// final text, and each line is prefixed with a timestamp
+type LogWriter struct {
+	out     io.Writer
+	line    []byte
+	pending bool // Saw \r; the next byte decides between CRLF and a redraw
+	now     func() time.Time
+}
+
+// NewLogWriter creates a LogWriter writing to out
+func NewLogWriter(out io.Writer) *LogWriter {
+	return &LogWriter{out: out, now: time.Now}
+}
+
+func (l *LogWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		switch {
+		case b == '\n':
+			l.pending = false
+			if err := l.flush(); err != nil {
+				return 0, err
+			}
+		case b == '\r':
+			l.pending = true
+		default:
+			if l.pending {
+				// Carriage return without newline: the line is being redrawn
+				l.line = l.line[:0]
+				l.pending = false
+			}
+			l.line = append(l.line, b)
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes the buffered line, if it has any visible text
+func (l *LogWriter) flush() error {
+	text := ansiEscapeRe.ReplaceAll(l.line, nil)
+	l.line = l.line[:0]
+	if len(bytes.TrimSpace(text)) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(l.out, l.now().Format("2006-01-02 15:04:05")+" "+string(text)+"\n")
+	return err
+}
+
+// Close writes any unterminated last line
+func (l *LogWriter) Close() error {
+	if len(l.line) == 0 {
+		return nil
+	}
+	return l.flush()
+}
+
+// teeStdout is the stdout TeeOutput replaced with its pipe, nil when not teeing
+var teeStdout *os.File
+
+// terminalStdout returns the stdout that reaches the terminal, which is not
+// os.Stdout while TeeOutput is active
+func terminalStdout() *os.File {
+	if teeStdout != nil {
+		return teeStdout
+	}
+	return os.Stdout
+}
+
+// TeeOutput mirrors everything written to stdout, colored or not, into a
+// clean log file at path (appended) while still showing it on the terminal.
+// The returned function restores stdout and closes the log.
+func TeeOutput(path string) (func() error, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// One pipe carries both plain and colored output so their order is kept.
+	// Colored output keeps going through color's writer, which translates
+	// escape codes on consoles that need it.
+	stdout, colorOutput := os.Stdout, color.Output
+	teeStdout = stdout
+	os.Stdout, color.Output = w, w
+
+	log := NewLogWriter(file)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		io.Copy(io.MultiWriter(colorOutput, log), r)
+	}()
+
+	var once sync.Once
+	return func() error {
+		var closeErr error
+		once.Do(func() {
+			os.Stdout, color.Output = stdout, colorOutput
+			teeStdout = nil
+			w.Close()
+			wg.Wait()
+			r.Close()
+			log.Close()
+			closeErr = file.Close()
+		})
+		return closeErr
+	}, nil
+}
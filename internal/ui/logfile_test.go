@@ -0,0 +1,58 @@
+package ui
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		expected string
+	}{
+		{"plain lines", []string{"one\ntwo\n"}, "2024-05-01 12:00:00 one\n2024-05-01 12:00:00 two\n"},
+		{"colors stripped", []string{"\x1b[32;1m✓ done\x1b[0m\n"}, "2024-05-01 12:00:00 ✓ done\n"},
+		{"spinner redraws keep final text", []string{"\r⠋ Thinking", "\r⠙ Thinking", "\r\x1b[K", "● Read file\n"}, "2024-05-01 12:00:00 ● Read file\n"},
+		{"crlf", []string{"line\r\n"}, "2024-05-01 12:00:00 line\n"},
+		{"split writes and blank lines", []string{"par", "tial\n", "\n   \n"}, "2024-05-01 12:00:00 partial\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			log := NewLogWriter(&out)
+			log.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+			for _, w := range tt.writes {
+				log.Write([]byte(w))
+			}
+			log.Close()
+			if out.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestTeeOutputKeepsTerminalStdout(t *testing.T) {
+	stdout := os.Stdout
+	restore, err := TeeOutput(filepath.Join(t.TempDir(), "session.log"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if os.Stdout == stdout {
+		t.Errorf("Expected stdout to be replaced while teeing")
+	}
+	if terminalStdout() != stdout {
+		t.Errorf("Expected terminal checks to use the original stdout while teeing")
+	}
+	if err := restore(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if os.Stdout != stdout || terminalStdout() != stdout {
+		t.Errorf("Expected stdout restored")
+	}
+}
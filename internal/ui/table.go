@@ -0,0 +1,265 @@
+package ui
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// defaultTerminalWidth is used when the console width can't be detected
+	defaultTerminalWidth = 120
+	// minColumnWidth is the narrowest a column is shrunk to when fitting the terminal
+	minColumnWidth = 4
+	// tableIndent matches the "  ⎿  " indentation of action details
+	tableIndent = "     "
+)
+
+// Table is tabular data rendered with aligned columns
+type Table struct {
+	Headers []string
+	Rows    [][]string
+}
+
+// TerminalWidth returns the console width in columns. COLUMNS overrides detection.
+func TerminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if width := consoleWidth(); width > 0 {
+		return width
+	}
+	return defaultTerminalWidth
+}
+
+// ParseColumns parses whitespace-aligned command output such as `ps aux` or
+// PowerShell's Format-Table. When the header is underlined with dashes the
+// dash runs define the column spans (so empty cells stay aligned); otherwise
+// rows are split on whitespace and the last column keeps the rest of the line.
+// It returns nil when the text doesn't look like a table.
+func ParseColumns(text string) *Table {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) < 2 {
+		return nil
+	}
+
+	if isUnderline(lines[1]) {
+		spans := underlineSpans(lines[1])
+		table := &Table{Headers: cutColumns(lines[0], spans)}
+		for _, line := range lines[2:] {
+			table.Rows = append(table.Rows, cutColumns(line, spans))
+		}
+		return table
+	}
+
+	headers := strings.Fields(lines[0])
+	if len(headers) < 2 {
+		return nil
+	}
+	table := &Table{Headers: headers}
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) > len(headers) {
+			last := strings.Join(fields[len(headers)-1:], " ")
+			fields = append(fields[:len(headers)-1], last)
+		}
+		table.Rows = append(table.Rows, fields)
+	}
+	return table
+}
+
+// ParseKeyValues builds a two-column table from "Key: value" lines
+func ParseKeyValues(text string) *Table {
+	table := &Table{Headers: []string{"Property", "Value"}}
+	for _, line := range strings.Split(text, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found || strings.TrimSpace(key) == "" {
+			continue
+		}
+		table.Rows = append(table.Rows, []string{strings.TrimSpace(key), strings.TrimSpace(value)})
+	}
+	if len(table.Rows) == 0 {
+		return nil
+	}
+	return table
+}
+
+// isUnderline reports whether a line only contains runs of dashes
+func isUnderline(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed != "" && strings.Trim(trimmed, "- ") == ""
+}
+
+// underlineSpans returns the [start, end) byte offsets of each dash run
+func underlineSpans(line string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		dash := i < len(line) && line[i] == '-'
+		if dash && start < 0 {
+			start = i
+		} else if !dash && start >= 0 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	return spans
+}
+
+// cutColumns slices a line into the given column spans. PowerShell right-aligns
+// numeric columns, so cells may spill past their dashes into the gap between
+// columns; each boundary is placed on the last blank inside that gap.
+func cutColumns(line string, spans [][2]int) []string {
+	cells := make([]string, len(spans))
+	start := 0
+	for i := range spans {
+		end := len(line)
+		if i+1 < len(spans) {
+			end = columnBoundary(line, spans[i][1], spans[i+1][0])
+		}
+		if start < end {
+			cells[i] = strings.TrimSpace(line[start:end])
+		}
+		if end > start {
+			start = end
+		}
+	}
+	return cells
+}
+
+// columnBoundary finds where one column ends and the next begins in line, given
+// the end of the first column's dashes and the start of the next column's dashes
+func columnBoundary(line string, gapStart, gapEnd int) int {
+	if gapStart >= len(line) {
+		return len(line)
+	}
+	if gapEnd > len(line) {
+		gapEnd = len(line)
+	}
+	for i := gapEnd; i >= gapStart; i-- {
+		if i < len(line) && line[i] == ' ' {
+			return i
+		}
+	}
+	// The cell overflows the gap: fall back to the nearest blank before it
+	for i := gapStart - 1; i > 0; i-- {
+		if line[i] == ' ' {
+			return i
+		}
+	}
+	return gapEnd
+}
+
+// RenderTable aligns the table's columns to fit within width, shrinking the
+// widest columns and truncating their cells with an ellipsis when needed
+func RenderTable(table *Table, width int) string {
+	if table == nil || len(table.Headers) == 0 {
+		return ""
+	}
+
+	widths := make([]int, len(table.Headers))
+	measure := func(cells []string) {
+		for i := 0; i < len(cells) && i < len(widths); i++ {
+			if w := utf8.RuneCountInString(cells[i]); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	measure(table.Headers)
+	for _, row := range table.Rows {
+		measure(row)
+	}
+
+	// Shrink the widest column until the table fits
+	available := width - utf8.RuneCountInString(tableIndent) - 2*(len(widths)-1)
+	for total(widths) > available {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minColumnWidth {
+			break
+		}
+		widths[widest]--
+	}
+
+	var sb strings.Builder
+	writeRow := func(cells []string) {
+		sb.WriteString(tableIndent)
+		for i, w := range widths {
+			cell := ""
+			if i < len(cells) {
+				cell = truncateCell(cells[i], w)
+			}
+			sb.WriteString(cell)
+			if i < len(widths)-1 {
+				sb.WriteString(strings.Repeat(" ", w-utf8.RuneCountInString(cell)+2))
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	writeRow(table.Headers)
+	underline := make([]string, len(widths))
+	for i, w := range widths {
+		underline[i] = strings.Repeat("─", w)
+	}
+	writeRow(underline)
+	for _, row := range table.Rows {
+		writeRow(row)
+	}
+	return sb.String()
+}
+
+// total sums column widths
+func total(widths []int) int {
+	sum := 0
+	for _, w := range widths {
+		sum += w
+	}
+	return sum
+}
+
+// truncateCell shortens a cell to width runes, marking the cut with an ellipsis
+func truncateCell(cell string, width int) string {
+	if utf8.RuneCountInString(cell) <= width {
+		return cell
+	}
+	if width <= 1 {
+		return "…"
+	}
+	runes := []rune(cell)
+	return string(runes[:width-1]) + "…"
+}
+
+// ShowTable prints a table under the current action, limited to maxRows rows
+func (id *InteractiveDisplay) ShowTable(table *Table, maxRows int) {
+	if table == nil {
+		return
+	}
+
+	shown := table
+	if maxRows > 0 && len(table.Rows) > maxRows {
+		shown = &Table{Headers: table.Headers, Rows: table.Rows[:maxRows]}
+	}
+
+	lines := strings.Split(strings.TrimRight(RenderTable(shown, TerminalWidth()), "\n"), "\n")
+	for i, line := range lines {
+		if i == 0 {
+			Highlight.Println(line)
+		} else {
+			Muted.Println(line)
+		}
+	}
+	if shown != table {
+		Muted.Printf("%s... (%d more rows)\n", tableIndent, len(table.Rows)-maxRows)
+	}
+}
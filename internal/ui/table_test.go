@@ -0,0 +1,104 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		expectedHead []string
+		expectedRows [][]string
+	}{
+		{
+			name: "ps aux keeps command with spaces",
+			text: "USER PID %CPU COMMAND\n" +
+				"root 1 0.0 /sbin/init splash\n" +
+				"bob 42 1.5 vim notes.txt\n",
+			expectedHead: []string{"USER", "PID", "%CPU", "COMMAND"},
+			expectedRows: [][]string{
+				{"root", "1", "0.0", "/sbin/init splash"},
+				{"bob", "42", "1.5", "vim notes.txt"},
+			},
+		},
+		{
+			name: "format-table with empty cells",
+			text: "\r\n  Id ProcessName   CPU WorkingSet\r\n" +
+				"  -- -----------   --- ----------\r\n" +
+				"   4 System            1234567\r\n" +
+				"1200 explorer     12.5   9876543\r\n",
+			expectedHead: []string{"Id", "ProcessName", "CPU", "WorkingSet"},
+			expectedRows: [][]string{
+				{"4", "System", "", "1234567"},
+				{"1200", "explorer", "12.5", "9876543"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := ParseColumns(tt.text)
+			if table == nil {
+				t.Fatalf("Expected a table, got nil")
+			}
+			if strings.Join(table.Headers, "|") != strings.Join(tt.expectedHead, "|") {
+				t.Errorf("Expected headers %q, got %q", tt.expectedHead, table.Headers)
+			}
+			if len(table.Rows) != len(tt.expectedRows) {
+				t.Fatalf("Expected %d rows, got %d", len(tt.expectedRows), len(table.Rows))
+			}
+			for i, row := range table.Rows {
+				if strings.Join(row, "|") != strings.Join(tt.expectedRows[i], "|") {
+					t.Errorf("Expected row %d to be %q, got %q", i, tt.expectedRows[i], row)
+				}
+			}
+		})
+	}
+}
+
+func TestParseColumnsNotTabular(t *testing.T) {
+	if table := ParseColumns("just one line"); table != nil {
+		t.Errorf("Expected nil for single line, got %+v", table)
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	table := ParseKeyValues("Name: a.txt\nSize: 12 bytes\nModTime: 2024-01-01T10:00:00Z\n")
+	if table == nil || len(table.Rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %+v", table)
+	}
+	if table.Rows[2][0] != "ModTime" || table.Rows[2][1] != "2024-01-01T10:00:00Z" {
+		t.Errorf("Expected value to keep its colons, got %q", table.Rows[2])
+	}
+}
+
+func TestRenderTable(t *testing.T) {
+	table := &Table{
+		Headers: []string{"PID", "COMMAND"},
+		Rows: [][]string{
+			{"1", "init"},
+			{"42", strings.Repeat("x", 200)},
+		},
+	}
+
+	t.Run("aligns columns", func(t *testing.T) {
+		lines := strings.Split(RenderTable(table, 1000), "\n")
+		if !strings.HasPrefix(lines[2], tableIndent+"1    init") {
+			t.Errorf("Expected aligned row, got %q", lines[2])
+		}
+	})
+
+	t.Run("fits terminal width", func(t *testing.T) {
+		for _, line := range strings.Split(strings.TrimRight(RenderTable(table, 40), "\n"), "\n") {
+			if width := utf8.RuneCountInString(line); width > 40 {
+				t.Errorf("Expected line within 40 columns, got %d: %q", width, line)
+			}
+		}
+		if !strings.Contains(RenderTable(table, 40), "…") {
+			t.Errorf("Expected truncated cell to end with an ellipsis")
+		}
+	})
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package ui
+
+import "golang.org/x/sys/unix"
+
+// consoleWidth returns the width of the terminal on stdout (0 if unknown)
+func consoleWidth() int {
+	ws, err := unix.IoctlGetWinsize(int(terminalStdout().Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(ws.Col)
+}
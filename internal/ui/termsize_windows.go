@@ -0,0 +1,32 @@
+package ui
+
+import "unsafe"
+
+var procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+
+// STD_OUTPUT_HANDLE is -11 as uintptr
+const STD_OUTPUT_HANDLE = ^uintptr(11 - 1)
+
+// consoleScreenBufferInfo mirrors CONSOLE_SCREEN_BUFFER_INFO
+type consoleScreenBufferInfo struct {
+	Size              [2]int16
+	CursorPosition    [2]int16
+	Attributes        uint16
+	Window            [4]int16 // Left, Top, Right, Bottom
+	MaximumWindowSize [2]int16
+}
+
+// consoleWidth returns the visible width of the console window (0 if unknown)
+func consoleWidth() int {
+	handle, _, _ := procGetStdHandle.Call(STD_OUTPUT_HANDLE)
+	if handle == 0 {
+		return 0
+	}
+
+	var info consoleScreenBufferInfo
+	ret, _, _ := procGetConsoleScreenBufferInfo.Call(handle, uintptr(unsafe.Pointer(&info)))
+	if ret == 0 {
+		return 0
+	}
+	return int(info.Window[2]-info.Window[0]) + 1
+}